@@ -0,0 +1,179 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package confirmations
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// BlockCache is a bounded cache of block headers, that is shared by all the confirmation managers using the
+// same connector. So each block is downloaded once, regardless of how many event streams are running.
+type BlockCache interface {
+	GetBlockByHash(ctx context.Context, blockHash string) (*apitypes.BlockInfo, error)
+	GetBlockByNumber(ctx context.Context, blockNumber uint64, expectedParentHash string) (*apitypes.BlockInfo, error)
+	Stats() *apitypes.BlockCacheStats
+}
+
+type blockCache struct {
+	connector ffcapi.API
+	mux       sync.Mutex
+	capacity  int
+	lru       *list.List               // most recently used first
+	byHash    map[string]*list.Element // every cached block
+	byNumber  map[uint64]string        // the hash of the block most recently seen at each height
+	hits      uint64
+	misses    uint64
+}
+
+func NewBlockCache(connector ffcapi.API, capacity int) BlockCache {
+	return &blockCache{
+		connector: connector,
+		capacity:  capacity,
+		lru:       list.New(),
+		byHash:    make(map[string]*list.Element),
+		byNumber:  make(map[uint64]string),
+	}
+}
+
+func (bc *blockCache) GetBlockByHash(ctx context.Context, blockHash string) (*apitypes.BlockInfo, error) {
+	bc.mux.Lock()
+	if el, ok := bc.byHash[blockHash]; ok {
+		bc.hits++
+		bc.lru.MoveToFront(el)
+		bc.mux.Unlock()
+		return el.Value.(*apitypes.BlockInfo), nil
+	}
+	bc.misses++
+	bc.mux.Unlock()
+
+	block, err := fetchBlockByHash(ctx, bc.connector, blockHash)
+	if err != nil || block == nil {
+		return nil, err
+	}
+	bc.add(ctx, block)
+	return block, nil
+}
+
+// GetBlockByNumber returns the block most recently seen at the height. If its parent does not match the
+// expected parent hash, then the chain has been re-organized since it was cached, so it is downloaded again.
+// Without an expected parent hash there is nothing to check the cached block against, so it is always downloaded
+// (and any block cached at that height with a different hash is replaced).
+func (bc *blockCache) GetBlockByNumber(ctx context.Context, blockNumber uint64, expectedParentHash string) (*apitypes.BlockInfo, error) {
+	bc.mux.Lock()
+	if el, ok := bc.byHash[bc.byNumber[blockNumber]]; ok && expectedParentHash != "" {
+		block := el.Value.(*apitypes.BlockInfo)
+		if block.ParentHash == expectedParentHash {
+			bc.hits++
+			bc.lru.MoveToFront(el)
+			bc.mux.Unlock()
+			return block, nil
+		}
+	}
+	bc.misses++
+	bc.mux.Unlock()
+
+	block, err := fetchBlockByNumber(ctx, bc.connector, blockNumber, expectedParentHash)
+	if err != nil || block == nil {
+		// We do not cache that the block is beyond the head of the chain
+		return nil, err
+	}
+	bc.add(ctx, block)
+	return block, nil
+}
+
+func (bc *blockCache) add(ctx context.Context, block *apitypes.BlockInfo) {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+
+	blockNumber := block.BlockNumber.Uint64()
+	if existing, ok := bc.byNumber[blockNumber]; ok && existing != block.BlockHash {
+		// A different block at this height means the chain has been re-organized, so none of the
+		// blocks we have cached from this height upwards are known to be in the canonical chain.
+		log.L(ctx).Infof("Block cache detected re-org at block %d: %s replaced by %s", blockNumber, existing, block.BlockHash)
+		for n := range bc.byNumber {
+			if n >= blockNumber {
+				delete(bc.byNumber, n)
+			}
+		}
+	}
+	bc.byNumber[blockNumber] = block.BlockHash
+
+	if el, ok := bc.byHash[block.BlockHash]; ok {
+		el.Value = block
+		bc.lru.MoveToFront(el)
+		return
+	}
+	bc.byHash[block.BlockHash] = bc.lru.PushFront(block)
+	for bc.lru.Len() > bc.capacity {
+		oldest := bc.lru.Remove(bc.lru.Back()).(*apitypes.BlockInfo)
+		delete(bc.byHash, oldest.BlockHash)
+		oldestNumber := oldest.BlockNumber.Uint64()
+		if bc.byNumber[oldestNumber] == oldest.BlockHash {
+			delete(bc.byNumber, oldestNumber)
+		}
+	}
+}
+
+func (bc *blockCache) Stats() *apitypes.BlockCacheStats {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	return &apitypes.BlockCacheStats{
+		Size:     bc.lru.Len(),
+		Capacity: bc.capacity,
+		Hits:     bc.hits,
+		Misses:   bc.misses,
+	}
+}
+
+func fetchBlockByHash(ctx context.Context, connector ffcapi.API, blockHash string) (*apitypes.BlockInfo, error) {
+	res, reason, err := connector.BlockInfoByHash(ctx, &ffcapi.BlockInfoByHashRequest{
+		BlockHash: blockHash,
+	})
+	if err != nil {
+		if reason == ffcapi.ErrorReasonNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	blockInfo := transformBlockInfo(&res.BlockInfo)
+	log.L(ctx).Debugf("Downloaded block header by hash: %d / %s parent=%s", blockInfo.BlockNumber, blockInfo.BlockHash, blockInfo.ParentHash)
+
+	return blockInfo, nil
+}
+
+func fetchBlockByNumber(ctx context.Context, connector ffcapi.API, blockNumber uint64, expectedParentHash string) (*apitypes.BlockInfo, error) {
+	res, reason, err := connector.BlockInfoByNumber(ctx, &ffcapi.BlockInfoByNumberRequest{
+		BlockNumber:        fftypes.NewFFBigInt(int64(blockNumber)),
+		ExpectedParentHash: expectedParentHash,
+	})
+	if err != nil {
+		if reason == ffcapi.ErrorReasonNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	blockInfo := transformBlockInfo(&res.BlockInfo)
+	log.L(ctx).Debugf("Downloaded block header by number: %d / %s parent=%s", blockInfo.BlockNumber, blockInfo.BlockHash, blockInfo.ParentHash)
+	return blockInfo, nil
+}
@@ -0,0 +1,240 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package confirmations
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func testBlockInfo(blockNumber int64, blockHash, parentHash string) ffcapi.BlockInfo {
+	return ffcapi.BlockInfo{
+		BlockNumber: fftypes.NewFFBigInt(blockNumber),
+		BlockHash:   blockHash,
+		ParentHash:  parentHash,
+	}
+}
+
+func mockBlockByNumber(mca *ffcapimocks.API, blockNumber int64, blockHash, parentHash string) *mock.Call {
+	return mca.On("BlockInfoByNumber", mock.Anything, mock.MatchedBy(func(r *ffcapi.BlockInfoByNumberRequest) bool {
+		return r.BlockNumber.Int64() == blockNumber
+	})).Return(&ffcapi.BlockInfoByNumberResponse{
+		BlockInfo: testBlockInfo(blockNumber, blockHash, parentHash),
+	}, ffcapi.ErrorReason(""), nil)
+}
+
+func mockBlockByHash(mca *ffcapimocks.API, blockNumber int64, blockHash, parentHash string) *mock.Call {
+	return mca.On("BlockInfoByHash", mock.Anything, mock.MatchedBy(func(r *ffcapi.BlockInfoByHashRequest) bool {
+		return r.BlockHash == blockHash
+	})).Return(&ffcapi.BlockInfoByHashResponse{
+		BlockInfo: testBlockInfo(blockNumber, blockHash, parentHash),
+	}, ffcapi.ErrorReason(""), nil)
+}
+
+func TestBlockCacheHitsAndMisses(t *testing.T) {
+	mca := &ffcapimocks.API{}
+	bc := NewBlockCache(mca, 10)
+	ctx := context.Background()
+
+	mockBlockByNumber(mca, 1001, "0xb1", "0xb0").Once()
+
+	block, err := bc.GetBlockByNumber(ctx, 1001, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "0xb1", block.BlockHash)
+
+	block, err = bc.GetBlockByNumber(ctx, 1001, "0xb0")
+	assert.NoError(t, err)
+	assert.Equal(t, "0xb1", block.BlockHash)
+
+	block, err = bc.GetBlockByHash(ctx, "0xb1")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1001), block.BlockNumber.Uint64())
+
+	assert.Equal(t, &apitypes.BlockCacheStats{Size: 1, Capacity: 10, Hits: 2, Misses: 1}, bc.Stats())
+	mca.AssertExpectations(t)
+}
+
+func TestBlockCacheParentMismatch(t *testing.T) {
+	mca := &ffcapimocks.API{}
+	bc := NewBlockCache(mca, 10)
+	ctx := context.Background()
+
+	mockBlockByNumber(mca, 1001, "0xa1", "0xa0").Once()
+	mockBlockByNumber(mca, 1001, "0xb1", "0xb0").Once()
+
+	block, err := bc.GetBlockByNumber(ctx, 1001, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "0xa1", block.BlockHash)
+
+	// The caller has seen a different parent, so the cached block is not used
+	block, err = bc.GetBlockByNumber(ctx, 1001, "0xb0")
+	assert.NoError(t, err)
+	assert.Equal(t, "0xb1", block.BlockHash)
+
+	block, err = bc.GetBlockByNumber(ctx, 1001, "0xb0")
+	assert.NoError(t, err)
+	assert.Equal(t, "0xb1", block.BlockHash)
+
+	assert.Equal(t, &apitypes.BlockCacheStats{Size: 2, Capacity: 10, Hits: 1, Misses: 2}, bc.Stats())
+	mca.AssertExpectations(t)
+}
+
+func TestBlockCacheReorgInvalidatesHigherBlocks(t *testing.T) {
+	mca := &ffcapimocks.API{}
+	bc := NewBlockCache(mca, 10)
+	ctx := context.Background()
+
+	mockBlockByNumber(mca, 1001, "0xa1", "0xa0").Once()
+	mockBlockByNumber(mca, 1002, "0xa2", "0xa1").Once()
+	mockBlockByHash(mca, 1001, "0xb1", "0xa0").Once()
+	mockBlockByNumber(mca, 1002, "0xb2", "0xb1").Once()
+
+	_, err := bc.GetBlockByNumber(ctx, 1001, "")
+	assert.NoError(t, err)
+	_, err = bc.GetBlockByNumber(ctx, 1002, "")
+	assert.NoError(t, err)
+
+	// A new block at 1001 means the block we have cached at 1002 cannot be trusted
+	_, err = bc.GetBlockByHash(ctx, "0xb1")
+	assert.NoError(t, err)
+
+	block, err := bc.GetBlockByNumber(ctx, 1002, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "0xb2", block.BlockHash)
+
+	// The blocks from the old fork are still available by hash
+	block, err = bc.GetBlockByHash(ctx, "0xa2")
+	assert.NoError(t, err)
+	assert.Equal(t, "0xa1", block.ParentHash)
+
+	assert.Equal(t, &apitypes.BlockCacheStats{Size: 4, Capacity: 10, Hits: 1, Misses: 4}, bc.Stats())
+	mca.AssertExpectations(t)
+}
+
+func TestBlockCacheNoParentHashRefreshes(t *testing.T) {
+	mca := &ffcapimocks.API{}
+	bc := NewBlockCache(mca, 10)
+	ctx := context.Background()
+
+	mockBlockByNumber(mca, 1001, "0xa1", "0xa0").Once()
+	mockBlockByNumber(mca, 1002, "0xa2", "0xa1").Once()
+	mockBlockByNumber(mca, 1001, "0xb1", "0xa0").Once()
+
+	_, err := bc.GetBlockByNumber(ctx, 1001, "")
+	assert.NoError(t, err)
+	_, err = bc.GetBlockByNumber(ctx, 1002, "0xa1")
+	assert.NoError(t, err)
+
+	// The chain has been re-organized at 1001 since it was cached, so the cached block must not be returned
+	block, err := bc.GetBlockByNumber(ctx, 1001, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "0xb1", block.BlockHash)
+
+	// The block cached at 1002 was on the replaced fork, so it is not returned
+	mockBlockByNumber(mca, 1002, "0xb2", "0xb1").Once()
+	block, err = bc.GetBlockByNumber(ctx, 1002, "0xb1")
+	assert.NoError(t, err)
+	assert.Equal(t, "0xb2", block.BlockHash)
+
+	assert.Equal(t, &apitypes.BlockCacheStats{Size: 4, Capacity: 10, Hits: 0, Misses: 4}, bc.Stats())
+	mca.AssertExpectations(t)
+}
+
+func TestBlockCacheEviction(t *testing.T) {
+	mca := &ffcapimocks.API{}
+	bc := NewBlockCache(mca, 2)
+	ctx := context.Background()
+
+	mockBlockByNumber(mca, 1001, "0xb1", "0xb0").Twice()
+	mockBlockByNumber(mca, 1002, "0xb2", "0xb1").Once()
+	mockBlockByNumber(mca, 1003, "0xb3", "0xb2").Once()
+
+	for i := int64(1001); i <= 1003; i++ {
+		block, err := bc.GetBlockByNumber(ctx, uint64(i), "")
+		assert.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("0xb%d", i-1000), block.BlockHash)
+	}
+
+	// The least recently used block has been evicted
+	_, err := bc.GetBlockByNumber(ctx, 1001, "")
+	assert.NoError(t, err)
+
+	assert.Equal(t, &apitypes.BlockCacheStats{Size: 2, Capacity: 2, Hits: 0, Misses: 4}, bc.Stats())
+	mca.AssertExpectations(t)
+}
+
+func TestBlockCacheNotFoundNotCached(t *testing.T) {
+	mca := &ffcapimocks.API{}
+	bc := NewBlockCache(mca, 10)
+	ctx := context.Background()
+
+	mca.On("BlockInfoByNumber", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonNotFound, fmt.Errorf("not found")).Twice()
+	mca.On("BlockInfoByHash", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonNotFound, fmt.Errorf("not found")).Once()
+
+	for i := 0; i < 2; i++ {
+		block, err := bc.GetBlockByNumber(ctx, 1001, "")
+		assert.NoError(t, err)
+		assert.Nil(t, block)
+	}
+	block, err := bc.GetBlockByHash(ctx, "0xb1")
+	assert.NoError(t, err)
+	assert.Nil(t, block)
+
+	assert.Equal(t, &apitypes.BlockCacheStats{Size: 0, Capacity: 10, Hits: 0, Misses: 3}, bc.Stats())
+	mca.AssertExpectations(t)
+}
+
+func TestBlockCacheFetchFail(t *testing.T) {
+	mca := &ffcapimocks.API{}
+	bc := NewBlockCache(mca, 10)
+	ctx := context.Background()
+
+	mca.On("BlockInfoByNumber", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+	mca.On("BlockInfoByHash", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+
+	_, err := bc.GetBlockByNumber(ctx, 1001, "")
+	assert.Regexp(t, "pop", err)
+	_, err = bc.GetBlockByHash(ctx, "0xb1")
+	assert.Regexp(t, "pop", err)
+
+	mca.AssertExpectations(t)
+}
+
+func TestBlockCacheSharedByConfirmationManagers(t *testing.T) {
+	mca := &ffcapimocks.API{}
+	bc := NewBlockCache(mca, 10)
+
+	mockBlockByHash(mca, 1001, "0xb1", "0xb0").Once()
+
+	for _, desc := range []string{"ut1", "ut2"} {
+		bcm := NewBlockConfirmationManager(context.Background(), mca, desc, bc, nil, nil).(*blockConfirmationManager)
+		block, err := bcm.getBlockByHash("0xb1")
+		assert.NoError(t, err)
+		assert.Equal(t, "0xb1", block.BlockHash)
+	}
+
+	assert.Equal(t, &apitypes.BlockCacheStats{Size: 1, Capacity: 10, Hits: 1, Misses: 1}, bc.Stats())
+	mca.AssertExpectations(t)
+}
@@ -26,8 +26,10 @@ import (
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
 	"github.com/hyperledger/firefly-common/pkg/i18n"
 	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/persistence"
 	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
 	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
 )
 
@@ -60,14 +62,24 @@ type Notification struct {
 }
 
 type EventInfo struct {
-	ID        *ffcapi.EventID
-	Confirmed func(ctx context.Context, confirmations []BlockInfo)
+	ID            *ffcapi.EventID
+	Confirmations *int // optional - overrides the number of confirmations required for this event
+	Confirmed     func(ctx context.Context, confirmations []apitypes.BlockInfo)
 }
 
 type TransactionInfo struct {
 	TransactionHash string
+	Confirmations   *int // optional - overrides the number of confirmations required for this transaction
 	Receipt         func(ctx context.Context, receipt *ffcapi.TransactionReceiptResponse)
-	Confirmed       func(ctx context.Context, confirmations []BlockInfo)
+	Progress        func(ctx context.Context, progress *ConfirmationProgress) // optional - called each time the number of confirmations of the mined transaction changes
+	Confirmed       func(ctx context.Context, confirmations []apitypes.BlockInfo)
+}
+
+// ConfirmationProgress is the number of confirmations a mined transaction has, out of the number required
+type ConfirmationProgress struct {
+	Count       int                 `json:"count"`
+	Required    int                 `json:"required"`
+	LatestBlock *apitypes.BlockInfo `json:"latestBlock,omitempty"` // the most recent block confirming the transaction, if any
 }
 
 type RemovedListenerInfo struct {
@@ -75,13 +87,6 @@ type RemovedListenerInfo struct {
 	Completed  chan struct{}
 }
 
-type BlockInfo struct {
-	BlockNumber       fftypes.FFuint64 `json:"blockNumber"`
-	BlockHash         string           `json:"blockHash"`
-	ParentHash        string           `json:"parentHash"`
-	TransactionHashes []string         `json:"transactionHashes,omitempty"`
-}
-
 type blockConfirmationManager struct {
 	baseContext           context.Context
 	ctx                   context.Context
@@ -97,12 +102,34 @@ type blockConfirmationManager struct {
 	pendingMux            sync.Mutex
 	staleReceipts         map[string]bool
 	done                  chan struct{}
+	finality              ffcapi.FinalityAPI         // set in finality mode, when supported by the connector
+	blockCache            BlockCache                 // shared between managers, or nil to always query the connector
+	reorgReporter         ReorgReporter              // notified of the reorgs detected under pending items, if set
+	reorgs                map[uint64]*apitypes.Reorg // detected in this cycle, keyed on the lowest block number that changed
+
+	persistence    StatePersistence // nil if the pending items are not persisted
+	managerID      string
+	restoreTimeout time.Duration
+	restored       map[string]*persistence.ConfirmationState // persisted before the last restart, and not yet notified again
+	restoredExpiry time.Time
+	persisted      map[string]bool // the keys that are currently persisted
+	dirty          map[string]bool // the keys that need to be written or deleted at the end of the cycle
 }
 
-func NewBlockConfirmationManager(baseContext context.Context, connector ffcapi.API, desc string) Manager {
+const (
+	// ModeBlocks releases events and receipts once the configured number of blocks have been mined on top of them
+	ModeBlocks = "blocks"
+	// ModeFinalized releases events and receipts once their block is at or below the finalized height reported by the connector
+	ModeFinalized = "finalized"
+)
+
+func NewBlockConfirmationManager(baseContext context.Context, connector ffcapi.API, desc string, blockCache BlockCache, reorgReporter ReorgReporter, statePersistence StatePersistence) Manager {
 	bcm := &blockConfirmationManager{
 		baseContext:           baseContext,
 		connector:             connector,
+		blockCache:            blockCache,
+		reorgReporter:         reorgReporter,
+		reorgs:                make(map[uint64]*apitypes.Reorg),
 		blockListenerStale:    true,
 		requiredConfirmations: config.GetInt(tmconfig.ConfirmationsRequired),
 		staleReceiptTimeout:   config.GetDuration(tmconfig.ConfirmationsStaleReceiptTimeout),
@@ -110,10 +137,29 @@ func NewBlockConfirmationManager(baseContext context.Context, connector ffcapi.A
 		pending:               make(map[string]*pendingItem),
 		staleReceipts:         make(map[string]bool),
 		newBlockHashes:        make(chan *ffcapi.BlockHashEvent, config.GetInt(tmconfig.ConfirmationsBlockQueueLength)),
+
+		persistence:    statePersistence,
+		managerID:      desc,
+		restoreTimeout: config.GetDuration(tmconfig.ConfirmationsRestoreTimeout),
+		restored:       make(map[string]*persistence.ConfirmationState),
+		persisted:      make(map[string]bool),
+		dirty:          make(map[string]bool),
 	}
 	bcm.ctx, bcm.cancelFunc = context.WithCancel(baseContext)
 	// add a log context for this specific confirmation manager (as there are many within the )
 	bcm.ctx = log.WithLogField(bcm.ctx, "role", fmt.Sprintf("confirmations_%s", desc))
+	switch mode := config.GetString(tmconfig.ConfirmationsMode); mode {
+	case ModeBlocks:
+	case ModeFinalized:
+		// Chains without a finality API keep counting blocks
+		if finality, ok := connector.(ffcapi.FinalityAPI); ok {
+			bcm.finality = finality
+		} else {
+			log.L(bcm.ctx).Warnf("Connector does not report finalized blocks - confirmations will be counted in blocks")
+		}
+	default:
+		log.L(bcm.ctx).Warnf("Unknown confirmations mode '%s' - confirmations will be counted in blocks", mode)
+	}
 	return bcm
 }
 
@@ -129,10 +175,14 @@ const (
 type pendingItem struct {
 	pType             pendingType
 	added             time.Time
-	confirmations     []*BlockInfo
+	confirmations     []*apitypes.BlockInfo
 	lastReceiptCheck  time.Time
+	receipt           *ffcapi.TransactionReceiptResponse // transactions only - kept so it can be persisted
 	receiptCallback   func(ctx context.Context, receipt *ffcapi.TransactionReceiptResponse)
-	confirmedCallback func(ctx context.Context, confirmations []BlockInfo)
+	progressCallback  func(ctx context.Context, progress *ConfirmationProgress)
+	confirmedCallback func(ctx context.Context, confirmations []apitypes.BlockInfo)
+	progressReported  int  // the count of confirmations last reported to the progress callback, or -1
+	requiredOverride  *int // overrides the configured number of confirmations required, if set
 	transactionHash   string
 	blockHash         string        // can be notified of changes to this for receipts
 	blockNumber       uint64        // known at creation time for event logs
@@ -159,10 +209,10 @@ func (pi *pendingItem) getKey() string {
 	}
 }
 
-func (pi *pendingItem) copyConfirmations() []BlockInfo {
-	copy := make([]BlockInfo, len(pi.confirmations))
+func (pi *pendingItem) copyConfirmations() []apitypes.BlockInfo {
+	copy := make([]apitypes.BlockInfo, len(pi.confirmations))
 	for i, c := range pi.confirmations {
-		copy[i] = BlockInfo{
+		copy[i] = apitypes.BlockInfo{
 			BlockNumber: c.BlockNumber,
 			BlockHash:   c.BlockHash,
 			ParentHash:  c.ParentHash,
@@ -182,6 +232,7 @@ func (n *Notification) eventPendingItem() *pendingItem {
 		transactionIndex:  n.Event.ID.TransactionIndex.Uint64(),
 		logIndex:          n.Event.ID.LogIndex.Uint64(),
 		confirmedCallback: n.Event.Confirmed,
+		requiredOverride:  n.Event.Confirmations,
 	}
 }
 
@@ -191,8 +242,19 @@ func (n *Notification) transactionPendingItem() *pendingItem {
 		lastReceiptCheck:  time.Now(),
 		transactionHash:   n.Transaction.TransactionHash,
 		receiptCallback:   n.Transaction.Receipt,
+		progressCallback:  n.Transaction.Progress,
 		confirmedCallback: n.Transaction.Confirmed,
+		progressReported:  -1,
+		requiredOverride:  n.Transaction.Confirmations,
+	}
+}
+
+// requiredConfirmationsFor returns the number of confirmations needed for the item to be dispatched
+func (bcm *blockConfirmationManager) requiredConfirmationsFor(pending *pendingItem) int {
+	if pending.requiredOverride != nil {
+		return *pending.requiredOverride
 	}
+	return bcm.requiredConfirmations
 }
 
 type pendingItems []*pendingItem
@@ -211,8 +273,10 @@ func (pi pendingItems) Less(i, j int) bool {
 
 type blockState struct {
 	bcm       *blockConfirmationManager
-	blocks    map[uint64]*BlockInfo
+	blocks    map[uint64]*apitypes.BlockInfo
 	lowestNil uint64
+	finalized *uint64                        // queried from the connector at most once per cycle, in finality mode
+	canonical map[uint64]*apitypes.BlockInfo // finalized blocks downloaded from the connector in this cycle, in finality mode
 }
 
 func (bcm *blockConfirmationManager) Start() {
@@ -270,40 +334,22 @@ func (bcm *blockConfirmationManager) CheckInFlight(listenerID *fftypes.UUID) boo
 	return false
 }
 
-func (bcm *blockConfirmationManager) getBlockByHash(blockHash string) (*BlockInfo, error) {
-	res, reason, err := bcm.connector.BlockInfoByHash(bcm.ctx, &ffcapi.BlockInfoByHashRequest{
-		BlockHash: blockHash,
-	})
-	if err != nil {
-		if reason == ffcapi.ErrorReasonNotFound {
-			return nil, nil
-		}
-		return nil, err
+func (bcm *blockConfirmationManager) getBlockByHash(blockHash string) (*apitypes.BlockInfo, error) {
+	if bcm.blockCache != nil {
+		return bcm.blockCache.GetBlockByHash(bcm.ctx, blockHash)
 	}
-	blockInfo := transformBlockInfo(&res.BlockInfo)
-	log.L(bcm.ctx).Debugf("Downloaded block header by hash: %d / %s parent=%s", blockInfo.BlockNumber, blockInfo.BlockHash, blockInfo.ParentHash)
-
-	return blockInfo, nil
+	return fetchBlockByHash(bcm.ctx, bcm.connector, blockHash)
 }
 
-func (bcm *blockConfirmationManager) getBlockByNumber(blockNumber uint64, expectedParentHash string) (*BlockInfo, error) {
-	res, reason, err := bcm.connector.BlockInfoByNumber(bcm.ctx, &ffcapi.BlockInfoByNumberRequest{
-		BlockNumber:        fftypes.NewFFBigInt(int64(blockNumber)),
-		ExpectedParentHash: expectedParentHash,
-	})
-	if err != nil {
-		if reason == ffcapi.ErrorReasonNotFound {
-			return nil, nil
-		}
-		return nil, err
+func (bcm *blockConfirmationManager) getBlockByNumber(blockNumber uint64, expectedParentHash string) (*apitypes.BlockInfo, error) {
+	if bcm.blockCache != nil {
+		return bcm.blockCache.GetBlockByNumber(bcm.ctx, blockNumber, expectedParentHash)
 	}
-	blockInfo := transformBlockInfo(&res.BlockInfo)
-	log.L(bcm.ctx).Debugf("Downloaded block header by number: %d / %s parent=%s", blockInfo.BlockNumber, blockInfo.BlockHash, blockInfo.ParentHash)
-	return blockInfo, nil
+	return fetchBlockByNumber(bcm.ctx, bcm.connector, blockNumber, expectedParentHash)
 }
 
-func transformBlockInfo(res *ffcapi.BlockInfo) *BlockInfo {
-	return &BlockInfo{
+func transformBlockInfo(res *ffcapi.BlockInfo) *apitypes.BlockInfo {
+	return &apitypes.BlockInfo{
 		BlockNumber:       fftypes.FFuint64(res.BlockNumber.Uint64()),
 		BlockHash:         res.BlockHash,
 		ParentHash:        res.ParentHash,
@@ -313,6 +359,7 @@ func transformBlockInfo(res *ffcapi.BlockInfo) *BlockInfo {
 
 func (bcm *blockConfirmationManager) confirmationsListener() {
 	defer close(bcm.done)
+	bcm.restoreState()
 	notifications := make([]*Notification, 0)
 	blockHashes := make([]string, 0)
 	for {
@@ -340,13 +387,14 @@ func (bcm *blockConfirmationManager) confirmationsListener() {
 		// otherwise we could potentially deliver things out of order.
 		blocks := bcm.newBlockState()
 
-		if bcm.blockListenerStale {
+		// In finality mode there is no need, as we walk the chain at the end of every cycle
+		if bcm.blockListenerStale && bcm.finality == nil {
 			if err := bcm.walkChain(blocks); err != nil {
 				log.L(bcm.ctx).Errorf("Failed to create walk chain after restoring blockListener: %s", err)
 				continue
 			}
-			bcm.blockListenerStale = false
 		}
+		bcm.blockListenerStale = false
 
 		// Process each new block
 		bcm.processBlockHashes(blockHashes)
@@ -373,6 +421,22 @@ func (bcm *blockConfirmationManager) confirmationsListener() {
 			}
 		}
 
+		// In finality mode, we check each cycle for items that have reached the finalized height.
+		// This is the only place they are released, so that all the items that have become final - whether they
+		// were notified in this cycle or earlier - are released together in the order on the chain.
+		if bcm.finality != nil {
+			if err := bcm.walkChain(blocks); err != nil {
+				log.L(bcm.ctx).Errorf("Failed to check finality of pending items: %s", err)
+			}
+		}
+
+		// Write any changes to the pending items, so they can be restored after a restart
+		bcm.expireRestoredState()
+		bcm.persistState()
+
+		// Report any reorgs found under the pending items in this cycle
+		bcm.reportReorgs()
+
 	}
 
 }
@@ -395,13 +459,21 @@ func (bcm *blockConfirmationManager) processNotifications(notifications []*Notif
 		case NewEventLog:
 			newItem := n.eventPendingItem()
 			bcm.addOrReplaceItem(newItem)
-			if err := bcm.walkChainForItem(newItem, blocks); err != nil {
+			restored, err := bcm.restoreItem(newItem, blocks)
+			if err == nil && !restored {
+				err = bcm.walkChainForItem(newItem, blocks)
+			}
+			if err != nil {
 				return err
 			}
 		case NewTransaction:
 			newItem := n.transactionPendingItem()
 			bcm.addOrReplaceItem(newItem)
-			bcm.staleReceipts[newItem.getKey()] = true
+			restored, err := bcm.restoreItem(newItem, blocks)
+			if err != nil || !restored {
+				// Fall back to querying the receipt
+				bcm.staleReceipts[newItem.getKey()] = true
+			}
 		case RemovedEventLog:
 			bcm.removeItem(n.eventPendingItem().getKey(), true)
 		case RemovedTransaction:
@@ -429,15 +501,28 @@ func (bcm *blockConfirmationManager) checkReceipt(pending *pendingItem, blocks *
 			return
 		}
 	} else {
+		if pending.blockHash != "" && pending.blockHash != res.BlockHash {
+			// The transaction has moved to a different block, so the confirmations of the old block no longer count
+			newHashes := []string{}
+			if res.BlockNumber.Uint64() == pending.blockNumber {
+				newHashes = append(newHashes, res.BlockHash)
+			}
+			bcm.recordReorg(pending, pending.blockNumber, append([]string{pending.blockHash}, hashesOf(pending.confirmations)...), newHashes)
+			pending.confirmations = pending.confirmations[:0]
+		}
+		if pending.receipt == nil || pending.blockHash != res.BlockHash {
+			bcm.markDirty(pending.getKey())
+		}
 		pending.blockNumber = res.BlockNumber.Uint64()
 		pending.blockHash = res.BlockHash
+		pending.receipt = res
 		log.L(bcm.ctx).Infof("Receipt for transaction %s downloaded. BlockNumber=%d BlockHash=%s", pending.transactionHash, pending.blockNumber, pending.blockHash)
 		// Notify of the receipt
 		if pending.receiptCallback != nil {
 			pending.receiptCallback(bcm.ctx, res)
 		}
 
-		if bcm.requiredConfirmations == 0 {
+		if bcm.requiredConfirmationsFor(pending) == 0 {
 			bcm.dispatchConfirmed(pending)
 		} else {
 			// Need to walk the chain for this new receipt
@@ -458,6 +543,13 @@ func (bcm *blockConfirmationManager) listenerRemoved(notification *Notification)
 	for pendingKey, pending := range bcm.pending {
 		if notification.RemovedListener.ListenerID.Equals(pending.listenerID) {
 			delete(bcm.pending, pendingKey)
+			bcm.markDirty(pendingKey)
+		}
+	}
+	for pendingKey, state := range bcm.restored {
+		if notification.RemovedListener.ListenerID.Equals(state.ListenerID) {
+			delete(bcm.restored, pendingKey)
+			bcm.markDirty(pendingKey)
 		}
 	}
 	close(notification.RemovedListener.Completed)
@@ -468,7 +560,7 @@ func (bcm *blockConfirmationManager) addOrReplaceItem(pending *pendingItem) {
 	bcm.pendingMux.Lock()
 	defer bcm.pendingMux.Unlock()
 	pending.added = time.Now()
-	pending.confirmations = make([]*BlockInfo, 0, bcm.requiredConfirmations)
+	pending.confirmations = make([]*apitypes.BlockInfo, 0, bcm.requiredConfirmationsFor(pending))
 	pendingKey := pending.getKey()
 	bcm.pending[pendingKey] = pending
 	log.L(bcm.ctx).Infof("Added pending item %s", pendingKey)
@@ -481,6 +573,7 @@ func (bcm *blockConfirmationManager) removeItem(pendingKey string, stale bool) {
 	log.L(bcm.ctx).Debugf("Removing pending item %s (stale=%t)", pendingKey, stale)
 	delete(bcm.pending, pendingKey)
 	delete(bcm.staleReceipts, pendingKey)
+	bcm.markDirty(pendingKey)
 }
 
 func (bcm *blockConfirmationManager) processBlockHashes(blockHashes []string) {
@@ -506,7 +599,7 @@ func (bcm *blockConfirmationManager) processBlockHashes(blockHashes []string) {
 	}
 }
 
-func (bcm *blockConfirmationManager) processBlock(block *BlockInfo) {
+func (bcm *blockConfirmationManager) processBlock(block *apitypes.BlockInfo) {
 
 	// For any transactions in the block that are known to us, we need to mark them
 	// stale to go query the receipt
@@ -524,6 +617,11 @@ func (bcm *blockConfirmationManager) processBlock(block *BlockInfo) {
 	}
 	bcm.pendingMux.Unlock()
 
+	if bcm.finality != nil {
+		// Items are released based on the finalized height reported by the connector, rather than counting blocks
+		return
+	}
+
 	// Go through all the events, adding in the confirmations, and popping any out
 	// that have reached their threshold. Then drop the log before logging/processing them.
 	blockNumber := block.BlockNumber.Uint64()
@@ -537,6 +635,14 @@ func (bcm *blockConfirmationManager) processBlock(block *BlockInfo) {
 			for i := 0; i < (len(pending.confirmations) + 1); i++ {
 				l.Tracef("Comparing block number=%d parent=%s to %d / %s for %s", blockNumber, block.ParentHash, expectedBlockNumber, expectedParentHash, pendingKey)
 				if block.ParentHash == expectedParentHash && blockNumber == expectedBlockNumber {
+					if i < len(pending.confirmations) && pending.confirmations[i].BlockHash != block.BlockHash {
+						// The block replaces confirmations we had already counted
+						bcm.recordReorg(pending, blockNumber, hashesOf(pending.confirmations[i:]), []string{block.BlockHash})
+					}
+					if i != len(pending.confirmations)-1 || pending.confirmations[i].BlockHash != block.BlockHash {
+						// Only write the state if the block was not already the latest confirmation
+						bcm.markDirty(pendingKey)
+					}
 					pending.confirmations = append(pending.confirmations[0:i], block)
 					l.Infof("Confirmation %d at block %d / %s item=%s",
 						len(pending.confirmations), block.BlockNumber, block.BlockHash, pending.getKey())
@@ -547,8 +653,10 @@ func (bcm *blockConfirmationManager) processBlock(block *BlockInfo) {
 				}
 				expectedBlockNumber++
 			}
-			if len(pending.confirmations) >= bcm.requiredConfirmations {
+			if len(pending.confirmations) >= bcm.requiredConfirmationsFor(pending) {
 				confirmed = append(confirmed, pending)
+			} else {
+				bcm.reportProgress(pending)
 			}
 
 		}
@@ -568,9 +676,32 @@ func (bcm *blockConfirmationManager) dispatchConfirmed(item *pendingItem) {
 	bcm.removeItem(pendingKey, false)
 
 	log.L(bcm.ctx).Infof("Confirmed with %d confirmations event=%s", len(item.confirmations), pendingKey)
+	bcm.reportProgress(item)
 	item.confirmedCallback(bcm.ctx, item.copyConfirmations() /* a safe copy outside of our cache */)
 }
 
+// reportProgress calls the progress callback of a mined transaction (if any), when the number of confirmations
+// has changed since it was last called. Walking the chain rebuilds the confirmations, so might not change the count.
+func (bcm *blockConfirmationManager) reportProgress(item *pendingItem) {
+	if bcm.finality != nil || item.progressCallback == nil || item.blockHash == "" || len(item.confirmations) == item.progressReported {
+		return
+	}
+	item.progressReported = len(item.confirmations)
+	progress := &ConfirmationProgress{
+		Count:    len(item.confirmations),
+		Required: bcm.requiredConfirmationsFor(item),
+	}
+	if len(item.confirmations) > 0 {
+		latest := item.confirmations[len(item.confirmations)-1]
+		progress.LatestBlock = &apitypes.BlockInfo{
+			BlockNumber: latest.BlockNumber,
+			BlockHash:   latest.BlockHash,
+			ParentHash:  latest.ParentHash,
+		}
+	}
+	item.progressCallback(bcm.ctx, progress)
+}
+
 // walkChain goes through each event and sees whether it's valid,
 // purging any stale confirmations - or whole events if the blockListener is invalid
 // We do this each time our blockListener is invalidated
@@ -592,7 +723,13 @@ func (bcm *blockConfirmationManager) walkChain(blocks *blockState) error {
 	//  then only walking the chain for later events in the list would find the block.
 	//  This means those later events would be delivered, but the earlier ones would not.
 	for _, pending := range pendingItems {
-		if err := bcm.walkChainForItem(pending, blocks); err != nil {
+		var err error
+		if bcm.finality != nil {
+			err = bcm.checkFinalized(pending, blocks)
+		} else {
+			err = bcm.walkChainForItem(pending, blocks)
+		}
+		if err != nil {
 			return err
 		}
 	}
@@ -603,12 +740,13 @@ func (bcm *blockConfirmationManager) walkChain(blocks *blockState) error {
 
 func (bcm *blockConfirmationManager) newBlockState() *blockState {
 	return &blockState{
-		bcm:    bcm,
-		blocks: make(map[uint64]*BlockInfo),
+		bcm:       bcm,
+		blocks:    make(map[uint64]*apitypes.BlockInfo),
+		canonical: make(map[uint64]*apitypes.BlockInfo),
 	}
 }
 
-func (bs *blockState) getByNumber(blockNumber uint64, expectedParentHash string) (*BlockInfo, error) {
+func (bs *blockState) getByNumber(blockNumber uint64, expectedParentHash string) (*apitypes.BlockInfo, error) {
 	// blockState gives a consistent view of the chain throughout a cycle, where we perform a carefully ordered
 	// set of actions against our pending items.
 	// - We never return newer blocks after a query has been made that found a nil result at a lower block number
@@ -646,11 +784,42 @@ func (bcm *blockConfirmationManager) walkChainForItem(pending *pendingItem, bloc
 		return nil
 	}
 
+	if bcm.finality != nil {
+		// Items are only released by walkChain in finality mode, so they are released in the order on the chain
+		return nil
+	}
+
+	var previous []*apitypes.BlockInfo
+	if len(pending.confirmations) > 0 {
+		// Keep the confirmations we had, so we can tell whether walking the chain replaced any of them
+		previous = make([]*apitypes.BlockInfo, len(pending.confirmations))
+		copy(previous, pending.confirmations)
+		pending.confirmations = pending.confirmations[:0]
+	}
+	err = bcm.extendConfirmations(pending, blocks)
+	if !sameBlocks(previous, pending.confirmations) {
+		// Only write the state of items that have changed, as the walk usually finds the same confirmations
+		bcm.markDirty(pending.getKey())
+	}
+	bcm.detectReorg(pending, previous, blocks)
+	return err
+
+}
+
+// extendConfirmations walks the chain from the latest confirmation of an item (or the block containing the item,
+// if it has no confirmations yet), dispatching the item if it reaches the required number of confirmations
+func (bcm *blockConfirmationManager) extendConfirmations(pending *pendingItem, blocks *blockState) (err error) {
+
 	pendingKey := pending.getKey()
 
 	blockNumber := pending.blockNumber + 1
 	expectedParentHash := pending.blockHash
-	pending.confirmations = pending.confirmations[:0]
+	if len(pending.confirmations) > 0 {
+		latest := pending.confirmations[len(pending.confirmations)-1]
+		blockNumber = latest.BlockNumber.Uint64() + 1
+		expectedParentHash = latest.BlockHash
+	}
+	defer bcm.reportProgress(pending)
 	for {
 		// No point in walking past the highest block we've seen via the notifier
 		if bcm.highestBlockSeen > 0 && blockNumber > bcm.highestBlockSeen {
@@ -671,7 +840,7 @@ func (bcm *blockConfirmationManager) walkChainForItem(pending *pendingItem, bloc
 			return nil
 		}
 		pending.confirmations = append(pending.confirmations, block)
-		if len(pending.confirmations) >= bcm.requiredConfirmations {
+		if len(pending.confirmations) >= bcm.requiredConfirmationsFor(pending) {
 			// Ready for dispatch
 			bcm.dispatchConfirmed(pending)
 			return nil
@@ -681,3 +850,66 @@ func (bcm *blockConfirmationManager) walkChainForItem(pending *pendingItem, bloc
 	}
 
 }
+
+// getFinalized returns the finalized height reported by the connector, which is queried at most once per cycle
+func (bs *blockState) getFinalized() (uint64, error) {
+	if bs.finalized == nil {
+		res, _, err := bs.bcm.finality.LatestFinalizedBlock(bs.bcm.ctx, &ffcapi.LatestFinalizedBlockRequest{})
+		if err != nil {
+			return 0, err
+		}
+		finalized := res.BlockNumber.Uint64()
+		bs.finalized = &finalized
+	}
+	return *bs.finalized, nil
+}
+
+// getCanonical returns the block at a finalized height, downloaded from the connector rather than from the
+// block cache, as a cached block is not checked against the canonical chain when there is no expected parent.
+func (bs *blockState) getCanonical(blockNumber uint64) (*apitypes.BlockInfo, error) {
+	block := bs.canonical[blockNumber]
+	if block == nil {
+		var err error
+		block, err = fetchBlockByNumber(bs.bcm.ctx, bs.bcm.connector, blockNumber, "")
+		if err != nil || block == nil {
+			return nil, err
+		}
+		bs.canonical[blockNumber] = block
+	}
+	return block, nil
+}
+
+// checkFinalized dispatches an item in finality mode, once its block is at or below the finalized height.
+// The finalized block at any height cannot change, so the item is only dispatched if its block is in the canonical chain.
+func (bcm *blockConfirmationManager) checkFinalized(pending *pendingItem, blocks *blockState) error {
+	if pending.blockHash == "" {
+		log.L(bcm.ctx).Debugf("Transaction %s still awaiting receipt", pending.transactionHash)
+		return nil
+	}
+	finalized, err := blocks.getFinalized()
+	if err != nil {
+		return err
+	}
+	pendingKey := pending.getKey()
+	if pending.blockNumber > finalized {
+		log.L(bcm.ctx).Debugf("Waiting for finality of block %d (finalized=%d) event=%s", pending.blockNumber, finalized, pendingKey)
+		return nil
+	}
+	block, err := blocks.getCanonical(pending.blockNumber)
+	if err != nil {
+		return err
+	}
+	if block == nil || block.BlockHash != pending.blockHash {
+		log.L(bcm.ctx).Infof("Block %d / %s is not in the canonical chain at finalized height %d event=%s", pending.blockNumber, pending.blockHash, finalized, pendingKey)
+		if pending.pType == pendingTypeTransaction {
+			// The transaction might have been mined in a different block, so we need to get the receipt again
+			bcm.staleReceipts[pendingKey] = true
+		} else {
+			// The block containing the event can never become final, so the event will never be confirmed
+			bcm.removeItem(pendingKey, true)
+		}
+		return nil
+	}
+	bcm.dispatchConfirmed(pending)
+	return nil
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
 	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
 	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
@@ -41,14 +42,14 @@ func newTestBlockConfirmationManager(t *testing.T, enabled bool) (*blockConfirma
 func newTestBlockConfirmationManagerCustomConfig(t *testing.T) (*blockConfirmationManager, *ffcapimocks.API) {
 	logrus.SetLevel(logrus.DebugLevel)
 	mca := &ffcapimocks.API{}
-	bcm := NewBlockConfirmationManager(context.Background(), mca, "ut")
+	bcm := NewBlockConfirmationManager(context.Background(), mca, "ut", nil, nil, nil)
 	return bcm.(*blockConfirmationManager), mca
 }
 
 func TestBlockConfirmationManagerE2ENewEvent(t *testing.T) {
 	bcm, mca := newTestBlockConfirmationManager(t, true)
 
-	confirmed := make(chan []BlockInfo, 1)
+	confirmed := make(chan []apitypes.BlockInfo, 1)
 	eventToConfirm := &EventInfo{
 		ID: &ffcapi.EventID{
 			ListenerID:       fftypes.NewUUID(),
@@ -58,7 +59,7 @@ func TestBlockConfirmationManagerE2ENewEvent(t *testing.T) {
 			TransactionIndex: 5,
 			LogIndex:         10,
 		},
-		Confirmed: func(ctx context.Context, confirmations []BlockInfo) {
+		Confirmed: func(ctx context.Context, confirmations []apitypes.BlockInfo) {
 			confirmed <- confirmations
 		},
 	}
@@ -67,7 +68,7 @@ func TestBlockConfirmationManagerE2ENewEvent(t *testing.T) {
 	blockHashes := bcm.NewBlockHashes()
 
 	// Next time round gives a block that is in the confirmation chain, but one block ahead
-	block1003 := &BlockInfo{
+	block1003 := &apitypes.BlockInfo{
 		BlockNumber: 1003,
 		BlockHash:   "0x64fd8179b80dd255d52ce60d7f265c0506be810e2f3df52463fadeb44bb4d2df",
 		ParentHash:  "0x46210d224888265c269359529618bf2f6adb2697ff52c63c10f16a2391bdd295",
@@ -94,7 +95,7 @@ func TestBlockConfirmationManagerE2ENewEvent(t *testing.T) {
 	}, ffcapi.ErrorReason(""), nil).Once()
 
 	// Then we should walk the chain by number to fill in 1002/1003, because our HWM is 1003
-	block1002 := &BlockInfo{
+	block1002 := &apitypes.BlockInfo{
 		BlockNumber: 1002,
 		BlockHash:   "0x46210d224888265c269359529618bf2f6adb2697ff52c63c10f16a2391bdd295",
 		ParentHash:  "0x0e32d749a86cfaf551d528b5b121cea456f980a39e5b8136eb8e85dbc744a542",
@@ -110,7 +111,7 @@ func TestBlockConfirmationManagerE2ENewEvent(t *testing.T) {
 	}, ffcapi.ErrorReason(""), nil)
 
 	// Notify of 1004 after we download 1003
-	block1004 := &BlockInfo{
+	block1004 := &apitypes.BlockInfo{
 		BlockNumber: 1004,
 		BlockHash:   "0xed21f4f73d150f16f922ae82b7485cd936ae1eca4c027516311b928360a347e8",
 		ParentHash:  "0x64fd8179b80dd255d52ce60d7f265c0506be810e2f3df52463fadeb44bb4d2df",
@@ -145,7 +146,7 @@ func TestBlockConfirmationManagerE2ENewEvent(t *testing.T) {
 	bcm.Start()
 
 	dispatched := <-confirmed
-	assert.Equal(t, []BlockInfo{
+	assert.Equal(t, []apitypes.BlockInfo{
 		*block1002,
 		*block1003,
 		*block1004,
@@ -159,7 +160,7 @@ func TestBlockConfirmationManagerE2ENewEvent(t *testing.T) {
 func TestBlockConfirmationManagerE2EFork(t *testing.T) {
 	bcm, mca := newTestBlockConfirmationManager(t, true)
 
-	confirmed := make(chan []BlockInfo, 1)
+	confirmed := make(chan []apitypes.BlockInfo, 1)
 	eventToConfirm := &EventInfo{
 		ID: &ffcapi.EventID{
 			ListenerID:       fftypes.NewUUID(),
@@ -169,18 +170,18 @@ func TestBlockConfirmationManagerE2EFork(t *testing.T) {
 			TransactionIndex: 5,
 			LogIndex:         10,
 		},
-		Confirmed: func(ctx context.Context, confirmations []BlockInfo) {
+		Confirmed: func(ctx context.Context, confirmations []apitypes.BlockInfo) {
 			confirmed <- confirmations
 		},
 	}
 
 	// The next filter gives us 1002, and a first 1003 block - which will later be removed
-	block1002 := &BlockInfo{
+	block1002 := &apitypes.BlockInfo{
 		BlockNumber: 1002,
 		BlockHash:   "0x64fd8179b80dd255d52ce60d7f265c0506be810e2f3df52463fadeb44bb4d2df",
 		ParentHash:  "0x0e32d749a86cfaf551d528b5b121cea456f980a39e5b8136eb8e85dbc744a542",
 	}
-	block1003a := &BlockInfo{
+	block1003a := &apitypes.BlockInfo{
 		BlockNumber: 1003,
 		BlockHash:   "0x46210d224888265c269359529618bf2f6adb2697ff52c63c10f16a2391bdd295",
 		ParentHash:  "0x64fd8179b80dd255d52ce60d7f265c0506be810e2f3df52463fadeb44bb4d2df",
@@ -221,12 +222,12 @@ func TestBlockConfirmationManagerE2EFork(t *testing.T) {
 	}, ffcapi.ErrorReason(""), nil).Once()
 
 	// Then we get the final fork up to our confirmation
-	block1003b := &BlockInfo{
+	block1003b := &apitypes.BlockInfo{
 		BlockNumber: 1003,
 		BlockHash:   "0xed21f4f73d150f16f922ae82b7485cd936ae1eca4c027516311b928360a347e8",
 		ParentHash:  "0x64fd8179b80dd255d52ce60d7f265c0506be810e2f3df52463fadeb44bb4d2df",
 	}
-	block1004 := &BlockInfo{
+	block1004 := &apitypes.BlockInfo{
 		BlockNumber: 1004,
 		BlockHash:   "0x110282339db2dfe4bfd13d78375f7883048cac6bc12f8393bd080a4e263d5d21",
 		ParentHash:  "0xed21f4f73d150f16f922ae82b7485cd936ae1eca4c027516311b928360a347e8",
@@ -275,7 +276,7 @@ func TestBlockConfirmationManagerE2EFork(t *testing.T) {
 	bcm.Start()
 
 	dispatched := <-confirmed
-	assert.Equal(t, []BlockInfo{
+	assert.Equal(t, []apitypes.BlockInfo{
 		*block1002,
 		*block1003b,
 		*block1004,
@@ -290,18 +291,18 @@ func TestBlockConfirmationManagerE2EFork(t *testing.T) {
 func TestBlockConfirmationManagerE2ETransactionMovedFork(t *testing.T) {
 	bcm, mca := newTestBlockConfirmationManager(t, true)
 
-	confirmed := make(chan []BlockInfo, 1)
+	confirmed := make(chan []apitypes.BlockInfo, 1)
 	receiptReceived := make(chan *ffcapi.TransactionReceiptResponse, 1)
 	txToConfirmForkA := &TransactionInfo{
 		TransactionHash: "0x1dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347",
-		Confirmed: func(ctx context.Context, confirmations []BlockInfo) {
+		Confirmed: func(ctx context.Context, confirmations []apitypes.BlockInfo) {
 			confirmed <- confirmations
 		},
 		Receipt: func(ctx context.Context, receipt *ffcapi.TransactionReceiptResponse) {
 			receiptReceived <- receipt
 		},
 	}
-	block1002a := &BlockInfo{
+	block1002a := &apitypes.BlockInfo{
 		BlockNumber: 1002,
 		BlockHash:   "0x46210d224888265c269359529618bf2f6adb2697ff52c63c10f16a2391bdd295",
 		ParentHash:  "0xea681fadcf56ee6254a0d30b255c56636ee9199c73c45f0dd5823759b2ad1ef8",
@@ -313,13 +314,13 @@ func TestBlockConfirmationManagerE2ETransactionMovedFork(t *testing.T) {
 	})
 	assert.NoError(t, err)
 
-	block1001b := &BlockInfo{
+	block1001b := &apitypes.BlockInfo{
 		BlockNumber:       1001,
 		BlockHash:         "0x33eb56730878a08e126f2d52b19242d3b3127dc7611447255928be91b2dda455",
 		ParentHash:        "0xe9afc4ff48efed19fc9256d2964c4194320d4d20dca25bb2ebcf7d047e1b83c6",
 		TransactionHashes: []string{txToConfirmForkA.TransactionHash},
 	}
-	block1002b := &BlockInfo{
+	block1002b := &apitypes.BlockInfo{
 		BlockNumber: 1002,
 		BlockHash:   "0xed21f4f73d150f16f922ae82b7485cd936ae1eca4c027516311b928360a347e8",
 		ParentHash:  "0x33eb56730878a08e126f2d52b19242d3b3127dc7611447255928be91b2dda455",
@@ -389,12 +390,12 @@ func TestBlockConfirmationManagerE2ETransactionMovedFork(t *testing.T) {
 	}, ffcapi.ErrorReason(""), nil).Once()
 
 	// Then we get the final fork up to our confirmation
-	block1003 := &BlockInfo{
+	block1003 := &apitypes.BlockInfo{
 		BlockNumber: 1003,
 		BlockHash:   "0xaf47ddbd9ba81736f808045b7fccc2179bba360573b362c82544f7360db0802e",
 		ParentHash:  "0xed21f4f73d150f16f922ae82b7485cd936ae1eca4c027516311b928360a347e8",
 	}
-	block1004 := &BlockInfo{
+	block1004 := &apitypes.BlockInfo{
 		BlockNumber: 1004,
 		BlockHash:   "0x110282339db2dfe4bfd13d78375f7883048cac6bc12f8393bd080a4e263d5d21",
 		ParentHash:  "0xaf47ddbd9ba81736f808045b7fccc2179bba360573b362c82544f7360db0802e",
@@ -445,7 +446,7 @@ func TestBlockConfirmationManagerE2ETransactionMovedFork(t *testing.T) {
 	assert.True(t, receipt.Success)
 
 	dispatched := <-confirmed
-	assert.Equal(t, []BlockInfo{
+	assert.Equal(t, []apitypes.BlockInfo{
 		*block1002b,
 		*block1003,
 		*block1004,
@@ -460,7 +461,7 @@ func TestBlockConfirmationManagerE2ETransactionMovedFork(t *testing.T) {
 func TestBlockConfirmationManagerE2EHistoricalEvent(t *testing.T) {
 	bcm, mca := newTestBlockConfirmationManager(t, true)
 
-	confirmed := make(chan []BlockInfo, 1)
+	confirmed := make(chan []apitypes.BlockInfo, 1)
 	eventToConfirm := &EventInfo{
 		ID: &ffcapi.EventID{
 			ListenerID:       fftypes.NewUUID(),
@@ -470,23 +471,23 @@ func TestBlockConfirmationManagerE2EHistoricalEvent(t *testing.T) {
 			TransactionIndex: 5,
 			LogIndex:         10,
 		},
-		Confirmed: func(ctx context.Context, confirmations []BlockInfo) {
+		Confirmed: func(ctx context.Context, confirmations []apitypes.BlockInfo) {
 			confirmed <- confirmations
 		},
 	}
 
 	// Then we should walk the chain by number to fill in 1002/1003, because our HWM is 1003
-	block1002 := &BlockInfo{
+	block1002 := &apitypes.BlockInfo{
 		BlockNumber: 1002,
 		BlockHash:   "0x46210d224888265c269359529618bf2f6adb2697ff52c63c10f16a2391bdd295",
 		ParentHash:  "0x0e32d749a86cfaf551d528b5b121cea456f980a39e5b8136eb8e85dbc744a542",
 	}
-	block1003 := &BlockInfo{
+	block1003 := &apitypes.BlockInfo{
 		BlockNumber: 1003,
 		BlockHash:   "0x64fd8179b80dd255d52ce60d7f265c0506be810e2f3df52463fadeb44bb4d2df",
 		ParentHash:  "0x46210d224888265c269359529618bf2f6adb2697ff52c63c10f16a2391bdd295",
 	}
-	block1004 := &BlockInfo{
+	block1004 := &apitypes.BlockInfo{
 		BlockNumber: 1004,
 		BlockHash:   "0xed21f4f73d150f16f922ae82b7485cd936ae1eca4c027516311b928360a347e8",
 		ParentHash:  "0x64fd8179b80dd255d52ce60d7f265c0506be810e2f3df52463fadeb44bb4d2df",
@@ -528,7 +529,7 @@ func TestBlockConfirmationManagerE2EHistoricalEvent(t *testing.T) {
 	bcm.Start()
 
 	dispatched := <-confirmed
-	assert.Equal(t, []BlockInfo{
+	assert.Equal(t, []apitypes.BlockInfo{
 		*block1002,
 		*block1003,
 		*block1004,
@@ -591,7 +592,7 @@ func TestConfirmationsListenerFailWalkingChainForNewEvent(t *testing.T) {
 	bcm, mca := newTestBlockConfirmationManager(t, false)
 	bcm.done = make(chan struct{})
 
-	confirmed := make(chan []BlockInfo, 1)
+	confirmed := make(chan []apitypes.BlockInfo, 1)
 	eventToConfirm := &EventInfo{
 		ID: &ffcapi.EventID{
 			ListenerID:       fftypes.NewUUID(),
@@ -601,7 +602,7 @@ func TestConfirmationsListenerFailWalkingChainForNewEvent(t *testing.T) {
 			TransactionIndex: 5,
 			LogIndex:         10,
 		},
-		Confirmed: func(ctx context.Context, confirmations []BlockInfo) {
+		Confirmed: func(ctx context.Context, confirmations []apitypes.BlockInfo) {
 			confirmed <- confirmations
 		},
 	}
@@ -926,7 +927,7 @@ func TestNotificationValidation(t *testing.T) {
 		NotificationType: NewTransaction,
 		Transaction: &TransactionInfo{
 			TransactionHash: "0x1dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347",
-			Confirmed:       func(ctx context.Context, confirmations []BlockInfo) {},
+			Confirmed:       func(ctx context.Context, confirmations []apitypes.BlockInfo) {},
 		},
 	})
 	assert.NoError(t, err)
@@ -970,7 +971,7 @@ func TestCheckReceiptImmediateConfirm(t *testing.T) {
 	pending := &pendingItem{
 		pType:           pendingTypeTransaction,
 		transactionHash: txHash,
-		confirmedCallback: func(ctx context.Context, confirmations []BlockInfo) {
+		confirmedCallback: func(ctx context.Context, confirmations []apitypes.BlockInfo) {
 			close(done)
 		},
 	}
@@ -981,6 +982,37 @@ func TestCheckReceiptImmediateConfirm(t *testing.T) {
 	<-done
 }
 
+func TestCheckReceiptImmediateConfirmOverride(t *testing.T) {
+
+	bcm, mca := newTestBlockConfirmationManager(t, false)
+	assert.Equal(t, 3, bcm.requiredConfirmations)
+
+	mca.On("TransactionReceipt", mock.Anything, mock.Anything).Return(&ffcapi.TransactionReceiptResponse{
+		BlockHash:        fftypes.NewRandB32().String(),
+		BlockNumber:      fftypes.NewFFBigInt(1001),
+		TransactionIndex: fftypes.NewFFBigInt(0),
+		Success:          true,
+	}, ffcapi.ErrorReasonNotFound, nil)
+
+	done := make(chan struct{})
+	required := 0
+	n := &Notification{
+		Transaction: &TransactionInfo{
+			TransactionHash: "0x1dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347",
+			Confirmations:   &required,
+			Confirmed: func(ctx context.Context, confirmations []apitypes.BlockInfo) {
+				close(done)
+			},
+		},
+	}
+	pending := n.transactionPendingItem()
+	bcm.addOrReplaceItem(pending)
+	blocks := bcm.newBlockState()
+	go bcm.checkReceipt(pending, blocks)
+
+	<-done
+}
+
 func TestCheckReceiptFail(t *testing.T) {
 
 	bcm, mca := newTestBlockConfirmationManager(t, false)
@@ -1082,3 +1114,389 @@ func TestBlockState(t *testing.T) {
 
 	mca.AssertExpectations(t)
 }
+
+func TestConfirmationProgress(t *testing.T) {
+	bcm, mca := newTestBlockConfirmationManager(t, false)
+
+	var reported []*ConfirmationProgress
+	n := &Notification{
+		Transaction: &TransactionInfo{
+			TransactionHash: "0x531e219d98d81dc9f9a14811ac537479f5d77a74bdba47629bfbebe2d7663ce7",
+			Progress: func(ctx context.Context, progress *ConfirmationProgress) {
+				reported = append(reported, progress)
+			},
+			Confirmed: func(ctx context.Context, confirmations []apitypes.BlockInfo) {},
+		},
+	}
+	pending := n.transactionPendingItem()
+	bcm.addOrReplaceItem(pending)
+
+	// No progress is reported before the receipt
+	bcm.reportProgress(pending)
+	assert.Empty(t, reported)
+
+	pending.blockNumber = 1001
+	pending.blockHash = "0xa4e6e7e8a5ef8b69b3e2d1a8b0c1f8e3d6e7c8a9b0c1d2e3f4a5b6c7d8e9f0a1"
+	block1002 := &apitypes.BlockInfo{
+		BlockNumber: 1002,
+		BlockHash:   "0xb5f7f8f9b6f09c7ac4f3e2b9c1d2f9f4e7f8d9bac1d2e3f4a5b6c7d8e9f0a1b2",
+		ParentHash:  pending.blockHash,
+	}
+	block1003 := &apitypes.BlockInfo{
+		BlockNumber: 1003,
+		BlockHash:   "0xc6a8a9a0c7a1ad8bd5a4f3cad2e3a0a5f8a9eacbd2e3f4a5b6c7d8e9f0a1b2c3",
+		ParentHash:  block1002.BlockHash,
+	}
+	mca.On("BlockInfoByNumber", mock.Anything, mock.MatchedBy(func(r *ffcapi.BlockInfoByNumberRequest) bool {
+		return r.BlockNumber.Uint64() == 1002
+	})).Return(&ffcapi.BlockInfoByNumberResponse{
+		BlockInfo: ffcapi.BlockInfo{
+			BlockNumber: fftypes.NewFFBigInt(1002),
+			BlockHash:   block1002.BlockHash,
+			ParentHash:  block1002.ParentHash,
+		},
+	}, ffcapi.ErrorReason(""), nil)
+	mca.On("BlockInfoByNumber", mock.Anything, mock.MatchedBy(func(r *ffcapi.BlockInfoByNumberRequest) bool {
+		return r.BlockNumber.Uint64() == 1003
+	})).Return(nil, ffcapi.ErrorReasonNotFound, fmt.Errorf("not found"))
+
+	// Walking the chain reports the first confirmation
+	err := bcm.walkChainForItem(pending, bcm.newBlockState())
+	assert.NoError(t, err)
+	assert.Len(t, reported, 1)
+	assert.Equal(t, 1, reported[0].Count)
+	assert.Equal(t, 3, reported[0].Required)
+	assert.Equal(t, block1002.BlockHash, reported[0].LatestBlock.BlockHash)
+
+	// Walking again does not report, as nothing has changed
+	err = bcm.walkChainForItem(pending, bcm.newBlockState())
+	assert.NoError(t, err)
+	assert.Len(t, reported, 1)
+
+	// A new block reports the next confirmation
+	bcm.processBlock(block1003)
+	assert.Len(t, reported, 2)
+	assert.Equal(t, 2, reported[1].Count)
+	assert.Equal(t, fftypes.FFuint64(1003), reported[1].LatestBlock.BlockNumber)
+
+	// The final confirmation is reported before dispatch
+	bcm.processBlock(&apitypes.BlockInfo{
+		BlockNumber: 1004,
+		BlockHash:   "0xd7b9b0b1d8b2be9ce6b5a4dbe3f4b1b6a9b0fbdce3f4a5b6c7d8e9f0a1b2c3d4",
+		ParentHash:  block1003.BlockHash,
+	})
+	assert.Len(t, reported, 3)
+	assert.Equal(t, 3, reported[2].Count)
+	assert.Empty(t, bcm.pending)
+
+	mca.AssertExpectations(t)
+}
+
+func TestConfirmationsPerTransactionOverride(t *testing.T) {
+	bcm, _ := newTestBlockConfirmationManager(t, false)
+
+	var confirmedTX1, confirmedTX2 []apitypes.BlockInfo
+	required := 1
+	tx1 := (&Notification{
+		Transaction: &TransactionInfo{
+			TransactionHash: "0x531e219d98d81dc9f9a14811ac537479f5d77a74bdba47629bfbebe2d7663ce7",
+			Confirmations:   &required,
+			Confirmed: func(ctx context.Context, confirmations []apitypes.BlockInfo) {
+				confirmedTX1 = confirmations
+			},
+		},
+	}).transactionPendingItem()
+	tx2 := (&Notification{
+		Transaction: &TransactionInfo{
+			TransactionHash: "0x6b012339fbb85b70c58ecfd97b31950c4a28bcef5226e12dbe551cb1abaf3b4c",
+			Confirmed: func(ctx context.Context, confirmations []apitypes.BlockInfo) {
+				confirmedTX2 = confirmations
+			},
+		},
+	}).transactionPendingItem()
+	for _, pending := range []*pendingItem{tx1, tx2} {
+		bcm.addOrReplaceItem(pending)
+		pending.blockNumber = 1001
+		pending.blockHash = "0xa4e6e7e8a5ef8b69b3e2d1a8b0c1f8e3d6e7c8a9b0c1d2e3f4a5b6c7d8e9f0a1"
+	}
+	assert.Equal(t, 1, cap(tx1.confirmations))
+	assert.Equal(t, 3, cap(tx2.confirmations))
+
+	// One block is enough for the transaction with the override, but not for the other
+	bcm.processBlock(&apitypes.BlockInfo{
+		BlockNumber: 1002,
+		BlockHash:   "0xb5f7f8f9b6f09c7ac4f3e2b9c1d2f9f4e7f8d9bac1d2e3f4a5b6c7d8e9f0a1b2",
+		ParentHash:  "0xa4e6e7e8a5ef8b69b3e2d1a8b0c1f8e3d6e7c8a9b0c1d2e3f4a5b6c7d8e9f0a1",
+	})
+	assert.Len(t, confirmedTX1, 1)
+	assert.Nil(t, confirmedTX2)
+	assert.Len(t, bcm.pending, 1)
+	assert.Len(t, tx2.confirmations, 1)
+}
+
+type testFinalityConnector struct {
+	*ffcapimocks.API
+	*ffcapimocks.FinalityAPI
+}
+
+func newTestFinalityConfirmationManager(t *testing.T) (*blockConfirmationManager, *ffcapimocks.API, *ffcapimocks.FinalityAPI) {
+	tmconfig.Reset()
+	config.Set(tmconfig.ConfirmationsRequired, 3)
+	config.Set(tmconfig.ConfirmationsMode, ModeFinalized)
+	mca := &ffcapimocks.API{}
+	mfa := &ffcapimocks.FinalityAPI{}
+	bcm := NewBlockConfirmationManager(context.Background(), &testFinalityConnector{API: mca, FinalityAPI: mfa}, "ut", nil, nil, nil)
+	return bcm.(*blockConfirmationManager), mca, mfa
+}
+
+func TestFinalityModeFallback(t *testing.T) {
+	tmconfig.Reset()
+	config.Set(tmconfig.ConfirmationsMode, ModeFinalized)
+	bcm := NewBlockConfirmationManager(context.Background(), &ffcapimocks.API{}, "ut", nil, nil, nil)
+	assert.Nil(t, bcm.(*blockConfirmationManager).finality)
+
+	config.Set(tmconfig.ConfirmationsMode, "wrong")
+	bcm = NewBlockConfirmationManager(context.Background(), &testFinalityConnector{}, "ut", nil, nil, nil)
+	assert.Nil(t, bcm.(*blockConfirmationManager).finality)
+}
+
+func TestFinalityModeEventReleased(t *testing.T) {
+	bcm, mca, mfa := newTestFinalityConfirmationManager(t)
+	assert.NotNil(t, bcm.finality)
+
+	var confirmed []apitypes.BlockInfo
+	blockHash := "0x0e32d749a86cfaf551d528b5b121cea456f980a39e5b8136eb8e85dbc744a542"
+	n := &Notification{
+		NotificationType: NewEventLog,
+		Event: &EventInfo{
+			ID: &ffcapi.EventID{
+				ListenerID:      fftypes.NewUUID(),
+				TransactionHash: "0x531e219d98d81dc9f9a14811ac537479f5d77a74bdba47629bfbebe2d7663ce7",
+				BlockHash:       blockHash,
+				BlockNumber:     1001,
+			},
+			Confirmed: func(ctx context.Context, confirmations []apitypes.BlockInfo) {
+				confirmed = confirmations
+			},
+		},
+	}
+	pending := n.eventPendingItem()
+	bcm.addOrReplaceItem(pending)
+
+	// Blocks do not count as confirmations in finality mode
+	bcm.processBlock(&apitypes.BlockInfo{
+		BlockNumber: 1002,
+		BlockHash:   "0x64fd8179b80dd255d52ce60d7f265c0506be810e2f3df52463fadeb44bb4d2df",
+		ParentHash:  blockHash,
+	})
+	assert.Empty(t, pending.confirmations)
+
+	// Not yet finalized
+	mfa.On("LatestFinalizedBlock", mock.Anything, mock.Anything).Return(&ffcapi.LatestFinalizedBlockResponse{
+		BlockNumber: fftypes.NewFFBigInt(1000),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	err := bcm.walkChain(bcm.newBlockState())
+	assert.NoError(t, err)
+	assert.Nil(t, confirmed)
+	assert.Len(t, bcm.pending, 1)
+
+	// Finalized, and in the canonical chain
+	mfa.On("LatestFinalizedBlock", mock.Anything, mock.Anything).Return(&ffcapi.LatestFinalizedBlockResponse{
+		BlockNumber: fftypes.NewFFBigInt(1001),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mca.On("BlockInfoByNumber", mock.Anything, mock.MatchedBy(func(r *ffcapi.BlockInfoByNumberRequest) bool {
+		return r.BlockNumber.Uint64() == 1001
+	})).Return(&ffcapi.BlockInfoByNumberResponse{
+		BlockInfo: ffcapi.BlockInfo{
+			BlockNumber: fftypes.NewFFBigInt(1001),
+			BlockHash:   blockHash,
+			ParentHash:  "0x46210d224888265c269359529618bf2f6adb2697ff52c63c10f16a2391bdd295",
+		},
+	}, ffcapi.ErrorReason(""), nil)
+	err = bcm.walkChain(bcm.newBlockState())
+	assert.NoError(t, err)
+	assert.NotNil(t, confirmed)
+	assert.Empty(t, bcm.pending)
+
+	mca.AssertExpectations(t)
+	mfa.AssertExpectations(t)
+}
+
+func TestFinalityModeTransactionNotCanonical(t *testing.T) {
+	bcm, mca, mfa := newTestFinalityConfirmationManager(t)
+
+	n := &Notification{
+		Transaction: &TransactionInfo{
+			TransactionHash: "0x531e219d98d81dc9f9a14811ac537479f5d77a74bdba47629bfbebe2d7663ce7",
+			Confirmed: func(ctx context.Context, confirmations []apitypes.BlockInfo) {
+				assert.Fail(t, "should not be confirmed")
+			},
+		},
+	}
+	pending := n.transactionPendingItem()
+	bcm.addOrReplaceItem(pending)
+	pending.blockNumber = 1001
+	pending.blockHash = "0x0e32d749a86cfaf551d528b5b121cea456f980a39e5b8136eb8e85dbc744a542"
+
+	mfa.On("LatestFinalizedBlock", mock.Anything, mock.Anything).Return(&ffcapi.LatestFinalizedBlockResponse{
+		BlockNumber: fftypes.NewFFBigInt(1005),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mca.On("BlockInfoByNumber", mock.Anything, mock.Anything).Return(&ffcapi.BlockInfoByNumberResponse{
+		BlockInfo: ffcapi.BlockInfo{
+			BlockNumber: fftypes.NewFFBigInt(1001),
+			BlockHash:   "0x64fd8179b80dd255d52ce60d7f265c0506be810e2f3df52463fadeb44bb4d2df",
+			ParentHash:  "0x46210d224888265c269359529618bf2f6adb2697ff52c63c10f16a2391bdd295",
+		},
+	}, ffcapi.ErrorReason(""), nil)
+
+	// The finalized height is only queried once in the cycle
+	blocks := bcm.newBlockState()
+	err := bcm.checkFinalized(pending, blocks)
+	assert.NoError(t, err)
+	err = bcm.checkFinalized(pending, blocks)
+	assert.NoError(t, err)
+	assert.True(t, bcm.staleReceipts[pending.getKey()])
+	assert.Len(t, bcm.pending, 1)
+
+	mca.AssertExpectations(t)
+	mfa.AssertExpectations(t)
+}
+
+func TestFinalityModeIgnoresStaleCachedBlock(t *testing.T) {
+	bcm, mca, mfa := newTestFinalityConfirmationManager(t)
+	bcm.blockCache = NewBlockCache(mca, 10)
+
+	staleHash := "0x0e32d749a86cfaf551d528b5b121cea456f980a39e5b8136eb8e85dbc744a542"
+	pending := &pendingItem{
+		pType:       pendingTypeEvent,
+		blockNumber: 1001,
+		blockHash:   staleHash,
+	}
+	bcm.addOrReplaceItem(pending)
+
+	// The cache holds the block the event was detected in, before the chain was re-organized
+	bcm.blockCache.(*blockCache).add(bcm.ctx, &apitypes.BlockInfo{
+		BlockNumber: 1001,
+		BlockHash:   staleHash,
+		ParentHash:  "0x46210d224888265c269359529618bf2f6adb2697ff52c63c10f16a2391bdd295",
+	})
+
+	mfa.On("LatestFinalizedBlock", mock.Anything, mock.Anything).Return(&ffcapi.LatestFinalizedBlockResponse{
+		BlockNumber: fftypes.NewFFBigInt(1005),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mca.On("BlockInfoByNumber", mock.Anything, mock.MatchedBy(func(r *ffcapi.BlockInfoByNumberRequest) bool {
+		return r.BlockNumber.Uint64() == 1001
+	})).Return(&ffcapi.BlockInfoByNumberResponse{
+		BlockInfo: ffcapi.BlockInfo{
+			BlockNumber: fftypes.NewFFBigInt(1001),
+			BlockHash:   "0x64fd8179b80dd255d52ce60d7f265c0506be810e2f3df52463fadeb44bb4d2df",
+			ParentHash:  "0x46210d224888265c269359529618bf2f6adb2697ff52c63c10f16a2391bdd295",
+		},
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	// The finalized block is downloaded once in the cycle, and the event in the replaced block is dropped
+	blocks := bcm.newBlockState()
+	err := bcm.checkFinalized(pending, blocks)
+	assert.NoError(t, err)
+	assert.Empty(t, bcm.pending)
+	err = bcm.checkFinalized(pending, blocks)
+	assert.NoError(t, err)
+
+	mca.AssertExpectations(t)
+	mfa.AssertExpectations(t)
+}
+
+func TestFinalityModeQueryFail(t *testing.T) {
+	bcm, mca, mfa := newTestFinalityConfirmationManager(t)
+
+	pending := &pendingItem{
+		pType:       pendingTypeEvent,
+		blockNumber: 1001,
+		blockHash:   "0x0e32d749a86cfaf551d528b5b121cea456f980a39e5b8136eb8e85dbc744a542",
+	}
+	mfa.On("LatestFinalizedBlock", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+	err := bcm.checkFinalized(pending, bcm.newBlockState())
+	assert.Regexp(t, "pop", err)
+
+	mfa.On("LatestFinalizedBlock", mock.Anything, mock.Anything).Return(&ffcapi.LatestFinalizedBlockResponse{
+		BlockNumber: fftypes.NewFFBigInt(1005),
+	}, ffcapi.ErrorReason(""), nil)
+	mca.On("BlockInfoByNumber", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+	err = bcm.checkFinalized(pending, bcm.newBlockState())
+	assert.Regexp(t, "pop", err)
+
+	mca.AssertExpectations(t)
+	mfa.AssertExpectations(t)
+}
+
+func TestFinalityModeReleasedInOrder(t *testing.T) {
+	bcm, mca, mfa := newTestFinalityConfirmationManager(t)
+
+	var confirmed []string
+	newEvent := func(blockNumber uint64, blockHash string) *Notification {
+		return &Notification{
+			NotificationType: NewEventLog,
+			Event: &EventInfo{
+				ID: &ffcapi.EventID{
+					ListenerID:      fftypes.NewUUID(),
+					TransactionHash: fmt.Sprintf("0x%064d", blockNumber),
+					BlockHash:       blockHash,
+					BlockNumber:     fftypes.FFuint64(blockNumber),
+				},
+				Confirmed: func(ctx context.Context, confirmations []apitypes.BlockInfo) {
+					confirmed = append(confirmed, blockHash)
+				},
+			},
+		}
+	}
+	hash1001 := "0x0e32d749a86cfaf551d528b5b121cea456f980a39e5b8136eb8e85dbc744a542"
+	hash1002 := "0x64fd8179b80dd255d52ce60d7f265c0506be810e2f3df52463fadeb44bb4d2df"
+	for _, b := range []*apitypes.BlockInfo{
+		{BlockNumber: 1001, BlockHash: hash1001, ParentHash: "0x46210d224888265c269359529618bf2f6adb2697ff52c63c10f16a2391bdd295"},
+		{BlockNumber: 1002, BlockHash: hash1002, ParentHash: hash1001},
+	} {
+		block := b
+		mca.On("BlockInfoByNumber", mock.Anything, mock.MatchedBy(func(r *ffcapi.BlockInfoByNumberRequest) bool {
+			return r.BlockNumber.Uint64() == block.BlockNumber.Uint64()
+		})).Return(&ffcapi.BlockInfoByNumberResponse{
+			BlockInfo: ffcapi.BlockInfo{
+				BlockNumber: fftypes.NewFFBigInt(int64(block.BlockNumber)),
+				BlockHash:   block.BlockHash,
+				ParentHash:  block.ParentHash,
+			},
+		}, ffcapi.ErrorReason(""), nil)
+	}
+
+	// A transaction awaiting its receipt is not released
+	bcm.addOrReplaceItem((&Notification{
+		NotificationType: NewTransaction,
+		Transaction:      &TransactionInfo{TransactionHash: "0x999"},
+	}).transactionPendingItem())
+
+	// The earlier event is notified before its block is final
+	mfa.On("LatestFinalizedBlock", mock.Anything, mock.Anything).Return(&ffcapi.LatestFinalizedBlockResponse{
+		BlockNumber: fftypes.NewFFBigInt(1000),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	blocks := bcm.newBlockState()
+	err := bcm.processNotifications([]*Notification{newEvent(1001, hash1001)}, blocks)
+	assert.NoError(t, err)
+	err = bcm.walkChain(blocks)
+	assert.NoError(t, err)
+	assert.Empty(t, confirmed)
+
+	// The later event is notified once both blocks are final, and is released after the earlier one
+	mfa.On("LatestFinalizedBlock", mock.Anything, mock.Anything).Return(&ffcapi.LatestFinalizedBlockResponse{
+		BlockNumber: fftypes.NewFFBigInt(1002),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	blocks = bcm.newBlockState()
+	err = bcm.processNotifications([]*Notification{newEvent(1002, hash1002)}, blocks)
+	assert.NoError(t, err)
+	assert.Empty(t, confirmed)
+	err = bcm.walkChain(blocks)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{hash1001, hash1002}, confirmed)
+	assert.Len(t, bcm.pending, 1)
+
+	mca.AssertExpectations(t)
+	mfa.AssertExpectations(t)
+}
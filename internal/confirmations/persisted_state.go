@@ -0,0 +1,169 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package confirmations
+
+import (
+	"context"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/persistence"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+)
+
+// StatePersistence stores the items pending confirmation in a confirmation manager, so that after a restart
+// the receipts and confirmations already collected are restored rather than queried again from the node.
+type StatePersistence interface {
+	ListConfirmationStates(ctx context.Context, managerID string) ([]*persistence.ConfirmationState, error)
+	WriteConfirmationStates(ctx context.Context, managerID string, states []*persistence.ConfirmationState, deletedKeys []string) error
+}
+
+// restoreState loads the items that were pending when the manager was last stopped. They are not tracked until
+// the event or transaction is notified again, as the callbacks cannot be persisted.
+func (bcm *blockConfirmationManager) restoreState() {
+	bcm.restored = make(map[string]*persistence.ConfirmationState)
+	if bcm.persistence == nil {
+		return
+	}
+	states, err := bcm.persistence.ListConfirmationStates(bcm.ctx, bcm.managerID)
+	if err != nil {
+		// We can still rebuild all of the state from the node
+		log.L(bcm.ctx).Errorf("Failed to restore confirmation state: %s", err)
+		return
+	}
+	for _, state := range states {
+		bcm.restored[state.Key] = state
+		bcm.persisted[state.Key] = true
+	}
+	bcm.restoredExpiry = time.Now().Add(bcm.restoreTimeout)
+	log.L(bcm.ctx).Infof("Restored confirmation state for %d pending items", len(states))
+}
+
+// expireRestoredState discards any restored state that has not been claimed by a notification within the timeout,
+// because the event or transaction is no longer of interest
+func (bcm *blockConfirmationManager) expireRestoredState() {
+	if len(bcm.restored) == 0 || time.Now().Before(bcm.restoredExpiry) {
+		return
+	}
+	for key := range bcm.restored {
+		log.L(bcm.ctx).Infof("Discarding unclaimed confirmation state for %s", key)
+		bcm.markDirty(key)
+	}
+	bcm.restored = make(map[string]*persistence.ConfirmationState)
+}
+
+// restoreItem applies any restored state to a newly notified item. The state is only used if the last block
+// it records (the latest confirmation, or the block containing the item) is still in the canonical chain - so all the
+// blocks before it are too. The confirmations are then extended from that point, rather than rebuilt from the node.
+// Returns false if there was no valid state to restore.
+func (bcm *blockConfirmationManager) restoreItem(pending *pendingItem, blocks *blockState) (bool, error) {
+	pendingKey := pending.getKey()
+	state := bcm.restored[pendingKey]
+	if state == nil || (pending.pType == pendingTypeEvent && state.BlockHash != pending.blockHash) {
+		return false, nil
+	}
+
+	lastNumber, lastHash, lastParentHash := state.BlockNumber.Uint64(), state.BlockHash, ""
+	if len(state.Confirmations) > 0 {
+		last := state.Confirmations[len(state.Confirmations)-1]
+		lastNumber, lastHash, lastParentHash = last.BlockNumber.Uint64(), last.BlockHash, last.ParentHash
+	}
+	block, err := blocks.getByNumber(lastNumber, lastParentHash)
+	if err != nil {
+		return false, err
+	}
+	delete(bcm.restored, pendingKey)
+	if block == nil || block.BlockHash != lastHash {
+		log.L(bcm.ctx).Infof("Discarding restored confirmation state for %s, as block %d / %s is no longer in the canonical chain", pendingKey, lastNumber, lastHash)
+		bcm.markDirty(pendingKey)
+		return false, nil
+	}
+
+	log.L(bcm.ctx).Infof("Restored %d confirmations at block %d / %s for %s", len(state.Confirmations), state.BlockNumber, state.BlockHash, pendingKey)
+	pending.blockNumber = state.BlockNumber.Uint64()
+	pending.blockHash = state.BlockHash
+	pending.receipt = state.Receipt
+	pending.confirmations = make([]*apitypes.BlockInfo, len(state.Confirmations))
+	for i := range state.Confirmations {
+		pending.confirmations[i] = &state.Confirmations[i]
+	}
+	if pending.receiptCallback != nil && pending.receipt != nil {
+		pending.receiptCallback(bcm.ctx, pending.receipt)
+	}
+	if bcm.finality != nil {
+		// Released by the walk at the end of the cycle, once final
+		return true, nil
+	}
+	if len(pending.confirmations) >= bcm.requiredConfirmationsFor(pending) {
+		bcm.dispatchConfirmed(pending)
+		return true, nil
+	}
+	err = bcm.extendConfirmations(pending, blocks)
+	if len(pending.confirmations) != len(state.Confirmations) {
+		bcm.markDirty(pendingKey)
+	}
+	return true, err
+}
+
+// markDirty records that the persisted state of an item needs to be written or deleted at the end of the cycle
+func (bcm *blockConfirmationManager) markDirty(pendingKey string) {
+	if bcm.persistence != nil {
+		bcm.dirty[pendingKey] = true
+	}
+}
+
+// persistState writes the items that have changed during the cycle, in a single batch. Only items that have a receipt
+// or confirmations are persisted, as there is nothing to be saved by restoring the others.
+func (bcm *blockConfirmationManager) persistState() {
+	var states []*persistence.ConfirmationState
+	var deletedKeys []string
+	for pendingKey := range bcm.dirty {
+		bcm.pendingMux.Lock()
+		pending := bcm.pending[pendingKey]
+		bcm.pendingMux.Unlock()
+		switch {
+		case pending != nil && (pending.receipt != nil || len(pending.confirmations) > 0):
+			states = append(states, &persistence.ConfirmationState{
+				Key:             pendingKey,
+				ListenerID:      pending.listenerID,
+				TransactionHash: pending.transactionHash,
+				BlockNumber:     fftypes.FFuint64(pending.blockNumber),
+				BlockHash:       pending.blockHash,
+				Receipt:         pending.receipt,
+				Confirmations:   pending.copyConfirmations(),
+				Updated:         fftypes.Now(),
+			})
+		case bcm.persisted[pendingKey] && bcm.restored[pendingKey] == nil:
+			deletedKeys = append(deletedKeys, pendingKey)
+		}
+	}
+	if len(states) > 0 || len(deletedKeys) > 0 {
+		if err := bcm.persistence.WriteConfirmationStates(bcm.ctx, bcm.managerID, states, deletedKeys); err != nil {
+			// Will be retried at the end of the next cycle
+			log.L(bcm.ctx).Errorf("Failed to persist confirmation state for %d items: %s", len(bcm.dirty), err)
+			return
+		}
+	}
+	for _, state := range states {
+		bcm.persisted[state.Key] = true
+	}
+	for _, pendingKey := range deletedKeys {
+		delete(bcm.persisted, pendingKey)
+	}
+	bcm.dirty = make(map[string]bool)
+}
@@ -0,0 +1,452 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package confirmations
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/internal/persistence"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type testStatePersistence struct {
+	states   map[string]*persistence.ConfirmationState
+	listErr  error
+	writeErr error
+	writes   int
+}
+
+func newTestStatePersistence() *testStatePersistence {
+	return &testStatePersistence{
+		states: make(map[string]*persistence.ConfirmationState),
+	}
+}
+
+func (tsp *testStatePersistence) ListConfirmationStates(ctx context.Context, managerID string) ([]*persistence.ConfirmationState, error) {
+	if tsp.listErr != nil {
+		return nil, tsp.listErr
+	}
+	states := make([]*persistence.ConfirmationState, 0, len(tsp.states))
+	for _, state := range tsp.states {
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+func (tsp *testStatePersistence) WriteConfirmationStates(ctx context.Context, managerID string, states []*persistence.ConfirmationState, deletedKeys []string) error {
+	if tsp.writeErr != nil {
+		return tsp.writeErr
+	}
+	tsp.writes++
+	for _, state := range states {
+		tsp.states[state.Key] = state
+	}
+	for _, key := range deletedKeys {
+		delete(tsp.states, key)
+	}
+	return nil
+}
+
+func newTestPersistedConfirmationManager(t *testing.T, tsp *testStatePersistence) (*blockConfirmationManager, *ffcapimocks.API) {
+	bcm, mca := newTestBlockConfirmationManager(t, true)
+	bcm.persistence = tsp
+	return bcm, mca
+}
+
+func mockBlockNotFound(mca *ffcapimocks.API, blockNumber int64) *mock.Call {
+	return mca.On("BlockInfoByNumber", mock.Anything, mock.MatchedBy(func(r *ffcapi.BlockInfoByNumberRequest) bool {
+		return r.BlockNumber.Int64() == blockNumber
+	})).Return(nil, ffcapi.ErrorReasonNotFound, fmt.Errorf("not found"))
+}
+
+func testEventNotification(listenerID *fftypes.UUID) *Notification {
+	return &Notification{
+		NotificationType: NewEventLog,
+		Event: &EventInfo{
+			ID: &ffcapi.EventID{
+				ListenerID:      listenerID,
+				TransactionHash: "0x111",
+				BlockHash:       "0xb1",
+				BlockNumber:     1001,
+			},
+			Confirmed: func(ctx context.Context, confirmations []apitypes.BlockInfo) {},
+		},
+	}
+}
+
+func TestPersistAndRestoreTransaction(t *testing.T) {
+	tsp := newTestStatePersistence()
+
+	// Persist the state of a mined transaction, with one confirmation
+	bcm1, _ := newTestPersistedConfirmationManager(t, tsp)
+	receipt := &ffcapi.TransactionReceiptResponse{
+		BlockNumber: fftypes.NewFFBigInt(1001),
+		BlockHash:   "0xb1",
+		Success:     true,
+	}
+	item := &pendingItem{pType: pendingTypeTransaction, transactionHash: "0x111", progressReported: -1}
+	bcm1.addOrReplaceItem(item)
+	item.blockNumber = 1001
+	item.blockHash = "0xb1"
+	item.receipt = receipt
+	item.confirmations = []*apitypes.BlockInfo{
+		{BlockNumber: 1002, BlockHash: "0xb2", ParentHash: "0xb1", TransactionHashes: []string{"0xaaa"}},
+	}
+	bcm1.markDirty(item.getKey())
+	bcm1.persistState()
+	assert.Empty(t, bcm1.dirty)
+	state := tsp.states[item.getKey()]
+	assert.Equal(t, "0xb1", state.BlockHash)
+	assert.Equal(t, []apitypes.BlockInfo{{BlockNumber: 1002, BlockHash: "0xb2", ParentHash: "0xb1"}}, state.Confirmations)
+
+	// Restore it in a new manager, where the transaction is notified again
+	bcm2, mca := newTestPersistedConfirmationManager(t, tsp)
+	bcm2.restoreState()
+	assert.Len(t, bcm2.restored, 1)
+
+	mockBlockByNumber(mca, 1002, "0xb2", "0xb1").Once() // validates the restored confirmations
+	mockBlockByNumber(mca, 1003, "0xb3", "0xb2").Once()
+	mockBlockNotFound(mca, 1004)
+
+	var restoredReceipt *ffcapi.TransactionReceiptResponse
+	var progress *ConfirmationProgress
+	err := bcm2.processNotifications([]*Notification{{
+		NotificationType: NewTransaction,
+		Transaction: &TransactionInfo{
+			TransactionHash: "0x111",
+			Receipt: func(ctx context.Context, receipt *ffcapi.TransactionReceiptResponse) {
+				restoredReceipt = receipt
+			},
+			Progress: func(ctx context.Context, p *ConfirmationProgress) {
+				progress = p
+			},
+			Confirmed: func(ctx context.Context, confirmations []apitypes.BlockInfo) {},
+		},
+	}}, bcm2.newBlockState())
+	assert.NoError(t, err)
+
+	// The receipt is not queried again
+	assert.Equal(t, receipt, restoredReceipt)
+	assert.Empty(t, bcm2.staleReceipts)
+	assert.Empty(t, bcm2.restored)
+	assert.Equal(t, 2, progress.Count)
+	assert.Equal(t, "0xb3", progress.LatestBlock.BlockHash)
+
+	bcm2.persistState()
+	assert.Len(t, tsp.states[item.getKey()].Confirmations, 2)
+
+	mca.AssertExpectations(t)
+}
+
+func TestRestoreTransactionConfirmed(t *testing.T) {
+	tsp := newTestStatePersistence()
+	tsp.states[pendingKeyForTX("0x111")] = &persistence.ConfirmationState{
+		Key:             pendingKeyForTX("0x111"),
+		TransactionHash: "0x111",
+		BlockNumber:     1001,
+		BlockHash:       "0xb1",
+		Receipt:         &ffcapi.TransactionReceiptResponse{BlockHash: "0xb1"},
+		Confirmations:   []apitypes.BlockInfo{{BlockNumber: 1002, BlockHash: "0xb2", ParentHash: "0xb1"}},
+	}
+
+	bcm, mca := newTestPersistedConfirmationManager(t, tsp)
+	bcm.restoreState()
+
+	mockBlockByNumber(mca, 1002, "0xb2", "0xb1").Once()
+
+	one := 1
+	confirmed := make(chan []apitypes.BlockInfo, 1)
+	err := bcm.processNotifications([]*Notification{{
+		NotificationType: NewTransaction,
+		Transaction: &TransactionInfo{
+			TransactionHash: "0x111",
+			Confirmations:   &one,
+			Confirmed: func(ctx context.Context, confirmations []apitypes.BlockInfo) {
+				confirmed <- confirmations
+			},
+		},
+	}}, bcm.newBlockState())
+	assert.NoError(t, err)
+	assert.Len(t, <-confirmed, 1)
+	assert.Empty(t, bcm.pending)
+
+	// The persisted state is removed, now the transaction is confirmed
+	bcm.persistState()
+	assert.Empty(t, tsp.states)
+
+	mca.AssertExpectations(t)
+}
+
+func TestRestoreTransactionFinalityMode(t *testing.T) {
+	tsp := newTestStatePersistence()
+	tsp.states[pendingKeyForTX("0x111")] = &persistence.ConfirmationState{
+		Key:             pendingKeyForTX("0x111"),
+		TransactionHash: "0x111",
+		BlockNumber:     1001,
+		BlockHash:       "0xb1",
+		Receipt:         &ffcapi.TransactionReceiptResponse{BlockHash: "0xb1"},
+	}
+
+	bcm, mca, mfa := newTestFinalityConfirmationManager(t)
+	bcm.persistence = tsp
+	bcm.restoreState()
+
+	mockBlockByNumber(mca, 1001, "0xb1", "0xb0")
+	mfa.On("LatestFinalizedBlock", mock.Anything, mock.Anything).Return(&ffcapi.LatestFinalizedBlockResponse{
+		BlockNumber: fftypes.NewFFBigInt(1001),
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	confirmed := make(chan []apitypes.BlockInfo, 1)
+	blocks := bcm.newBlockState()
+	err := bcm.processNotifications([]*Notification{{
+		NotificationType: NewTransaction,
+		Transaction: &TransactionInfo{
+			TransactionHash: "0x111",
+			Confirmed: func(ctx context.Context, confirmations []apitypes.BlockInfo) {
+				confirmed <- confirmations
+			},
+		},
+	}}, blocks)
+	assert.NoError(t, err)
+	assert.False(t, bcm.staleReceipts[pendingKeyForTX("0x111")])
+
+	// The restored transaction is final, but is only released by the walk at the end of the cycle
+	assert.Len(t, bcm.pending, 1)
+	err = bcm.walkChain(blocks)
+	assert.NoError(t, err)
+	assert.Empty(t, <-confirmed)
+	assert.Empty(t, bcm.pending)
+
+	mca.AssertExpectations(t)
+	mfa.AssertExpectations(t)
+}
+
+func TestRestoreTransactionValidateFail(t *testing.T) {
+	tsp := newTestStatePersistence()
+	tsp.states[pendingKeyForTX("0x111")] = &persistence.ConfirmationState{
+		Key:             pendingKeyForTX("0x111"),
+		TransactionHash: "0x111",
+		BlockNumber:     1001,
+		BlockHash:       "0xb1",
+	}
+
+	bcm, mca := newTestPersistedConfirmationManager(t, tsp)
+	bcm.restoreState()
+
+	mca.On("BlockInfoByNumber", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+
+	err := bcm.processNotifications([]*Notification{{
+		NotificationType: NewTransaction,
+		Transaction: &TransactionInfo{
+			TransactionHash: "0x111",
+		},
+	}}, bcm.newBlockState())
+	assert.NoError(t, err)
+
+	// Falls back to querying the receipt
+	assert.True(t, bcm.staleReceipts[pendingKeyForTX("0x111")])
+	assert.Len(t, bcm.restored, 1)
+
+	mca.AssertExpectations(t)
+}
+
+func TestRestoreEventNotCanonical(t *testing.T) {
+	tsp := newTestStatePersistence()
+	n := testEventNotification(fftypes.NewUUID())
+	pendingKey := n.eventPendingItem().getKey()
+	tsp.states[pendingKey] = &persistence.ConfirmationState{
+		Key:             pendingKey,
+		ListenerID:      n.Event.ID.ListenerID,
+		TransactionHash: "0x111",
+		BlockNumber:     1001,
+		BlockHash:       "0xb1",
+		Confirmations:   []apitypes.BlockInfo{{BlockNumber: 1002, BlockHash: "0xb2", ParentHash: "0xb1"}},
+	}
+
+	bcm, mca := newTestPersistedConfirmationManager(t, tsp)
+	bcm.restoreState()
+
+	// Block 1002 has been replaced since the state was persisted
+	mockBlockByNumber(mca, 1002, "0xc2", "0xb1").Once()
+	mockBlockNotFound(mca, 1003)
+
+	err := bcm.processNotifications([]*Notification{n}, bcm.newBlockState())
+	assert.NoError(t, err)
+
+	// The confirmations are rebuilt from the chain
+	assert.Equal(t, "0xc2", bcm.pending[pendingKey].confirmations[0].BlockHash)
+	bcm.persistState()
+	assert.Equal(t, "0xc2", tsp.states[pendingKey].Confirmations[0].BlockHash)
+
+	mca.AssertExpectations(t)
+}
+
+func TestRestoreEventValidateFail(t *testing.T) {
+	tsp := newTestStatePersistence()
+	n := testEventNotification(fftypes.NewUUID())
+	pendingKey := n.eventPendingItem().getKey()
+	tsp.states[pendingKey] = &persistence.ConfirmationState{
+		Key:         pendingKey,
+		BlockNumber: 1001,
+		BlockHash:   "0xb1",
+	}
+
+	bcm, mca := newTestPersistedConfirmationManager(t, tsp)
+	bcm.restoreState()
+
+	mca.On("BlockInfoByNumber", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+
+	err := bcm.processNotifications([]*Notification{n}, bcm.newBlockState())
+	assert.Regexp(t, "pop", err)
+
+	mca.AssertExpectations(t)
+}
+
+func TestRestoreStateListFail(t *testing.T) {
+	tsp := newTestStatePersistence()
+	tsp.listErr = fmt.Errorf("pop")
+
+	bcm, _ := newTestPersistedConfirmationManager(t, tsp)
+	bcm.restoreState()
+	assert.Empty(t, bcm.restored)
+	assert.Empty(t, bcm.persisted)
+}
+
+func TestRestoredStateExpired(t *testing.T) {
+	tsp := newTestStatePersistence()
+	tsp.states[pendingKeyForTX("0x111")] = &persistence.ConfirmationState{
+		Key:             pendingKeyForTX("0x111"),
+		TransactionHash: "0x111",
+	}
+
+	bcm, _ := newTestPersistedConfirmationManager(t, tsp)
+	bcm.restoreTimeout = 0
+	bcm.restoreState()
+	assert.Len(t, bcm.restored, 1)
+
+	time.Sleep(1 * time.Millisecond)
+	bcm.expireRestoredState()
+	assert.Empty(t, bcm.restored)
+
+	bcm.persistState()
+	assert.Empty(t, tsp.states)
+}
+
+func TestRestoredStateListenerRemoved(t *testing.T) {
+	tsp := newTestStatePersistence()
+	n := testEventNotification(fftypes.NewUUID())
+	pendingKey := n.eventPendingItem().getKey()
+	tsp.states[pendingKey] = &persistence.ConfirmationState{
+		Key:        pendingKey,
+		ListenerID: n.Event.ID.ListenerID,
+	}
+
+	bcm, _ := newTestPersistedConfirmationManager(t, tsp)
+	bcm.restoreState()
+
+	completed := make(chan struct{})
+	bcm.listenerRemoved(&Notification{
+		NotificationType: ListenerRemoved,
+		RemovedListener: &RemovedListenerInfo{
+			ListenerID: n.Event.ID.ListenerID,
+			Completed:  completed,
+		},
+	})
+	<-completed
+	assert.Empty(t, bcm.restored)
+
+	bcm.persistState()
+	assert.Empty(t, tsp.states)
+}
+
+func TestPersistStateFailRetried(t *testing.T) {
+	tsp := newTestStatePersistence()
+	bcm, _ := newTestPersistedConfirmationManager(t, tsp)
+
+	item := &pendingItem{pType: pendingTypeTransaction, transactionHash: "0x111"}
+	bcm.addOrReplaceItem(item)
+	item.receipt = &ffcapi.TransactionReceiptResponse{BlockHash: "0xb1"}
+	pendingKey := item.getKey()
+	bcm.markDirty(pendingKey)
+
+	tsp.writeErr = fmt.Errorf("pop")
+	bcm.persistState()
+	assert.True(t, bcm.dirty[pendingKey])
+	assert.Empty(t, tsp.states)
+
+	tsp.writeErr = nil
+	bcm.persistState()
+	assert.Empty(t, bcm.dirty)
+	assert.Len(t, tsp.states, 1)
+
+	bcm.removeItem(pendingKey, false)
+	tsp.writeErr = fmt.Errorf("pop")
+	bcm.persistState()
+	assert.True(t, bcm.dirty[pendingKey])
+	assert.Len(t, tsp.states, 1)
+
+	tsp.writeErr = nil
+	bcm.persistState()
+	assert.Empty(t, bcm.dirty)
+	assert.Empty(t, tsp.states)
+}
+
+func TestPersistStateOnlyChangedItems(t *testing.T) {
+	tsp := newTestStatePersistence()
+	bcm, mca := newTestPersistedConfirmationManager(t, tsp)
+
+	n := testEventNotification(fftypes.NewUUID())
+	item := n.eventPendingItem()
+	bcm.addOrReplaceItem(item)
+
+	mockBlockByNumber(mca, 1002, "0xb2", "0xb1")
+	mockBlockNotFound(mca, 1003)
+
+	err := bcm.walkChain(bcm.newBlockState())
+	assert.NoError(t, err)
+	bcm.persistState()
+	assert.Equal(t, 1, tsp.writes)
+	assert.Len(t, tsp.states[item.getKey()].Confirmations, 1)
+
+	// Walking the chain again finds the same confirmations, so nothing is written
+	err = bcm.walkChain(bcm.newBlockState())
+	assert.NoError(t, err)
+	assert.Empty(t, bcm.dirty)
+	bcm.persistState()
+	assert.Equal(t, 1, tsp.writes)
+
+	// A notification for the block that is already the latest confirmation does not change anything
+	bcm.processBlock(&apitypes.BlockInfo{BlockNumber: 1002, BlockHash: "0xb2", ParentHash: "0xb1"})
+	assert.Empty(t, bcm.dirty)
+
+	// The next block does
+	bcm.processBlock(&apitypes.BlockInfo{BlockNumber: 1003, BlockHash: "0xb3", ParentHash: "0xb2"})
+	assert.True(t, bcm.dirty[item.getKey()])
+	bcm.persistState()
+	assert.Equal(t, 2, tsp.writes)
+	assert.Len(t, tsp.states[item.getKey()].Confirmations, 2)
+
+	mca.AssertExpectations(t)
+}
@@ -0,0 +1,207 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package confirmations
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+)
+
+// ReorgReporter is notified of each reorg detected by a confirmation manager. It is called on the goroutine
+// of the confirmation manager, so must not block.
+type ReorgReporter interface {
+	ReorgDetected(reorg *apitypes.Reorg)
+}
+
+// ReorgHistory keeps the most recent reorgs reported by all the confirmation managers, and counts every reorg
+type ReorgHistory interface {
+	ReorgReporter
+	List() []*apitypes.Reorg
+	Metrics() *apitypes.ReorgMetrics
+}
+
+type reorgHistory struct {
+	mux      sync.Mutex
+	capacity int
+	reorgs   []*apitypes.Reorg // oldest first
+	metrics  apitypes.ReorgMetrics
+}
+
+func NewReorgHistory(capacity int) ReorgHistory {
+	return &reorgHistory{
+		capacity: capacity,
+		reorgs:   make([]*apitypes.Reorg, 0),
+		metrics: apitypes.ReorgMetrics{
+			ByDepth:   make(map[int]uint64),
+			ByManager: make(map[string]uint64),
+		},
+	}
+}
+
+func (rh *reorgHistory) ReorgDetected(reorg *apitypes.Reorg) {
+	rh.mux.Lock()
+	defer rh.mux.Unlock()
+	if rh.capacity > 0 {
+		if len(rh.reorgs) >= rh.capacity {
+			rh.reorgs = append(rh.reorgs[:0], rh.reorgs[len(rh.reorgs)-rh.capacity+1:]...)
+		}
+		rh.reorgs = append(rh.reorgs, reorg)
+	}
+	rh.metrics.Total++
+	if reorg.Depth > rh.metrics.MaxDepth {
+		rh.metrics.MaxDepth = reorg.Depth
+	}
+	rh.metrics.ByDepth[reorg.Depth]++
+	rh.metrics.ByManager[reorg.Manager]++
+	rh.metrics.LastDetected = reorg.Detected
+}
+
+// List returns the reorgs in the history, most recent first
+func (rh *reorgHistory) List() []*apitypes.Reorg {
+	rh.mux.Lock()
+	defer rh.mux.Unlock()
+	reorgs := make([]*apitypes.Reorg, len(rh.reorgs))
+	for i, reorg := range rh.reorgs {
+		reorgs[len(rh.reorgs)-1-i] = reorg
+	}
+	return reorgs
+}
+
+func (rh *reorgHistory) Metrics() *apitypes.ReorgMetrics {
+	rh.mux.Lock()
+	defer rh.mux.Unlock()
+	metrics := rh.metrics
+	metrics.ByDepth = make(map[int]uint64, len(rh.metrics.ByDepth))
+	for depth, count := range rh.metrics.ByDepth {
+		metrics.ByDepth[depth] = count
+	}
+	metrics.ByManager = make(map[string]uint64, len(rh.metrics.ByManager))
+	for manager, count := range rh.metrics.ByManager {
+		metrics.ByManager[manager] = count
+	}
+	return &metrics
+}
+
+// recordReorg notes that a pending item was affected by a reorg, to be reported at the end of the cycle.
+// Items affected by the same reorg in a cycle share a record, keyed on the lowest block number that changed.
+func (bcm *blockConfirmationManager) recordReorg(pending *pendingItem, blockNumber uint64, oldHashes, newHashes []string) {
+	log.L(bcm.ctx).Warnf("Reorg at block %d depth=%d old=%v new=%v item=%s", blockNumber, len(oldHashes), oldHashes, newHashes, pending.getKey())
+	reorg := bcm.reorgs[blockNumber]
+	if reorg == nil {
+		reorg = &apitypes.Reorg{
+			ID:          fftypes.NewUUID(),
+			Detected:    fftypes.Now(),
+			Manager:     bcm.managerID,
+			BlockNumber: fftypes.FFuint64(blockNumber),
+			OldHashes:   []string{},
+			NewHashes:   []string{},
+		}
+		bcm.reorgs[blockNumber] = reorg
+	}
+	// Items can have counted different numbers of blocks, so we keep the longest view of each side
+	if len(oldHashes) > len(reorg.OldHashes) {
+		reorg.OldHashes = oldHashes
+		reorg.Depth = len(oldHashes)
+	}
+	if len(newHashes) > len(reorg.NewHashes) {
+		reorg.NewHashes = newHashes
+	}
+	switch pending.pType {
+	case pendingTypeEvent:
+		for _, listenerID := range reorg.Listeners {
+			if listenerID.Equals(pending.listenerID) {
+				return
+			}
+		}
+		reorg.Listeners = append(reorg.Listeners, pending.listenerID)
+	case pendingTypeTransaction:
+		for _, txHash := range reorg.Transactions {
+			if txHash == pending.transactionHash {
+				return
+			}
+		}
+		reorg.Transactions = append(reorg.Transactions, pending.transactionHash)
+	}
+}
+
+// detectReorg compares the confirmations an item had before walking the chain, with the blocks found by the walk
+func (bcm *blockConfirmationManager) detectReorg(pending *pendingItem, previous []*apitypes.BlockInfo, blocks *blockState) {
+	for i, old := range previous {
+		current := blocks.blocks[old.BlockNumber.Uint64()]
+		if current == nil {
+			// The walk did not get this far, so we cannot tell
+			return
+		}
+		if current.BlockHash != old.BlockHash {
+			newHashes := []string{}
+			for _, replaced := range previous[i:] {
+				block := blocks.blocks[replaced.BlockNumber.Uint64()]
+				if block == nil {
+					break
+				}
+				newHashes = append(newHashes, block.BlockHash)
+			}
+			bcm.recordReorg(pending, old.BlockNumber.Uint64(), hashesOf(previous[i:]), newHashes)
+			return
+		}
+	}
+}
+
+// reportReorgs passes the reorgs detected in this cycle to the reporter, in block number order
+func (bcm *blockConfirmationManager) reportReorgs() {
+	if len(bcm.reorgs) == 0 {
+		return
+	}
+	reorgs := make([]*apitypes.Reorg, 0, len(bcm.reorgs))
+	for _, reorg := range bcm.reorgs {
+		reorgs = append(reorgs, reorg)
+	}
+	sort.Slice(reorgs, func(i, j int) bool {
+		return reorgs[i].BlockNumber < reorgs[j].BlockNumber
+	})
+	bcm.reorgs = make(map[uint64]*apitypes.Reorg)
+	if bcm.reorgReporter != nil {
+		for _, reorg := range reorgs {
+			bcm.reorgReporter.ReorgDetected(reorg)
+		}
+	}
+}
+
+func hashesOf(blocks []*apitypes.BlockInfo) []string {
+	hashes := make([]string, len(blocks))
+	for i, block := range blocks {
+		hashes[i] = block.BlockHash
+	}
+	return hashes
+}
+
+// sameBlocks returns true if two lists of confirmations contain the same blocks
+func sameBlocks(a, b []*apitypes.BlockInfo) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].BlockHash != b[i].BlockHash {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,243 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package confirmations
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type testReorgReporter struct {
+	reorgs []*apitypes.Reorg
+}
+
+func (trr *testReorgReporter) ReorgDetected(reorg *apitypes.Reorg) {
+	trr.reorgs = append(trr.reorgs, reorg)
+}
+
+func newTestReorgEvent(listenerID *fftypes.UUID, logIndex uint64, confirmations ...*apitypes.BlockInfo) *pendingItem {
+	return &pendingItem{
+		pType:             pendingTypeEvent,
+		listenerID:        listenerID,
+		transactionHash:   "0x531e219d98d81dc9f9a14811ac537479f5d77a74bdba47629bfbebe2d7663ce7",
+		blockHash:         "0x1001",
+		blockNumber:       1001,
+		logIndex:          logIndex,
+		confirmations:     confirmations,
+		confirmedCallback: func(ctx context.Context, confirmations []apitypes.BlockInfo) {},
+	}
+}
+
+func TestReorgHistory(t *testing.T) {
+	rh := NewReorgHistory(2)
+
+	for i := 1; i <= 3; i++ {
+		rh.ReorgDetected(&apitypes.Reorg{
+			ID:          fftypes.NewUUID(),
+			Detected:    fftypes.Now(),
+			Manager:     "receipts",
+			BlockNumber: fftypes.FFuint64(1000 + i),
+			Depth:       i % 2,
+		})
+	}
+
+	reorgs := rh.List()
+	assert.Len(t, reorgs, 2)
+	assert.Equal(t, fftypes.FFuint64(1003), reorgs[0].BlockNumber)
+	assert.Equal(t, fftypes.FFuint64(1002), reorgs[1].BlockNumber)
+
+	metrics := rh.Metrics()
+	assert.Equal(t, uint64(3), metrics.Total)
+	assert.Equal(t, 1, metrics.MaxDepth)
+	assert.Equal(t, map[int]uint64{0: 1, 1: 2}, metrics.ByDepth)
+	assert.Equal(t, map[string]uint64{"receipts": 3}, metrics.ByManager)
+	assert.Equal(t, reorgs[0].Detected, metrics.LastDetected)
+
+	// The metrics returned are a copy
+	metrics.ByDepth[5] = 1
+	assert.Equal(t, 1, rh.Metrics().MaxDepth)
+	assert.Len(t, rh.Metrics().ByDepth, 2)
+}
+
+func TestReorgHistoryCountOnly(t *testing.T) {
+	rh := NewReorgHistory(0)
+	rh.ReorgDetected(&apitypes.Reorg{Manager: "receipts", Depth: 3})
+	assert.Empty(t, rh.List())
+	assert.Equal(t, uint64(1), rh.Metrics().Total)
+	assert.Equal(t, 3, rh.Metrics().MaxDepth)
+}
+
+func TestProcessBlockReorg(t *testing.T) {
+	bcm, _ := newTestBlockConfirmationManager(t, false)
+	reporter := &testReorgReporter{}
+	bcm.reorgReporter = reporter
+
+	block1002 := &apitypes.BlockInfo{BlockNumber: 1002, BlockHash: "0x1002", ParentHash: "0x1001"}
+	block1003a := &apitypes.BlockInfo{BlockNumber: 1003, BlockHash: "0x1003a", ParentHash: "0x1002"}
+	block1003b := &apitypes.BlockInfo{BlockNumber: 1003, BlockHash: "0x1003b", ParentHash: "0x1002"}
+
+	// Two events for the same listener, and one for another listener that has not counted block 1003a yet
+	listener1 := fftypes.NewUUID()
+	listener2 := fftypes.NewUUID()
+	bcm.pending["e1"] = newTestReorgEvent(listener1, 1, block1002, block1003a)
+	bcm.pending["e2"] = newTestReorgEvent(listener1, 2, block1002, block1003a)
+	bcm.pending["e3"] = newTestReorgEvent(listener2, 3, block1002)
+
+	// Notification of a block we have already counted is not a reorg, and neither is a new confirmation
+	bcm.processBlock(block1003a)
+	bcm.reportReorgs()
+	assert.Empty(t, reporter.reorgs)
+
+	bcm.processBlock(block1003b)
+	bcm.reportReorgs()
+	assert.Len(t, reporter.reorgs, 1)
+	reorg := reporter.reorgs[0]
+	assert.NotNil(t, reorg.ID)
+	assert.NotNil(t, reorg.Detected)
+	assert.Equal(t, "ut", reorg.Manager)
+	assert.Equal(t, fftypes.FFuint64(1003), reorg.BlockNumber)
+	assert.Equal(t, 1, reorg.Depth)
+	assert.Equal(t, []string{"0x1003a"}, reorg.OldHashes)
+	assert.Equal(t, []string{"0x1003b"}, reorg.NewHashes)
+	assert.ElementsMatch(t, []*fftypes.UUID{listener1, listener2}, reorg.Listeners)
+	assert.Empty(t, reorg.Transactions)
+	assert.Equal(t, []*apitypes.BlockInfo{block1002, block1003b}, bcm.pending["e1"].confirmations)
+
+	// Nothing left to report
+	bcm.reportReorgs()
+	assert.Len(t, reporter.reorgs, 1)
+}
+
+func TestWalkChainForItemReorg(t *testing.T) {
+	bcm, mca := newTestBlockConfirmationManager(t, false)
+	reporter := &testReorgReporter{}
+	bcm.reorgReporter = reporter
+
+	listenerID := fftypes.NewUUID()
+	pending := newTestReorgEvent(listenerID, 1,
+		&apitypes.BlockInfo{BlockNumber: 1002, BlockHash: "0x1002a", ParentHash: "0x1001"},
+		&apitypes.BlockInfo{BlockNumber: 1003, BlockHash: "0x1003a", ParentHash: "0x1002a"},
+	)
+	bcm.pending[pending.getKey()] = pending
+
+	mca.On("BlockInfoByNumber", mock.Anything, mock.MatchedBy(func(r *ffcapi.BlockInfoByNumberRequest) bool {
+		return r.BlockNumber.Uint64() == 1002
+	})).Return(&ffcapi.BlockInfoByNumberResponse{
+		BlockInfo: ffcapi.BlockInfo{BlockNumber: fftypes.NewFFBigInt(1002), BlockHash: "0x1002b", ParentHash: "0x1001"},
+	}, ffcapi.ErrorReason(""), nil)
+	mca.On("BlockInfoByNumber", mock.Anything, mock.MatchedBy(func(r *ffcapi.BlockInfoByNumberRequest) bool {
+		return r.BlockNumber.Uint64() == 1003
+	})).Return(&ffcapi.BlockInfoByNumberResponse{
+		BlockInfo: ffcapi.BlockInfo{BlockNumber: fftypes.NewFFBigInt(1003), BlockHash: "0x1003b", ParentHash: "0x1002b"},
+	}, ffcapi.ErrorReason(""), nil)
+	mca.On("BlockInfoByNumber", mock.Anything, mock.MatchedBy(func(r *ffcapi.BlockInfoByNumberRequest) bool {
+		return r.BlockNumber.Uint64() == 1004
+	})).Return(nil, ffcapi.ErrorReasonNotFound, fmt.Errorf("not found"))
+
+	err := bcm.walkChainForItem(pending, bcm.newBlockState())
+	assert.NoError(t, err)
+	bcm.reportReorgs()
+
+	assert.Len(t, reporter.reorgs, 1)
+	reorg := reporter.reorgs[0]
+	assert.Equal(t, fftypes.FFuint64(1002), reorg.BlockNumber)
+	assert.Equal(t, 2, reorg.Depth)
+	assert.Equal(t, []string{"0x1002a", "0x1003a"}, reorg.OldHashes)
+	assert.Equal(t, []string{"0x1002b", "0x1003b"}, reorg.NewHashes)
+	assert.Equal(t, []*fftypes.UUID{listenerID}, reorg.Listeners)
+
+	mca.AssertExpectations(t)
+}
+
+func TestWalkChainForItemNoReorg(t *testing.T) {
+	bcm, mca := newTestBlockConfirmationManager(t, false)
+	reporter := &testReorgReporter{}
+	bcm.reorgReporter = reporter
+
+	pending := newTestReorgEvent(fftypes.NewUUID(), 1,
+		&apitypes.BlockInfo{BlockNumber: 1002, BlockHash: "0x1002", ParentHash: "0x1001"},
+	)
+	bcm.pending[pending.getKey()] = pending
+
+	// The chain is the same as far as we can walk it
+	mca.On("BlockInfoByNumber", mock.Anything, mock.MatchedBy(func(r *ffcapi.BlockInfoByNumberRequest) bool {
+		return r.BlockNumber.Uint64() == 1002
+	})).Return(nil, ffcapi.ErrorReasonNotFound, fmt.Errorf("not found"))
+
+	err := bcm.walkChainForItem(pending, bcm.newBlockState())
+	assert.NoError(t, err)
+	bcm.reportReorgs()
+	assert.Empty(t, reporter.reorgs)
+
+	mca.AssertExpectations(t)
+}
+
+func TestCheckReceiptTransactionMovedReorg(t *testing.T) {
+	bcm, mca := newTestBlockConfirmationManager(t, false)
+	reporter := &testReorgReporter{}
+	bcm.reorgReporter = reporter
+
+	txHash := "0x1dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347"
+	pending := &pendingItem{
+		pType:             pendingTypeTransaction,
+		transactionHash:   txHash,
+		blockHash:         "0x1001a",
+		blockNumber:       1001,
+		confirmations:     []*apitypes.BlockInfo{{BlockNumber: 1002, BlockHash: "0x1002a", ParentHash: "0x1001a"}},
+		confirmedCallback: func(ctx context.Context, confirmations []apitypes.BlockInfo) {},
+	}
+	bcm.pending[pending.getKey()] = pending
+
+	mca.On("TransactionReceipt", mock.Anything, mock.Anything).Return(&ffcapi.TransactionReceiptResponse{
+		BlockHash:   "0x1001b",
+		BlockNumber: fftypes.NewFFBigInt(1001),
+		Success:     true,
+	}, ffcapi.ErrorReason(""), nil)
+	mca.On("BlockInfoByNumber", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonNotFound, fmt.Errorf("not found"))
+
+	bcm.checkReceipt(pending, bcm.newBlockState())
+	bcm.reportReorgs()
+
+	assert.Len(t, reporter.reorgs, 1)
+	reorg := reporter.reorgs[0]
+	assert.Equal(t, fftypes.FFuint64(1001), reorg.BlockNumber)
+	assert.Equal(t, 2, reorg.Depth)
+	assert.Equal(t, []string{"0x1001a", "0x1002a"}, reorg.OldHashes)
+	assert.Equal(t, []string{"0x1001b"}, reorg.NewHashes)
+	assert.Equal(t, []string{txHash}, reorg.Transactions)
+	assert.Empty(t, reorg.Listeners)
+	assert.Empty(t, pending.confirmations)
+	assert.Equal(t, "0x1001b", pending.blockHash)
+
+	mca.AssertExpectations(t)
+}
+
+func TestReportReorgsNoReporter(t *testing.T) {
+	bcm, _ := newTestBlockConfirmationManager(t, false)
+
+	bcm.recordReorg(newTestReorgEvent(fftypes.NewUUID(), 1), 1002, []string{"0x1002a"}, []string{"0x1002b"})
+	assert.Len(t, bcm.reorgs, 1)
+	bcm.reportReorgs()
+	assert.Empty(t, bcm.reorgs)
+}
@@ -59,6 +59,7 @@ var esDefaults struct {
 	blockedRetryDelay         fftypes.FFDuration
 	webhookRequestTimeout     fftypes.FFDuration
 	websocketDistributionMode apitypes.DistributionMode
+	confirmations             int64
 	retry                     *retry.Retry
 }
 
@@ -70,6 +71,7 @@ func InitDefaults() {
 	esDefaults.blockedRetryDelay = fftypes.FFDuration(config.GetDuration(tmconfig.EventStreamsDefaultsBlockedRetryDelay))
 	esDefaults.webhookRequestTimeout = fftypes.FFDuration(config.GetDuration(tmconfig.EventStreamsDefaultsWebhookRequestTimeout))
 	esDefaults.websocketDistributionMode = fftypes.FFEnum(config.GetString(tmconfig.EventStreamsDefaultsWebsocketDistributionMode))
+	esDefaults.confirmations = config.GetInt64(tmconfig.ConfirmationsRequired)
 	esDefaults.retry = &retry.Retry{
 		InitialDelay: config.GetDuration(tmconfig.EventStreamsRetryInitDelay),
 		MaximumDelay: config.GetDuration(tmconfig.EventStreamsRetryMaxDelay),
@@ -106,6 +108,8 @@ type eventStream struct {
 	connector          ffcapi.API
 	persistence        persistence.Persistence
 	confirmations      confirmations.Manager
+	blockCache         confirmations.BlockCache
+	reorgReporter      confirmations.ReorgReporter
 	listeners          map[fftypes.UUID]*listener
 	wsChannels         ws.WebSocketChannels
 	retry              *retry.Retry
@@ -118,6 +122,8 @@ func NewEventStream(
 	bgCtx context.Context,
 	persistedSpec *apitypes.EventStream,
 	connector ffcapi.API,
+	blockCache confirmations.BlockCache,
+	reorgReporter confirmations.ReorgReporter,
 	persistence persistence.Persistence,
 	wsChannels ws.WebSocketChannels,
 	initialListeners []*apitypes.Listener,
@@ -128,15 +134,14 @@ func NewEventStream(
 		status:             apitypes.EventStreamStatusStopped,
 		spec:               persistedSpec,
 		connector:          connector,
+		blockCache:         blockCache,
+		reorgReporter:      reorgReporter,
 		persistence:        persistence,
 		listeners:          make(map[fftypes.UUID]*listener),
 		wsChannels:         wsChannels,
 		retry:              esDefaults.retry,
 		checkpointInterval: config.GetDuration(tmconfig.EventStreamsCheckpointInterval),
 	}
-	if config.GetInt(tmconfig.ConfirmationsRequired) > 0 {
-		es.confirmations = confirmations.NewBlockConfirmationManager(esCtx, connector, "_es_"+persistedSpec.ID.String())
-	}
 	// The configuration we have in memory, applies all the defaults to what is passed in
 	// to ensure there are no nil fields on the configuration object.
 	if es.spec, _, err = mergeValidateEsConfig(esCtx, nil, persistedSpec); err != nil {
@@ -153,6 +158,7 @@ func NewEventStream(
 			spec: spec,
 		}
 	}
+	es.checkConfirmationsManager()
 	log.L(esCtx).Infof("Initialized Event Stream")
 	return es, nil
 }
@@ -232,6 +238,9 @@ func mergeValidateEsConfig(ctx context.Context, base *apitypes.EventStream, upda
 		changed = apitypes.CheckUpdateDuration(changed, &merged.BlockedRetryDelay, base.BlockedRetryDelay, updates.BlockedRetryDelay, esDefaults.blockedRetryDelay)
 	}
 
+	// Confirmations
+	changed = apitypes.CheckUpdateUint64(changed, &merged.Confirmations, base.Confirmations, updates.Confirmations, esDefaults.confirmations)
+
 	// Type
 	changed = apitypes.CheckUpdateEnum(changed, &merged.Type, base.Type, updates.Type, apitypes.EventStreamTypeWebSocket)
 	switch *merged.Type {
@@ -263,6 +272,9 @@ func (es *eventStream) UpdateSpec(ctx context.Context, updates *apitypes.EventSt
 	es.mux.Lock()
 	es.spec = merged
 	isStarted := es.status == apitypes.EventStreamStatusStarted
+	if !isStarted {
+		es.checkConfirmationsManager()
+	}
 	es.mux.Unlock()
 
 	if changed && isStarted {
@@ -277,6 +289,50 @@ func (es *eventStream) UpdateSpec(ctx context.Context, updates *apitypes.EventSt
 	return nil
 }
 
+// confirmationsFor returns the number of confirmations required for events from a listener
+func (es *eventStream) confirmationsFor(spec *apitypes.Listener) int {
+	if spec.Confirmations != nil {
+		return int(*spec.Confirmations)
+	}
+	return int(*es.spec.Confirmations)
+}
+
+// confirmationsManagerRequired checks whether the stream, or any of its listeners, requires confirmations.
+// Caller must hold the mux
+func (es *eventStream) confirmationsManagerRequired() bool {
+	if *es.spec.Confirmations > 0 {
+		return true
+	}
+	for _, l := range es.listeners {
+		if l.spec.Confirmations != nil && *l.spec.Confirmations > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// confirmationsManagerID identifies the confirmation manager of the stream, including its persisted state
+func (es *eventStream) confirmationsManagerID() string {
+	return "_es_" + es.spec.ID.String()
+}
+
+// checkConfirmationsManager creates or tears down the confirmation manager, if the confirmations required by
+// the stream or its listeners have changed. Caller must hold the mux, with the stream not started.
+func (es *eventStream) checkConfirmationsManager() {
+	switch {
+	case es.confirmationsManagerRequired() && es.confirmations == nil:
+		log.L(es.bgCtx).Infof("Creating confirmation manager for event stream %s", es)
+		es.confirmations = confirmations.NewBlockConfirmationManager(es.bgCtx, es.connector, es.confirmationsManagerID(), es.blockCache, es.reorgReporter, es.persistence)
+	case !es.confirmationsManagerRequired() && es.confirmations != nil:
+		log.L(es.bgCtx).Infof("Removing confirmation manager for event stream %s", es)
+		es.confirmations = nil
+		// Nothing will restore the persisted state of the manager, so it is removed along with it
+		if err := es.persistence.DeleteConfirmationStates(es.bgCtx, es.confirmationsManagerID()); err != nil {
+			log.L(es.bgCtx).Errorf("Failed to delete the confirmation state of event stream %s: %s", es, err)
+		}
+	}
+}
+
 func (es *eventStream) mergeListenerOptions(id *fftypes.UUID, updates *apitypes.Listener) *apitypes.Listener {
 
 	es.mux.Lock()
@@ -306,6 +362,10 @@ func (es *eventStream) mergeListenerOptions(id *fftypes.UUID, updates *apitypes.
 		merged.FromBlock = updates.FromBlock
 	}
 
+	if updates.Confirmations != nil {
+		merged.Confirmations = updates.Confirmations
+	}
+
 	if updates.Options != nil {
 		merged.Options = updates.Options
 	} else {
@@ -372,7 +432,9 @@ func (es *eventStream) AddOrUpdateListener(ctx context.Context, id *fftypes.UUID
 	if err != nil {
 		return nil, err
 	}
-	if reset {
+	// If the confirmation manager needs to be created or torn down for the listener, we restart the stream
+	restart := startedState != nil && es.confirmationsManagerChanged()
+	if reset || restart {
 		// Only safe to do the reset with the event stream stopped
 		if startedState != nil {
 			if err := es.Stop(ctx); err != nil {
@@ -380,8 +442,10 @@ func (es *eventStream) AddOrUpdateListener(ctx context.Context, id *fftypes.UUID
 			}
 		}
 		// Clear out the checkpoint for this listener
-		if err := es.resetListenerCheckpoint(ctx, l); err != nil {
-			return nil, err
+		if reset {
+			if err := es.resetListenerCheckpoint(ctx, l); err != nil {
+				return nil, err
+			}
 		}
 		// Restart if we were started
 		if startedState != nil {
@@ -396,6 +460,12 @@ func (es *eventStream) AddOrUpdateListener(ctx context.Context, id *fftypes.UUID
 	return spec, nil
 }
 
+func (es *eventStream) confirmationsManagerChanged() bool {
+	es.mux.Lock()
+	defer es.mux.Unlock()
+	return es.confirmationsManagerRequired() != (es.confirmations != nil)
+}
+
 func (es *eventStream) resetListenerCheckpoint(ctx context.Context, l *listener) error {
 	cp, err := es.persistence.GetCheckpoint(ctx, es.spec.ID)
 	if err != nil || cp == nil {
@@ -427,6 +497,9 @@ func (es *eventStream) lockedListenerUpdate(ctx context.Context, spec *apitypes.
 		}
 		es.listeners[*spec.ID] = l
 	}
+	if es.currentState == nil {
+		es.checkConfirmationsManager()
+	}
 	// Take a copy of the current started status, before unlocking
 	return !exists, l, es.currentState, nil
 }
@@ -482,6 +555,7 @@ func (es *eventStream) Start(ctx context.Context) error {
 	startedState.ctx, startedState.cancelCtx = context.WithCancel(es.bgCtx)
 	es.currentState = startedState
 	es.initAction(startedState)
+	es.checkConfirmationsManager()
 
 	cp, err := es.persistence.GetCheckpoint(ctx, es.spec.ID)
 	if err != nil {
@@ -560,7 +634,9 @@ func (es *eventStream) Stop(ctx context.Context) error {
 	}
 
 	// Stop the confirmations manager
-	es.confirmations.Stop()
+	if es.confirmations != nil {
+		es.confirmations.Stop()
+	}
 
 	// Wait for our event loop to stop
 	<-startedState.eventLoopDone
@@ -594,6 +670,9 @@ func (es *eventStream) Delete(ctx context.Context) error {
 	if err := es.persistence.DeleteCheckpoint(ctx, es.spec.ID); err != nil {
 		return err
 	}
+	if err := es.persistence.DeleteConfirmationStates(ctx, es.confirmationsManagerID()); err != nil {
+		return err
+	}
 	return es.checkSetStatus(ctx, apitypes.EventStreamStatusStopped, apitypes.EventStreamStatusDeleted)
 }
 
@@ -608,9 +687,10 @@ func (es *eventStream) processNewEvent(ctx context.Context, fev *ffcapi.Listener
 	es.mux.Unlock()
 	if l != nil {
 		log.L(ctx).Debugf("%s event detected: %s", l.spec.ID, event)
-		if es.confirmations == nil {
+		requiredConfirmations := es.confirmationsFor(l.spec)
+		if es.confirmations == nil || requiredConfirmations == 0 {
 			// Updates that are just a checkpoint update, go straight to the batch loop.
-			// Or if the confirmation manager is disabled.
+			// Or if the confirmation manager is disabled, or no confirmations are required for this listener.
 			// - Note this will block the eventLoop when the event stream is blocked
 			es.batchChannel <- fev
 		} else {
@@ -619,8 +699,9 @@ func (es *eventStream) processNewEvent(ctx context.Context, fev *ffcapi.Listener
 			err := es.confirmations.Notify(&confirmations.Notification{
 				NotificationType: confirmations.NewEventLog,
 				Event: &confirmations.EventInfo{
-					ID: &event.ID,
-					Confirmed: func(ctx context.Context, confirmations []confirmations.BlockInfo) {
+					ID:            &event.ID,
+					Confirmations: &requiredConfirmations,
+					Confirmed: func(ctx context.Context, confirmations []apitypes.BlockInfo) {
 						// Push it to the batch when confirmed
 						// - Note this will block the confirmation manager when the event stream is blocked
 						es.batchChannel <- fev
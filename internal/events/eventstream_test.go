@@ -76,6 +76,8 @@ func newTestEventStreamWithListener(t *testing.T, mfc *ffcapimocks.API, conf str
 	InitDefaults()
 	ees, err := NewEventStream(context.Background(), testESConf(t, conf),
 		mfc,
+		nil,
+		nil,
 		&persistencemocks.Persistence{},
 		&wsmocks.WebSocketChannels{},
 		listeners,
@@ -92,7 +94,7 @@ func newTestEventStreamWithListener(t *testing.T, mfc *ffcapimocks.API, conf str
 	mcm.On("Notify", mock.Anything).Run(func(args mock.Arguments) {
 		n := args[0].(*confirmations.Notification)
 		if n.Event != nil {
-			go n.Event.Confirmed(context.Background(), []confirmations.BlockInfo{})
+			go n.Event.Confirmed(context.Background(), []apitypes.BlockInfo{})
 		}
 	}).Return(nil).Maybe()
 	return es, err
@@ -111,6 +113,8 @@ func TestNewTestEventStreamMissingID(t *testing.T) {
 	InitDefaults()
 	_, err := NewEventStream(context.Background(), &apitypes.EventStream{},
 		&ffcapimocks.API{},
+		nil,
+		nil,
 		&persistencemocks.Persistence{},
 		&wsmocks.WebSocketChannels{},
 		[]*apitypes.Listener{},
@@ -123,6 +127,8 @@ func TestNewTestEventStreamBadConfig(t *testing.T) {
 	InitDefaults()
 	_, err := NewEventStream(context.Background(), testESConf(t, `{}`),
 		&ffcapimocks.API{},
+		nil,
+		nil,
 		&persistencemocks.Persistence{},
 		&wsmocks.WebSocketChannels{},
 		[]*apitypes.Listener{},
@@ -150,6 +156,7 @@ func TestConfigNewDefaultsUpdate(t *testing.T) {
 		"batchSize": 50,
 		"batchTimeout": "5s",
 		"blockedRetryDelay": "30s",
+		"confirmations": 20,
 		"errorHandling":"block",
 		"name":"test1",
 		"retryTimeout":"30s",
@@ -169,6 +176,7 @@ func TestConfigNewDefaultsUpdate(t *testing.T) {
 		"batchSize": 111,
 		"batchTimeoutMS": 222,
 		"blockedRetryDelaySec": 333,
+		"confirmations": 0,
 		"errorHandling": "skip",
 		"name": "test2",
 		"retryTimeoutSec": 444,
@@ -191,6 +199,7 @@ func TestConfigNewDefaultsUpdate(t *testing.T) {
 		"batchSize": 111,
 		"batchTimeout": "222ms",
 		"blockedRetryDelay": "5m33s",
+		"confirmations": 0,
 		"errorHandling":"skip",
 		"name":"test2",
 		"retryTimeout":"7m24s",
@@ -789,6 +798,8 @@ func TestUpdateListenerAndDeleteStarted(t *testing.T) {
 	msp.On("GetCheckpoint", mock.Anything, es.spec.ID).Return(nil, nil)
 	msp.On("DeleteCheckpoint", mock.Anything, es.spec.ID).Return(fmt.Errorf("pop")).Once()
 	msp.On("DeleteCheckpoint", mock.Anything, es.spec.ID).Return(nil)
+	msp.On("DeleteConfirmationStates", mock.Anything, "_es_"+es.spec.ID.String()).Return(fmt.Errorf("pop")).Once()
+	msp.On("DeleteConfirmationStates", mock.Anything, "_es_"+es.spec.ID.String()).Return(nil)
 
 	err := es.Start(es.bgCtx)
 	assert.NoError(t, err)
@@ -816,6 +827,9 @@ func TestUpdateListenerAndDeleteStarted(t *testing.T) {
 	err = es.Delete(es.bgCtx)
 	assert.Regexp(t, "pop", err)
 
+	err = es.Delete(es.bgCtx)
+	assert.Regexp(t, "pop", err)
+
 	err = es.Delete(es.bgCtx)
 	assert.NoError(t, err)
 
@@ -1137,6 +1151,7 @@ func TestResetListenerRestartFail(t *testing.T) {
 	}, nil)
 	msp.On("WriteCheckpoint", mock.Anything, mock.Anything).Return(nil)
 	msp.On("DeleteCheckpoint", mock.Anything, es.spec.ID).Return(nil)
+	msp.On("DeleteConfirmationStates", mock.Anything, "_es_"+es.spec.ID.String()).Return(nil)
 
 	err := es.Start(es.bgCtx)
 	assert.NoError(t, err)
@@ -1778,3 +1793,169 @@ func TestHWMCheckpointFail(t *testing.T) {
 	msp.AssertExpectations(t)
 	mcm.AssertExpectations(t)
 }
+
+func TestConfirmationsManagerLifecycle(t *testing.T) {
+	tmconfig.Reset()
+	InitDefaults()
+
+	mfc := &ffcapimocks.API{}
+	mfc.On("EventListenerVerifyOptions", mock.Anything, mock.Anything).Return(&ffcapi.EventListenerVerifyOptionsResponse{}, ffcapi.ErrorReason(""), nil)
+	ees, err := NewEventStream(context.Background(), testESConf(t, `{
+		"name": "ut_stream",
+		"confirmations": 0
+	}`),
+		mfc,
+		nil,
+		nil,
+		&persistencemocks.Persistence{},
+		&wsmocks.WebSocketChannels{},
+		[]*apitypes.Listener{},
+	)
+	assert.NoError(t, err)
+	es := ees.(*eventStream)
+	assert.Nil(t, es.confirmations)
+
+	// The persisted state is deleted each time the manager is torn down - logging any failure
+	msp := es.persistence.(*persistencemocks.Persistence)
+	msp.On("DeleteConfirmationStates", mock.Anything, "_es_"+es.spec.ID.String()).Return(nil).Once()
+	msp.On("DeleteConfirmationStates", mock.Anything, "_es_"+es.spec.ID.String()).Return(fmt.Errorf("pop")).Once()
+
+	// Created when the stream requires confirmations
+	confirmations := uint64(5)
+	err = es.UpdateSpec(context.Background(), &apitypes.EventStream{Confirmations: &confirmations})
+	assert.NoError(t, err)
+	assert.NotNil(t, es.confirmations)
+
+	// Torn down when it no longer does
+	noConfirmations := uint64(0)
+	err = es.UpdateSpec(context.Background(), &apitypes.EventStream{Confirmations: &noConfirmations})
+	assert.NoError(t, err)
+	assert.Nil(t, es.confirmations)
+
+	// Created when a listener requires confirmations
+	l := &apitypes.Listener{
+		ID:            fftypes.NewUUID(),
+		Name:          strPtr("ut_listener"),
+		Filters:       []fftypes.JSONAny{`{"event":"definition1"}`},
+		Confirmations: &confirmations,
+	}
+	_, err = es.AddOrUpdateListener(es.bgCtx, l.ID, l, false)
+	assert.NoError(t, err)
+	assert.NotNil(t, es.confirmations)
+	assert.Equal(t, 5, es.confirmationsFor(es.listeners[*l.ID].spec))
+
+	// Torn down when the listener no longer does
+	l.Confirmations = &noConfirmations
+	_, err = es.AddOrUpdateListener(es.bgCtx, l.ID, l, false)
+	assert.NoError(t, err)
+	assert.Nil(t, es.confirmations)
+
+	msp.AssertExpectations(t)
+}
+
+func TestAddListenerConfirmationsRestartsStream(t *testing.T) {
+	tmconfig.Reset()
+	InitDefaults()
+
+	mfc := &ffcapimocks.API{}
+	ees, err := NewEventStream(context.Background(), testESConf(t, `{
+		"name": "ut_stream",
+		"confirmations": 0
+	}`),
+		mfc,
+		nil,
+		nil,
+		&persistencemocks.Persistence{},
+		&wsmocks.WebSocketChannels{},
+		[]*apitypes.Listener{},
+	)
+	assert.NoError(t, err)
+	es := ees.(*eventStream)
+	assert.Nil(t, es.confirmations)
+
+	mfc.On("EventListenerVerifyOptions", mock.Anything, mock.Anything).Return(&ffcapi.EventListenerVerifyOptionsResponse{}, ffcapi.ErrorReason(""), nil)
+	mfc.On("EventStreamNewCheckpointStruct").Return(&utCheckpointType{}).Maybe()
+	started := make(chan *ffcapi.EventStreamStartRequest, 1)
+	mfc.On("EventStreamStart", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		started <- args[1].(*ffcapi.EventStreamStartRequest)
+	}).Return(&ffcapi.EventStreamStartResponse{}, ffcapi.ErrorReason(""), nil)
+	mfc.On("EventStreamStopped", mock.Anything, mock.Anything).Return(&ffcapi.EventStreamStoppedResponse{}, ffcapi.ErrorReason(""), nil)
+
+	msp := es.persistence.(*persistencemocks.Persistence)
+	msp.On("GetCheckpoint", mock.Anything, mock.Anything).Return(nil, nil) // no existing checkpoint
+	msp.On("ListConfirmationStates", mock.Anything, "_es_"+es.spec.ID.String()).Return(nil, nil).Maybe()
+
+	err = es.Start(es.bgCtx)
+	assert.NoError(t, err)
+	r := <-started
+	assert.Empty(t, r.InitialListeners)
+
+	confirmations := uint64(1)
+	l := &apitypes.Listener{
+		ID:            fftypes.NewUUID(),
+		Name:          strPtr("ut_listener"),
+		Filters:       []fftypes.JSONAny{`{"event":"definition1"}`},
+		Confirmations: &confirmations,
+	}
+	_, err = es.AddOrUpdateListener(es.bgCtx, l.ID, l, false)
+	assert.NoError(t, err)
+
+	// The stream restarts with the listener, and a confirmation manager
+	<-r.StreamContext.Done()
+	r = <-started
+	assert.Len(t, r.InitialListeners, 1)
+	assert.NotNil(t, es.confirmations)
+
+	err = es.Stop(es.bgCtx)
+	assert.NoError(t, err)
+
+	mfc.AssertExpectations(t)
+}
+
+func TestEventLoopListenerConfirmations(t *testing.T) {
+
+	es := newTestEventStream(t, `{
+		"name": "ut_stream"
+	}`)
+
+	ss := &startedStreamState{
+		updates:       make(chan *ffcapi.ListenerEvent, 1),
+		eventLoopDone: make(chan struct{}),
+	}
+	ss.ctx, ss.cancelCtx = context.WithCancel(context.Background())
+
+	immediate := uint64(0)
+	l1 := &apitypes.Listener{ID: fftypes.NewUUID(), Confirmations: &immediate}
+	overridden := uint64(5)
+	l2 := &apitypes.Listener{ID: fftypes.NewUUID(), Confirmations: &overridden}
+	es.listeners[*l1.ID] = &listener{spec: l1}
+	es.listeners[*l2.ID] = &listener{spec: l2}
+
+	u1 := &ffcapi.ListenerEvent{
+		Checkpoint: &utCheckpointType{SomeSequenceNumber: 12345},
+		Event:      &ffcapi.Event{ID: ffcapi.EventID{ListenerID: l1.ID}},
+	}
+	u2 := &ffcapi.ListenerEvent{
+		Checkpoint: &utCheckpointType{SomeSequenceNumber: 12346},
+		Event:      &ffcapi.Event{ID: ffcapi.EventID{ListenerID: l2.ID}},
+	}
+
+	mcm := &confirmationsmocks.Manager{}
+	mcm.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
+		return n.Event.ID.ListenerID.Equals(l2.ID) && *n.Event.Confirmations == 5
+	})).Return(nil).Run(func(args mock.Arguments) {
+		ss.cancelCtx()
+	})
+	es.confirmations = mcm
+
+	go func() {
+		// The first listener does not require confirmations, so bypasses the confirmation manager
+		ss.updates <- u1
+		assert.Equal(t, u1, <-es.batchChannel)
+		ss.updates <- u2
+	}()
+
+	es.eventLoop(ss)
+
+	mcm.AssertExpectations(t)
+}
@@ -0,0 +1,129 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gasprice
+
+import (
+	"context"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/policyengine"
+)
+
+// Canceller deletes transactions on behalf of the policy engines.
+// Before the first submission, or once it has reached a terminal status, the transaction can be deleted straight away. After that, the transaction might be
+// in the transaction pool of the node and could be mined at any time. So we replace it with a zero-value transfer
+// from the signer to itself, at the same nonce with a higher gas price, and only delete once one of them is mined.
+// Transactions that expire after submission are cancelled in the same way, but are not deleted - the policy loop
+// records whether the replacement or the original was mined.
+type Canceller struct {
+	escalator          *Escalator
+	resubmitInterval   time.Duration
+	escalateOnResubmit bool
+}
+
+// NewCanceller builds a canceller that prices the replacement using the supplied escalator, and resubmits it each
+// resubmit interval - either at the same price, or escalating the price further each time
+func NewCanceller(escalator *Escalator, resubmitInterval time.Duration, escalateOnResubmit bool) *Canceller {
+	return &Canceller{
+		escalator:          escalator,
+		resubmitInterval:   resubmitInterval,
+		escalateOnResubmit: escalateOnResubmit,
+	}
+}
+
+// CancelTX performs the next step in cancelling the transaction, and is called by the policy engine on each execution
+// after a deletion has been requested
+func (c *Canceller) CancelTX(ctx context.Context, cAPI ffcapi.API, mtx *apitypes.ManagedTX) (update policyengine.UpdateType, reason ffcapi.ErrorReason, err error) {
+	switch {
+	case mtx.DeleteRequested == nil && mtx.Receipt != nil:
+		// Cancelled on expiry - the outcome is recorded by the policy loop once the receipt is confirmed
+		return policyengine.UpdateNo, "", nil
+	case mtx.FirstSubmit == nil, mtx.Receipt != nil, mtx.Status != apitypes.TxStatusPending:
+		// A replacement is only possible while the nonce is still pending. Once the transaction has reached
+		// a terminal status without a receipt (such as Superseded), the nonce has already been consumed.
+		return policyengine.UpdateDelete, "", nil
+	case mtx.Cancellation == nil:
+		cancelGasPrice, _, err := c.escalator.Escalate(ctx, mtx.GasPrice)
+		if err != nil {
+			return policyengine.UpdateNo, "", err
+		}
+		log.L(ctx).Infof("Cancelling transaction %s at nonce %s / %d with replacement gasPrice=%s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), cancelGasPrice)
+		mtx.Cancellation = &apitypes.ManagedTXCancellation{
+			GasPrice: cancelGasPrice,
+		}
+	case mtx.Cancellation.LastSubmit == nil:
+		// Previous attempt failed - retry with the current price
+	case time.Since(*mtx.Cancellation.LastSubmit.Time()) > c.resubmitInterval:
+		// We resubmit the replacement on the same interval as the original - as it might no longer be in the TX pool
+		if c.escalateOnResubmit {
+			cancelGasPrice, _, err := c.escalator.Escalate(ctx, mtx.Cancellation.GasPrice)
+			if err != nil {
+				return policyengine.UpdateNo, "", err
+			}
+			mtx.Cancellation.GasPrice = cancelGasPrice
+		}
+	default:
+		return policyengine.UpdateNo, "", nil
+	}
+	return c.submitCancellation(ctx, cAPI, mtx)
+}
+
+func (c *Canceller) submitCancellation(ctx context.Context, cAPI ffcapi.API, mtx *apitypes.ManagedTX) (update policyengine.UpdateType, reason ffcapi.ErrorReason, err error) {
+	cancellation := mtx.Cancellation
+	sendTX := &ffcapi.TransactionSendRequest{
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From:  mtx.TransactionHeaders.From,
+			To:    mtx.TransactionHeaders.From,
+			Nonce: (*fftypes.FFBigInt)(mtx.Nonce.Int()),
+			Gas:   (*fftypes.FFBigInt)(mtx.Gas.Int()),
+			Value: fftypes.NewFFBigInt(0),
+		},
+		GasPrice: cancellation.GasPrice,
+	}
+	res, reason, err := cAPI.TransactionSend(ctx, sendTX)
+	if err != nil {
+		switch reason {
+		case ffcapi.ErrorKnownTransaction, ffcapi.ErrorReasonNonceTooLow:
+			// Either the replacement or the original is known to the node, or has already been mined.
+			// We wait for the receipt, which will be delivered for whichever of them is mined.
+			log.L(ctx).Infof("Cancellation of transaction %s at nonce %s / %d waiting for mining (%s)", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), err)
+			cancellation.LastSubmit = fftypes.Now()
+			return policyengine.UpdateYes, "", nil
+		case ffcapi.ErrorReasonTransactionUnderpriced:
+			// Increase the price again for the next attempt
+			newGasPrice, _, escalateErr := c.escalator.Escalate(ctx, cancellation.GasPrice)
+			if escalateErr == nil {
+				cancellation.GasPrice = newGasPrice
+			}
+		}
+		return policyengine.UpdateYes, reason, err
+	}
+	cancellation.TransactionHash = res.TransactionHash
+	if !cancellation.HasTransactionHash(res.TransactionHash) {
+		cancellation.TransactionHashes = append(cancellation.TransactionHashes, res.TransactionHash)
+	}
+	cancellation.LastSubmit = fftypes.Now()
+	if cancellation.FirstSubmit == nil {
+		cancellation.FirstSubmit = cancellation.LastSubmit
+	}
+	log.L(ctx).Infof("Cancellation of transaction %s at nonce %s / %d submitted. Hash: %s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), cancellation.TransactionHash)
+	return policyengine.UpdateYes, "", nil
+}
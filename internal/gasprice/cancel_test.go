@@ -0,0 +1,268 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gasprice
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/policyengine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestCancelTX() *apitypes.ManagedTX {
+	firstSubmit := fftypes.Now()
+	return &apitypes.ManagedTX{
+		ID:              "ns1:" + fftypes.NewUUID().String(),
+		Nonce:           fftypes.NewFFBigInt(42),
+		Gas:             fftypes.NewFFBigInt(100000),
+		GasPrice:        fftypes.JSONAnyPtr(`1000`),
+		TransactionHash: "0x12345",
+		Status:          apitypes.TxStatusPending,
+		FirstSubmit:     firstSubmit,
+		LastSubmit:      firstSubmit,
+		DeleteRequested: fftypes.Now(),
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From: "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712",
+			To:   "0x3f0ad1a04ab4ebd2ad2ac6ddf2ac3ee7e5da0b60",
+		},
+	}
+}
+
+func TestCancelNotSubmitted(t *testing.T) {
+	c := NewCanceller(NewEscalator(big.NewRat(10, 1), nil, nil), time.Minute, false)
+
+	mtx := newTestCancelTX()
+	mtx.FirstSubmit = nil
+
+	mockFFCAPI := &ffcapimocks.API{}
+	updated, reason, err := c.CancelTX(context.Background(), mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, policyengine.UpdateDelete, updated)
+	assert.Nil(t, mtx.Cancellation)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestCancelTerminalWithoutReceipt(t *testing.T) {
+	c := NewCanceller(NewEscalator(big.NewRat(10, 1), nil, nil), time.Minute, false)
+
+	for _, status := range []apitypes.TxStatus{apitypes.TxStatusFailed, apitypes.TxStatusSuperseded} {
+		mtx := newTestCancelTX()
+		mtx.Status = status
+
+		mockFFCAPI := &ffcapimocks.API{}
+		updated, reason, err := c.CancelTX(context.Background(), mockFFCAPI, mtx)
+		assert.NoError(t, err)
+		assert.Empty(t, reason)
+		assert.Equal(t, policyengine.UpdateDelete, updated)
+		assert.Nil(t, mtx.Cancellation)
+
+		mockFFCAPI.AssertExpectations(t)
+	}
+}
+
+func TestCancelSubmitsReplacement(t *testing.T) {
+	c := NewCanceller(NewEscalator(big.NewRat(10, 1), nil, nil), time.Minute, false)
+
+	mtx := newTestCancelTX()
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.String() == "1100" &&
+			req.From == mtx.TransactionHeaders.From &&
+			req.To == mtx.TransactionHeaders.From &&
+			req.Nonce.Int64() == 42 &&
+			req.Gas.Int64() == 100000 &&
+			req.Value.Int64() == 0
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x67890",
+	}, ffcapi.ErrorReason(""), nil)
+
+	ctx := context.Background()
+	updated, reason, err := c.CancelTX(ctx, mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Equal(t, "0x67890", mtx.Cancellation.TransactionHash)
+	assert.NotNil(t, mtx.Cancellation.FirstSubmit)
+	assert.Equal(t, mtx.Cancellation.FirstSubmit, mtx.Cancellation.LastSubmit)
+	assert.True(t, mtx.Cancellation.HasTransactionHash("0x67890"))
+	assert.False(t, mtx.Cancellation.HasTransactionHash(mtx.TransactionHash))
+
+	// Not due to resubmit
+	updated, _, err = c.CancelTX(ctx, mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Equal(t, policyengine.UpdateNo, updated)
+
+	// Delete once mined
+	mtx.Receipt = &ffcapi.TransactionReceiptResponse{}
+	updated, _, err = c.CancelTX(ctx, mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Equal(t, policyengine.UpdateDelete, updated)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestCancelExpiredWaitsForConfirmation(t *testing.T) {
+	c := NewCanceller(NewEscalator(big.NewRat(10, 1), nil, nil), time.Minute, false)
+
+	mtx := newTestCancelTX()
+	mtx.DeleteRequested = nil
+	mtx.ExpiryCancelRequested = fftypes.Now()
+	mtx.Cancellation = &apitypes.ManagedTXCancellation{
+		TransactionHash:   "0x67890",
+		TransactionHashes: []string{"0x67890"},
+		GasPrice:          fftypes.JSONAnyPtr(`1100`),
+	}
+	mtx.Receipt = &ffcapi.TransactionReceiptResponse{}
+
+	// The transaction is not deleted, as the policy loop needs to record the outcome
+	mockFFCAPI := &ffcapimocks.API{}
+	updated, reason, err := c.CancelTX(context.Background(), mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, policyengine.UpdateNo, updated)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestCancelResubmitSamePrice(t *testing.T) {
+	c := NewCanceller(NewEscalator(big.NewRat(10, 1), nil, nil), time.Minute, false)
+
+	lastSubmit := fftypes.FFTime(time.Now().Add(-2 * time.Minute))
+	mtx := newTestCancelTX()
+	mtx.Cancellation = &apitypes.ManagedTXCancellation{
+		GasPrice:    fftypes.JSONAnyPtr(`1100`),
+		FirstSubmit: &lastSubmit,
+		LastSubmit:  &lastSubmit,
+	}
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.String() == "1100"
+	})).Return(nil, ffcapi.ErrorKnownTransaction, fmt.Errorf("known transaction"))
+
+	updated, reason, err := c.CancelTX(context.Background(), mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Equal(t, &lastSubmit, mtx.Cancellation.FirstSubmit)
+	assert.NotEqual(t, &lastSubmit, mtx.Cancellation.LastSubmit)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestCancelResubmitEscalates(t *testing.T) {
+	c := NewCanceller(NewEscalator(big.NewRat(10, 1), nil, nil), time.Minute, true)
+
+	lastSubmit := fftypes.FFTime(time.Now().Add(-2 * time.Minute))
+	mtx := newTestCancelTX()
+	mtx.Cancellation = &apitypes.ManagedTXCancellation{
+		GasPrice:    fftypes.JSONAnyPtr(`1100`),
+		FirstSubmit: &lastSubmit,
+		LastSubmit:  &lastSubmit,
+	}
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.String() == "1210"
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0xabcde",
+	}, ffcapi.ErrorReason(""), nil)
+
+	updated, reason, err := c.CancelTX(context.Background(), mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Equal(t, "0xabcde", mtx.Cancellation.TransactionHash)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestCancelUnderpricedThenRetry(t *testing.T) {
+	c := NewCanceller(NewEscalator(big.NewRat(10, 1), nil, nil), time.Minute, false)
+
+	mtx := newTestCancelTX()
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.String() == "1100"
+	})).Return(nil, ffcapi.ErrorReasonTransactionUnderpriced, fmt.Errorf("underpriced"))
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.String() == "1210"
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x67890",
+	}, ffcapi.ErrorReason(""), nil)
+
+	ctx := context.Background()
+	updated, reason, err := c.CancelTX(ctx, mockFFCAPI, mtx)
+	assert.Regexp(t, "underpriced", err)
+	assert.Equal(t, ffcapi.ErrorReasonTransactionUnderpriced, reason)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Nil(t, mtx.Cancellation.LastSubmit)
+
+	// The failed attempt is retried straight away at the escalated price
+	updated, reason, err = c.CancelTX(ctx, mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Equal(t, "0x67890", mtx.Cancellation.TransactionHash)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestCancelBadGasPrice(t *testing.T) {
+	c := NewCanceller(NewEscalator(big.NewRat(10, 1), nil, nil), time.Minute, false)
+
+	mtx := newTestCancelTX()
+	mtx.GasPrice = fftypes.JSONAnyPtr(`true`)
+
+	mockFFCAPI := &ffcapimocks.API{}
+	updated, _, err := c.CancelTX(context.Background(), mockFFCAPI, mtx)
+	assert.Regexp(t, "FF21070", err)
+	assert.Equal(t, policyengine.UpdateNo, updated)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestCancelResubmitBadGasPrice(t *testing.T) {
+	c := NewCanceller(NewEscalator(big.NewRat(10, 1), nil, nil), time.Minute, true)
+
+	lastSubmit := fftypes.FFTime(time.Now().Add(-2 * time.Minute))
+	mtx := newTestCancelTX()
+	mtx.Cancellation = &apitypes.ManagedTXCancellation{
+		GasPrice:   fftypes.JSONAnyPtr(`true`),
+		LastSubmit: &lastSubmit,
+	}
+
+	mockFFCAPI := &ffcapimocks.API{}
+	updated, _, err := c.CancelTX(context.Background(), mockFFCAPI, mtx)
+	assert.Regexp(t, "FF21070", err)
+	assert.Equal(t, policyengine.UpdateNo, updated)
+
+	mockFFCAPI.AssertExpectations(t)
+}
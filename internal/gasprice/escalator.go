@@ -0,0 +1,214 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gasprice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"strconv"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+)
+
+// Escalator increases gas prices by a percentage and/or a fixed step, up to an optional ceiling.
+// The values are held as rationals, so that integer gas prices (such as wei) are escalated exactly.
+type Escalator struct {
+	factor *big.Rat
+	step   *big.Rat
+	max    *big.Rat
+}
+
+// NewEscalator builds an escalator from a percentage increase, and optional step and ceiling (which can be nil)
+func NewEscalator(percentage, step, max *big.Rat) *Escalator {
+	return &Escalator{
+		factor: new(big.Rat).Add(big.NewRat(1, 1), new(big.Rat).Quo(percentage, big.NewRat(100, 1))),
+		step:   step,
+		max:    max,
+	}
+}
+
+// NewMultiplier builds an escalator that multiplies gas prices by the supplied factor, without any ceiling
+func NewMultiplier(factor *big.Rat) *Escalator {
+	return &Escalator{
+		factor: factor,
+	}
+}
+
+// ParsePercentage converts a floating point percentage from configuration, checking it is not negative
+func ParsePercentage(ctx context.Context, key string, value float64) (*big.Rat, error) {
+	s := strconv.FormatFloat(value, 'f', -1, 64)
+	v, ok := new(big.Rat).SetString(s)
+	if !ok || v.Sign() < 0 {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidPolicyEngineConfig, s, key)
+	}
+	return v, nil
+}
+
+// ParseOptionalNumber converts a numeric string from configuration, returning nil if the string is empty
+func ParseOptionalNumber(ctx context.Context, key string, s string) (*big.Rat, error) {
+	if s == "" {
+		return nil, nil
+	}
+	v, ok := new(big.Rat).SetString(s)
+	if !ok || v.Sign() < 0 {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidPolicyEngineConfig, s, key)
+	}
+	return v, nil
+}
+
+// Escalate applies the configured increase to a gas price, which is opaque to FFTM in general.
+// We support the formats commonly used between policy engines and connectors:
+// - A JSON number: 12345
+// - A numeric JSON string: "12345"
+// - An object, where every numeric field is escalated: {"maxPriorityFeePerGas":123,"maxFeePerGas":456}
+//
+// Returns false if no field could be increased, because the ceiling has been reached
+func (e *Escalator) Escalate(ctx context.Context, gasPrice *fftypes.JSONAny) (*fftypes.JSONAny, bool, error) {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader([]byte(gasPrice.String())))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, false, i18n.NewError(ctx, tmmsgs.MsgGasPriceNotEscalatable, gasPrice)
+	}
+	switch vt := value.(type) {
+	case json.Number:
+		newValue, escalated, ok := e.escalateNumber(vt.String())
+		if ok {
+			return fftypes.JSONAnyPtr(newValue), escalated, nil
+		}
+	case string:
+		newValue, escalated, ok := e.escalateNumber(vt)
+		if ok {
+			b, _ := json.Marshal(newValue)
+			return fftypes.JSONAnyPtrBytes(b), escalated, nil
+		}
+	case map[string]interface{}:
+		numericFields := 0
+		anyEscalated := false
+		for k, fv := range vt {
+			switch ft := fv.(type) {
+			case json.Number:
+				if newValue, escalated, ok := e.escalateNumber(ft.String()); ok {
+					vt[k] = json.Number(newValue)
+					anyEscalated = anyEscalated || escalated
+					numericFields++
+				}
+			case string:
+				if newValue, escalated, ok := e.escalateNumber(ft); ok {
+					vt[k] = newValue
+					anyEscalated = anyEscalated || escalated
+					numericFields++
+				}
+			}
+		}
+		if numericFields > 0 {
+			b, _ := json.Marshal(vt)
+			return fftypes.JSONAnyPtrBytes(b), anyEscalated, nil
+		}
+	}
+	return nil, false, i18n.NewError(ctx, tmmsgs.MsgGasPriceNotEscalatable, gasPrice)
+}
+
+// escalateNumber increases a single numeric value by the configured percentage and step, capped at the ceiling.
+// Integer inputs produce integer outputs (rounded up), so that values such as wei always increase.
+func (e *Escalator) escalateNumber(s string) (newValue string, escalated bool, ok bool) {
+	current, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return "", false, false
+	}
+	if e.max != nil && current.Cmp(e.max) >= 0 {
+		return s, false, true
+	}
+	next := new(big.Rat).Mul(current, e.factor)
+	if e.step != nil {
+		next.Add(next, e.step)
+	}
+	if e.max != nil && next.Cmp(e.max) > 0 {
+		next.Set(e.max)
+	}
+	if current.IsInt() {
+		q, r := new(big.Int).QuoRem(next.Num(), next.Denom(), new(big.Int))
+		if r.Sign() > 0 {
+			q.Add(q, big.NewInt(1))
+		}
+		return q.String(), true, true
+	}
+	return new(big.Float).SetPrec(256).SetRat(next).Text('f', -1), true, true
+}
+
+// IsIncrease checks a proposed gas price is greater than the current gas price, in any of the formats supported
+// by Escalate. For an object every numeric field of the current gas price must be increased, as a node only
+// accepts a replacement transaction when each of its fees has been bumped.
+// Any proposed gas price is an increase when there is no current gas price.
+func IsIncrease(ctx context.Context, current, proposed *fftypes.JSONAny) (bool, error) {
+	if current.IsNil() {
+		return true, nil
+	}
+	currentValues, ok := numericValues(current)
+	if !ok {
+		return false, i18n.NewError(ctx, tmmsgs.MsgGasPriceNotEscalatable, current)
+	}
+	proposedValues, ok := numericValues(proposed)
+	if !ok {
+		return false, nil
+	}
+	for k, currentValue := range currentValues {
+		proposedValue, ok := proposedValues[k]
+		if !ok || proposedValue.Cmp(currentValue) <= 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// numericValues returns the numeric values of a gas price, keyed by field name for an object,
+// or with an empty key for a number or numeric string
+func numericValues(gasPrice *fftypes.JSONAny) (map[string]*big.Rat, bool) {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader([]byte(gasPrice.String())))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, false
+	}
+	values := make(map[string]*big.Rat)
+	addValue := func(k string, v interface{}) {
+		var s string
+		switch vt := v.(type) {
+		case json.Number:
+			s = vt.String()
+		case string:
+			s = vt
+		default:
+			return
+		}
+		if r, ok := new(big.Rat).SetString(s); ok {
+			values[k] = r
+		}
+	}
+	if fields, isObject := value.(map[string]interface{}); isObject {
+		for k, fv := range fields {
+			addValue(k, fv)
+		}
+	} else {
+		addValue("", value)
+	}
+	return values, len(values) > 0
+}
@@ -0,0 +1,155 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gasprice
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEscalateGasPrice(t *testing.T) {
+
+	testCases := []struct {
+		name      string
+		step      *big.Rat
+		max       *big.Rat
+		input     string
+		output    string
+		escalated bool
+	}{
+		{name: "number", input: `12345`, output: `13580`, escalated: true},
+		{name: "exponent", input: `1e3`, output: `1100`, escalated: true},
+		{name: "string", input: `"100"`, output: `"110"`, escalated: true},
+		{name: "decimal", input: `32.146027800733336`, output: `35.3606305808066696`, escalated: true},
+		{name: "step", input: `100`, output: `1110`, escalated: true, step: big.NewRat(1000, 1)},
+		{name: "ceiling", input: `100`, output: `105`, escalated: true, max: big.NewRat(105, 1)},
+		{name: "above ceiling", input: `200`, output: `200`, escalated: false, max: big.NewRat(105, 1)},
+		{name: "object", input: `{"unit":"gwei","value":33}`, output: `{"unit":"gwei","value":37}`, escalated: true},
+		{name: "object at ceiling", input: `{"maxFeePerGas":"200","maxPriorityFeePerGas":100}`, output: `{"maxFeePerGas":"200","maxPriorityFeePerGas":105}`, escalated: true, max: big.NewRat(105, 1)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := NewEscalator(big.NewRat(10, 1), tc.step, tc.max)
+			newGasPrice, escalated, err := e.Escalate(context.Background(), fftypes.JSONAnyPtr(tc.input))
+			assert.NoError(t, err)
+			assert.Equal(t, tc.escalated, escalated)
+			assert.Equal(t, tc.output, newGasPrice.String())
+		})
+	}
+
+}
+
+func TestEscalateGasPriceNotEscalatable(t *testing.T) {
+
+	e := NewEscalator(big.NewRat(10, 1), nil, nil)
+
+	for _, input := range []string{
+		`!not json!`,
+		`true`,
+		`"not a number"`,
+		`[12345]`,
+		`{"unit":"gwei"}`,
+	} {
+		_, _, err := e.Escalate(context.Background(), fftypes.JSONAnyPtr(input))
+		assert.Regexp(t, "FF21070", err)
+	}
+
+}
+
+func TestParsePercentage(t *testing.T) {
+
+	v, err := ParsePercentage(context.Background(), "pct", 12.5)
+	assert.NoError(t, err)
+	assert.Equal(t, "25/2", v.String())
+
+	_, err = ParsePercentage(context.Background(), "pct", -1)
+	assert.Regexp(t, "FF21071.*pct", err)
+
+}
+
+func TestParseOptionalNumber(t *testing.T) {
+
+	v, err := ParseOptionalNumber(context.Background(), "num", "")
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	v, err = ParseOptionalNumber(context.Background(), "num", "1000")
+	assert.NoError(t, err)
+	assert.Equal(t, "1000/1", v.String())
+
+	_, err = ParseOptionalNumber(context.Background(), "num", "lots")
+	assert.Regexp(t, "FF21071.*num", err)
+
+	_, err = ParseOptionalNumber(context.Background(), "num", "-1")
+	assert.Regexp(t, "FF21071.*num", err)
+
+}
+
+func TestMultiplier(t *testing.T) {
+
+	e := NewMultiplier(big.NewRat(3, 2))
+	newGasPrice, escalated, err := e.Escalate(context.Background(), fftypes.JSONAnyPtr(`{"maxFeePerGas":"1000","maxPriorityFeePerGas":101}`))
+	assert.NoError(t, err)
+	assert.True(t, escalated)
+	assert.Equal(t, `{"maxFeePerGas":"1500","maxPriorityFeePerGas":152}`, newGasPrice.String())
+
+}
+
+func TestIsIncrease(t *testing.T) {
+
+	testCases := []struct {
+		name     string
+		current  string
+		proposed string
+		increase bool
+	}{
+		{name: "number", current: `100`, proposed: `101`, increase: true},
+		{name: "number equal", current: `100`, proposed: `100`},
+		{name: "number lower", current: `100`, proposed: `99`},
+		{name: "string", current: `"100"`, proposed: `101`, increase: true},
+		{name: "object", current: `{"maxFeePerGas":"1000","maxPriorityFeePerGas":100}`, proposed: `{"maxFeePerGas":2000,"maxPriorityFeePerGas":"101"}`, increase: true},
+		{name: "object one field equal", current: `{"maxFeePerGas":"1000","maxPriorityFeePerGas":100}`, proposed: `{"maxFeePerGas":2000,"maxPriorityFeePerGas":100}`},
+		{name: "object field missing", current: `{"maxFeePerGas":"1000","maxPriorityFeePerGas":100}`, proposed: `{"maxFeePerGas":2000}`},
+		{name: "different format", current: `{"maxFeePerGas":"1000"}`, proposed: `2000`},
+		{name: "not numeric", current: `100`, proposed: `"fast"`},
+		{name: "invalid", current: `100`, proposed: `!`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			increase, err := IsIncrease(context.Background(), fftypes.JSONAnyPtr(tc.current), fftypes.JSONAnyPtr(tc.proposed))
+			assert.NoError(t, err)
+			assert.Equal(t, tc.increase, increase)
+		})
+	}
+
+}
+
+func TestIsIncreaseNoCurrentGasPrice(t *testing.T) {
+
+	increase, err := IsIncrease(context.Background(), nil, fftypes.JSONAnyPtr(`100`))
+	assert.NoError(t, err)
+	assert.True(t, increase)
+
+	_, err = IsIncrease(context.Background(), fftypes.JSONAnyPtr(`"fast"`), fftypes.JSONAnyPtr(`100`))
+	assert.Regexp(t, "FF21070", err)
+
+}
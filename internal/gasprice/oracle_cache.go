@@ -0,0 +1,85 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gasprice
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+)
+
+// OracleCache holds the gas price returned by an oracle for a query interval, on behalf of a policy engine that
+// is invoked in parallel for different signers. The lock is only held to read or update the cached value, and
+// the query itself is made outside of it. Callers that arrive while a query is in flight wait for its result,
+// rather than each querying the oracle.
+type OracleCache struct {
+	interval  time.Duration
+	mux       sync.Mutex
+	value     *fftypes.JSONAny
+	queryTime *fftypes.FFTime
+	inflight  *oracleQuery
+}
+
+type oracleQuery struct {
+	done  chan struct{}
+	value *fftypes.JSONAny
+	err   error
+}
+
+func NewOracleCache(interval time.Duration) *OracleCache {
+	return &OracleCache{
+		interval: interval,
+	}
+}
+
+// Get returns the cached gas price if it was queried within the interval, otherwise the result of the query
+func (oc *OracleCache) Get(ctx context.Context, query func(ctx context.Context) (*fftypes.JSONAny, error)) (*fftypes.JSONAny, error) {
+	oc.mux.Lock()
+	if oc.value != nil && oc.queryTime != nil && time.Since(*oc.queryTime.Time()) < oc.interval {
+		value := oc.value
+		oc.mux.Unlock()
+		return value, nil
+	}
+	q := oc.inflight
+	if q != nil {
+		oc.mux.Unlock()
+		select {
+		case <-q.done:
+			return q.value, q.err
+		case <-ctx.Done():
+			return nil, i18n.NewError(ctx, i18n.MsgContextCanceled)
+		}
+	}
+	q = &oracleQuery{done: make(chan struct{})}
+	oc.inflight = q
+	oc.mux.Unlock()
+
+	q.value, q.err = query(ctx)
+
+	oc.mux.Lock()
+	if q.err == nil {
+		oc.value = q.value
+		oc.queryTime = fftypes.Now()
+	}
+	oc.inflight = nil
+	oc.mux.Unlock()
+	close(q.done)
+	return q.value, q.err
+}
@@ -0,0 +1,103 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gasprice
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOracleCacheCachesValue(t *testing.T) {
+	oc := NewOracleCache(time.Hour)
+
+	queries := 0
+	query := func(ctx context.Context) (*fftypes.JSONAny, error) {
+		queries++
+		if queries == 1 {
+			return nil, fmt.Errorf("pop")
+		}
+		return fftypes.JSONAnyPtr(fmt.Sprintf("%d", queries)), nil
+	}
+
+	// Failures are not cached
+	_, err := oc.Get(context.Background(), query)
+	assert.Regexp(t, "pop", err)
+
+	for i := 0; i < 2; i++ {
+		gasPrice, err := oc.Get(context.Background(), query)
+		assert.NoError(t, err)
+		assert.Equal(t, "2", gasPrice.String())
+	}
+	assert.Equal(t, 2, queries)
+}
+
+func TestOracleCacheExpires(t *testing.T) {
+	oc := NewOracleCache(0)
+
+	queries := 0
+	query := func(ctx context.Context) (*fftypes.JSONAny, error) {
+		queries++
+		return fftypes.JSONAnyPtr(fmt.Sprintf("%d", queries)), nil
+	}
+	for i := 1; i <= 2; i++ {
+		gasPrice, err := oc.Get(context.Background(), query)
+		assert.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("%d", i), gasPrice.String())
+	}
+}
+
+func TestOracleCacheSingleQueryInFlight(t *testing.T) {
+	oc := NewOracleCache(time.Hour)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go func() {
+		_, _ = oc.Get(context.Background(), func(ctx context.Context) (*fftypes.JSONAny, error) {
+			close(started)
+			<-release
+			return fftypes.JSONAnyPtr("12345"), nil
+		})
+	}()
+	<-started
+
+	// A caller arriving while the query is in flight waits for its result, rather than querying again
+	waiter := make(chan *fftypes.JSONAny)
+	go func() {
+		gasPrice, err := oc.Get(context.Background(), func(ctx context.Context) (*fftypes.JSONAny, error) {
+			panic("unexpected query")
+		})
+		assert.NoError(t, err)
+		waiter <- gasPrice
+	}()
+
+	// A caller whose context is cancelled stops waiting
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := oc.Get(ctx, func(ctx context.Context) (*fftypes.JSONAny, error) {
+		panic("unexpected query")
+	})
+	assert.Regexp(t, "FF00154", err)
+
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	assert.Equal(t, "12345", (<-waiter).String())
+}
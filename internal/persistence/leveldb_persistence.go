@@ -17,6 +17,7 @@
 package persistence
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -73,10 +74,13 @@ const listenersPrefix = "listeners_0/"
 const listenersEnd = "listeners_1"
 const transactionsPrefix = "tx_0/"
 const nonceAllocationPrefix = "nonce_0/"
+const nonceAllocationEnd = "nonce_1"
 const txPendingIndexPrefix = "tx_inflight_0/"
 const txPendingIndexEnd = "tx_inflight_1"
 const txCreatedIndexPrefix = "tx_created_0/"
 const txCreatedIndexEnd = "tx_created_1"
+const confirmationsPrefix = "confirmations_0/"
+const nonceFloorsPrefix = "noncefloors_0/"
 
 func signerNoncePrefix(signer string) string {
 	return fmt.Sprintf("%s%s_0/", nonceAllocationPrefix, signer)
@@ -86,6 +90,26 @@ func signerNonceEnd(signer string) string {
 	return fmt.Sprintf("%s%s_1", nonceAllocationPrefix, signer)
 }
 
+func signerFromNonceAllocationKey(k []byte) string {
+	signerAndNonce := strings.TrimPrefix(string(k), nonceAllocationPrefix)
+	if idx := strings.LastIndex(signerAndNonce, "_0/"); idx >= 0 {
+		return signerAndNonce[0:idx]
+	}
+	return signerAndNonce
+}
+
+func confirmationsManagerPrefix(managerID string) string {
+	return fmt.Sprintf("%s%s_0/", confirmationsPrefix, managerID)
+}
+
+func confirmationsManagerEnd(managerID string) string {
+	return fmt.Sprintf("%s%s_1", confirmationsPrefix, managerID)
+}
+
+func confirmationStateKey(managerID, key string) []byte {
+	return []byte(fmt.Sprintf("%s%s", confirmationsManagerPrefix(managerID), key))
+}
+
 func txNonceAllocationKey(signer string, nonce *fftypes.FFBigInt) []byte {
 	return []byte(fmt.Sprintf("%s%s_0/%.24d", nonceAllocationPrefix, signer, nonce.Int()))
 }
@@ -384,6 +408,47 @@ func (p *leveldbPersistence) ListTransactionsPending(ctx context.Context, after
 	return p.listTransactionsByIndex(ctx, txPendingIndexPrefix, txPendingIndexEnd, after.String(), limit, dir)
 }
 
+func (p *leveldbPersistence) ListSigners(ctx context.Context, after string, limit int, dir SortDirection) ([]string, error) {
+	p.txMux.RLock()
+	defer p.txMux.RUnlock()
+
+	// The nonce allocation index is keyed by signer and then nonce, so we can use it as an index of signers
+	// by seeking past all the nonces of each signer we find
+	collectionRange := &util.Range{
+		Start: []byte(nonceAllocationPrefix),
+		Limit: []byte(nonceAllocationEnd),
+	}
+	if after != "" {
+		if dir == SortDirectionAscending {
+			collectionRange.Start = []byte(signerNonceEnd(after))
+		} else {
+			collectionRange.Limit = []byte(signerNoncePrefix(after))
+		}
+	}
+	it := p.db.NewIterator(collectionRange, &opt.ReadOptions{DontFillCache: true})
+	defer it.Release()
+
+	signers := make([]string, 0)
+	valid := it.First()
+	if dir == SortDirectionDescending {
+		valid = it.Last()
+	}
+	for valid && (limit <= 0 || len(signers) < limit) {
+		signer := signerFromNonceAllocationKey(it.Key())
+		signers = append(signers, signer)
+		if dir == SortDirectionDescending {
+			valid = it.Seek([]byte(signerNoncePrefix(signer))) && it.Prev()
+		} else {
+			valid = it.Seek([]byte(signerNonceEnd(signer)))
+		}
+	}
+	if err := it.Error(); err != nil {
+		return nil, i18n.WrapError(ctx, err, tmmsgs.MsgPersistenceReadFailed, nonceAllocationPrefix)
+	}
+	log.L(ctx).Debugf("Listed %d signers", len(signers))
+	return signers, nil
+}
+
 func (p *leveldbPersistence) GetTransactionByID(ctx context.Context, txID string) (tx *apitypes.ManagedTX, err error) {
 	p.txMux.RLock()
 	defer p.txMux.RUnlock()
@@ -406,13 +471,8 @@ func (p *leveldbPersistence) WriteTransaction(ctx context.Context, tx *apitypes.
 	p.txMux.Lock()
 	defer p.txMux.Unlock()
 
-	if tx.TransactionHeaders.From == "" ||
-		tx.Nonce == nil ||
-		tx.SequenceID == nil ||
-		tx.Created == nil ||
-		tx.ID == "" ||
-		tx.Status == "" {
-		return i18n.NewError(ctx, tmmsgs.MsgPersistenceTXIncomplete)
+	if err := checkTXComplete(ctx, tx); err != nil {
+		return err
 	}
 	idKey := txDataKey(tx.ID)
 	if new {
@@ -447,18 +507,144 @@ func (p *leveldbPersistence) WriteTransaction(ctx context.Context, tx *apitypes.
 	return err
 }
 
+func (p *leveldbPersistence) WriteNewTransactions(ctx context.Context, txs []*apitypes.ManagedTX) error {
+	// As the whole set of records (including the indexes) is written in a single atomic batch,
+	// there is no partial write to consider - unlike WriteTransaction. We still take the write lock,
+	// for the uniqueness checks.
+	p.txMux.Lock()
+	defer p.txMux.Unlock()
+
+	batch := new(leveldb.Batch)
+	inBatch := make(map[string]bool)
+	for _, tx := range txs {
+		if err := checkTXComplete(ctx, tx); err != nil {
+			return err
+		}
+		idKey := txDataKey(tx.ID)
+		if existing, err := p.getKeyValue(ctx, idKey); err != nil {
+			return err
+		} else if existing != nil || inBatch[tx.ID] {
+			return i18n.NewError(ctx, tmmsgs.MsgDuplicateID, idKey)
+		}
+		inBatch[tx.ID] = true
+		b, err := json.Marshal(tx)
+		if err != nil {
+			return i18n.WrapError(ctx, err, tmmsgs.MsgPersistenceMarshalFailed)
+		}
+		batch.Put(txCreatedIndexKey(tx), idKey)
+		if tx.Status == apitypes.TxStatusPending {
+			batch.Put(txPendingIndexKey(tx.SequenceID), idKey)
+		}
+		batch.Put(txNonceAllocationKey(tx.TransactionHeaders.From, tx.Nonce), idKey)
+		batch.Put(idKey, b)
+	}
+	if err := p.db.Write(batch, &opt.WriteOptions{Sync: p.syncWrites}); err != nil {
+		return i18n.WrapError(ctx, err, tmmsgs.MsgPersistenceWriteFailed)
+	}
+	return nil
+}
+
+func checkTXComplete(ctx context.Context, tx *apitypes.ManagedTX) error {
+	if tx.TransactionHeaders.From == "" ||
+		tx.Nonce == nil ||
+		tx.SequenceID == nil ||
+		tx.Created == nil ||
+		tx.ID == "" ||
+		tx.Status == "" {
+		return i18n.NewError(ctx, tmmsgs.MsgPersistenceTXIncomplete)
+	}
+	return nil
+}
+
 func (p *leveldbPersistence) DeleteTransaction(ctx context.Context, txID string) error {
+	p.txMux.Lock()
+	defer p.txMux.Unlock()
+
 	var tx *apitypes.ManagedTX
-	err := p.readJSON(ctx, txDataKey(txID), &tx)
+	idKey := txDataKey(txID)
+	err := p.readJSON(ctx, idKey, &tx)
 	if err != nil || tx == nil {
 		return err
 	}
-	return p.deleteKeys(ctx,
-		txDataKey(txID),
+	keys := [][]byte{
+		idKey,
 		txCreatedIndexKey(tx),
 		txPendingIndexKey(tx.SequenceID),
-		txNonceAllocationKey(tx.TransactionHeaders.From, tx.Nonce),
-	)
+	}
+	// The nonce of a transaction that failed before it was submitted can be re-used to fill the gap, in which
+	// case the nonce allocation index belongs to the gap fill
+	nonceKey := txNonceAllocationKey(tx.TransactionHeaders.From, tx.Nonce)
+	owner, err := p.getKeyValue(ctx, nonceKey)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(owner, idKey) {
+		keys = append(keys, nonceKey)
+	}
+	return p.deleteKeys(ctx, keys...)
+}
+
+func nonceFloorKey(signer string) []byte {
+	return []byte(fmt.Sprintf("%s%s", nonceFloorsPrefix, signer))
+}
+
+func (p *leveldbPersistence) GetNonceFloor(ctx context.Context, signer string) (floor *fftypes.FFBigInt, err error) {
+	err = p.readJSON(ctx, nonceFloorKey(signer), &floor)
+	return floor, err
+}
+
+func (p *leveldbPersistence) WriteNonceFloor(ctx context.Context, signer string, floor *fftypes.FFBigInt) error {
+	return p.writeJSON(ctx, nonceFloorKey(signer), floor)
+}
+
+func (p *leveldbPersistence) DeleteNonceFloor(ctx context.Context, signer string) error {
+	return p.deleteKeys(ctx, nonceFloorKey(signer))
+}
+
+func (p *leveldbPersistence) ListConfirmationStates(ctx context.Context, managerID string) ([]*ConfirmationState, error) {
+	states := make([]*ConfirmationState, 0)
+	if _, err := p.listJSON(ctx, confirmationsManagerPrefix(managerID), confirmationsManagerEnd(managerID), "", 0, SortDirectionAscending,
+		func() interface{} { var v *ConfirmationState; return &v },
+		func(v interface{}) { states = append(states, *(v.(**ConfirmationState))) },
+		nil,
+	); err != nil {
+		return nil, err
+	}
+	return states, nil
+}
+
+func (p *leveldbPersistence) WriteConfirmationStates(ctx context.Context, managerID string, states []*ConfirmationState, deletedKeys []string) error {
+	batch := new(leveldb.Batch)
+	for _, state := range states {
+		b, err := json.Marshal(state)
+		if err != nil {
+			return i18n.WrapError(ctx, err, tmmsgs.MsgPersistenceMarshalFailed)
+		}
+		batch.Put(confirmationStateKey(managerID, state.Key), b)
+	}
+	for _, key := range deletedKeys {
+		batch.Delete(confirmationStateKey(managerID, key))
+	}
+	if err := p.db.Write(batch, &opt.WriteOptions{Sync: p.syncWrites}); err != nil {
+		return i18n.WrapError(ctx, err, tmmsgs.MsgPersistenceWriteFailed)
+	}
+	return nil
+}
+
+func (p *leveldbPersistence) DeleteConfirmationStates(ctx context.Context, managerID string) error {
+	it := p.db.NewIterator(&util.Range{
+		Start: []byte(confirmationsManagerPrefix(managerID)),
+		Limit: []byte(confirmationsManagerEnd(managerID)),
+	}, &opt.ReadOptions{DontFillCache: true})
+	defer it.Release()
+	var keys [][]byte
+	for it.Next() {
+		keys = append(keys, append([]byte{}, it.Key()...)) // the iterator reuses the key buffer
+	}
+	if err := it.Error(); err != nil {
+		return i18n.WrapError(ctx, err, tmmsgs.MsgPersistenceReadFailed, confirmationsManagerPrefix(managerID))
+	}
+	return p.deleteKeys(ctx, keys...)
 }
 
 func (p *leveldbPersistence) Close(ctx context.Context) {
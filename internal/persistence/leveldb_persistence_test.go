@@ -635,3 +635,263 @@ func TestDeleteTransactionMissing(t *testing.T) {
 	assert.NoError(t, err)
 
 }
+
+func TestDeleteTransactionNonceReused(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+
+	ctx := context.Background()
+	failed := newTestTX("0xaaaaa", 10001, apitypes.TxStatusFailed)
+	err := p.WriteTransaction(ctx, failed, true)
+	assert.NoError(t, err)
+	fill := newTestTX("0xaaaaa", 10001, apitypes.TxStatusPending)
+	err = p.WriteTransaction(ctx, fill, true)
+	assert.NoError(t, err)
+
+	// Deleting the failed transaction leaves the fill in the nonce index
+	err = p.DeleteTransaction(ctx, failed.ID)
+	assert.NoError(t, err)
+	owner, err := p.GetTransactionByNonce(ctx, "0xaaaaa", fftypes.NewFFBigInt(10001))
+	assert.NoError(t, err)
+	assert.Equal(t, fill.ID, owner.ID)
+
+	err = p.DeleteTransaction(ctx, fill.ID)
+	assert.NoError(t, err)
+	owner, err = p.GetTransactionByNonce(ctx, "0xaaaaa", fftypes.NewFFBigInt(10001))
+	assert.NoError(t, err)
+	assert.Nil(t, owner)
+
+}
+
+func TestWriteNewTransactions(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+
+	ctx := context.Background()
+	existing := newTestTX("0xaaaaa", 10001, apitypes.TxStatusPending)
+	err := p.WriteTransaction(ctx, existing, true)
+	assert.NoError(t, err)
+
+	t2 := newTestTX("0xaaaaa", 10002, apitypes.TxStatusPending)
+	t3 := newTestTX("0xaaaaa", 10003, apitypes.TxStatusPending)
+	err = p.WriteNewTransactions(ctx, []*apitypes.ManagedTX{t2, t3})
+	assert.NoError(t, err)
+
+	txns, err := p.ListTransactionsByNonce(ctx, "0xaaaaa", nil, 0, SortDirectionAscending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 3)
+	assert.Equal(t, existing.ID, txns[0].ID)
+	assert.Equal(t, t2.ID, txns[1].ID)
+	assert.Equal(t, t3.ID, txns[2].ID)
+
+	txns, err = p.ListTransactionsPending(ctx, nil, 0, SortDirectionAscending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 3)
+	assert.Equal(t, t3.ID, txns[2].ID)
+
+	// Clash with an existing ID fails the whole batch
+	t4 := newTestTX("0xaaaaa", 10004, apitypes.TxStatusPending)
+	t5 := newTestTX("0xaaaaa", 10005, apitypes.TxStatusPending)
+	t5.ID = existing.ID
+	err = p.WriteNewTransactions(ctx, []*apitypes.ManagedTX{t4, t5})
+	assert.Regexp(t, "FF21065", err)
+
+	// Clash within the batch also fails the whole batch
+	t5.ID = t4.ID
+	err = p.WriteNewTransactions(ctx, []*apitypes.ManagedTX{t4, t5})
+	assert.Regexp(t, "FF21065", err)
+
+	tx, err := p.GetTransactionByID(ctx, t4.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, tx)
+
+}
+
+func TestWriteNewTransactionsIncomplete(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+
+	err := p.WriteNewTransactions(context.Background(), []*apitypes.ManagedTX{{}})
+	assert.Regexp(t, "FF21059", err)
+
+}
+
+func TestWriteNewTransactionsFail(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+
+	p.db.Close()
+
+	err := p.WriteNewTransactions(context.Background(), []*apitypes.ManagedTX{
+		newTestTX("0x1234", 1000, apitypes.TxStatusPending),
+	})
+	assert.Error(t, err)
+
+}
+
+func TestListSigners(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+
+	ctx := context.Background()
+	for _, signer := range []string{"0xccccc", "0xaaaaa", "0xbbbbb"} {
+		for nonce := int64(1000); nonce < 1003; nonce++ {
+			err := p.WriteTransaction(ctx, newTestTX(signer, nonce, apitypes.TxStatusPending), true)
+			assert.NoError(t, err)
+		}
+	}
+
+	signers, err := p.ListSigners(ctx, "", 0, SortDirectionAscending)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0xaaaaa", "0xbbbbb", "0xccccc"}, signers)
+
+	signers, err = p.ListSigners(ctx, "0xaaaaa", 1, SortDirectionAscending)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0xbbbbb"}, signers)
+
+	signers, err = p.ListSigners(ctx, "", 0, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0xccccc", "0xbbbbb", "0xaaaaa"}, signers)
+
+	signers, err = p.ListSigners(ctx, "0xccccc", 1, SortDirectionDescending)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0xbbbbb"}, signers)
+
+}
+
+func TestListSignersFail(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+
+	p.db.Close()
+
+	_, err := p.ListSigners(context.Background(), "", 0, SortDirectionAscending)
+	assert.Regexp(t, "FF21055", err)
+
+}
+
+func TestReadWriteNonceFloor(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+
+	ctx := context.Background()
+	floor, err := p.GetNonceFloor(ctx, "0xaaaaa")
+	assert.NoError(t, err)
+	assert.Nil(t, floor)
+
+	err = p.WriteNonceFloor(ctx, "0xaaaaa", fftypes.NewFFBigInt(10005))
+	assert.NoError(t, err)
+	floor, err = p.GetNonceFloor(ctx, "0xaaaaa")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10005), floor.Int64())
+
+	// The floor is not part of the nonce index of the signer
+	signers, err := p.ListSigners(ctx, "", 0, SortDirectionAscending)
+	assert.NoError(t, err)
+	assert.Empty(t, signers)
+
+	err = p.DeleteNonceFloor(ctx, "0xaaaaa")
+	assert.NoError(t, err)
+	floor, err = p.GetNonceFloor(ctx, "0xaaaaa")
+	assert.NoError(t, err)
+	assert.Nil(t, floor)
+
+}
+
+func TestReadWriteConfirmationStates(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+
+	ctx := context.Background()
+	state1 := &ConfirmationState{
+		Key:             "TX:th=0x111",
+		TransactionHash: "0x111",
+		BlockNumber:     1001,
+		BlockHash:       "0xb1",
+		Receipt: &ffcapi.TransactionReceiptResponse{
+			BlockNumber: fftypes.NewFFBigInt(1001),
+			BlockHash:   "0xb1",
+			Success:     true,
+		},
+		Confirmations: []apitypes.BlockInfo{
+			{BlockNumber: 1002, BlockHash: "0xb2", ParentHash: "0xb1"},
+		},
+		Updated: fftypes.Now(),
+	}
+	state2 := &ConfirmationState{
+		Key:             "TX:th=0x222",
+		TransactionHash: "0x222",
+		Updated:         fftypes.Now(),
+	}
+
+	err := p.WriteConfirmationStates(ctx, "receipts", []*ConfirmationState{state1, state2}, nil)
+	assert.NoError(t, err)
+	err = p.WriteConfirmationStates(ctx, "receipts_other", []*ConfirmationState{state2}, nil)
+	assert.NoError(t, err)
+
+	states, err := p.ListConfirmationStates(ctx, "receipts")
+	assert.NoError(t, err)
+	assert.Len(t, states, 2)
+	assert.Equal(t, "TX:th=0x111", states[0].Key)
+	assert.Equal(t, "0xb2", states[0].Confirmations[0].BlockHash)
+	assert.True(t, states[0].Receipt.Success)
+	assert.Equal(t, "TX:th=0x222", states[1].Key)
+
+	// Writes and deletes in the same batch
+	state2.BlockHash = "0xb2"
+	err = p.WriteConfirmationStates(ctx, "receipts", []*ConfirmationState{state2}, []string{state1.Key})
+	assert.NoError(t, err)
+
+	states, err = p.ListConfirmationStates(ctx, "receipts")
+	assert.NoError(t, err)
+	assert.Len(t, states, 1)
+	assert.Equal(t, "TX:th=0x222", states[0].Key)
+	assert.Equal(t, "0xb2", states[0].BlockHash)
+
+	err = p.DeleteConfirmationStates(ctx, "receipts")
+	assert.NoError(t, err)
+
+	states, err = p.ListConfirmationStates(ctx, "receipts")
+	assert.NoError(t, err)
+	assert.Empty(t, states)
+
+	// Other confirmation managers are unaffected
+	states, err = p.ListConfirmationStates(ctx, "receipts_other")
+	assert.NoError(t, err)
+	assert.Len(t, states, 1)
+
+}
+
+func TestListConfirmationStatesBadJSON(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+
+	err := p.db.Put(confirmationStateKey("receipts", "TX:th=0x111"), []byte("{! not json"), &opt.WriteOptions{})
+	assert.NoError(t, err)
+
+	_, err = p.ListConfirmationStates(context.Background(), "receipts")
+	assert.Error(t, err)
+
+}
+
+func TestWriteConfirmationStatesFail(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+
+	p.db.Close()
+
+	err := p.WriteConfirmationStates(context.Background(), "receipts", nil, []string{"TX:th=0x111"})
+	assert.Regexp(t, "FF21056", err)
+
+}
+
+func TestDeleteConfirmationStatesFail(t *testing.T) {
+	p, done := newTestLevelDBPersistence(t)
+	defer done()
+
+	p.db.Close()
+
+	err := p.DeleteConfirmationStates(context.Background(), "receipts")
+	assert.Regexp(t, "FF21055", err)
+
+}
@@ -21,6 +21,7 @@ import (
 
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
 )
 
 type Persistence interface {
@@ -42,10 +43,32 @@ type Persistence interface {
 	ListTransactionsByCreateTime(ctx context.Context, after *apitypes.ManagedTX, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error)         // reverse create time order
 	ListTransactionsByNonce(ctx context.Context, signer string, after *fftypes.FFBigInt, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error) // reverse nonce order within signer
 	ListTransactionsPending(ctx context.Context, after *fftypes.UUID, limit int, dir SortDirection) ([]*apitypes.ManagedTX, error)                    // reverse UUIDv1 order, only those in pending state
+	ListSigners(ctx context.Context, after string, limit int, dir SortDirection) ([]string, error)                                                    // signers with allocated nonces, in address order
 	GetTransactionByID(ctx context.Context, txID string) (*apitypes.ManagedTX, error)
 	GetTransactionByNonce(ctx context.Context, signer string, nonce *fftypes.FFBigInt) (*apitypes.ManagedTX, error)
 	WriteTransaction(ctx context.Context, tx *apitypes.ManagedTX, new bool) error // must reject if new is true, and the request ID is no
+	WriteNewTransactions(ctx context.Context, txs []*apitypes.ManagedTX) error    // atomic - must reject all if any request ID is not unique
 	DeleteTransaction(ctx context.Context, txID string) error
 
+	GetNonceFloor(ctx context.Context, signer string) (*fftypes.FFBigInt, error) // the lowest nonce to allocate next for the signer, established by a resync
+	WriteNonceFloor(ctx context.Context, signer string, floor *fftypes.FFBigInt) error
+	DeleteNonceFloor(ctx context.Context, signer string) error
+
+	ListConfirmationStates(ctx context.Context, managerID string) ([]*ConfirmationState, error)                             // in key order, for a single confirmation manager
+	WriteConfirmationStates(ctx context.Context, managerID string, states []*ConfirmationState, deletedKeys []string) error // atomic - the writes and deletes are a single batch
+	DeleteConfirmationStates(ctx context.Context, managerID string) error                                                   // all the state of a confirmation manager
+
 	Close(ctx context.Context)
 }
+
+// ConfirmationState is the persisted form of an item pending confirmation in a confirmation manager
+type ConfirmationState struct {
+	Key             string                             `json:"key"`
+	ListenerID      *fftypes.UUID                      `json:"listenerId,omitempty"` // events only
+	TransactionHash string                             `json:"transactionHash"`
+	BlockNumber     fftypes.FFuint64                   `json:"blockNumber"`
+	BlockHash       string                             `json:"blockHash"`
+	Receipt         *ffcapi.TransactionReceiptResponse `json:"receipt,omitempty"` // transactions only
+	Confirmations   []apitypes.BlockInfo               `json:"confirmations,omitempty"`
+	Updated         *fftypes.FFTime                    `json:"updated"`
+}
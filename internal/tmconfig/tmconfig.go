@@ -30,10 +30,24 @@ var (
 	ConfirmationsBlockQueueLength                 = ffc("confirmations.blockQueueLength")
 	ConfirmationsStaleReceiptTimeout              = ffc("confirmations.staleReceiptTimeout")
 	ConfirmationsNotificationQueueLength          = ffc("confirmations.notificationQueueLength")
+	ConfirmationsProgressPersistInterval          = ffc("confirmations.progressPersistInterval")
+	ConfirmationsMode                             = ffc("confirmations.mode")
+	ConfirmationsBlockCacheSize                   = ffc("confirmations.blockCacheSize")
+	ConfirmationsRestoreTimeout                   = ffc("confirmations.restoreTimeout")
+	ConfirmationsReorgHistorySize                 = ffc("confirmations.reorgHistorySize")
 	TransactionsErrorHistoryCount                 = ffc("transactions.errorHistoryCount")
 	TransactionsMaxInFlight                       = ffc("transactions.maxInFlight")
+	TransactionsMaxInFlightPerSigner              = ffc("transactions.maxInFlightPerSigner")
+	TransactionsMaxInFlightSignerOverrides        = ffc("transactions.maxInFlightSignerOverrides")
 	TransactionsNonceStateTimeout                 = ffc("transactions.nonceStateTimeout")
+	TransactionsPausedSignerProbeInterval         = ffc("transactions.pausedSignerProbeInterval")
+	TransactionsSimulate                          = ffc("transactions.simulate")
+	TransactionsReconcileInterval                 = ffc("transactions.reconcile.interval")
+	TransactionsReconcileAutoFill                 = ffc("transactions.reconcile.autoFill")
+	TransactionsSupersededCheckInterval           = ffc("transactions.superseded.checkInterval")
+	TransactionsSupersededGracePeriod             = ffc("transactions.superseded.gracePeriod")
 	PolicyLoopInterval                            = ffc("policyloop.interval")
+	PolicyLoopWorkers                             = ffc("policyloop.workers")
 	PolicyLoopRetryInitDelay                      = ffc("policyloop.retry.initialDelay")
 	PolicyLoopRetryMaxDelay                       = ffc("policyloop.retry.maxDelay")
 	PolicyLoopRetryFactor                         = ffc("policyloop.retry.factor")
@@ -56,6 +70,8 @@ var (
 	PersistenceLevelDBSyncWrites                  = ffc("persistence.leveldb.syncWrites")
 	APIDefaultRequestTimeout                      = ffc("api.defaultRequestTimeout")
 	APIMaxRequestTimeout                          = ffc("api.maxRequestTimeout")
+	APIAdminTopic                                 = ffc("api.adminTopic")
+	APISubStatusScanLimit                         = ffc("api.subStatusScanLimit")
 	DebugPort                                     = ffc("debug.port")
 )
 
@@ -69,13 +85,26 @@ var WebhookPrefix config.Section
 
 func setDefaults() {
 	viper.SetDefault(string(TransactionsMaxInFlight), 100)
+	viper.SetDefault(string(TransactionsMaxInFlightPerSigner), 0)
 	viper.SetDefault(string(TransactionsErrorHistoryCount), 25)
 	viper.SetDefault(string(TransactionsNonceStateTimeout), "1h")
+	viper.SetDefault(string(TransactionsPausedSignerProbeInterval), "1m")
+	viper.SetDefault(string(TransactionsSimulate), false)
+	viper.SetDefault(string(TransactionsReconcileInterval), "1m")
+	viper.SetDefault(string(TransactionsReconcileAutoFill), false)
+	viper.SetDefault(string(TransactionsSupersededCheckInterval), "5m")
+	viper.SetDefault(string(TransactionsSupersededGracePeriod), "1m")
 	viper.SetDefault(string(ConfirmationsRequired), 20)
 	viper.SetDefault(string(ConfirmationsBlockQueueLength), 50)
+	viper.SetDefault(string(ConfirmationsBlockCacheSize), 1000)
 	viper.SetDefault(string(ConfirmationsNotificationQueueLength), 50)
 	viper.SetDefault(string(ConfirmationsStaleReceiptTimeout), "1m")
+	viper.SetDefault(string(ConfirmationsProgressPersistInterval), "5s")
+	viper.SetDefault(string(ConfirmationsRestoreTimeout), "5m")
+	viper.SetDefault(string(ConfirmationsReorgHistorySize), 100)
+	viper.SetDefault(string(ConfirmationsMode), "blocks")
 	viper.SetDefault(string(PolicyLoopInterval), "10s")
+	viper.SetDefault(string(PolicyLoopWorkers), 1)
 	viper.SetDefault(string(PolicyEngineName), "simple")
 
 	viper.SetDefault(string(EventStreamsDefaultsBatchSize), 50)
@@ -94,6 +123,8 @@ func setDefaults() {
 
 	viper.SetDefault(string(APIDefaultRequestTimeout), "30s")
 	viper.SetDefault(string(APIMaxRequestTimeout), "10m")
+	viper.SetDefault(string(APIAdminTopic), "fftm_admin")
+	viper.SetDefault(string(APISubStatusScanLimit), 1000)
 
 	viper.SetDefault(string(PolicyLoopRetryInitDelay), "250ms")
 	viper.SetDefault(string(PolicyLoopRetryMaxDelay), "30s")
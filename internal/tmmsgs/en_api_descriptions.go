@@ -27,7 +27,7 @@ var ffm = func(key, translation string) i18n.MessageKey {
 
 //revive:disable
 var (
-	APIEndpointPostRoot                     = ffm("api.endpoints.post.root", "RPC/webhook style interface initiate a submit transactions, and execute queries")
+	APIEndpointPostRoot                     = ffm("api.endpoints.post.root", "RPC/webhook style interface initiate a submit transactions (individually or in batches from a single signer), and execute queries")
 	APIEndpointPostRootQueryOutput          = ffm("api.endpoints.post.root.query.output", "The data result of a query against a smart contract")
 	APIEndpointPostEventStream              = ffm("api.endpoints.post.eventstreams", "Create a new event stream")
 	APIEndpointPatchEventStream             = ffm("api.endpoints.patch.eventstreams", "Update an existing event stream")
@@ -36,7 +36,8 @@ var (
 	APIEndpointGetEventStreams              = ffm("api.endpoints.get.eventstreams", "List event streams")
 	APIEndpointGetEventStream               = ffm("api.endpoints.get.eventstream", "Get an event stream with status")
 	APIEndpointDeleteEventStream            = ffm("api.endpoints.delete.eventstream", "Delete an event stream")
-	APIEndpointDeleteTransaction            = ffm("api.endpoints.delete.transaction", "Request transaction deletion by the policy engine. Result could be immediate (200), asynchronous (202), or rejected with an error")
+	APIEndpointDeleteTransaction            = ffm("api.endpoints.delete.transaction", "Request transaction deletion by the policy engine. Result could be immediate (200), asynchronous (202), or rejected with an error. Submitted transactions are cancelled by replacement at the same nonce, with progress reported in the cancellation field")
+	APIEndpointPostTransactionSpeedUp       = ffm("api.endpoints.post.transaction.speedup", "Force an immediate resubmission of a pending transaction at the same nonce, with a supplied gas price or a multiple of the current gas price")
 	APIEndpointGetSubscriptions             = ffm("api.endpoints.get.subscriptions", "Get listeners - route deprecated in favor of /eventstreams/{streamId}/listeners")
 	APIEndpointGetSubscription              = ffm("api.endpoints.get.subscription", "Get listener - route deprecated in favor of /eventstreams/{streamId}/listeners/{listenerId}")
 	APIEndpointPostSubscriptions            = ffm("api.endpoints.post.subscriptions", "Create new listener - route deprecated in favor of /eventstreams/{streamId}/listeners")
@@ -49,6 +50,14 @@ var (
 	APIEndpointPostEventStreamListenerReset = ffm("api.endpoints.post.eventstream.listener.reset", "Reset an event stream listener, to redeliver all events since the specified block")
 	APIEndpointPatchEventStreamListener     = ffm("api.endpoints.patch.eventstream.listener", "Update event stream listener")
 	APIEndpointDeleteEventStreamListener    = ffm("api.endpoints.delete.eventstream.listener", "Delete event stream listener")
+	APIEndpointGetSigners                   = ffm("api.endpoints.get.signers", "List the signing addresses that nonces have been allocated for")
+	APIEndpointGetSignerNonce               = ffm("api.endpoints.get.signer.nonce", "Get the nonce state of a signing address, comparing the next nonce that will be allocated with the state store and the blockchain node")
+	APIEndpointGetNonceFindings             = ffm("api.endpoints.get.nonce.findings", "List the nonce gaps, and nonces used outside of the connector, found by the last reconciliation of pending transactions with the blockchain node")
+	APIEndpointPostSignerNonceResync        = ffm("api.endpoints.post.signer.nonce.resync", "Realign the next nonce of a signing address with the blockchain node, for example after transactions have been submitted outside of the connector. The realignment is persisted until the next transaction is submitted for the signer")
+	APIEndpointPostSignerResume             = ffm("api.endpoints.post.signer.resume", "Resume submission of transactions for a signing address that was paused due to insufficient funds, without waiting for the next probe")
+	APIEndpointGetBlockCache                = ffm("api.endpoints.get.blockcache", "Get the size and hit/miss statistics of the block header cache shared by all confirmation managers")
+	APIEndpointGetReorgs                    = ffm("api.endpoints.get.reorgs", "List the most recent chain reorgs detected under pending transactions and events, with the replaced blocks and the affected listeners and transactions")
+	APIEndpointGetReorgMetrics              = ffm("api.endpoints.get.reorgs.metrics", "Get the number of chain reorgs detected under pending transactions and events since startup, by depth and by confirmation manager")
 
 	APIParamStreamID      = ffm("api.params.streamId", "Event Stream ID")
 	APIParamListenerID    = ffm("api.params.listenerId", "Listener ID")
@@ -58,4 +67,8 @@ var (
 	APIParamTXSigner      = ffm("api.params.txSigner", "Return only transactions for a specific signing address, in reverse nonce order")
 	APIParamTXPending     = ffm("api.params.txPending", "Return only pending transactions, in reverse submission sequence (a 'sequenceId' is assigned to each transaction to determine its sequence")
 	APIParamSortDirection = ffm("api.params.sortDirection", "Sort direction: 'asc'/'ascending' or 'desc'/'descending'")
+	APIParamTXSubStatus   = ffm("api.params.txSubStatus", "Return only transactions with this sub-status, such as Queued, Submitted, Mined or Confirmed. Only a limited number of transactions are scanned to find them, so use 'after' to page through a large number of transactions")
+	APIParamSignerAddress = ffm("api.params.signerAddress", "Signing address")
+	APIParamFindingSigner = ffm("api.params.findingSigner", "Return only the findings for a specific signing address")
+	APIParamSignerAfter   = ffm("api.params.signerAfter", "Return signers after this address - for pagination (non-inclusive)")
 )
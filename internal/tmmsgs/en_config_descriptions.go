@@ -29,6 +29,8 @@ var ffc = func(key, translation, fieldType string) i18n.ConfigMessageKey {
 var (
 	ConfigAPIDefaultRequestTimeout = ffc("config.api.defaultRequestTimeout", "Default server-side request timeout for API calls", i18n.TimeDurationType)
 	ConfigAPIMaxRequestTimeout     = ffc("config.api.maxRequestTimeout", "Maximum server-side request timeout a caller can request with a Request-Timeout header", i18n.TimeDurationType)
+	ConfigAPIAdminTopic            = ffc("config.api.adminTopic", "The websocket topic on which administrative notifications are published, such as chain reorgs detected under pending transactions and events. Set to an empty string to disable", i18n.StringType)
+	ConfigAPISubStatusScanLimit    = ffc("config.api.subStatusScanLimit", "The maximum number of transactions scanned by a single query for transactions with a sub-status, as there is no index for sub-status. Transactions beyond this many from the start of the query are not returned", i18n.IntType)
 	ConfigAPIAddress               = ffc("config.api.address", "Listener address for API", i18n.StringType)
 	ConfigAPIPort                  = ffc("config.api.port", "Listener port for API", i18n.IntType)
 	ConfigAPIPublicURL             = ffc("config.api.publicURL", "External address callers should access API over", i18n.StringType)
@@ -40,19 +42,33 @@ var (
 
 	ConfigConfirmationsBlockCacheSize           = ffc("config.confirmations.blockCacheSize", "The maximum number of block headers to keep in the cache", i18n.IntType)
 	ConfigConfirmationsBlockQueueLength         = ffc("config.confirmations.blockQueueLength", "Internal queue length for notifying the confirmations manager of new blocks", i18n.IntType)
+	ConfigConfirmationsMode                     = ffc("config.confirmations.mode", "How transactions and events are considered final. 'blocks' waits for the required number of confirmation blocks. 'finalized' waits until the block is at or below the finalized height reported by the connector, if the connector supports it", i18n.StringType)
 	ConfigConfirmationsNotificationsQueueLength = ffc("config.confirmations.notificationQueueLength", "Internal queue length for notifying the confirmations manager of new transactions/events", i18n.IntType)
-	ConfigConfirmationsRequired                 = ffc("config.confirmations.required", "Number of confirmations required to consider a transaction/event final", i18n.IntType)
+	ConfigConfirmationsProgressInterval         = ffc("config.confirmations.progressPersistInterval", "The minimum interval between persisting the confirmation progress of a mined transaction. Each new confirmation is still sent on the websocket, and the final confirmations are always persisted", i18n.TimeDurationType)
+	ConfigConfirmationsReorgHistorySize         = ffc("config.confirmations.reorgHistorySize", "The maximum number of chain reorgs detected under pending transactions and events to keep, for query via the API", i18n.IntType)
+	ConfigConfirmationsRestoreTimeout           = ffc("config.confirmations.restoreTimeout", "The receipts and confirmations of pending transactions and events are persisted, and restored after a restart when they are detected again. State that has not been claimed after this duration is discarded", i18n.TimeDurationType)
+	ConfigConfirmationsRequired                 = ffc("config.confirmations.required", "Number of confirmations required to consider a transaction/event final. Can be overridden by the confirmations header of each transaction request. Also the default for the confirmations of each event stream, which can be overridden for each listener", i18n.IntType)
 	ConfigConfirmationsStaleReceiptTimeout      = ffc("config.confirmations.staleReceiptTimeout", "Duration after which to force a receipt check for a pending transaction", i18n.TimeDurationType)
 
 	ConfigTransactionsErrorHistoryCount = ffc("config.transactions.errorHistoryCount", "The number of historical errors to retain in the operation", i18n.IntType)
 	ConfigTransactionsMaxInflight       = ffc("config.transactions.maxInFlight", "The maximum number of transactions to have in-flight with the policy engine / blockchain transaction pool", i18n.IntType)
+	ConfigTransactionsMaxInflightSigner = ffc("config.transactions.maxInFlightPerSigner", "The maximum number of transactions for each signing address to have in-flight. Zero means each signer is only limited by maxInFlight. Signers are always given in-flight slots in turn", i18n.IntType)
+	ConfigTransactionsSignerOverrides   = ffc("config.transactions.maxInFlightSignerOverrides", "A map of signing address to the maximum number of transactions to have in-flight for that signer, overriding maxInFlightPerSigner", "map[string]int")
 	ConfigTransactionsNonceStateTimeout = ffc("config.transactions.nonceStateTimeout", "How old the most recently submitted transaction record in our local state needs to be, before we make a request to the node to query the next nonce for a signing address", i18n.TimeDurationType)
+	ConfigTransactionsPausedSignerProbe = ffc("config.transactions.pausedSignerProbeInterval", "When transactions for a signer are paused because it has insufficient funds, how often to pass one of its transactions to the policy engine to check whether it can resume", i18n.TimeDurationType)
+	ConfigTransactionsSimulate          = ffc("config.transactions.simulate", "Whether to execute each transaction as a query against the blockchain node before allocating a nonce, so that transactions that would revert are rejected without spending gas. Can be overridden by the simulate header of each request", i18n.BooleanType)
+	ConfigTransactionsReconcileInterval = ffc("config.transactions.reconcile.interval", "How often to compare the nonces of pending transactions with the next nonce of the node for each signer, to find gaps and nonces used outside of the connector. Zero disables reconciliation", i18n.TimeDurationType)
+	ConfigTransactionsReconcileAutoFill = ffc("config.transactions.reconcile.autoFill", "Whether to submit a no-op transaction (a zero value transfer from the signer to itself) to fill each gap found by reconciliation", i18n.BooleanType)
+	ConfigTransactionsSupersededCheck   = ffc("config.transactions.superseded.checkInterval", "How long a submitted transaction can go without a receipt, before we check whether its nonce has been used by another transaction from the same signer. Should be at least the resubmit interval of the policy engine. The check is also made when the node rejects a submission because the nonce is too low", i18n.TimeDurationType)
+	ConfigTransactionsSupersededGrace   = ffc("config.transactions.superseded.gracePeriod", "How long the nonce of a transaction must remain used by another transaction, with no receipt for any of our submissions, before the transaction is moved to the Superseded status", i18n.TimeDurationType)
 
 	ConfigPolicyEngineName = ffc("config.policyengine.name", "The name of the policy engine to use", i18n.StringType)
 
 	ConfigLoopInterval = ffc("config.policyloop.interval", "Interval at which to invoke the policy engine to evaluate outstanding transactions", i18n.TimeDurationType)
+	ConfigLoopWorkers  = ffc("config.policyloop.workers", "The number of workers that evaluate the in-flight transactions in parallel. Transactions are distributed between workers by signing address, so that each signer is processed in nonce order", i18n.IntType)
 
 	ConfigPolicyEngineSimpleFixedGasPrice          = ffc("config.policyengine.simple.fixedGasPrice", "A fixed gasPrice value/structure to pass to the connector", "Raw JSON")
+	ConfigPolicyEngineSimpleCancelGasPriceIncrease = ffc("config.policyengine.simple.cancelGasPriceIncrease", "The percentage increase over the gas price of a submitted transaction, used for the replacement transaction that cancels it when deletion is requested", i18n.FloatType)
 	ConfigPolicyEngineSimpleResubmitInterval       = ffc("config.policyengine.simple.resubmitInterval", "The time between warning and re-sending a transaction (same nonce) when a blockchain transaction has not been allocated a receipt", i18n.TimeDurationType)
 	ConfigPolicyEngineSimpleGasOracleEnabled       = ffc("config.policyengine.simple.gasOracle.mode", "The gas oracle mode", "connector | restapi | disabled")
 	ConfigPolicyEngineSimpleGasOracleGoTemplate    = ffc("config.policyengine.simple.gasOracle.template", "REST API Gas Oracle: A go template to execute against the result from the Gas Oracle, to create a JSON block that will be passed as the gas price to the connector", i18n.GoTemplateType)
@@ -61,6 +77,14 @@ var (
 	ConfigPolicyEngineSimpleGasOracleMethod        = ffc("config.policyengine.simple.gasOracle.method", "The HTTP Method to use when invoking the Gas Oracle REST API", i18n.StringType)
 	ConfigPolicyEngineSimpleGasOracleQueryInterval = ffc("config.policyengine.simple.gasOracle.queryInterval", "The minimum interval between queries to the Gas Oracle", i18n.TimeDurationType)
 
+	ConfigPolicyEngineEscalatingFixedGasPrice          = ffc("config.policyengine.escalating.fixedGasPrice", "A fixed gasPrice value/structure to use for the first submission of each transaction, when not using a gas oracle", "Raw JSON")
+	ConfigPolicyEngineEscalatingResubmitInterval       = ffc("config.policyengine.escalating.resubmitInterval", "The time to wait for a receipt, before escalating the gas price and re-sending a transaction (same nonce)", i18n.TimeDurationType)
+	ConfigPolicyEngineEscalatingIncreasePercentage     = ffc("config.policyengine.escalating.increasePercentage", "The percentage to increase the gas price by on each escalation", i18n.FloatType)
+	ConfigPolicyEngineEscalatingIncreaseStep           = ffc("config.policyengine.escalating.increaseStep", "A fixed amount to add to the gas price on each escalation, after the percentage increase is applied", i18n.StringType)
+	ConfigPolicyEngineEscalatingMaxGasPrice            = ffc("config.policyengine.escalating.maxGasPrice", "The ceiling for gas price escalation. Applied to each numeric field when the gas price is an object", i18n.StringType)
+	ConfigPolicyEngineEscalatingGasOracleMode          = ffc("config.policyengine.escalating.gasOracle.mode", "The gas oracle mode used to determine the gas price for the first submission", "connector | disabled")
+	ConfigPolicyEngineEscalatingGasOracleQueryInterval = ffc("config.policyengine.escalating.gasOracle.queryInterval", "The minimum interval between queries to the Gas Oracle", i18n.TimeDurationType)
+
 	ConfigEventStreamsDefaultsBatchSize                 = ffc("config.eventstreams.defaults.batchSize", "Default batch size for newly created event streams", i18n.IntType)
 	ConfigEventStreamsDefaultsBatchTimeout              = ffc("config.eventstreams.defaults.batchTimeout", "Default batch timeout for newly created event streams", i18n.TimeDurationType)
 	ConfigEventStreamsDefaultsErrorHandling             = ffc("config.eventstreams.defaults.errorHandling", "Default error handling for newly created event streams", "'skip' or 'block'")
@@ -85,4 +85,25 @@ var (
 	MsgTransactionNotFound           = ffe("FF21067", "Transaction '%s' not found", http.StatusNotFound)
 	MsgPolicyEngineRequestTimeout    = ffe("FF21068", "The policy engine did not acknowledge the request after %.2fs", 408)
 	MsgPolicyEngineRequestInvalid    = ffe("FF21069", "Invalid policy engine request type '%d'")
+	MsgGasPriceNotEscalatable        = ffe("FF21070", "Gas price '%s' cannot be escalated. Must be a number, a numeric string, or an object containing numeric fields")
+	MsgInvalidPolicyEngineConfig     = ffe("FF21071", "Invalid value '%s' for '%s' in policy engine configuration")
+	MsgSpeedUpRequestInvalid         = ffe("FF21072", "Exactly one of 'gasPrice' or 'gasPriceMultiplier' must be supplied to speed up a transaction", http.StatusBadRequest)
+	MsgSpeedUpMultiplierInvalid      = ffe("FF21073", "Invalid gas price multiplier '%s'. Must be greater than 1", http.StatusBadRequest)
+	MsgSpeedUpNotInFlight            = ffe("FF21074", "Transaction '%s' cannot be sped up, as it is not submitted and awaiting a receipt", http.StatusConflict)
+	MsgInvalidRequestTime            = ffe("FF21075", "Invalid '%s' value '%s'. Must be a duration such as '30m', or an absolute time", http.StatusBadRequest)
+	MsgTransactionExpired            = ffe("FF21076", "Transaction expired at %s before it was mined")
+	MsgTransactionExpiredCancelling  = ffe("FF21077", "Transaction expired at %s before it was mined, and is being cancelled")
+	MsgTransactionBatchEmpty         = ffe("FF21078", "Transaction batch must contain at least one transaction", http.StatusBadRequest)
+	MsgTransactionBatchMixedSigners  = ffe("FF21079", "All transactions in a batch must have the same signer. Expected '%s' but found '%s'", http.StatusBadRequest)
+	MsgDependencyNotFound            = ffe("FF21080", "Dependency '%s' not found. Dependencies must be submitted before the transactions that depend on them", http.StatusBadRequest)
+	MsgDependencyFailed              = ffe("FF21081", "Dependency '%s' did not succeed")
+	MsgDuplicateIDConflict           = ffe("FF21082", "ID '%s' has already been used for a different request. Existing request hash '%s', new request hash '%s'", http.StatusConflict)
+	MsgTransactionSuperseded         = ffe("FF21083", "Nonce %s of signer '%s' was used by another transaction, and none of the %d transaction hashes we submitted has a receipt")
+	MsgTransactionSimulationReverted = ffe("FF21084", "Transaction reverted when simulated before submission: %s", http.StatusBadRequest)
+	MsgTransactionReverted           = ffe("FF21085", "Transaction execution failed: %s")
+	MsgInvalidConfirmations          = ffe("FF21086", "Invalid confirmations '%d' - the number of confirmations required cannot be negative", http.StatusBadRequest)
+	MsgInvalidSignerInflightLimit    = ffe("FF21087", "Invalid in-flight limit '%v' for signer '%s' in '%s'. Must be a whole number that is not negative")
+	MsgSpeedUpNotSubmitted           = ffe("FF21088", "The policy engine did not resubmit transaction '%s' with the requested gas price", http.StatusConflict)
+	MsgSpeedUpGasPriceNotIncreased   = ffe("FF21089", "Gas price '%s' is not greater than the current gas price '%s' of the transaction", http.StatusBadRequest)
+	MsgSpeedUpNotInflightSet         = ffe("FF21090", "Transaction '%s' cannot be sped up, as it is not yet in the in-flight set of transactions being managed by the policy engine", http.StatusConflict)
 )
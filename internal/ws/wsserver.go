@@ -151,11 +151,16 @@ func (s *webSocketServer) GetChannels(topic string) (chan<- interface{}, chan<-
 }
 
 func (s *webSocketServer) ListenOnTopic(c *webSocketConnection, topic string) {
-	// Track that this connection is interested in this topic
+	// Track that this connection is interested in this topic.
+	// The lock is needed as the broadcaster reads the map concurrently.
+	s.mux.Lock()
+	defer s.mux.Unlock()
 	s.topicMap[topic][c.id] = c
 }
 
 func (s *webSocketServer) ListenForReplies(c *webSocketConnection) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
 	s.replyMap[c.id] = c
 }
 
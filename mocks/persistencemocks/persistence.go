@@ -38,6 +38,20 @@ func (_m *Persistence) DeleteCheckpoint(ctx context.Context, streamID *fftypes.U
 	return r0
 }
 
+// DeleteConfirmationStates provides a mock function with given fields: ctx, managerID
+func (_m *Persistence) DeleteConfirmationStates(ctx context.Context, managerID string) error {
+	ret := _m.Called(ctx, managerID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, managerID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // DeleteListener provides a mock function with given fields: ctx, listenerID
 func (_m *Persistence) DeleteListener(ctx context.Context, listenerID *fftypes.UUID) error {
 	ret := _m.Called(ctx, listenerID)
@@ -52,6 +66,20 @@ func (_m *Persistence) DeleteListener(ctx context.Context, listenerID *fftypes.U
 	return r0
 }
 
+// DeleteNonceFloor provides a mock function with given fields: ctx, signer
+func (_m *Persistence) DeleteNonceFloor(ctx context.Context, signer string) error {
+	ret := _m.Called(ctx, signer)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, signer)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // DeleteStream provides a mock function with given fields: ctx, streamID
 func (_m *Persistence) DeleteStream(ctx context.Context, streamID *fftypes.UUID) error {
 	ret := _m.Called(ctx, streamID)
@@ -126,6 +154,29 @@ func (_m *Persistence) GetListener(ctx context.Context, listenerID *fftypes.UUID
 	return r0, r1
 }
 
+// GetNonceFloor provides a mock function with given fields: ctx, signer
+func (_m *Persistence) GetNonceFloor(ctx context.Context, signer string) (*fftypes.FFBigInt, error) {
+	ret := _m.Called(ctx, signer)
+
+	var r0 *fftypes.FFBigInt
+	if rf, ok := ret.Get(0).(func(context.Context, string) *fftypes.FFBigInt); ok {
+		r0 = rf(ctx, signer)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*fftypes.FFBigInt)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, signer)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetStream provides a mock function with given fields: ctx, streamID
 func (_m *Persistence) GetStream(ctx context.Context, streamID *fftypes.UUID) (*apitypes.EventStream, error) {
 	ret := _m.Called(ctx, streamID)
@@ -195,6 +246,29 @@ func (_m *Persistence) GetTransactionByNonce(ctx context.Context, signer string,
 	return r0, r1
 }
 
+// ListConfirmationStates provides a mock function with given fields: ctx, managerID
+func (_m *Persistence) ListConfirmationStates(ctx context.Context, managerID string) ([]*persistence.ConfirmationState, error) {
+	ret := _m.Called(ctx, managerID)
+
+	var r0 []*persistence.ConfirmationState
+	if rf, ok := ret.Get(0).(func(context.Context, string) []*persistence.ConfirmationState); ok {
+		r0 = rf(ctx, managerID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*persistence.ConfirmationState)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, managerID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ListListeners provides a mock function with given fields: ctx, after, limit, dir
 func (_m *Persistence) ListListeners(ctx context.Context, after *fftypes.UUID, limit int, dir persistence.SortDirection) ([]*apitypes.Listener, error) {
 	ret := _m.Called(ctx, after, limit, dir)
@@ -218,6 +292,29 @@ func (_m *Persistence) ListListeners(ctx context.Context, after *fftypes.UUID, l
 	return r0, r1
 }
 
+// ListSigners provides a mock function with given fields: ctx, after, limit, dir
+func (_m *Persistence) ListSigners(ctx context.Context, after string, limit int, dir persistence.SortDirection) ([]string, error) {
+	ret := _m.Called(ctx, after, limit, dir)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(context.Context, string, int, persistence.SortDirection) []string); ok {
+		r0 = rf(ctx, after, limit, dir)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, int, persistence.SortDirection) error); ok {
+		r1 = rf(ctx, after, limit, dir)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ListStreamListeners provides a mock function with given fields: ctx, after, limit, dir, streamID
 func (_m *Persistence) ListStreamListeners(ctx context.Context, after *fftypes.UUID, limit int, dir persistence.SortDirection, streamID *fftypes.UUID) ([]*apitypes.Listener, error) {
 	ret := _m.Called(ctx, after, limit, dir, streamID)
@@ -347,6 +444,20 @@ func (_m *Persistence) WriteCheckpoint(ctx context.Context, checkpoint *apitypes
 	return r0
 }
 
+// WriteConfirmationStates provides a mock function with given fields: ctx, managerID, states, deletedKeys
+func (_m *Persistence) WriteConfirmationStates(ctx context.Context, managerID string, states []*persistence.ConfirmationState, deletedKeys []string) error {
+	ret := _m.Called(ctx, managerID, states, deletedKeys)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, []*persistence.ConfirmationState, []string) error); ok {
+		r0 = rf(ctx, managerID, states, deletedKeys)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // WriteListener provides a mock function with given fields: ctx, spec
 func (_m *Persistence) WriteListener(ctx context.Context, spec *apitypes.Listener) error {
 	ret := _m.Called(ctx, spec)
@@ -361,6 +472,34 @@ func (_m *Persistence) WriteListener(ctx context.Context, spec *apitypes.Listene
 	return r0
 }
 
+// WriteNewTransactions provides a mock function with given fields: ctx, txs
+func (_m *Persistence) WriteNewTransactions(ctx context.Context, txs []*apitypes.ManagedTX) error {
+	ret := _m.Called(ctx, txs)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []*apitypes.ManagedTX) error); ok {
+		r0 = rf(ctx, txs)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// WriteNonceFloor provides a mock function with given fields: ctx, signer, floor
+func (_m *Persistence) WriteNonceFloor(ctx context.Context, signer string, floor *fftypes.FFBigInt) error {
+	ret := _m.Called(ctx, signer, floor)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, *fftypes.FFBigInt) error); ok {
+		r0 = rf(ctx, signer, floor)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // WriteStream provides a mock function with given fields: ctx, spec
 func (_m *Persistence) WriteStream(ctx context.Context, spec *apitypes.EventStream) error {
 	ret := _m.Called(ctx, spec)
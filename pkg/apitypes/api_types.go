@@ -60,6 +60,7 @@ type EventStream struct {
 	BatchTimeout      *fftypes.FFDuration `ffstruct:"eventstream" json:"batchTimeout"`
 	RetryTimeout      *fftypes.FFDuration `ffstruct:"eventstream" json:"retryTimeout"`
 	BlockedRetryDelay *fftypes.FFDuration `ffstruct:"eventstream" json:"blockedRetryDelay"`
+	Confirmations     *uint64             `ffstruct:"eventstream" json:"confirmations"` // the number of confirmations required before events are delivered - zero for immediate delivery
 
 	EthCompatBatchTimeoutMS       *uint64 `ffstruct:"eventstream" json:"batchTimeoutMS,omitempty"`       // input only, for backwards compatibility
 	EthCompatRetryTimeoutSec      *uint64 `ffstruct:"eventstream" json:"retryTimeoutSec,omitempty"`      // input only, for backwards compatibility
@@ -114,6 +115,7 @@ type Listener struct {
 	Options          *fftypes.JSONAny  `ffstruct:"listener" json:"options"`
 	Signature        string            `ffstruct:"listener" json:"signature,omitempty" ffexcludeinput:"true"`
 	FromBlock        *string           `ffstruct:"listener" json:"fromBlock,omitempty"`
+	Confirmations    *uint64           `ffstruct:"listener" json:"confirmations,omitempty"` // optional - overrides the confirmations of the event stream for this listener
 }
 
 type ListenerWithStatus struct {
@@ -121,6 +123,22 @@ type ListenerWithStatus struct {
 	ffcapi.EventListenerHWMResponse
 }
 
+// BlockInfo is a block tracked by the confirmation managers, such as one confirming a transaction or event
+type BlockInfo struct {
+	BlockNumber       fftypes.FFuint64 `json:"blockNumber"`
+	BlockHash         string           `json:"blockHash"`
+	ParentHash        string           `json:"parentHash"`
+	TransactionHashes []string         `json:"transactionHashes,omitempty"`
+}
+
+// BlockCacheStats are the statistics of the block cache shared by the confirmation managers, since it was created
+type BlockCacheStats struct {
+	Size     int    `json:"size"`
+	Capacity int    `json:"capacity"`
+	Hits     uint64 `json:"hits"`
+	Misses   uint64 `json:"misses"`
+}
+
 // CheckUpdateString helper merges supplied configuration, with a base, and applies a default if unset
 func CheckUpdateString(changed bool, merged **string, old *string, new *string, defValue string) bool {
 	if new != nil {
@@ -38,14 +38,20 @@ func (br *BaseRequest) UnmarshalTo(o interface{}) error {
 }
 
 type RequestHeaders struct {
-	ID   string      `ffstruct:"fftmrequest" json:"id"`
-	Type RequestType `json:"type"`
+	ID            string      `ffstruct:"fftmrequest" json:"id"`
+	Type          RequestType `json:"type"`
+	NotBefore     string      `ffstruct:"fftmrequest" json:"notBefore,omitempty"`     // optional - the transaction is not submitted before this time (absolute, or a duration such as "1h" from when the request is received)
+	Expiry        string      `ffstruct:"fftmrequest" json:"expiry,omitempty"`        // optional - an absolute time, or a duration such as "30m" from when the request is received
+	DependsOn     []string    `ffstruct:"fftmrequest" json:"dependsOn,omitempty"`     // optional - IDs of transactions that must succeed before this transaction is submitted
+	Simulate      *bool       `ffstruct:"fftmrequest" json:"simulate,omitempty"`      // optional - overrides the transactions.simulate config for a SendTransaction request, to execute the transaction as a query before allocating a nonce
+	Confirmations *int        `ffstruct:"fftmrequest" json:"confirmations,omitempty"` // optional - overrides the confirmations.required config for a SendTransaction or DeployContract request
 }
 
 type RequestType string
 
 const (
-	RequestTypeSendTransaction RequestType = "SendTransaction"
-	RequestTypeQuery           RequestType = "Query"
-	RequestTypeDeploy          RequestType = "DeployContract"
+	RequestTypeSendTransaction      RequestType = "SendTransaction"
+	RequestTypeSendTransactionBatch RequestType = "SendTransactionBatch"
+	RequestTypeQuery                RequestType = "Query"
+	RequestTypeDeploy               RequestType = "DeployContract"
 )
@@ -18,7 +18,6 @@ package apitypes
 
 import (
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
-	"github.com/hyperledger/firefly-transaction-manager/internal/confirmations"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
 )
 
@@ -32,8 +31,30 @@ const (
 	TxStatusSucceeded TxStatus = "Succeeded"
 	// TxStatusFailed happens when an error is reported by the infrastructure runtime
 	TxStatusFailed TxStatus = "Failed"
+	// TxStatusSuperseded happens when the nonce was used by another transaction from the same signer, submitted outside of FFTM
+	TxStatusSuperseded TxStatus = "Superseded"
 )
 
+// TxSubStatus is the progress of a transaction through its lifecycle, in more detail than the TxStatus
+type TxSubStatus string
+
+const (
+	// TxSubStatusQueued the transaction has been allocated a nonce, and is waiting to be submitted to the blockchain
+	TxSubStatusQueued TxSubStatus = "Queued"
+	// TxSubStatusSubmitted the transaction has been submitted to the blockchain, and is waiting to be mined
+	TxSubStatusSubmitted TxSubStatus = "Submitted"
+	// TxSubStatusMined a receipt has been received for the transaction, and it is waiting for confirmations
+	TxSubStatusMined TxSubStatus = "Mined"
+	// TxSubStatusConfirmed the receipt of the transaction has been confirmed
+	TxSubStatusConfirmed TxSubStatus = "Confirmed"
+)
+
+// TxSubStatusEntry records a transition of the sub-status of a transaction
+type TxSubStatusEntry struct {
+	SubStatus TxSubStatus     `json:"subStatus"`
+	Time      *fftypes.FFTime `json:"time"`
+}
+
 type ManagedTXError struct {
 	Time   *fftypes.FFTime    `json:"time"`
 	Error  string             `json:"error,omitempty"`
@@ -57,33 +78,95 @@ type ManagedTXError struct {
 //   - When listing back entries, the persistence layer will automatically clean up indexes if the underlying
 //     TX they refer to is not available. For this reason the index records are written first.
 type ManagedTX struct {
-	ID                 string                             `json:"id"`
-	Created            *fftypes.FFTime                    `json:"created"`
-	Updated            *fftypes.FFTime                    `json:"updated"`
-	Status             TxStatus                           `json:"status"`
-	DeleteRequested    *fftypes.FFTime                    `json:"deleteRequested,omitempty"`
-	SequenceID         *fftypes.UUID                      `json:"sequenceId"`
-	Nonce              *fftypes.FFBigInt                  `json:"nonce"`
-	Gas                *fftypes.FFBigInt                  `json:"gas"`
-	TransactionHeaders ffcapi.TransactionHeaders          `json:"transactionHeaders"`
-	TransactionData    string                             `json:"transactionData"`
-	TransactionHash    string                             `json:"transactionHash,omitempty"`
-	GasPrice           *fftypes.JSONAny                   `json:"gasPrice"`
-	PolicyInfo         *fftypes.JSONAny                   `json:"policyInfo"`
-	FirstSubmit        *fftypes.FFTime                    `json:"firstSubmit,omitempty"`
-	LastSubmit         *fftypes.FFTime                    `json:"lastSubmit,omitempty"`
-	Receipt            *ffcapi.TransactionReceiptResponse `json:"receipt,omitempty"`
-	ErrorMessage       string                             `json:"errorMessage,omitempty"`
-	ErrorHistory       []*ManagedTXError                  `json:"errorHistory"`
-	Confirmations      []confirmations.BlockInfo          `json:"confirmations,omitempty"`
+	ID                    string                             `json:"id"`
+	Created               *fftypes.FFTime                    `json:"created"`
+	Updated               *fftypes.FFTime                    `json:"updated"`
+	Status                TxStatus                           `json:"status"`
+	SubStatus             TxSubStatus                        `json:"subStatus,omitempty"`
+	SubStatusHistory      []*TxSubStatusEntry                `json:"subStatusHistory,omitempty"` // every transition of the sub-status, oldest first
+	DeleteRequested       *fftypes.FFTime                    `json:"deleteRequested,omitempty"`
+	NotBefore             *fftypes.FFTime                    `json:"notBefore,omitempty"`
+	Scheduled             bool                               `json:"scheduled,omitempty"`
+	Expiry                *fftypes.FFTime                    `json:"expiry,omitempty"`
+	ExpiryCancelRequested *fftypes.FFTime                    `json:"expiryCancelRequested,omitempty"` // set when the transaction expired after submission, and is being cancelled by replacement
+	DependsOn             []string                           `json:"dependsOn,omitempty"`
+	AwaitingDependency    bool                               `json:"awaitingDependency,omitempty"`
+	SequenceID            *fftypes.UUID                      `json:"sequenceId"`
+	RequestHash           string                             `json:"requestHash,omitempty"`
+	Nonce                 *fftypes.FFBigInt                  `json:"nonce"`
+	Gas                   *fftypes.FFBigInt                  `json:"gas"`
+	TransactionHeaders    ffcapi.TransactionHeaders          `json:"transactionHeaders"`
+	TransactionData       string                             `json:"transactionData"`
+	TransactionInput      *ffcapi.TransactionInput           `json:"transactionInput,omitempty"` // the original input of a contract invocation, so a failure can be replayed to find the revert reason
+	TransactionHash       string                             `json:"transactionHash,omitempty"`
+	GasPrice              *fftypes.JSONAny                   `json:"gasPrice"`
+	SpeedUpGasPrice       *fftypes.JSONAny                   `json:"speedUpGasPrice,omitempty"` // only set while the policy engine is executing a speed-up requested via the API, with the gas price to resubmit at
+	PolicyInfo            *fftypes.JSONAny                   `json:"policyInfo"`
+	FirstSubmit           *fftypes.FFTime                    `json:"firstSubmit,omitempty"`
+	LastSubmit            *fftypes.FFTime                    `json:"lastSubmit,omitempty"`
+	Receipt               *ffcapi.TransactionReceiptResponse `json:"receipt,omitempty"`
+	ErrorMessage          string                             `json:"errorMessage,omitempty"`
+	ErrorHistory          []*ManagedTXError                  `json:"errorHistory"`
+	SubmissionHistory     []*ManagedTXSubmission             `json:"submissionHistory,omitempty"`
+	RequiredConfirmations *int                               `json:"requiredConfirmations,omitempty"` // overrides the number of confirmations required for this transaction, when set in the request
+	Confirmations         []BlockInfo                        `json:"confirmations,omitempty"`
+	ConfirmationProgress  *ConfirmationProgress              `json:"confirmationProgress,omitempty"` // updated as each confirmation is received for the mined transaction
+	Cancellation          *ManagedTXCancellation             `json:"cancellation,omitempty"`
+}
+
+// ManagedTXSubmission is an entry in the submission history of a transaction, most recent first.
+// Every hash in the history is watched for a receipt, as any of them could be the one that is mined.
+// Consecutive identical attempts (same hash, gas price and result) are combined into a single entry.
+type ManagedTXSubmission struct {
+	Time            *fftypes.FFTime    `json:"time"`
+	LastAttempt     *fftypes.FFTime    `json:"lastAttempt"`
+	Attempts        int                `json:"attempts"`
+	TransactionHash string             `json:"transactionHash,omitempty"`
+	GasPrice        *fftypes.JSONAny   `json:"gasPrice"`
+	Reason          ffcapi.ErrorReason `json:"reason,omitempty"`
+	Error           string             `json:"error,omitempty"`
+}
+
+// ConfirmationProgress is the number of confirmations a mined transaction has, out of the number required
+type ConfirmationProgress struct {
+	Count       int        `json:"count"`
+	Required    int        `json:"required"`
+	LatestBlock *BlockInfo `json:"latestBlock,omitempty"` // the most recent block confirming the transaction, if any
+}
+
+// ManagedTXCancellation records the progress of cancelling a transaction that has already been submitted.
+// The policy engine replaces the transaction with a zero-value transfer from the signer back to itself,
+// at the same nonce and with a higher gas price. For a delete request, the ManagedTX is only removed once either
+// the replacement, or the original transaction, has been mined. For an expired transaction, the ManagedTX fails
+// once the replacement is mined, and completes as normal if the original is mined.
+type ManagedTXCancellation struct {
+	TransactionHash   string           `json:"transactionHash,omitempty"`
+	TransactionHashes []string         `json:"transactionHashes,omitempty"` // every hash submitted for the replacement, any of which could be mined
+	GasPrice          *fftypes.JSONAny `json:"gasPrice"`
+	FirstSubmit       *fftypes.FFTime  `json:"firstSubmit,omitempty"`
+	LastSubmit        *fftypes.FFTime  `json:"lastSubmit,omitempty"`
+}
+
+// HasTransactionHash returns true if the hash was submitted for the replacement, rather than the original transaction
+func (c *ManagedTXCancellation) HasTransactionHash(txHash string) bool {
+	if c == nil {
+		return false
+	}
+	for _, h := range c.TransactionHashes {
+		if h == txHash {
+			return true
+		}
+	}
+	return false
 }
 
 type ReplyType string
 
 const (
-	TransactionUpdate        ReplyType = "TransactionUpdate"
-	TransactionUpdateSuccess ReplyType = "TransactionSuccess"
-	TransactionUpdateFailure ReplyType = "TransactionFailure"
+	TransactionUpdate           ReplyType = "TransactionUpdate"
+	TransactionUpdateSuccess    ReplyType = "TransactionSuccess"
+	TransactionUpdateFailure    ReplyType = "TransactionFailure"
+	TransactionUpdateSuperseded ReplyType = "TransactionSuperseded"
 )
 
 type ReplyHeaders struct {
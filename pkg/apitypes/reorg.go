@@ -0,0 +1,53 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apitypes
+
+import (
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+)
+
+const ReorgDetected ReplyType = "ReorgDetected"
+
+// Reorg is a change to the chain found under the items pending confirmation, where blocks the confirmation
+// manager had already counted (or the block a transaction was mined in) were replaced by different blocks
+type Reorg struct {
+	ID           *fftypes.UUID    `json:"id"`
+	Detected     *fftypes.FFTime  `json:"detected"`
+	Manager      string           `json:"manager"`                // the confirmation manager that detected the reorg - "receipts", or one per event stream
+	BlockNumber  fftypes.FFuint64 `json:"blockNumber"`            // the lowest block number that changed
+	Depth        int              `json:"depth"`                  // the number of blocks replaced, as far as the pending items had counted them
+	OldHashes    []string         `json:"oldHashes"`              // the replaced blocks, from the lowest block number
+	NewHashes    []string         `json:"newHashes"`              // the replacement blocks known when the reorg was detected, from the lowest block number
+	Listeners    []*fftypes.UUID  `json:"listeners,omitempty"`    // the listeners with events whose confirmations were replaced
+	Transactions []string         `json:"transactions,omitempty"` // the transactions whose block or confirmations were replaced
+}
+
+// ReorgMetrics count the reorgs detected under the items pending confirmation, since startup
+type ReorgMetrics struct {
+	Total        uint64            `json:"total"`
+	MaxDepth     int               `json:"maxDepth"`
+	ByDepth      map[int]uint64    `json:"byDepth"`
+	ByManager    map[string]uint64 `json:"byManager"`
+	LastDetected *fftypes.FFTime   `json:"lastDetected,omitempty"`
+}
+
+// ReorgNotification is published on the websocket admin topic, when a confirmation manager detects
+// a reorg under the transactions and events pending confirmation
+type ReorgNotification struct {
+	Headers ReplyHeaders `json:"headers"`
+	Reorg
+}
@@ -0,0 +1,75 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apitypes
+
+import (
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// Signer is a signing address that nonces have been allocated for
+type Signer struct {
+	Address string       `json:"address"`
+	Paused  *SignerPause `json:"paused,omitempty"` // set while the policy engine is not being invoked for transactions of the signer
+}
+
+// SignerPause records why transactions for a signer are not being submitted, and when submission will next be tried
+type SignerPause struct {
+	Reason        ffcapi.ErrorReason `json:"reason"`
+	Error         string             `json:"error"`
+	TransactionID string             `json:"transactionId"` // the transaction that most recently failed with this reason
+	Paused        *fftypes.FFTime    `json:"paused"`
+	NextProbe     *fftypes.FFTime    `json:"nextProbe"` // the time after which one transaction will be passed to the policy engine, to check whether the signer can resume
+}
+
+// SignerNonceStatus compares the nonce state of FFTM for a signer, with the view of the blockchain node
+type SignerNonceStatus struct {
+	Signer              string            `json:"signer"`
+	NextNonce           *fftypes.FFBigInt `json:"nextNonce"`                     // the nonce that will be assigned to the next transaction
+	NodeNextNonce       *fftypes.FFBigInt `json:"nodeNextNonce"`                 // the next nonce reported by the blockchain node, including transactions in its pool
+	HighestStoredNonce  *fftypes.FFBigInt `json:"highestStoredNonce,omitempty"`  // the highest nonce allocated to a transaction in our state store
+	HighestStoredTxID   string            `json:"highestStoredTxId,omitempty"`   // the ID of the transaction with the highest nonce in our state store
+	HighestStoredStatus TxStatus          `json:"highestStoredStatus,omitempty"` // the status of the transaction with the highest nonce in our state store
+	Findings            []*NonceFinding   `json:"findings,omitempty"`            // issues found by the last nonce reconciliation for the signer
+}
+
+type NonceFindingType = fftypes.FFEnum
+
+var (
+	NonceFindingTypeGap      = fftypes.FFEnumValue("noncefinding", "gap")      // a nonce below a pending transaction that the node has not seen, and we have no pending transaction for
+	NonceFindingTypeConsumed = fftypes.FFEnumValue("noncefinding", "consumed") // a nonce allocated to a pending transaction we have not submitted, that the node has already used
+)
+
+// NonceFinding is an issue with the nonces of a signer, found by comparing the pending transactions with the node
+type NonceFinding struct {
+	Signer            string            `json:"signer"`
+	Type              NonceFindingType  `json:"type"`
+	Nonce             *fftypes.FFBigInt `json:"nonce"`
+	NodeNextNonce     *fftypes.FFBigInt `json:"nodeNextNonce"`
+	TransactionID     string            `json:"transactionId,omitempty"`     // the transaction allocated this nonce, if any
+	FillTransactionID string            `json:"fillTransactionId,omitempty"` // the no-op transaction submitted to fill a gap, if enabled
+	FillError         string            `json:"fillError,omitempty"`
+	Detected          *fftypes.FFTime   `json:"detected"`
+}
+
+const NonceFindingUpdate ReplyType = "NonceFinding"
+
+// NonceFindingReply is sent on the websocket when a new nonce finding is detected
+type NonceFindingReply struct {
+	Headers ReplyHeaders `json:"headers"`
+	NonceFinding
+}
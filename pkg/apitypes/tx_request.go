@@ -17,6 +17,7 @@
 package apitypes
 
 import (
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
 )
 
@@ -26,8 +27,35 @@ type TransactionRequest struct {
 	ffcapi.TransactionInput
 }
 
+// TransactionBatchRequest is the payload sent to initiate multiple transactions from a single signer.
+// The transactions are allocated a contiguous range of nonces, in the order they are supplied.
+type TransactionBatchRequest struct {
+	Headers      RequestHeaders       `json:"headers"`
+	Transactions []TransactionRequest `json:"transactions"` // the headers of each item can supply the ID, and optional schedule, of that transaction
+}
+
+// TransactionBatchResponse contains a result for each transaction in a batch, in the order they were supplied
+type TransactionBatchResponse struct {
+	Transactions []*TransactionBatchResult `json:"transactions"`
+}
+
+// TransactionBatchResult is the result for an individual transaction in a batch.
+// A transaction that could not be prepared has an error, and does not consume a nonce.
+type TransactionBatchResult struct {
+	ID          string     `json:"id"`
+	Transaction *ManagedTX `json:"transaction,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
 // ContractDeployRequest is the payload sent to initiate a new transaction
 type ContractDeployRequest struct {
 	Headers RequestHeaders `json:"headers"`
 	ffcapi.ContractDeployPrepareRequest
 }
+
+// SpeedUpRequest is the payload sent to force an immediate resubmission of a pending transaction with a higher gas price.
+// Exactly one of GasPrice or GasPriceMultiplier must be supplied.
+type SpeedUpRequest struct {
+	GasPrice           *fftypes.JSONAny `json:"gasPrice,omitempty"`           // the new gas price, in the format understood by the connector
+	GasPriceMultiplier *float64         `json:"gasPriceMultiplier,omitempty"` // applied to each numeric field of the current gas price, so must be greater than 1
+}
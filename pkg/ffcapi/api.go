@@ -81,6 +81,26 @@ type API interface {
 	NewBlockListener(ctx context.Context, req *NewBlockListenerRequest) (*NewBlockListenerResponse, ErrorReason, error)
 }
 
+// FinalityAPI is an optional interface, that can be implemented alongside API by connectors for chains with
+// deterministic or checkpointed finality. When the confirmations manager is configured for finality mode,
+// events and receipts are released once their block is at or below the finalized height, rather than after
+// a number of confirmation blocks.
+type FinalityAPI interface {
+
+	// LatestFinalizedBlock returns the highest block in the canonical chain that is final
+	LatestFinalizedBlock(ctx context.Context, req *LatestFinalizedBlockRequest) (*LatestFinalizedBlockResponse, ErrorReason, error)
+}
+
+// ConfirmedNonceAPI is an optional interface, that can be implemented alongside API by connectors that can query
+// the nonce of a signer as of the latest block (rather than including the transaction pool of the node). It is used
+// to detect a transaction whose nonce has been used by another system signing with the same key. Without it, that is
+// only detected when the node rejects a submission of the transaction with ErrorReasonNonceTooLow.
+type ConfirmedNonceAPI interface {
+
+	// ConfirmedNonceForSigner returns the next nonce for a signing identity, counting only mined transactions
+	ConfirmedNonceForSigner(ctx context.Context, req *ConfirmedNonceForSignerRequest) (*ConfirmedNonceForSignerResponse, ErrorReason, error)
+}
+
 type BlockHashEvent struct {
 	BlockHashes  []string `json:"blockHash"`              // zero or more hashes (can be nil)
 	GapPotential bool     `json:"gapPotential,omitempty"` // when true, the caller cannot be sure if blocks have been missed (use on reconnect of a websocket for example)
@@ -232,6 +232,52 @@ func TestSendInvalidRequestBadTXType(t *testing.T) {
 	assert.Regexp(t, "FF21022", errRes.Error)
 }
 
+func TestSendInvalidRequestBadBatchType(t *testing.T) {
+
+	url, m, cancel := newTestManager(t)
+	defer cancel()
+	m.Start()
+
+	req := strings.NewReader(`{
+		"headers": {
+			"type": "SendTransactionBatch"
+		},
+		"transactions": {
+			"Not": "an array"
+		}
+	}`)
+	var errRes fftypes.RESTError
+	res, err := resty.New().R().
+		SetBody(req).
+		SetError(&errRes).
+		Post(url)
+	assert.NoError(t, err)
+	assert.Equal(t, 400, res.StatusCode())
+	assert.Regexp(t, "FF21022", errRes.Error)
+}
+
+func TestSendEmptyBatch(t *testing.T) {
+
+	url, m, cancel := newTestManager(t)
+	defer cancel()
+	m.Start()
+
+	req := strings.NewReader(`{
+		"headers": {
+			"type": "SendTransactionBatch"
+		},
+		"transactions": []
+	}`)
+	var errRes fftypes.RESTError
+	res, err := resty.New().R().
+		SetBody(req).
+		SetError(&errRes).
+		Post(url)
+	assert.NoError(t, err)
+	assert.Equal(t, 400, res.StatusCode())
+	assert.Regexp(t, "FF21078", errRes.Error)
+}
+
 func TestSendInvalidDeployBadTXType(t *testing.T) {
 
 	url, m, cancel := newTestManager(t)
@@ -26,12 +26,17 @@ import (
 	"testing"
 
 	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/policyengines"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/policyengines/escalating"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/policyengines/simple"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestGenerateConfigDocs(t *testing.T) {
 	// Initialize config of all plugins
 	InitConfig()
+	policyengines.RegisterEngine(&simple.PolicyEngineFactory{})
+	policyengines.RegisterEngine(&escalating.PolicyEngineFactory{})
 	f, err := os.Create(filepath.Join("..", "..", "config.md"))
 	assert.NoError(t, err)
 	generatedConfig, err := config.GenerateConfigMarkdown(context.Background(), config.GetKnownKeys())
@@ -27,12 +27,17 @@ import (
 	"testing"
 
 	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/policyengines"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/policyengines/escalating"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/policyengines/simple"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestConfigDocsUpToDate(t *testing.T) {
 	// Initialize config of all plugins
 	InitConfig()
+	policyengines.RegisterEngine(&simple.PolicyEngineFactory{})
+	policyengines.RegisterEngine(&escalating.PolicyEngineFactory{})
 	generatedConfig, err := config.GenerateConfigMarkdown(context.Background(), config.GetKnownKeys())
 	assert.NoError(t, err)
 	configOnDisk, err := os.ReadFile(filepath.Join("..", "..", "config.md"))
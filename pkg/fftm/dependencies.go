@@ -0,0 +1,56 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+)
+
+// checkDependencies determines whether every transaction a pending transaction depends on has succeeded.
+// Returns the ID of the first dependency found to have failed (or been deleted), or whether we are still waiting.
+// Dependencies are read from persistence, as they might be in-flight on a different policy loop worker.
+// Those that have succeeded are remembered, so they are only read until that point.
+func (m *manager) checkDependencies(ctx context.Context, pending *pendingState) (failedDep string, waiting bool, err error) {
+	for _, depID := range pending.mtx.DependsOn {
+		if pending.succeededDeps[depID] {
+			continue
+		}
+		dep, err := m.persistence.GetTransactionByID(ctx, depID)
+		if err != nil {
+			return "", true, err
+		}
+		if dep == nil {
+			log.L(ctx).Warnf("Dependency %s of transaction %s has been deleted", depID, pending.mtx.ID)
+			return depID, false, nil
+		}
+		switch dep.Status {
+		case apitypes.TxStatusSucceeded:
+			if pending.succeededDeps == nil {
+				pending.succeededDeps = make(map[string]bool)
+			}
+			pending.succeededDeps[depID] = true
+		case apitypes.TxStatusFailed, apitypes.TxStatusSuperseded:
+			return depID, false, nil
+		default:
+			waiting = true
+		}
+	}
+	return "", waiting, nil
+}
@@ -0,0 +1,223 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/persistencemocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/policyenginemocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCheckDependencies(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	inflightDep := newTestTxn(t, m, "0xaaaaa", 10001, apitypes.TxStatusPending)
+	succeededDep := newTestTxn(t, m, "0xbbbbb", 20001, apitypes.TxStatusSucceeded)
+
+	tx := genTestTxn("0xccccc", 30001, apitypes.TxStatusPending)
+	tx.DependsOn = []string{inflightDep.ID, succeededDep.ID}
+	pending := &pendingState{mtx: tx}
+
+	failedDep, waiting, err := m.checkDependencies(m.ctx, pending)
+	assert.NoError(t, err)
+	assert.True(t, waiting)
+	assert.Empty(t, failedDep)
+	assert.True(t, pending.succeededDeps[succeededDep.ID])
+
+	// Once the in-flight dependency succeeds, we are no longer waiting
+	inflightDep.Status = apitypes.TxStatusSucceeded
+	err = m.persistence.WriteTransaction(m.ctx, inflightDep, false)
+	assert.NoError(t, err)
+	failedDep, waiting, err = m.checkDependencies(m.ctx, pending)
+	assert.NoError(t, err)
+	assert.False(t, waiting)
+	assert.Empty(t, failedDep)
+
+}
+
+func TestCheckDependenciesFailed(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	failed := newTestTxn(t, m, "0xaaaaa", 10001, apitypes.TxStatusFailed)
+
+	tx := genTestTxn("0xccccc", 30001, apitypes.TxStatusPending)
+	tx.DependsOn = []string{failed.ID}
+
+	failedDep, waiting, err := m.checkDependencies(m.ctx, &pendingState{mtx: tx})
+	assert.NoError(t, err)
+	assert.False(t, waiting)
+	assert.Equal(t, failed.ID, failedDep)
+
+}
+
+func TestCheckDependenciesSuperseded(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	superseded := newTestTxn(t, m, "0xaaaaa", 10001, apitypes.TxStatusSuperseded)
+
+	tx := genTestTxn("0xccccc", 30001, apitypes.TxStatusPending)
+	tx.DependsOn = []string{superseded.ID}
+
+	failedDep, waiting, err := m.checkDependencies(m.ctx, &pendingState{mtx: tx})
+	assert.NoError(t, err)
+	assert.False(t, waiting)
+	assert.Equal(t, superseded.ID, failedDep)
+
+}
+
+func TestCheckDependenciesDeleted(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	tx := genTestTxn("0xccccc", 30001, apitypes.TxStatusPending)
+	tx.DependsOn = []string{"missing"}
+
+	failedDep, waiting, err := m.checkDependencies(m.ctx, &pendingState{mtx: tx})
+	assert.NoError(t, err)
+	assert.False(t, waiting)
+	assert.Equal(t, "missing", failedDep)
+
+}
+
+func TestCheckDependenciesReadFail(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("GetTransactionByID", m.ctx, "dep1").Return(nil, fmt.Errorf("pop"))
+
+	tx := genTestTxn("0xccccc", 30001, apitypes.TxStatusPending)
+	tx.DependsOn = []string{"dep1"}
+	tx.AwaitingDependency = true
+
+	// The error is returned from the policy cycle for the transaction
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	err := m.execPolicy(m.ctx, &pendingState{mtx: tx}, false)
+	assert.Regexp(t, "pop", err)
+	assert.True(t, tx.AwaitingDependency)
+
+	mp.AssertExpectations(t)
+	mpe.AssertExpectations(t)
+
+}
+
+func TestExecPolicyDependenciesMet(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+
+	dep := newTestTxn(t, m, "0xaaaaa", 10001, apitypes.TxStatusSucceeded)
+	tx := newTestTxn(t, m, "0xccccc", 30001, apitypes.TxStatusPending)
+	tx.DependsOn = []string{dep.ID}
+	tx.AwaitingDependency = true
+
+	err := m.execPolicy(m.ctx, &pendingState{mtx: tx}, false)
+	assert.NoError(t, err)
+
+	rtx, err := m.persistence.GetTransactionByID(m.ctx, tx.ID)
+	assert.NoError(t, err)
+	assert.False(t, rtx.AwaitingDependency)
+	assert.Equal(t, apitypes.TxStatusPending, rtx.Status)
+
+	mpe.AssertExpectations(t)
+
+}
+
+func TestExecPolicyDependencyFailed(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+
+	dep := newTestTxn(t, m, "0xaaaaa", 10001, apitypes.TxStatusFailed)
+	tx := newTestTxn(t, m, "0xccccc", 30001, apitypes.TxStatusPending)
+	tx.DependsOn = []string{dep.ID}
+	tx.AwaitingDependency = true
+	pending := &pendingState{mtx: tx}
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("TransactionPrepare", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+
+	err := m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.True(t, pending.remove)
+	assert.Empty(t, m.policyEngineAPIRequests) // failing to fill the released nonce is only logged
+
+	rtx, err := m.persistence.GetTransactionByID(m.ctx, tx.ID)
+	assert.NoError(t, err)
+	assert.False(t, rtx.AwaitingDependency)
+	assert.Equal(t, apitypes.TxStatusFailed, rtx.Status)
+	assert.Regexp(t, "FF21081", rtx.ErrorMessage)
+
+	mpe.AssertExpectations(t)
+
+}
+
+func TestPolicyLoopDependencyFailedReleasesNonce(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	// The dependency is from another signer
+	dep := newTestTxn(t, m, "0xbbbbb", 20001, apitypes.TxStatusFailed)
+	dependent := genTestTxn("0xaaaaa", 1000, apitypes.TxStatusPending)
+	dependent.DependsOn = []string{dep.ID}
+	dependent.AwaitingDependency = true
+	err := m.persistence.WriteTransaction(m.ctx, dependent, true)
+	assert.NoError(t, err)
+	later := newTestTxn(t, m, "0xaaaaa", 1001, apitypes.TxStatusPending)
+
+	mockNodeMinesInNonceOrder(m, "0xaaaaa", 1000)
+	mockNonceGapFillPrepare(m, "0xaaaaa")
+
+	// The later transaction is mined, once the released nonce has been filled
+	rtx := runPolicyLoopUntilComplete(t, m, later.ID)
+	assert.Equal(t, apitypes.TxStatusSucceeded, rtx.Status)
+
+	rtx, err = m.persistence.GetTransactionByID(m.ctx, dependent.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.TxStatusFailed, rtx.Status)
+	assert.Regexp(t, "FF21081", rtx.ErrorMessage)
+
+	fill, err := m.persistence.GetTransactionByNonce(m.ctx, "0xaaaaa", fftypes.NewFFBigInt(1000))
+	assert.NoError(t, err)
+	assert.NotEqual(t, dependent.ID, fill.ID)
+	assert.Equal(t, "0x1000", fill.TransactionHash)
+
+}
@@ -18,6 +18,9 @@ package fftm
 
 import (
 	"context"
+	"fmt"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -48,12 +51,15 @@ type policyEngineAPIRequestType int
 
 const (
 	policyEngineAPIRequestTypeDelete policyEngineAPIRequestType = iota
+	policyEngineAPIRequestTypeSpeedUp
+	policyEngineAPIRequestTypeFillGap
 )
 
 // policyEngineAPIRequest requests are queued to the policy engine thread for processing against a given Transaction
 type policyEngineAPIRequest struct {
 	requestType policyEngineAPIRequestType
 	txID        string
+	speedUp     *apitypes.SpeedUpRequest
 	startTime   time.Time
 	response    chan policyEngineAPIResponse
 }
@@ -70,6 +76,8 @@ type manager struct {
 	retry          *retry.Retry
 	connector      ffcapi.API
 	confirmations  confirmations.Manager
+	blockCache     confirmations.BlockCache
+	reorgHistory   confirmations.ReorgHistory
 	policyEngine   policyengine.PolicyEngine
 	apiServer      httpserver.HTTPServer
 	wsServer       ws.WebSocketServer
@@ -81,18 +89,45 @@ type manager struct {
 	mux                     sync.Mutex
 	policyEngineAPIRequests []*policyEngineAPIRequest
 	lockedNonces            map[string]*lockedNonce
+	nonceFindings           map[string][]*apitypes.NonceFinding
+	pausedSigners           map[string]*apitypes.SignerPause
 	eventStreams            map[fftypes.UUID]events.Stream
 	streamsByName           map[string]*fftypes.UUID
 	policyLoopDone          chan struct{}
+	nonceReconcilerDone     chan struct{}
+	reorgNotifications      chan *apitypes.Reorg
+	reorgDispatcherDone     chan struct{}
 	blockListenerDone       chan struct{}
 	started                 bool
 	apiServerDone           chan error
 	debugServerDone         chan struct{}
 
 	policyLoopInterval time.Duration
+	policyLoopWorkers  int
 	nonceStateTimeout  time.Duration
 	errorHistoryCount  int
 	maxInFlight        int
+
+	maxInFlightPerSigner       int
+	maxInFlightSignerOverrides map[string]int
+	pendingScanCursor          *fftypes.UUID                // only accessed on the policy loop - the last pending transaction scanned for new signers
+	pendingScanCount           int                          // only accessed on the policy loop - the number of scans since the last full scan
+	signerCursors              map[string]*fftypes.FFBigInt // only accessed on the policy loop - the nonce of the last transaction of each signer added to the in-flight set
+	activeSigners              []string                     // only accessed on the policy loop - signers that might have pending transactions not yet in-flight
+
+	nonceReconcileInterval time.Duration
+	nonceReconcileAutoFill bool
+
+	supersededCheckInterval time.Duration
+	supersededGracePeriod   time.Duration
+	confirmedNonceMux       sync.Mutex
+	confirmedNonces         map[string]*confirmedNonce // reset each policy loop cycle, so each signer is queried at most once per cycle
+
+	pausedSignerProbeInterval time.Duration
+	simulateTransactions      bool
+	progressPersistInterval   time.Duration
+	adminTopic                string
+	subStatusScanLimit        int
 }
 
 func InitConfig() {
@@ -103,12 +138,17 @@ func InitConfig() {
 func NewManager(ctx context.Context, connector ffcapi.API) (Manager, error) {
 	var err error
 	m := newManager(ctx, connector)
-	if err = m.initServices(ctx); err != nil {
+	if err = m.initSignerInflightLimits(ctx); err != nil {
 		return nil, err
 	}
+	// Persistence is initialized first, as the confirmations manager persists its state
 	if err = m.initPersistence(ctx); err != nil {
 		return nil, err
 	}
+	if err = m.initServices(ctx); err != nil {
+		m.persistence.Close(ctx)
+		return nil, err
+	}
 	return m, nil
 }
 
@@ -119,13 +159,33 @@ func newManager(ctx context.Context, connector ffcapi.API) *manager {
 		apiServerDone: make(chan error),
 		eventStreams:  make(map[fftypes.UUID]events.Stream),
 		streamsByName: make(map[string]*fftypes.UUID),
+		pausedSigners: make(map[string]*apitypes.SignerPause),
 
 		policyLoopInterval: config.GetDuration(tmconfig.PolicyLoopInterval),
+		policyLoopWorkers:  config.GetInt(tmconfig.PolicyLoopWorkers),
 		errorHistoryCount:  config.GetInt(tmconfig.TransactionsErrorHistoryCount),
 		maxInFlight:        config.GetInt(tmconfig.TransactionsMaxInFlight),
 		nonceStateTimeout:  config.GetDuration(tmconfig.TransactionsNonceStateTimeout),
 		inflightStale:      make(chan bool, 1),
 		inflightUpdate:     make(chan bool, 1),
+		reorgNotifications: make(chan *apitypes.Reorg, reorgNotificationBufferSize),
+
+		maxInFlightPerSigner:       config.GetInt(tmconfig.TransactionsMaxInFlightPerSigner),
+		maxInFlightSignerOverrides: make(map[string]int),
+		signerCursors:              make(map[string]*fftypes.FFBigInt),
+
+		nonceReconcileInterval: config.GetDuration(tmconfig.TransactionsReconcileInterval),
+		nonceReconcileAutoFill: config.GetBool(tmconfig.TransactionsReconcileAutoFill),
+
+		supersededCheckInterval: config.GetDuration(tmconfig.TransactionsSupersededCheckInterval),
+		supersededGracePeriod:   config.GetDuration(tmconfig.TransactionsSupersededGracePeriod),
+		confirmedNonces:         make(map[string]*confirmedNonce),
+
+		pausedSignerProbeInterval: config.GetDuration(tmconfig.TransactionsPausedSignerProbeInterval),
+		simulateTransactions:      config.GetBool(tmconfig.TransactionsSimulate),
+		progressPersistInterval:   config.GetDuration(tmconfig.ConfirmationsProgressPersistInterval),
+		adminTopic:                config.GetString(tmconfig.APIAdminTopic),
+		subStatusScanLimit:        config.GetInt(tmconfig.APISubStatusScanLimit),
 		retry: &retry.Retry{
 			InitialDelay: config.GetDuration(tmconfig.PolicyLoopRetryInitDelay),
 			MaximumDelay: config.GetDuration(tmconfig.PolicyLoopRetryMaxDelay),
@@ -137,15 +197,22 @@ func newManager(ctx context.Context, connector ffcapi.API) *manager {
 }
 
 type pendingState struct {
-	mtx                     *apitypes.ManagedTX
-	lastPolicyCycle         time.Time
-	confirmed               bool
-	remove                  bool
-	trackingTransactionHash string
+	mtx             *apitypes.ManagedTX
+	lastPolicyCycle time.Time
+	confirmed       bool
+	minedHash       string // set by the confirmation manager when a receipt is received for any of the submitted hashes
+	remove          bool
+	trackedHashes   map[string]bool
+	succeededDeps   map[string]bool
+	progressUpdated bool      // the confirmation progress has changed since the last policy cycle
+	progressPersist time.Time // when a change to the confirmation progress was last persisted
+	supersededSince time.Time // when the nonce was first found to be used by another transaction, with no receipt for any of our hashes
 }
 
 func (m *manager) initServices(ctx context.Context) (err error) {
-	m.confirmations = confirmations.NewBlockConfirmationManager(ctx, m.connector, "receipts")
+	m.blockCache = confirmations.NewBlockCache(m.connector, config.GetInt(tmconfig.ConfirmationsBlockCacheSize))
+	m.reorgHistory = confirmations.NewReorgHistory(config.GetInt(tmconfig.ConfirmationsReorgHistorySize))
+	m.confirmations = confirmations.NewBlockConfirmationManager(ctx, m.connector, "receipts", m.blockCache, m, m.persistence)
 	m.policyEngine, err = policyengines.NewPolicyEngine(ctx, tmconfig.PolicyEngineBaseConfig, config.GetString(tmconfig.PolicyEngineName))
 	if err != nil {
 		return err
@@ -158,6 +225,20 @@ func (m *manager) initServices(ctx context.Context) (err error) {
 	return nil
 }
 
+// initSignerInflightLimits reads the per-signer overrides of the in-flight limit. The values can be numbers or numeric
+// strings, depending on the source of the configuration, so we parse them here and reject anything that is not a
+// whole number - rather than silently treating it as zero (no limit).
+func (m *manager) initSignerInflightLimits(ctx context.Context) error {
+	for signer, v := range config.GetObject(tmconfig.TransactionsMaxInFlightSignerOverrides) {
+		limit, err := strconv.Atoi(fmt.Sprintf("%v", v))
+		if err != nil || limit < 0 {
+			return i18n.NewError(ctx, tmmsgs.MsgInvalidSignerInflightLimit, v, signer, tmconfig.TransactionsMaxInFlightSignerOverrides)
+		}
+		m.maxInFlightSignerOverrides[strings.ToLower(signer)] = limit
+	}
+	return nil
+}
+
 func (m *manager) initPersistence(ctx context.Context) (err error) {
 	pType := config.GetString(tmconfig.PersistenceType)
 	switch pType {
@@ -189,6 +270,10 @@ func (m *manager) Start() error {
 	m.policyLoopDone = make(chan struct{})
 	m.markInflightStale()
 	go m.policyLoop()
+	m.nonceReconcilerDone = make(chan struct{})
+	go m.nonceReconciler()
+	m.reorgDispatcherDone = make(chan struct{})
+	go m.reorgDispatcher()
 	go m.confirmations.Start()
 
 	m.started = true
@@ -201,6 +286,8 @@ func (m *manager) Close() {
 		m.started = false
 		<-m.apiServerDone
 		<-m.policyLoopDone
+		<-m.nonceReconcilerDone
+		<-m.reorgDispatcherDone
 		<-m.blockListenerDone
 		<-m.debugServerDone
 
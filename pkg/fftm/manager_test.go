@@ -35,6 +35,7 @@ import (
 	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/policyengines"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/policyengines/escalating"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/policyengines/simple"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -47,6 +48,7 @@ func strPtr(s string) *string { return &s }
 func testManagerCommonInit(t *testing.T) string {
 	InitConfig()
 	policyengines.RegisterEngine(&simple.PolicyEngineFactory{})
+	policyengines.RegisterEngine(&escalating.PolicyEngineFactory{})
 	tmconfig.PolicyEngineBaseConfig.SubSection("simple").SubSection(simple.GasOracleConfig).Set(simple.GasOracleMode, simple.GasOracleModeDisabled)
 
 	ln, err := net.Listen("tcp", "127.0.0.1:0")
@@ -96,6 +98,8 @@ func newTestManagerMockPersistence(t *testing.T) (string, *manager, func()) {
 	m := newManager(context.Background(), &ffcapimocks.API{})
 	mp := &persistencemocks.Persistence{}
 	mp.On("Close", mock.Anything).Return(nil).Maybe()
+	mp.On("ListConfirmationStates", mock.Anything, mock.Anything).Return(nil, nil).Maybe()
+	mp.On("GetNonceFloor", mock.Anything, mock.Anything).Return(nil, nil).Maybe()
 	m.persistence = mp
 
 	err := m.initServices(context.Background())
@@ -108,13 +112,18 @@ func newTestManagerMockPersistence(t *testing.T) (string, *manager, func()) {
 
 func TestNewManagerBadHttpConfig(t *testing.T) {
 
+	dir, err := ioutil.TempDir("", "ldb_*")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
 	tmconfig.Reset()
+	config.Set(tmconfig.PersistenceLevelDBPath, dir)
 	tmconfig.APIConfig.Set(httpserver.HTTPConfAddress, "::::")
 
 	policyengines.RegisterEngine(&simple.PolicyEngineFactory{})
 	tmconfig.PolicyEngineBaseConfig.SubSection("simple").Set(simple.FixedGasPrice, "223344556677")
 
-	_, err := NewManager(context.Background(), nil)
+	_, err = NewManager(context.Background(), nil)
 	assert.Regexp(t, "FF00151", err)
 
 }
@@ -153,14 +162,51 @@ func TestNewManagerBadPersistenceConfig(t *testing.T) {
 
 func TestNewManagerBadPolicyEngine(t *testing.T) {
 
+	dir, err := ioutil.TempDir("", "ldb_*")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
 	tmconfig.Reset()
+	config.Set(tmconfig.PersistenceLevelDBPath, dir)
 	config.Set(tmconfig.PolicyEngineName, "wrong")
 
-	_, err := NewManager(context.Background(), nil)
+	_, err = NewManager(context.Background(), nil)
 	assert.Regexp(t, "FF21019", err)
 
 }
 
+func TestNewManagerSignerInflightLimits(t *testing.T) {
+
+	tmconfig.Reset()
+	config.Set(tmconfig.TransactionsMaxInFlightPerSigner, 5)
+	config.Set(tmconfig.TransactionsMaxInFlightSignerOverrides, map[string]interface{}{
+		"0xAAAAA": 10,
+		"0xccccc": "20",
+		"0xddddd": float64(0),
+	})
+
+	m := newManager(context.Background(), &ffcapimocks.API{})
+	err := m.initSignerInflightLimits(m.ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, m.signerInflightLimit("0xaaaaa"))
+	assert.Equal(t, 5, m.signerInflightLimit("0xbbbbb"))
+	assert.Equal(t, 20, m.signerInflightLimit("0xccccc"))
+	assert.Equal(t, 0, m.signerInflightLimit("0xddddd"))
+
+}
+
+func TestNewManagerSignerInflightLimitsBadValue(t *testing.T) {
+
+	tmconfig.Reset()
+	config.Set(tmconfig.TransactionsMaxInFlightSignerOverrides, map[string]interface{}{
+		"0xAAAAA": 10.5,
+	})
+
+	_, err := NewManager(context.Background(), &ffcapimocks.API{})
+	assert.Regexp(t, "FF21087", err)
+
+}
+
 func TestAddErrorMessageMax(t *testing.T) {
 
 	_, m, close := newTestManagerMockPersistence(t)
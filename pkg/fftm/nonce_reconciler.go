@@ -0,0 +1,316 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/persistence"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+func (m *manager) nonceReconciler() {
+	defer close(m.nonceReconcilerDone)
+	if m.nonceReconcileInterval <= 0 {
+		return
+	}
+	ctx := log.WithLogField(m.ctx, "role", "nonce-reconciler")
+
+	timer := time.NewTimer(m.nonceReconcileInterval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			if err := m.reconcileNonces(ctx); err != nil {
+				log.L(ctx).Errorf("Nonce reconciliation failed: %s", err)
+			}
+			timer.Reset(m.nonceReconcileInterval)
+		case <-ctx.Done():
+			log.L(ctx).Infof("Nonce reconciler exiting")
+			return
+		}
+	}
+}
+
+// reconcileNonces compares the pending transactions of every signer with the next nonce of the node. The findings
+// replace those from the previous run, and only new findings are notified (and filled, if enabled).
+func (m *manager) reconcileNonces(ctx context.Context) error {
+	var signers []string
+	bySigner := make(map[string][]*apitypes.ManagedTX)
+	var after *fftypes.UUID
+	pageSize := m.maxInFlight
+	for {
+		page, err := m.persistence.ListTransactionsPending(ctx, after, pageSize, persistence.SortDirectionAscending)
+		if err != nil {
+			return err
+		}
+		for _, mtx := range page {
+			after = mtx.SequenceID
+			signer := mtx.TransactionHeaders.From
+			if _, ok := bySigner[signer]; !ok {
+				signers = append(signers, signer)
+			}
+			bySigner[signer] = append(bySigner[signer], mtx)
+		}
+		if len(page) < pageSize {
+			break
+		}
+	}
+
+	m.mux.Lock()
+	previous := m.nonceFindings
+	m.mux.Unlock()
+
+	findings := make(map[string][]*apitypes.NonceFinding)
+	var newFindings []*apitypes.NonceFinding
+	for _, signer := range signers {
+		signerFindings, err := m.reconcileSignerNonces(ctx, signer, bySigner[signer])
+		if err != nil {
+			log.L(ctx).Warnf("Nonce reconciliation failed for signer %s: %s", signer, err)
+			signerFindings = previous[signer]
+		} else {
+			newFindings = append(newFindings, mergeNonceFindings(previous[signer], signerFindings)...)
+		}
+		if len(signerFindings) > 0 {
+			findings[signer] = signerFindings
+		}
+	}
+
+	for _, finding := range newFindings {
+		log.L(ctx).Warnf("Nonce %s found for signer %s at nonce %s (node next nonce %s)", finding.Type, finding.Signer, finding.Nonce, finding.NodeNextNonce)
+		// We only fill nonces that no transaction in our state store owns, other than one that completed without
+		// being submitted - which is checked under the nonce lock when writing the fill
+		if finding.Type == apitypes.NonceFindingTypeGap && m.nonceReconcileAutoFill {
+			var err error
+			if finding.FillTransactionID, err = m.fillNonceGap(ctx, finding.Signer, finding.Nonce.Uint64()); err != nil {
+				log.L(ctx).Errorf("Failed to fill nonce gap for signer %s at nonce %s: %s", finding.Signer, finding.Nonce, err)
+				finding.FillError = err.Error()
+			}
+		}
+		m.sendNonceFindingWSReply(finding)
+	}
+
+	m.mux.Lock()
+	m.nonceFindings = findings
+	m.mux.Unlock()
+	return nil
+}
+
+// reconcileSignerNonces finds two kinds of issue for a signer:
+//   - Gaps: nonces at or above the next nonce of the node, but below a pending transaction, that we have no
+//     pending transaction for - because it was deleted, or failed before it reached the node
+//   - Consumed: nonces of pending transactions we have never submitted, that the node has already used
+func (m *manager) reconcileSignerNonces(ctx context.Context, signer string, pending []*apitypes.ManagedTX) ([]*apitypes.NonceFinding, error) {
+	nextNonceRes, _, err := m.connector.NextNonceForSigner(ctx, &ffcapi.NextNonceForSignerRequest{
+		Signer: signer,
+	})
+	if err != nil {
+		return nil, err
+	}
+	nodeNextNonce := nextNonceRes.Nonce.Uint64()
+
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Nonce.Uint64() < pending[j].Nonce.Uint64() })
+	pendingNonces := make(map[uint64]bool)
+	findings := make([]*apitypes.NonceFinding, 0)
+	for _, mtx := range pending {
+		nonce := mtx.Nonce.Uint64()
+		pendingNonces[nonce] = true
+		if nonce < nodeNextNonce && mtx.FirstSubmit == nil {
+			findings = append(findings, newNonceFinding(signer, apitypes.NonceFindingTypeConsumed, nonce, nodeNextNonce, mtx.ID))
+		}
+	}
+
+	highestPending := pending[len(pending)-1].Nonce.Uint64()
+	gaps := 0
+	for nonce := nodeNextNonce; nonce < highestPending; nonce++ {
+		if pendingNonces[nonce] {
+			continue
+		}
+		if gaps >= m.maxInFlight {
+			// Protect against a node that is a long way behind our state
+			log.L(ctx).Warnf("Too many nonce gaps for signer %s between node next nonce %d and pending nonce %d", signer, nodeNextNonce, highestPending)
+			break
+		}
+		finding := newNonceFinding(signer, apitypes.NonceFindingTypeGap, nonce, nodeNextNonce, "")
+		gapTX, err := m.persistence.GetTransactionByNonce(ctx, signer, finding.Nonce)
+		if err != nil {
+			return nil, err
+		}
+		if gapTX != nil {
+			finding.TransactionID = gapTX.ID
+		}
+		findings = append(findings, finding)
+		gaps++
+	}
+	return findings, nil
+}
+
+func newNonceFinding(signer string, findingType apitypes.NonceFindingType, nonce, nodeNextNonce uint64, txID string) *apitypes.NonceFinding {
+	return &apitypes.NonceFinding{
+		Signer:        signer,
+		Type:          findingType,
+		Nonce:         fftypes.NewFFBigInt(int64(nonce)),
+		NodeNextNonce: fftypes.NewFFBigInt(int64(nodeNextNonce)),
+		TransactionID: txID,
+		Detected:      fftypes.Now(),
+	}
+}
+
+// mergeNonceFindings carries over the detection time (and any fill) for findings that were also found by the
+// previous run, and returns those that are new
+func mergeNonceFindings(previous, current []*apitypes.NonceFinding) (newFindings []*apitypes.NonceFinding) {
+	previousByKey := make(map[string]*apitypes.NonceFinding)
+	for _, finding := range previous {
+		previousByKey[fmt.Sprintf("%s/%s", finding.Type, finding.Nonce)] = finding
+	}
+	for _, finding := range current {
+		if existing, ok := previousByKey[fmt.Sprintf("%s/%s", finding.Type, finding.Nonce)]; ok {
+			finding.Detected = existing.Detected
+			finding.FillTransactionID = existing.FillTransactionID
+			finding.FillError = existing.FillError
+		} else {
+			newFindings = append(newFindings, finding)
+		}
+	}
+	return newFindings
+}
+
+// fillNonceGap submits a zero value transfer from the signer to itself at the gap nonce, which is added straight
+// into the in-flight set of the policy loop. We hold the nonce lock for the signer while we check the gap
+// has not been filled since we found it, and write the transaction.
+func (m *manager) fillNonceGap(ctx context.Context, signer string, nonce uint64) (string, error) {
+	txID := fftypes.NewUUID().String()
+	mtx, err := m.writeNonceGapFill(ctx, txID, signer, nonce)
+	if err != nil || mtx == nil {
+		return "", err
+	}
+	res := m.policyEngineAPIRequest(ctx, &policyEngineAPIRequest{
+		requestType: policyEngineAPIRequestTypeFillGap,
+		txID:        txID,
+	})
+	if res.err != nil {
+		// The transaction is persisted, so will still be picked up by the policy loop as a normal pending transaction
+		log.L(ctx).Warnf("Failed to add nonce gap fill %s to the in-flight set: %s", txID, res.err)
+	}
+	return txID, nil
+}
+
+// releaseNonce fills the nonce of a transaction that failed without being submitted, so the later transactions of
+// the signer are not blocked behind the gap. This is called on the policy loop, so rather than waiting for the fill
+// to be added to the in-flight set (as fillNonceGap does) the request is queued for the next cycle.
+func (m *manager) releaseNonce(ctx context.Context, mtx *apitypes.ManagedTX) {
+	txID := fftypes.NewUUID().String()
+	fill, err := m.writeNonceGapFill(ctx, txID, mtx.TransactionHeaders.From, mtx.Nonce.Uint64())
+	if err != nil {
+		// The gap is still found by the nonce reconciler
+		log.L(ctx).Errorf("Failed to fill nonce %s / %s released by transaction %s: %s", mtx.TransactionHeaders.From, mtx.Nonce, mtx.ID, err)
+		return
+	}
+	if fill == nil {
+		return
+	}
+	m.mux.Lock()
+	m.policyEngineAPIRequests = append(m.policyEngineAPIRequests, &policyEngineAPIRequest{
+		requestType: policyEngineAPIRequestTypeFillGap,
+		txID:        txID,
+		startTime:   time.Now(),
+		response:    make(chan policyEngineAPIResponse, 1), // nobody waits for the response
+	})
+	m.mux.Unlock()
+	m.markInflightUpdate()
+}
+
+func (m *manager) writeNonceGapFill(ctx context.Context, txID, signer string, nonce uint64) (*apitypes.ManagedTX, error) {
+	// The nonce is already known, so we only need the lock to be sure it is not allocated while we fill it
+	lockedNonce := m.lockNonce(ctx, txID, signer)
+	lockedNonce.nonce = nonce
+	defer lockedNonce.complete(ctx)
+
+	existing, err := m.persistence.GetTransactionByNonce(ctx, signer, fftypes.NewFFBigInt(int64(nonce)))
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && !nonceReleased(existing) {
+		log.L(ctx).Infof("Nonce gap for signer %s at nonce %d is owned by %s (status=%s), so will not be filled", signer, nonce, existing.ID, existing.Status)
+		return nil, nil
+	}
+
+	txHeaders := &ffcapi.TransactionHeaders{
+		From:  signer,
+		To:    signer,
+		Value: fftypes.NewFFBigInt(0),
+	}
+	prepared, _, err := m.connector.TransactionPrepare(ctx, &ffcapi.TransactionPrepareRequest{
+		TransactionInput: ffcapi.TransactionInput{
+			TransactionHeaders: *txHeaders,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	mtx := newManagedTX(txID, "", apitypes.NewULID(), nil, txHeaders, nil, nonce, prepared.Gas, prepared.TransactionData)
+	// When the nonce was released, the fill takes it over in the nonce index - the released transaction
+	// can still be queried by its ID
+	if err = m.persistence.WriteTransaction(ctx, mtx, true); err != nil {
+		return nil, err
+	}
+	log.L(ctx).Infof("Filling nonce gap for signer %s at nonce %d with transaction %s", signer, nonce, txID)
+	m.sendWSReply(mtx)
+	return mtx, nil
+}
+
+// nonceReleased is true for a transaction that completed without ever being submitted, so its nonce never
+// reached the node and needs to be filled for the later transactions of the signer to be mined
+func nonceReleased(mtx *apitypes.ManagedTX) bool {
+	return mtx.Status != apitypes.TxStatusPending && mtx.FirstSubmit == nil
+}
+
+func (m *manager) getNonceFindings(signer string) []*apitypes.NonceFinding {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if signer != "" {
+		return m.nonceFindings[signer]
+	}
+	findings := make([]*apitypes.NonceFinding, 0)
+	for _, signerFindings := range m.nonceFindings {
+		findings = append(findings, signerFindings...)
+	}
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].Signer == findings[j].Signer {
+			return findings[i].Nonce.Uint64() < findings[j].Nonce.Uint64()
+		}
+		return findings[i].Signer < findings[j].Signer
+	})
+	return findings
+}
+
+func (m *manager) sendNonceFindingWSReply(finding *apitypes.NonceFinding) {
+	// Notify on the websocket - this is best-effort (there is no subscription/acknowledgement)
+	m.wsServer.SendReply(&apitypes.NonceFindingReply{
+		Headers: apitypes.ReplyHeaders{
+			RequestID: finding.TransactionID,
+			Type:      apitypes.NonceFindingUpdate,
+		},
+		NonceFinding: *finding,
+	})
+}
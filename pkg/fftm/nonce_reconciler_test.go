@@ -0,0 +1,406 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/internal/persistence"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/persistencemocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func mockNodeNextNonce(m *manager, signer string, nonce int64) *mock.Call {
+	mfc := m.connector.(*ffcapimocks.API)
+	return mfc.On("NextNonceForSigner", mock.Anything, &ffcapi.NextNonceForSignerRequest{
+		Signer: signer,
+	}).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(nonce),
+	}, ffcapi.ErrorReason(""), nil)
+}
+
+func TestReconcileNoncesGapsAndConsumed(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	// Small enough to page through the pending transactions, and to check the consumed
+	// finding does not count towards the cap on gaps
+	m.maxInFlight = 2
+
+	// Nonce 1000 has been used outside of FFTM, before we submitted our transaction for it
+	consumed := newTestTxn(t, m, "0xaaaaa", 1000, apitypes.TxStatusPending)
+	// Nonce 1001 failed before submission, and 1002 was deleted - so 1003 is blocked
+	failed := newTestTxn(t, m, "0xaaaaa", 1001, apitypes.TxStatusFailed)
+	_ = newTestTxn(t, m, "0xaaaaa", 1003, apitypes.TxStatusPending)
+	// Signer 0xbbbbb is healthy
+	_ = newTestTxn(t, m, "0xbbbbb", 2000, apitypes.TxStatusPending)
+	_ = newTestTxn(t, m, "0xbbbbb", 2001, apitypes.TxStatusPending)
+
+	mockNodeNextNonce(m, "0xaaaaa", 1001)
+	mockNodeNextNonce(m, "0xbbbbb", 2000)
+
+	err := m.reconcileNonces(m.ctx)
+	assert.NoError(t, err)
+
+	findings := m.getNonceFindings("")
+	assert.Len(t, findings, 3)
+	assert.Equal(t, apitypes.NonceFindingTypeConsumed, findings[0].Type)
+	assert.Equal(t, int64(1000), findings[0].Nonce.Int64())
+	assert.Equal(t, consumed.ID, findings[0].TransactionID)
+	assert.Equal(t, apitypes.NonceFindingTypeGap, findings[1].Type)
+	assert.Equal(t, int64(1001), findings[1].Nonce.Int64())
+	assert.Equal(t, failed.ID, findings[1].TransactionID)
+	assert.Equal(t, apitypes.NonceFindingTypeGap, findings[2].Type)
+	assert.Equal(t, int64(1002), findings[2].Nonce.Int64())
+	assert.Empty(t, findings[2].TransactionID)
+	assert.Empty(t, findings[2].FillTransactionID)
+	assert.Empty(t, m.getNonceFindings("0xbbbbb"))
+
+	// Findings that remain keep their original detection time
+	detected := findings[1].Detected
+	time.Sleep(1 * time.Millisecond)
+	err = m.reconcileNonces(m.ctx)
+	assert.NoError(t, err)
+	findings = m.getNonceFindings("0xaaaaa")
+	assert.Len(t, findings, 3)
+	assert.Equal(t, detected, findings[1].Detected)
+
+}
+
+func TestReconcileNoncesAutoFill(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	noopPolicyEngine(m)
+	m.nonceReconcileAutoFill = true
+
+	_ = newTestTxn(t, m, "0xaaaaa", 1000, apitypes.TxStatusPending)
+	_ = newTestTxn(t, m, "0xaaaaa", 1002, apitypes.TxStatusPending)
+	mockNodeNextNonce(m, "0xaaaaa", 1000)
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("TransactionPrepare", mock.Anything, &ffcapi.TransactionPrepareRequest{
+		TransactionInput: ffcapi.TransactionInput{
+			TransactionHeaders: ffcapi.TransactionHeaders{
+				From:  "0xaaaaa",
+				To:    "0xaaaaa",
+				Value: fftypes.NewFFBigInt(0),
+			},
+		},
+	}).Return(&ffcapi.TransactionPrepareResponse{
+		Gas:             fftypes.NewFFBigInt(21000),
+		TransactionData: "0x",
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	err = m.reconcileNonces(m.ctx)
+	assert.NoError(t, err)
+
+	findings := m.getNonceFindings("0xaaaaa")
+	assert.Len(t, findings, 1)
+	assert.NotEmpty(t, findings[0].FillTransactionID)
+
+	fill, err := m.persistence.GetTransactionByNonce(m.ctx, "0xaaaaa", fftypes.NewFFBigInt(1001))
+	assert.NoError(t, err)
+	assert.Equal(t, findings[0].FillTransactionID, fill.ID)
+	assert.Equal(t, apitypes.TxStatusPending, fill.Status)
+	assert.Equal(t, "0xaaaaa", fill.TransactionHeaders.To)
+
+	// The gap is filled, so is no longer found
+	err = m.reconcileNonces(m.ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, m.getNonceFindings("0xaaaaa"))
+
+	mfc.AssertExpectations(t)
+
+}
+
+func newTestSubmittedFailedTxn(t *testing.T, m *manager, signer string, nonce int64) *apitypes.ManagedTX {
+	tx := genTestTxn(signer, nonce, apitypes.TxStatusFailed)
+	tx.TransactionHash = "0x12345"
+	tx.FirstSubmit = fftypes.Now()
+	err := m.persistence.WriteTransaction(m.ctx, tx, true)
+	assert.NoError(t, err)
+	return tx
+}
+
+func TestReconcileNoncesAutoFillSkipsOwnedNonce(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.nonceReconcileAutoFill = true
+
+	_ = newTestTxn(t, m, "0xaaaaa", 1000, apitypes.TxStatusPending)
+	failed := newTestSubmittedFailedTxn(t, m, "0xaaaaa", 1001)
+	_ = newTestTxn(t, m, "0xaaaaa", 1002, apitypes.TxStatusPending)
+	mockNodeNextNonce(m, "0xaaaaa", 1000)
+
+	err := m.reconcileNonces(m.ctx)
+	assert.NoError(t, err)
+
+	findings := m.getNonceFindings("0xaaaaa")
+	assert.Len(t, findings, 1)
+	assert.Equal(t, failed.ID, findings[0].TransactionID)
+	assert.Empty(t, findings[0].FillTransactionID)
+
+	// The failed transaction was submitted, so still owns the nonce
+	owner, err := m.persistence.GetTransactionByNonce(m.ctx, "0xaaaaa", fftypes.NewFFBigInt(1001))
+	assert.NoError(t, err)
+	assert.Equal(t, failed.ID, owner.ID)
+
+}
+
+func TestReconcileNoncesAutoFillReleasedNonce(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	noopPolicyEngine(m)
+	m.nonceReconcileAutoFill = true
+
+	_ = newTestTxn(t, m, "0xaaaaa", 1000, apitypes.TxStatusPending)
+	// Failed before it was submitted, so the nonce never reached the node
+	failed := newTestTxn(t, m, "0xaaaaa", 1001, apitypes.TxStatusFailed)
+	_ = newTestTxn(t, m, "0xaaaaa", 1002, apitypes.TxStatusPending)
+	mockNodeNextNonce(m, "0xaaaaa", 1000)
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("TransactionPrepare", mock.Anything, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{
+		Gas:             fftypes.NewFFBigInt(21000),
+		TransactionData: "0x",
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	err = m.reconcileNonces(m.ctx)
+	assert.NoError(t, err)
+
+	findings := m.getNonceFindings("0xaaaaa")
+	assert.Len(t, findings, 1)
+	assert.Equal(t, failed.ID, findings[0].TransactionID)
+	assert.NotEmpty(t, findings[0].FillTransactionID)
+
+	// The fill takes over the nonce, and the failed transaction is kept
+	owner, err := m.persistence.GetTransactionByNonce(m.ctx, "0xaaaaa", fftypes.NewFFBigInt(1001))
+	assert.NoError(t, err)
+	assert.Equal(t, findings[0].FillTransactionID, owner.ID)
+	failedTX, err := m.persistence.GetTransactionByID(m.ctx, failed.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.TxStatusFailed, failedTX.Status)
+
+	// Deleting the failed transaction does not remove the fill from the nonce index
+	err = m.persistence.DeleteTransaction(m.ctx, failed.ID)
+	assert.NoError(t, err)
+	owner, err = m.persistence.GetTransactionByNonce(m.ctx, "0xaaaaa", fftypes.NewFFBigInt(1001))
+	assert.NoError(t, err)
+	assert.Equal(t, findings[0].FillTransactionID, owner.ID)
+
+	mfc.AssertExpectations(t)
+
+}
+
+func TestReconcileNoncesAutoFillPrepareFail(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.nonceReconcileAutoFill = true
+
+	_ = newTestTxn(t, m, "0xaaaaa", 1000, apitypes.TxStatusPending)
+	_ = newTestTxn(t, m, "0xaaaaa", 1002, apitypes.TxStatusPending)
+	mockNodeNextNonce(m, "0xaaaaa", 1000)
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("TransactionPrepare", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+
+	err := m.reconcileNonces(m.ctx)
+	assert.NoError(t, err)
+
+	findings := m.getNonceFindings("0xaaaaa")
+	assert.Len(t, findings, 1)
+	assert.Empty(t, findings[0].FillTransactionID)
+	assert.Equal(t, "pop", findings[0].FillError)
+
+}
+
+func TestWriteNonceGapFillAlreadyFilled(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	_ = newTestTxn(t, m, "0xaaaaa", 1000, apitypes.TxStatusPending)
+
+	mtx, err := m.writeNonceGapFill(m.ctx, fftypes.NewUUID().String(), "0xaaaaa", 1000)
+	assert.NoError(t, err)
+	assert.Nil(t, mtx)
+
+	txID, err := m.fillNonceGap(m.ctx, "0xaaaaa", 1000)
+	assert.NoError(t, err)
+	assert.Empty(t, txID)
+
+	// A transaction in a terminal state also owns its nonce, once it has been submitted
+	_ = newTestSubmittedFailedTxn(t, m, "0xaaaaa", 1001)
+	mtx, err = m.writeNonceGapFill(m.ctx, fftypes.NewUUID().String(), "0xaaaaa", 1001)
+	assert.NoError(t, err)
+	assert.Nil(t, mtx)
+
+}
+
+func TestReconcileNoncesNodeFailKeepsFindings(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	previous := []*apitypes.NonceFinding{
+		newNonceFinding("0xaaaaa", apitypes.NonceFindingTypeGap, 1001, 1001, ""),
+	}
+	m.nonceFindings = map[string][]*apitypes.NonceFinding{"0xaaaaa": previous}
+
+	_ = newTestTxn(t, m, "0xaaaaa", 1002, apitypes.TxStatusPending)
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+
+	err := m.reconcileNonces(m.ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, previous, m.getNonceFindings("0xaaaaa"))
+
+}
+
+func TestReconcileNoncesListFail(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	defer close()
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("ListTransactionsPending", m.ctx, (*fftypes.UUID)(nil), m.maxInFlight, persistence.SortDirectionAscending).Return(nil, fmt.Errorf("pop"))
+
+	err := m.reconcileNonces(m.ctx)
+	assert.Regexp(t, "pop", err)
+
+}
+
+func TestReconcileNoncesGetByNonceFail(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	defer close()
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("ListTransactionsPending", m.ctx, (*fftypes.UUID)(nil), m.maxInFlight, persistence.SortDirectionAscending).Return([]*apitypes.ManagedTX{
+		genTestTxn("0xaaaaa", 1002, apitypes.TxStatusPending),
+	}, nil)
+	mp.On("GetTransactionByNonce", m.ctx, "0xaaaaa", mock.Anything).Return(nil, fmt.Errorf("pop"))
+	mockNodeNextNonce(m, "0xaaaaa", 1000)
+
+	err := m.reconcileNonces(m.ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, m.getNonceFindings(""))
+
+}
+
+func TestReconcileSignerNoncesTooManyGaps(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.maxInFlight = 5
+
+	mockNodeNextNonce(m, "0xaaaaa", 10)
+	findings, err := m.reconcileSignerNonces(m.ctx, "0xaaaaa", []*apitypes.ManagedTX{
+		genTestTxn("0xaaaaa", 0, apitypes.TxStatusPending),
+		genTestTxn("0xaaaaa", 1, apitypes.TxStatusPending),
+		genTestTxn("0xaaaaa", 1000, apitypes.TxStatusPending),
+	})
+	assert.NoError(t, err)
+	// The consumed nonces do not count towards the cap on gaps
+	assert.Len(t, findings, 7)
+	assert.Equal(t, apitypes.NonceFindingTypeConsumed, findings[1].Type)
+	assert.Equal(t, apitypes.NonceFindingTypeGap, findings[6].Type)
+
+}
+
+func TestNonceReconcilerLoop(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	m.nonceReconcileInterval = 0
+	m.nonceReconcilerDone = make(chan struct{})
+	m.nonceReconciler()
+	<-m.nonceReconcilerDone
+
+	ctx, cancelCtx := context.WithCancel(m.ctx)
+	m.ctx = ctx
+	m.nonceReconcileInterval = 1 * time.Millisecond
+	m.nonceReconcilerDone = make(chan struct{})
+	reconciled := make(chan struct{})
+	mp := &persistencemocks.Persistence{}
+	mp.On("ListTransactionsPending", mock.Anything, (*fftypes.UUID)(nil), m.maxInFlight, persistence.SortDirectionAscending).Return(nil, fmt.Errorf("pop")).Run(func(args mock.Arguments) {
+		select {
+		case <-reconciled:
+		default:
+			close(reconciled)
+		}
+	})
+	mp.On("Close", mock.Anything).Return(nil).Maybe()
+	realPersistence := m.persistence
+	m.persistence = mp
+	defer func() { m.persistence = realPersistence }()
+
+	go m.nonceReconciler()
+	<-reconciled
+	cancelCtx()
+	<-m.nonceReconcilerDone
+
+}
+
+func TestReleaseNonceOnlyLocks(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	// Calculating the next nonce would query the node, and delete the resync floor (as the next nonce has
+	// caught up with it) - but the floor is still needed, as no transaction has been written at or above it
+	m.nonceStateTimeout = 0
+	failed := newTestTxn(t, m, "0xaaaaa", 1000, apitypes.TxStatusFailed)
+	err := m.persistence.WriteNonceFloor(m.ctx, "0xaaaaa", fftypes.NewFFBigInt(1001))
+	assert.NoError(t, err)
+	mockNonceGapFillPrepare(m, "0xaaaaa")
+
+	m.releaseNonce(m.ctx, failed)
+	assert.Len(t, m.policyEngineAPIRequests, 1)
+
+	fill, err := m.persistence.GetTransactionByNonce(m.ctx, "0xaaaaa", fftypes.NewFFBigInt(1000))
+	assert.NoError(t, err)
+	assert.Equal(t, m.policyEngineAPIRequests[0].txID, fill.ID)
+
+	// The released nonce is filled without calculating the next nonce of the signer
+	floor, err := m.persistence.GetNonceFloor(m.ctx, "0xaaaaa")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1001), floor.Int64())
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.AssertNotCalled(t, "NextNonceForSigner", mock.Anything, mock.Anything)
+	mfc.AssertExpectations(t)
+
+}
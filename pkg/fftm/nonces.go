@@ -20,6 +20,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
 	"github.com/hyperledger/firefly-common/pkg/log"
 	"github.com/hyperledger/firefly-transaction-manager/internal/persistence"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
@@ -33,12 +34,16 @@ type lockedNonce struct {
 	unlocked chan struct{}
 	nonce    uint64
 	spent    *apitypes.ManagedTX
+
+	nodeNextNonce *fftypes.FFBigInt // set if the node was queried to calculate the nonce
+	floor         *fftypes.FFBigInt // set if there was a resync floor when the nonce was calculated
 }
 
 // complete must be called for any lockedNonce returned from a successful assignAndLockNonce call
 func (ln *lockedNonce) complete(ctx context.Context) {
 	if ln.spent != nil {
 		log.L(ctx).Debugf("Next nonce %d for signer %s spent", ln.nonce, ln.signer)
+		ln.clearNonceFloor(ctx)
 	} else {
 		log.L(ctx).Debugf("Returning next nonce %d for signer %s unspent", ln.nonce, ln.signer)
 	}
@@ -50,10 +55,32 @@ func (ln *lockedNonce) complete(ctx context.Context) {
 
 func (m *manager) assignAndLockNonce(ctx context.Context, nsOpID, signer string) (*lockedNonce, error) {
 
+	locked := m.lockNonce(ctx, nsOpID, signer)
+
+	// We have to ensure we either successfully return a nonce,
+	// or otherwise we unlock when we send the error
+	nextNonce, nodeNextNonce, err := m.calcNextNonce(ctx, signer)
+	if err == nil {
+		nextNonce, locked.floor, err = m.applyNonceFloor(ctx, signer, nextNonce)
+	}
+	if err != nil {
+		locked.complete(ctx)
+		return nil, err
+	}
+	locked.nonce = nextNonce
+	locked.nodeNextNonce = nodeNextNonce
+	return locked, nil
+
+}
+
+// lockNonce takes the nonce lock of the signer, without calculating the next nonce. It is used when the nonce
+// is already known (such as filling a gap), so there is no need to query the node or apply the resync floor.
+// complete must be called on the returned lockedNonce.
+func (m *manager) lockNonce(ctx context.Context, nsOpID, signer string) *lockedNonce {
+
 	for {
 		// Take the lock to query our nonce cache, and check if we are already locked
 		m.mux.Lock()
-		doLookup := false
 		locked, isLocked := m.lockedNonces[signer]
 		if !isLocked {
 			locked = &lockedNonce{
@@ -63,30 +90,20 @@ func (m *manager) assignAndLockNonce(ctx context.Context, nsOpID, signer string)
 				unlocked: make(chan struct{}),
 			}
 			m.lockedNonces[signer] = locked
-			doLookup = true
 		}
 		m.mux.Unlock()
 
-		// If we're locked, then wait
-		if isLocked {
-			log.L(ctx).Debugf("Contention for next nonce for signer %s", signer)
-			<-locked.unlocked
-		} else if doLookup {
-			// We have to ensure we either successfully return a nonce,
-			// or otherwise we unlock when we send the error
-			nextNonce, err := m.calcNextNonce(ctx, signer)
-			if err != nil {
-				locked.complete(ctx)
-				return nil, err
-			}
-			locked.nonce = nextNonce
-			return locked, nil
+		if !isLocked {
+			return locked
 		}
+		// If we're locked, then wait
+		log.L(ctx).Debugf("Contention for next nonce for signer %s", signer)
+		<-locked.unlocked
 	}
 
 }
 
-func (m *manager) calcNextNonce(ctx context.Context, signer string) (uint64, error) {
+func (m *manager) calcNextNonce(ctx context.Context, signer string) (uint64, *fftypes.FFBigInt, error) {
 
 	// First we check our DB to find the last nonce we used for this address.
 	// Note we are within the nonce-lock in assignAndLockNonce for this signer, so we can be sure we're the
@@ -94,14 +111,14 @@ func (m *manager) calcNextNonce(ctx context.Context, signer string) (uint64, err
 	var lastTxn *apitypes.ManagedTX
 	txns, err := m.persistence.ListTransactionsByNonce(ctx, signer, nil, 1, persistence.SortDirectionDescending)
 	if err != nil {
-		return 0, err
+		return 0, nil, err
 	}
 	if len(txns) > 0 {
 		lastTxn = txns[0]
 		if time.Since(*lastTxn.Created.Time()) < m.nonceStateTimeout {
 			nextNonce := lastTxn.Nonce.Uint64() + 1
 			log.L(ctx).Debugf("Allocating next nonce '%s' / '%d' after TX '%s' (status=%s)", signer, nextNonce, lastTxn.ID, lastTxn.Status)
-			return nextNonce, nil
+			return nextNonce, nil, nil
 		}
 	}
 
@@ -110,7 +127,7 @@ func (m *manager) calcNextNonce(ctx context.Context, signer string) (uint64, err
 		Signer: signer,
 	})
 	if err != nil {
-		return 0, err
+		return 0, nil, err
 	}
 	nextNonce := nextNonceRes.Nonce.Uint64()
 
@@ -122,6 +139,36 @@ func (m *manager) calcNextNonce(ctx context.Context, signer string) (uint64, err
 		nextNonce = lastTxn.Nonce.Uint64() + 1
 	}
 
-	return nextNonce, nil
+	return nextNonce, nextNonceRes.Nonce, nil
+
+}
+
+// applyNonceFloor ensures we do not allocate a nonce behind the one established by a resync of the signer,
+// which can happen if transactions have been submitted for the signer outside of FFTM since our last one.
+// The floor is persisted, so it survives a restart. It is only needed until a transaction at or above the
+// floor is written for the signer, after which our state store is ahead of it - so it is then deleted by
+// complete on the lockedNonce. This function only reads the floor, which it returns if there is one.
+// Called within the nonce lock of the signer.
+func (m *manager) applyNonceFloor(ctx context.Context, signer string, nextNonce uint64) (uint64, *fftypes.FFBigInt, error) {
+	floor, err := m.persistence.GetNonceFloor(ctx, signer)
+	if err != nil || floor == nil {
+		return nextNonce, nil, err
+	}
+	if nextNonce >= floor.Uint64() {
+		return nextNonce, floor, nil
+	}
+	log.L(ctx).Debugf("Allocating next nonce '%s' / '%d' from resync, rather than '%d'", signer, floor.Uint64(), nextNonce)
+	return floor.Uint64(), floor, nil
+}
 
+// clearNonceFloor deletes the resync floor once a transaction at or above it has been written, as our state
+// store is then ahead of it. A failure is not returned, as the transaction is already written - the floor is
+// left in place, and deleting it is tried again after the next transaction is written for the signer.
+func (ln *lockedNonce) clearNonceFloor(ctx context.Context) {
+	if ln.floor == nil || ln.spent.Nonce == nil || ln.spent.Nonce.Uint64() < ln.floor.Uint64() {
+		return
+	}
+	if err := ln.m.persistence.DeleteNonceFloor(ctx, ln.signer); err != nil {
+		log.L(ctx).Warnf("Failed to delete resync nonce floor %d for signer %s: %s", ln.floor.Uint64(), ln.signer, err)
+	}
 }
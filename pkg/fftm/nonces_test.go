@@ -119,7 +119,7 @@ func TestNonceListError(t *testing.T) {
 	mp.On("ListTransactionsByNonce", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
 		Return(nil, fmt.Errorf("pop"))
 
-	_, err := m.sendManagedTransaction(context.Background(), &apitypes.TransactionRequest{
+	_, _, err := m.sendManagedTransaction(context.Background(), &apitypes.TransactionRequest{
 		TransactionInput: ffcapi.TransactionInput{
 			TransactionHeaders: ffcapi.TransactionHeaders{
 				From: "0x12345",
@@ -152,7 +152,7 @@ func TestNonceListStaleThenQueryFail(t *testing.T) {
 	}, ffcapi.ErrorReason(""), nil)
 	mFFC.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
 
-	_, err := m.sendManagedTransaction(context.Background(), &apitypes.TransactionRequest{
+	_, _, err := m.sendManagedTransaction(context.Background(), &apitypes.TransactionRequest{
 		TransactionInput: ffcapi.TransactionInput{
 			TransactionHeaders: ffcapi.TransactionHeaders{
 				From: "0x12345",
@@ -179,8 +179,63 @@ func TestNonceListNotStale(t *testing.T) {
 			{ID: "id12345", Created: fftypes.Now(), Status: apitypes.TxStatusSucceeded, Nonce: fftypes.NewFFBigInt(1000)},
 		}, nil)
 
-	n, err := m.calcNextNonce(context.Background(), "0x12345")
+	n, _, err := m.calcNextNonce(context.Background(), "0x12345")
 	assert.NoError(t, err)
 	assert.Equal(t, uint64(1001), n)
 
 }
+
+func TestNonceFloorReadFail(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	defer close()
+	m.nonceStateTimeout = 1 * time.Hour
+
+	mp := &persistencemocks.Persistence{}
+	mp.On("Close", mock.Anything).Return(nil).Maybe()
+	m.persistence = mp
+	mp.On("ListTransactionsByNonce", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return([]*apitypes.ManagedTX{
+			{ID: "id12345", Created: fftypes.Now(), Status: apitypes.TxStatusSucceeded, Nonce: fftypes.NewFFBigInt(1000)},
+		}, nil)
+	mp.On("GetNonceFloor", mock.Anything, "0x12345").Return(nil, fmt.Errorf("pop"))
+
+	_, err := m.assignAndLockNonce(context.Background(), "ns1:id12346", "0x12345")
+	assert.Regexp(t, "pop", err)
+
+	mp.AssertExpectations(t)
+
+}
+
+func TestNonceFloorDeleteFail(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	defer close()
+	m.nonceStateTimeout = 1 * time.Hour
+
+	mp := &persistencemocks.Persistence{}
+	mp.On("Close", mock.Anything).Return(nil).Maybe()
+	m.persistence = mp
+	mp.On("ListTransactionsByNonce", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return([]*apitypes.ManagedTX{
+			{ID: "id12345", Created: fftypes.Now(), Status: apitypes.TxStatusSucceeded, Nonce: fftypes.NewFFBigInt(1000)},
+		}, nil)
+	mp.On("GetNonceFloor", mock.Anything, "0x12345").Return(fftypes.NewFFBigInt(1001), nil)
+	mp.On("DeleteNonceFloor", mock.Anything, "0x12345").Return(fmt.Errorf("pop")).Once()
+
+	// The floor is not deleted if the nonce is not spent, such as when writing the transaction fails
+	ln, err := m.assignAndLockNonce(context.Background(), "ns1:id12346", "0x12345")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1001), ln.nonce)
+	ln.complete(context.Background())
+	mp.AssertNotCalled(t, "DeleteNonceFloor", mock.Anything, mock.Anything)
+
+	// A failure to delete the floor once the nonce is spent does not fail the allocation
+	ln, err = m.assignAndLockNonce(context.Background(), "ns1:id12346", "0x12345")
+	assert.NoError(t, err)
+	ln.spent = &apitypes.ManagedTX{ID: "ns1:id12346", Nonce: fftypes.NewFFBigInt(int64(ln.nonce))}
+	ln.complete(context.Background())
+
+	mp.AssertExpectations(t)
+
+}
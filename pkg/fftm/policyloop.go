@@ -18,13 +18,19 @@ package fftm
 
 import (
 	"context"
+	"hash/fnv"
+	"math/big"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
 	"github.com/hyperledger/firefly-common/pkg/i18n"
 	"github.com/hyperledger/firefly-common/pkg/log"
 	"github.com/hyperledger/firefly-transaction-manager/internal/confirmations"
+	"github.com/hyperledger/firefly-transaction-manager/internal/gasprice"
 	"github.com/hyperledger/firefly-transaction-manager/internal/persistence"
 	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
@@ -81,34 +87,181 @@ func (m *manager) updateInflightSet(ctx context.Context) bool {
 	// If we are not at maximum, then query if there are more candidates now
 	spaces := m.maxInFlight - len(m.inflight)
 	if spaces > 0 {
-		var after *fftypes.UUID
-		if len(m.inflight) > 0 {
-			after = m.inflight[len(m.inflight)-1].mtx.SequenceID
+		candidates, ok := m.listInflightCandidates(ctx, spaces)
+		if !ok {
+			log.L(ctx).Infof("Policy loop context cancelled while retrying")
+			return false
+		}
+		for _, mtx := range selectRoundRobin(candidates, spaces) {
+			m.inflight = append(m.inflight, &pendingState{mtx: mtx})
+			m.signerCursors[mtx.TransactionHeaders.From] = mtx.Nonce
+		}
+		newLen := len(m.inflight)
+		if newLen > 0 {
+			log.L(ctx).Debugf("Inflight set updated len=%d signers=%d head-seq=%s tail-seq=%s", len(m.inflight), len(candidates), m.inflight[0].mtx.SequenceID, m.inflight[newLen-1].mtx.SequenceID)
 		}
-		var additional []*apitypes.ManagedTX
+	}
+	return true
+
+}
+
+// signerInflightLimit returns the maximum number of in-flight transactions for a signer, or zero for no limit
+// other than the global maxInFlight
+func (m *manager) signerInflightLimit(signer string) int {
+	if limit, ok := m.maxInFlightSignerOverrides[strings.ToLower(signer)]; ok {
+		return limit
+	}
+	return m.maxInFlightPerSigner
+}
+
+type signerCandidates struct {
+	inflight int
+	txs      []*apitypes.ManagedTX
+}
+
+// listInflightCandidates finds the transactions that could be added to the in-flight set, grouped by signer.
+// Rather than paging through every pending transaction on each refresh, we keep a cursor for each signer of the
+// nonce of the last transaction we added to the in-flight set. Each refresh:
+//   - Scans only the pending transactions submitted since the last scan, to find signers with new work
+//   - Reads the next transactions of each active signer in nonce order, after its cursor, limited to the space
+//     remaining within the in-flight limit of that signer, and the spaces in the in-flight set
+//
+// Signers that have no more transactions waiting become inactive, until new transactions are found for them.
+func (m *manager) listInflightCandidates(ctx context.Context, spaces int) ([]*signerCandidates, bool) {
+	inflightIDs := make(map[string]bool)
+	inflightCounts := make(map[string]int)
+	for _, p := range m.inflight {
+		inflightIDs[p.mtx.ID] = true
+		inflightCounts[p.mtx.TransactionHeaders.From]++
+	}
+	if !m.scanPendingSigners(ctx, inflightIDs) {
+		return nil, false
+	}
+	var candidates []*signerCandidates
+	activeSigners := make([]string, 0, len(m.activeSigners))
+	for _, signer := range m.activeSigners {
+		sc := &signerCandidates{inflight: inflightCounts[signer]}
+		available := spaces
+		if limit := m.signerInflightLimit(signer); limit > 0 && limit-sc.inflight < available {
+			available = limit - sc.inflight
+		}
+		if available <= 0 {
+			// At the limit - we do not need to read anything until some of its transactions complete
+			activeSigners = append(activeSigners, signer)
+			continue
+		}
+		var next []*apitypes.ManagedTX
+		// We retry the get from persistence indefinitely (until the context cancels)
+		err := m.retry.Do(ctx, "get signer transactions", func(attempt int) (retry bool, err error) {
+			next, err = m.persistence.ListTransactionsByNonce(ctx, signer, m.signerCursors[signer], available, persistence.SortDirectionAscending)
+			return true, err
+		})
+		if err != nil {
+			return nil, false
+		}
+		for _, mtx := range next {
+			switch {
+			case mtx.Status != apitypes.TxStatusPending && len(sc.txs) == 0:
+				// Completed without being in-flight (such as by a delete request) - we can move the cursor past it
+				m.signerCursors[signer] = mtx.Nonce
+			case mtx.Status == apitypes.TxStatusPending && !inflightIDs[mtx.ID]:
+				sc.txs = append(sc.txs, mtx)
+			}
+		}
+		if len(next) == 0 {
+			log.L(ctx).Debugf("No more transactions waiting for signer %s", signer)
+			continue
+		}
+		activeSigners = append(activeSigners, signer)
+		if len(sc.txs) > 0 {
+			candidates = append(candidates, sc)
+		}
+	}
+	m.activeSigners = activeSigners
+	return candidates, true
+}
+
+// pendingFullScanInterval is the number of scans of the pending transactions between full scans, while the
+// in-flight set does not drain
+const pendingFullScanInterval = 100
+
+// scanPendingSigners pages through the pending transactions submitted since the last scan, marking their signers
+// as active. The cursor of each signer is moved back if required, to just before the pending transactions we find.
+func (m *manager) scanPendingSigners(ctx context.Context, inflightIDs map[string]bool) bool {
+	// Sequence IDs are allocated before the transaction is written, so a transaction can be written after we
+	// have scanned past its sequence ID. So we scan from the start whenever the in-flight set drains, and
+	// periodically otherwise, to find the signers of any such transactions.
+	m.pendingScanCount++
+	if len(inflightIDs) == 0 || m.pendingScanCount >= pendingFullScanInterval {
+		m.pendingScanCursor = nil
+		m.pendingScanCount = 0
+	}
+	active := make(map[string]bool, len(m.activeSigners))
+	for _, signer := range m.activeSigners {
+		active[signer] = true
+	}
+	pageSize := m.maxInFlight
+	for {
+		var page []*apitypes.ManagedTX
 		// We retry the get from persistence indefinitely (until the context cancels)
 		err := m.retry.Do(ctx, "get pending transactions", func(attempt int) (retry bool, err error) {
-			additional, err = m.persistence.ListTransactionsPending(ctx, after, spaces, persistence.SortDirectionAscending)
+			page, err = m.persistence.ListTransactionsPending(ctx, m.pendingScanCursor, pageSize, persistence.SortDirectionAscending)
 			return true, err
 		})
 		if err != nil {
-			log.L(ctx).Infof("Policy loop context cancelled while retrying")
 			return false
 		}
-		for _, mtx := range additional {
-			m.inflight = append(m.inflight, &pendingState{mtx: mtx})
+		for _, mtx := range page {
+			m.pendingScanCursor = mtx.SequenceID
+			signer := mtx.TransactionHeaders.From
+			if inflightIDs[mtx.ID] {
+				continue
+			}
+			if cursor, known := m.signerCursors[signer]; !known || (cursor != nil && mtx.Nonce.Int().Cmp(cursor.Int()) <= 0) {
+				// Pending transactions are not always found in nonce order, such as a gap fill
+				var before *fftypes.FFBigInt
+				if mtx.Nonce.Int().Sign() > 0 {
+					before = (*fftypes.FFBigInt)(new(big.Int).Sub(mtx.Nonce.Int(), big.NewInt(1)))
+				}
+				m.signerCursors[signer] = before
+			}
+			if !active[signer] {
+				active[signer] = true
+				m.activeSigners = append(m.activeSigners, signer)
+			}
 		}
-		newLen := len(m.inflight)
-		if newLen > 0 {
-			log.L(ctx).Debugf("Inflight set updated len=%d head-seq=%s tail-seq=%s old-tail=%s", len(m.inflight), m.inflight[0].mtx.SequenceID, m.inflight[newLen-1].mtx.SequenceID, after)
+		if len(page) < pageSize {
+			return true
 		}
 	}
-	return true
+}
 
+// selectRoundRobin gives each signer a slot in turn until the spaces are filled, counting the transactions
+// each signer already has in-flight - so the signers with the fewest in-flight transactions are served first.
+// The transactions of each signer remain in sequence (and hence nonce) order.
+func selectRoundRobin(candidates []*signerCandidates, spaces int) []*apitypes.ManagedTX {
+	selected := make([]*apitypes.ManagedTX, 0, spaces)
+	for slot := 0; len(selected) < spaces; slot++ {
+		remaining := false
+		for _, sc := range candidates {
+			i := slot - sc.inflight
+			if i >= 0 && i < len(sc.txs) && len(selected) < spaces {
+				selected = append(selected, sc.txs[i])
+			}
+			remaining = remaining || i < len(sc.txs)-1
+		}
+		if !remaining {
+			break
+		}
+	}
+	return selected
 }
 
 func (m *manager) policyLoopCycle(ctx context.Context, inflightStale bool) {
 
+	// Each signer has its confirmed nonce queried at most once per cycle
+	m.resetConfirmedNonces()
+
 	// Process any synchronous commands first - these might not be in our inflight set
 	m.processPolicyAPIRequests(ctx)
 
@@ -118,14 +271,51 @@ func (m *manager) policyLoopCycle(ctx context.Context, inflightStale bool) {
 		}
 	}
 
-	// Go through executing the policy engine against them
+	// Go through executing the policy engine against them, sharded across the workers by signer.
+	// The in-flight set (and hence maxInFlight) is global, and we wait for every worker to complete
+	// before the next cycle, so the in-flight set is only ever updated by this routine.
+	shards := m.shardInflightBySigner()
+	if len(shards) == 1 {
+		m.execPolicyShard(ctx, shards[0])
+		return
+	}
+	var wg sync.WaitGroup
+	for _, shard := range shards {
+		wg.Add(1)
+		go func(shard []*pendingState) {
+			defer wg.Done()
+			m.execPolicyShard(ctx, shard)
+		}(shard)
+	}
+	wg.Wait()
+
+}
+
+// shardInflightBySigner distributes the in-flight transactions between the workers, using a hash of the signer.
+// The transactions for each signer are kept together in nonce order, so that one slow signer only delays the
+// other signers that share its worker.
+func (m *manager) shardInflightBySigner() [][]*pendingState {
+	workers := m.policyLoopWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	shards := make([][]*pendingState, workers)
 	for _, pending := range m.inflight {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(pending.mtx.TransactionHeaders.From))
+		i := h.Sum32() % uint32(workers)
+		shards[i] = append(shards[i], pending)
+	}
+	return shards
+}
+
+func (m *manager) execPolicyShard(ctx context.Context, shard []*pendingState) {
+	for _, pending := range shard {
 		err := m.execPolicy(ctx, pending, false)
 		if err != nil {
 			log.L(ctx).Errorf("Failed policy cycle transaction=%s operation=%s: %s", pending.mtx.TransactionHash, pending.mtx.ID, err)
 		}
 	}
-
 }
 
 // processPolicyAPIRequests executes any API calls requested that require policy engine involvement - such as transaction deletions
@@ -147,7 +337,8 @@ func (m *manager) processPolicyAPIRequests(ctx context.Context) {
 				break
 			}
 		}
-		if pending == nil {
+		isInflight := pending != nil
+		if !isInflight {
 			mtx, err := m.getTransactionByID(ctx, request.txID)
 			if err != nil {
 				request.response <- policyEngineAPIResponse{err: err}
@@ -169,6 +360,19 @@ func (m *manager) processPolicyAPIRequests(ctx context.Context) {
 				}
 				request.response <- res
 			}
+		case policyEngineAPIRequestTypeSpeedUp:
+			if err := m.execSpeedUp(ctx, pending, isInflight, request.speedUp); err != nil {
+				request.response <- policyEngineAPIResponse{err: err}
+			} else {
+				request.response <- policyEngineAPIResponse{tx: pending.mtx, status: http.StatusOK}
+			}
+		case policyEngineAPIRequestTypeFillGap:
+			// Gap fills are added to the in-flight set straight away, regardless of the in-flight limits,
+			// as every other pending transaction for the signer is blocked until they are mined
+			if !isInflight {
+				m.inflight = append(m.inflight, pending)
+			}
+			request.response <- policyEngineAPIResponse{tx: pending.mtx, status: http.StatusAccepted}
 		default:
 			request.response <- policyEngineAPIResponse{
 				err: i18n.NewError(ctx, tmmsgs.MsgPolicyEngineRequestInvalid, request.requestType),
@@ -197,55 +401,190 @@ func (m *manager) addError(mtx *apitypes.ManagedTX, reason ffcapi.ErrorReason, e
 	}
 }
 
+// setSubStatus records a transition of the sub-status of a transaction, if it has changed
+func setSubStatus(mtx *apitypes.ManagedTX, subStatus apitypes.TxSubStatus) {
+	if mtx.SubStatus != subStatus {
+		mtx.SubStatus = subStatus
+		mtx.SubStatusHistory = append(mtx.SubStatusHistory, &apitypes.TxSubStatusEntry{
+			SubStatus: subStatus,
+			Time:      fftypes.Now(),
+		})
+	}
+}
+
 func (m *manager) execPolicy(ctx context.Context, pending *pendingState, syncDeleteRequest bool) (err error) {
 
 	update := policyengine.UpdateNo
 	completed := false
+	releaseNonce := false // for a transaction that failed before it was submitted, which would otherwise leave a gap
 
 	// Check whether this has been confirmed by the confirmation manager
 	m.mux.Lock()
 	mtx := pending.mtx
 	confirmed := pending.confirmed
+	mined := mtx.Receipt != nil
+	minedHash := pending.minedHash
+	progressUpdated := pending.progressUpdated
+	pending.progressUpdated = false
 	if syncDeleteRequest && mtx.DeleteRequested == nil {
 		mtx.DeleteRequested = fftypes.Now()
 	}
 	m.mux.Unlock()
 
+	// Every transition of the sub-status is persisted, and sent on the websocket
+	prevSubStatus := mtx.SubStatus
+	if mined {
+		if minedHash != "" && mtx.TransactionHash != minedHash {
+			log.L(ctx).Infof("Earlier submission mined for transaction %s at nonce %s / %d - hash: %s (latest: %s)", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), minedHash, mtx.TransactionHash)
+			mtx.TransactionHash = minedHash
+		}
+		setSubStatus(mtx, apitypes.TxSubStatusMined)
+	}
+
 	switch {
-	case confirmed && !syncDeleteRequest:
+	case confirmed && mtx.DeleteRequested == nil:
 		update = policyengine.UpdateYes
 		completed = true
-		if mtx.Receipt.Success {
+		setSubStatus(mtx, apitypes.TxSubStatusConfirmed)
+		switch {
+		case mtx.ExpiryCancelRequested != nil && mtx.Cancellation.HasTransactionHash(mtx.TransactionHash):
+			// The replacement was mined at the nonce, so the expired transaction never will be
+			log.L(ctx).Warnf("Transaction %s expired at %s and was cancelled by replacement %s", mtx.ID, mtx.Expiry, mtx.TransactionHash)
+			mtx.Status = apitypes.TxStatusFailed
+			m.addError(mtx, "", i18n.NewError(ctx, tmmsgs.MsgTransactionExpired, mtx.Expiry))
+		case mtx.Receipt.Success:
 			mtx.Status = apitypes.TxStatusSucceeded
 			mtx.ErrorMessage = ""
+		default:
+			mtx.Status = apitypes.TxStatusFailed
+			if revertReason := m.revertReason(ctx, mtx); revertReason != "" {
+				m.addError(mtx, ffcapi.ErrorReasonTransactionReverted, i18n.NewError(ctx, tmmsgs.MsgTransactionReverted, revertReason))
+			} else {
+				mtx.ErrorMessage = i18n.NewError(ctx, tmmsgs.MsgTransactionFailed).Error()
+			}
+		}
+
+	case mtx.Expiry != nil && mtx.DeleteRequested == nil && mtx.ExpiryCancelRequested == nil && mtx.Receipt == nil && time.Now().After(time.Time(*mtx.Expiry)):
+		// Expiry is enforced here for all policy engines, so a transaction never lands on the chain
+		// long after the time the requester was prepared to wait for it.
+		update = policyengine.UpdateYes
+		if mtx.FirstSubmit == nil {
+			// Nothing has been sent to the chain, so we can fail the transaction immediately
+			log.L(ctx).Warnf("Transaction %s expired at %s before submission", mtx.ID, mtx.Expiry)
+			completed = true
+			releaseNonce = true
+			mtx.Status = apitypes.TxStatusFailed
+			mtx.Scheduled = false
+			m.addError(mtx, "", i18n.NewError(ctx, tmmsgs.MsgTransactionExpired, mtx.Expiry))
 		} else {
+			// The transaction might still be mined, so the policy engine needs to cancel it by replacement.
+			// Unlike a delete request the transaction is kept, and completes once the cancellation (or the original)
+			// is mined and confirmed - failing if it was the cancellation.
+			log.L(ctx).Warnf("Transaction %s expired at %s before it was mined - cancelling", mtx.ID, mtx.Expiry)
+			m.mux.Lock()
+			mtx.ExpiryCancelRequested = fftypes.Now()
+			m.mux.Unlock()
+			m.addError(mtx, "", i18n.NewError(ctx, tmmsgs.MsgTransactionExpiredCancelling, mtx.Expiry))
+		}
+
+	case mtx.AwaitingDependency && mtx.DeleteRequested == nil:
+		// The policy engine is not invoked until all dependencies have succeeded, which works across signers.
+		// The transaction keeps its nonce in the meantime, so later transactions from the same signer also wait.
+		failedDep, waiting, depErr := m.checkDependencies(ctx, pending)
+		if depErr != nil {
+			return depErr
+		}
+		switch {
+		case failedDep != "":
+			update = policyengine.UpdateYes
+			completed = true
+			releaseNonce = true
 			mtx.Status = apitypes.TxStatusFailed
-			mtx.ErrorMessage = i18n.NewError(ctx, tmmsgs.MsgTransactionFailed).Error()
+			mtx.AwaitingDependency = false
+			mtx.Scheduled = false
+			m.addError(mtx, "", i18n.NewError(ctx, tmmsgs.MsgDependencyFailed, failedDep))
+		case !waiting:
+			log.L(ctx).Infof("Dependencies of transaction %s have all succeeded", mtx.ID)
+			update = policyengine.UpdateYes
+			mtx.AwaitingDependency = false
+			m.markInflightUpdate() // so the policy engine is invoked promptly
 		}
 
+	case mtx.Scheduled && mtx.DeleteRequested == nil && time.Now().Before(time.Time(*mtx.NotBefore)):
+		// The transaction is scheduled for submission later, so we do not invoke the policy engine yet.
+		// It keeps its place in the pending index (and its nonce) in the meantime.
+
 	default:
 		// We get woken for lots of reasons to go through the policy loop, but we only want
 		// to drive the policy engine at regular intervals.
 		// So we track the last time we ran the policy engine against each pending item.
 		// We always call the policy engine on every loop, when deletion has been requested.
-		if syncDeleteRequest || time.Since(pending.lastPolicyCycle) > m.policyLoopInterval {
+		// While the signer is paused, we only call the policy engine for a periodic probe.
+		skipPaused, probe := m.checkSignerPause(mtx)
+		if !skipPaused && (syncDeleteRequest || probe || time.Since(pending.lastPolicyCycle) > m.policyLoopInterval) {
 			// Pass the state to the pluggable policy engine to potentially perform more actions against it,
 			// such as submitting for the first time, or raising the gas etc.
 			var reason ffcapi.ErrorReason
-			update, reason, err = m.policyEngine.Execute(ctx, m.connector, pending.mtx)
+			scheduleReached := mtx.Scheduled
+			if scheduleReached {
+				log.L(ctx).Infof("Transaction %s reached its not-before time %s", mtx.ID, mtx.NotBefore)
+				mtx.Scheduled = false
+			}
+			// The connector is wrapped, so that every submission is recorded in the history of the transaction.
+			lastSubmit := mtx.LastSubmit
+			execStart := time.Now()
+			update, reason, err = m.policyEngine.Execute(ctx, m.submissionRecorder(mtx), pending.mtx)
+			// Any hash that has been submitted could be the one that is mined, so add them all to the confirmations
+			// manager for receipt checking - even if the policy engine returned an error after submission
+			m.trackSubmissions(ctx, pending)
 			if err != nil {
 				log.L(ctx).Errorf("Policy engine returned error for transaction %s reason=%s: %s", mtx.ID, reason, err)
 				m.addError(mtx, reason, err)
-			} else {
-				if mtx.FirstSubmit != nil && pending.trackingTransactionHash != mtx.TransactionHash {
-					// If now submitted, add to confirmations manager for receipt checking
-					m.trackSubmittedTransaction(ctx, pending)
+				if reason == ffcapi.ErrorReasonInsufficientFunds {
+					m.pauseSigner(ctx, mtx, reason, err)
 				}
+			} else {
 				pending.lastPolicyCycle = time.Now()
+				// The probe only proves the signer can submit again if the policy engine actually submitted
+				if probe && mtx.LastSubmit != nil && (lastSubmit == nil || !mtx.LastSubmit.Equal(lastSubmit)) {
+					m.resumeSigner(ctx, mtx.TransactionHeaders.From)
+				}
+				if scheduleReached && update == policyengine.UpdateNo {
+					// Make sure the end of the scheduled state is persisted
+					update = policyengine.UpdateYes
+				}
+			}
+			// The nonce might have been used by another system signing with the same key. We cannot rely on the
+			// policy engine to tell us, as once a transaction has been submitted a nonce too low error is expected
+			// on resubmission - so we check against the node when a transaction has gone too long without a receipt.
+			nonceTooLow := reason == ffcapi.ErrorReasonNonceTooLow || nonceTooLowSince(mtx, execStart)
+			if mtx.Status == apitypes.TxStatusPending && m.supersededCheckDue(pending, nonceTooLow) {
+				superseded, checkErr := m.checkSuperseded(ctx, pending, nonceTooLow)
+				switch {
+				case checkErr != nil:
+					log.L(ctx).Errorf("Failed to check whether transaction %s has been superseded: %s", mtx.ID, checkErr)
+				case superseded:
+					log.L(ctx).Warnf("Transaction %s has been superseded at nonce %s / %s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce)
+					err = nil
+					update = policyengine.UpdateYes
+					completed = true
+					mtx.Status = apitypes.TxStatusSuperseded
+					m.addError(mtx, ffcapi.ErrorReasonNonceTooLow, i18n.NewError(ctx, tmmsgs.MsgTransactionSuperseded, mtx.Nonce, mtx.TransactionHeaders.From, len(submittedHashes(mtx))))
+				}
 			}
 		}
 	}
 
+	if mtx.SubStatus != prevSubStatus && update == policyengine.UpdateNo {
+		update = policyengine.UpdateYes
+	}
+
+	// Each new confirmation is sent on the websocket, but persisting them is throttled
+	if progressUpdated && update == policyengine.UpdateNo && time.Since(pending.progressPersist) >= m.progressPersistInterval {
+		update = policyengine.UpdateYes
+		pending.progressPersist = time.Now()
+	}
+
 	if err == nil {
 		switch update {
 		case policyengine.UpdateYes:
@@ -256,8 +595,18 @@ func (m *manager) execPolicy(ctx context.Context, pending *pendingState, syncDel
 				return err
 			}
 			if completed {
+				// The confirmations manager has finished with the mined hash, but will still be watching any others.
+				// None of our hashes were mined for a superseded transaction.
+				keep := mtx.TransactionHash
+				if mtx.Status == apitypes.TxStatusSuperseded {
+					keep = ""
+				}
+				m.untrackTransaction(ctx, pending, keep)
 				pending.remove = true // for the next time round the loop
 				m.markInflightStale()
+				if releaseNonce {
+					m.releaseNonce(ctx, mtx)
+				}
 			}
 		case policyengine.UpdateDelete:
 			err := m.persistence.DeleteTransaction(ctx, mtx.ID)
@@ -265,6 +614,7 @@ func (m *manager) execPolicy(ctx context.Context, pending *pendingState, syncDel
 				log.L(ctx).Errorf("Failed to delete transaction %s (status=%s): %s", mtx.ID, mtx.Status, err)
 				return err
 			}
+			m.untrackTransaction(ctx, pending, "")
 			pending.remove = true // for the next time round the loop
 			m.markInflightStale()
 		}
@@ -274,6 +624,78 @@ func (m *manager) execPolicy(ctx context.Context, pending *pendingState, syncDel
 	return nil
 }
 
+// execSpeedUp forces an immediate resubmission of a submitted transaction at the same nonce, with a higher gas price.
+// The policy engine performs the resubmission outside of its normal cycle, so that it can take the new gas price
+// into account when it next manages the transaction.
+// The transaction must be in the in-flight set, as the hash of the resubmission is tracked on its pendingState.
+func (m *manager) execSpeedUp(ctx context.Context, pending *pendingState, isInflight bool, speedUp *apitypes.SpeedUpRequest) error {
+
+	m.mux.Lock()
+	mtx := pending.mtx
+	inFlight := mtx.Status == apitypes.TxStatusPending && mtx.FirstSubmit != nil && mtx.Receipt == nil && mtx.DeleteRequested == nil && mtx.ExpiryCancelRequested == nil
+	m.mux.Unlock()
+	if !inFlight {
+		return i18n.NewError(ctx, tmmsgs.MsgSpeedUpNotInFlight, mtx.ID)
+	}
+	if !isInflight {
+		return i18n.NewError(ctx, tmmsgs.MsgSpeedUpNotInflightSet, mtx.ID)
+	}
+
+	gasPrice, err := m.speedUpGasPrice(ctx, mtx, speedUp)
+	if err != nil {
+		return err
+	}
+
+	log.L(ctx).Infof("Speeding up transaction %s at nonce %s / %d from gasPrice=%s to gasPrice=%s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), mtx.GasPrice, gasPrice)
+	lastSubmit := mtx.LastSubmit
+	mtx.SpeedUpGasPrice = gasPrice
+	update, reason, err := m.policyEngine.Execute(ctx, m.submissionRecorder(mtx), mtx)
+	mtx.SpeedUpGasPrice = nil
+	m.trackSubmissions(ctx, pending)
+	if err != nil {
+		log.L(ctx).Errorf("Speed up failed for transaction %s reason=%s: %s", mtx.ID, reason, err)
+		return err
+	}
+	if mtx.LastSubmit == nil || (lastSubmit != nil && mtx.LastSubmit.Equal(lastSubmit)) {
+		return i18n.NewError(ctx, tmmsgs.MsgSpeedUpNotSubmitted, mtx.ID)
+	}
+	pending.lastPolicyCycle = time.Now()
+
+	if update == policyengine.UpdateYes {
+		mtx.Updated = fftypes.Now()
+		if err := m.persistence.WriteTransaction(ctx, mtx, false); err != nil {
+			log.L(ctx).Errorf("Failed to update transaction %s after speed up: %s", mtx.ID, err)
+			return err
+		}
+	}
+	m.sendWSReply(mtx)
+	return nil
+}
+
+func (m *manager) speedUpGasPrice(ctx context.Context, mtx *apitypes.ManagedTX, speedUp *apitypes.SpeedUpRequest) (*fftypes.JSONAny, error) {
+	switch {
+	case speedUp == nil || speedUp.GasPrice.IsNil() == (speedUp.GasPriceMultiplier == nil):
+		return nil, i18n.NewError(ctx, tmmsgs.MsgSpeedUpRequestInvalid)
+	case speedUp.GasPriceMultiplier != nil:
+		multiplierStr := strconv.FormatFloat(*speedUp.GasPriceMultiplier, 'f', -1, 64)
+		multiplier, ok := new(big.Rat).SetString(multiplierStr)
+		if !ok || multiplier.Cmp(big.NewRat(1, 1)) <= 0 {
+			return nil, i18n.NewError(ctx, tmmsgs.MsgSpeedUpMultiplierInvalid, multiplierStr)
+		}
+		gasPrice, _, err := gasprice.NewMultiplier(multiplier).Escalate(ctx, mtx.GasPrice)
+		return gasPrice, err
+	default:
+		increased, err := gasprice.IsIncrease(ctx, mtx.GasPrice, speedUp.GasPrice)
+		if err != nil {
+			return nil, err
+		}
+		if !increased {
+			return nil, i18n.NewError(ctx, tmmsgs.MsgSpeedUpGasPriceNotIncreased, speedUp.GasPrice, mtx.GasPrice)
+		}
+		return speedUp.GasPrice, nil
+	}
+}
+
 func (m *manager) sendWSReply(mtx *apitypes.ManagedTX) {
 	wsr := &apitypes.TransactionUpdateReply{
 		ManagedTX: *mtx,
@@ -286,6 +708,8 @@ func (m *manager) sendWSReply(mtx *apitypes.ManagedTX) {
 		wsr.Headers.Type = apitypes.TransactionUpdateSuccess
 	case apitypes.TxStatusFailed:
 		wsr.Headers.Type = apitypes.TransactionUpdateFailure
+	case apitypes.TxStatusSuperseded:
+		wsr.Headers.Type = apitypes.TransactionUpdateSuperseded
 	default:
 		wsr.Headers.Type = apitypes.TransactionUpdate
 	}
@@ -293,61 +717,98 @@ func (m *manager) sendWSReply(mtx *apitypes.ManagedTX) {
 	m.wsServer.SendReply(wsr)
 }
 
-func (m *manager) trackSubmittedTransaction(ctx context.Context, pending *pendingState) {
-	var err error
-
-	// Clear any old transaction hash
-	if pending.trackingTransactionHash != "" {
-		err = m.confirmations.Notify(&confirmations.Notification{
-			NotificationType: confirmations.RemovedTransaction,
-			Transaction: &confirmations.TransactionInfo{
-				TransactionHash: pending.trackingTransactionHash,
-			},
-		})
+// trackSubmissions ensures the confirmations manager is watching every hash submitted for the transaction
+func (m *manager) trackSubmissions(ctx context.Context, pending *pendingState) {
+	if pending.trackedHashes == nil {
+		pending.trackedHashes = make(map[string]bool)
+	}
+	for _, txHash := range submittedHashes(pending.mtx) {
+		if !pending.trackedHashes[txHash] && m.trackTransactionHash(ctx, pending, txHash) {
+			pending.trackedHashes[txHash] = true
+		}
 	}
+	if len(pending.trackedHashes) > 0 && (pending.mtx.SubStatus == "" || pending.mtx.SubStatus == apitypes.TxSubStatusQueued) {
+		setSubStatus(pending.mtx, apitypes.TxSubStatusSubmitted)
+	}
+}
 
-	// Notify of the new
-	if err == nil {
-		err = m.confirmations.Notify(&confirmations.Notification{
-			NotificationType: confirmations.NewTransaction,
-			Transaction: &confirmations.TransactionInfo{
-				TransactionHash: pending.mtx.TransactionHash,
-				Receipt: func(ctx context.Context, receipt *ffcapi.TransactionReceiptResponse) {
-					// Will be picked up on the next policy loop cycle - guaranteed to occur before Confirmed
-					m.mux.Lock()
-					pending.mtx.Receipt = receipt
-					m.mux.Unlock()
-					log.L(m.ctx).Debugf("Receipt received for transaction %s at nonce %s / %d - hash: %s", pending.mtx.ID, pending.mtx.TransactionHeaders.From, pending.mtx.Nonce.Int64(), pending.mtx.TransactionHash)
-					m.markInflightUpdate()
-				},
-				Confirmed: func(ctx context.Context, confirmations []confirmations.BlockInfo) {
-					// Will be picked up on the next policy loop cycle
-					m.mux.Lock()
-					pending.confirmed = true
-					pending.mtx.Confirmations = confirmations
-					m.mux.Unlock()
-					log.L(m.ctx).Debugf("Confirmed transaction %s at nonce %s / %d - hash: %s", pending.mtx.ID, pending.mtx.TransactionHeaders.From, pending.mtx.Nonce.Int64(), pending.mtx.TransactionHash)
-					m.markInflightUpdate()
+// untrackTransaction removes the hashes we are tracking from the confirmation manager, other than the one to keep (if any)
+func (m *manager) untrackTransaction(ctx context.Context, pending *pendingState, keep string) {
+	for txHash := range pending.trackedHashes {
+		if txHash != keep {
+			err := m.confirmations.Notify(&confirmations.Notification{
+				NotificationType: confirmations.RemovedTransaction,
+				Transaction: &confirmations.TransactionInfo{
+					TransactionHash: txHash,
 				},
-			},
-		})
+			})
+			if err != nil {
+				log.L(ctx).Infof("Error detected notifying confirmation manager: %s", err)
+			}
+		}
 	}
+	pending.trackedHashes = nil
+}
+
+// trackTransactionHash asks the confirmations manager to watch for a receipt for one of the hashes submitted for the
+// transaction. Whichever hash is mined becomes the transaction hash, and the receipt and confirmations are recorded.
+func (m *manager) trackTransactionHash(ctx context.Context, pending *pendingState, txHash string) bool {
+	err := m.confirmations.Notify(&confirmations.Notification{
+		NotificationType: confirmations.NewTransaction,
+		Transaction: &confirmations.TransactionInfo{
+			TransactionHash: txHash,
+			Confirmations:   pending.mtx.RequiredConfirmations,
+			Receipt: func(ctx context.Context, receipt *ffcapi.TransactionReceiptResponse) {
+				// Will be picked up on the next policy loop cycle - guaranteed to occur before Confirmed
+				// The policy engine owns the transaction hash, so the mined hash is copied across on the policy loop
+				m.mux.Lock()
+				pending.minedHash = txHash
+				pending.mtx.Receipt = receipt
+				m.mux.Unlock()
+				log.L(m.ctx).Debugf("Receipt received for transaction %s at nonce %s / %d - hash: %s", pending.mtx.ID, pending.mtx.TransactionHeaders.From, pending.mtx.Nonce.Int64(), txHash)
+				m.markInflightUpdate()
+			},
+			Progress: func(ctx context.Context, progress *confirmations.ConfirmationProgress) {
+				// Will be picked up on the next policy loop cycle
+				m.mux.Lock()
+				pending.mtx.ConfirmationProgress = &apitypes.ConfirmationProgress{
+					Count:       progress.Count,
+					Required:    progress.Required,
+					LatestBlock: progress.LatestBlock,
+				}
+				pending.progressUpdated = true
+				m.mux.Unlock()
+				log.L(m.ctx).Debugf("Confirmation %d of %d for transaction %s at nonce %s / %d - hash: %s", progress.Count, progress.Required, pending.mtx.ID, pending.mtx.TransactionHeaders.From, pending.mtx.Nonce.Int64(), txHash)
+				m.markInflightUpdate()
+			},
+			Confirmed: func(ctx context.Context, confirmations []apitypes.BlockInfo) {
+				// Will be picked up on the next policy loop cycle
+				m.mux.Lock()
+				pending.confirmed = true
+				pending.mtx.Confirmations = confirmations
+				m.mux.Unlock()
+				log.L(m.ctx).Debugf("Confirmed transaction %s at nonce %s / %d - hash: %s", pending.mtx.ID, pending.mtx.TransactionHeaders.From, pending.mtx.Nonce.Int64(), txHash)
+				m.markInflightUpdate()
+			},
+		},
+	})
 
 	// Only reason for error here should be a cancelled context
 	if err != nil {
 		log.L(ctx).Infof("Error detected notifying confirmation manager: %s", err)
-	} else {
-		pending.trackingTransactionHash = pending.mtx.TransactionHash
+		return false
 	}
+	return true
 }
 
 func (m *manager) policyEngineAPIRequest(ctx context.Context, req *policyEngineAPIRequest) policyEngineAPIResponse {
+	// The request must be complete before it is queued, as it is picked up by the policy loop
+	req.response = make(chan policyEngineAPIResponse, 1)
+	req.startTime = time.Now()
 	m.mux.Lock()
 	m.policyEngineAPIRequests = append(m.policyEngineAPIRequests, req)
 	m.mux.Unlock()
 	m.markInflightUpdate()
-	req.response = make(chan policyEngineAPIResponse, 1)
-	req.startTime = time.Now()
 	select {
 	case res := <-req.response:
 		return res
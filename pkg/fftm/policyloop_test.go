@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"sync"
 	"testing"
 	"time"
 
@@ -58,7 +59,7 @@ func sendSampleTX(t *testing.T, m *manager, signer string, nonce int64) *apitype
 		TransactionData: "0xabce1234",
 	}, ffcapi.ErrorReason(""), nil).Once()
 
-	mtx, err := m.sendManagedTransaction(m.ctx, &apitypes.TransactionRequest{
+	_, mtx, err := m.sendManagedTransaction(m.ctx, &apitypes.TransactionRequest{
 		TransactionInput: txInput,
 	})
 	assert.NoError(t, err)
@@ -91,7 +92,11 @@ func TestPolicyLoopE2EOk(t *testing.T) {
 			BlockHash:        fftypes.NewRandB32().String(),
 			Success:          true,
 		})
-		n.Transaction.Confirmed(context.Background(), []confirmations.BlockInfo{})
+		n.Transaction.Progress(context.Background(), &confirmations.ConfirmationProgress{
+			Count:    1,
+			Required: 1,
+		})
+		n.Transaction.Confirmed(context.Background(), []apitypes.BlockInfo{})
 	}).Return(nil)
 
 	// Run the policy once to do the send
@@ -111,6 +116,7 @@ func TestPolicyLoopE2EOk(t *testing.T) {
 	rtx, err := m.persistence.GetTransactionByID(m.ctx, mtx.ID)
 	assert.NoError(t, err)
 	assert.Equal(t, apitypes.TxStatusSucceeded, rtx.Status)
+	assert.Equal(t, 1, rtx.ConfirmationProgress.Count)
 
 	mc.AssertExpectations(t)
 	mfc.AssertExpectations(t)
@@ -142,8 +148,10 @@ func TestPolicyLoopE2EReverted(t *testing.T) {
 			BlockHash:        fftypes.NewRandB32().String(),
 			Success:          false,
 		})
-		n.Transaction.Confirmed(context.Background(), []confirmations.BlockInfo{})
+		n.Transaction.Confirmed(context.Background(), []apitypes.BlockInfo{})
 	}).Return(nil)
+	// Replaying the transaction does not give a revert reason
+	mfc.On("QueryInvoke", m.ctx, mock.Anything).Return(&ffcapi.QueryInvokeResponse{}, ffcapi.ErrorReason(""), nil)
 
 	// Run the policy once to do the send
 	<-m.inflightStale // from sending the TX
@@ -196,11 +204,6 @@ func TestPolicyLoopResubmitNewTXID(t *testing.T) {
 		return n.NotificationType == confirmations.NewTransaction &&
 			n.Transaction.TransactionHash == txHash1
 	})).Return(nil)
-	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
-		// Then we get notified to remove the old TX hash
-		return n.NotificationType == confirmations.RemovedTransaction &&
-			n.Transaction.TransactionHash == txHash1
-	})).Return(nil)
 	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
 		// Then we get the new TX hash, which we confirm
 		return n.NotificationType == confirmations.NewTransaction &&
@@ -213,7 +216,7 @@ func TestPolicyLoopResubmitNewTXID(t *testing.T) {
 			BlockHash:        fftypes.NewRandB32().String(),
 			Success:          true,
 		})
-		n.Transaction.Confirmed(context.Background(), []confirmations.BlockInfo{})
+		n.Transaction.Confirmed(context.Background(), []apitypes.BlockInfo{})
 	}).Return(nil)
 
 	// Run the policy once to do the send with the first hash
@@ -234,261 +237,1333 @@ func TestPolicyLoopResubmitNewTXID(t *testing.T) {
 	m.policyLoopCycle(m.ctx, false)
 	assert.Equal(t, mtx.ID, m.inflight[0].mtx.ID)
 	assert.Equal(t, apitypes.TxStatusPending, m.inflight[0].mtx.Status)
+	assert.True(t, m.inflight[0].trackedHashes[txHash1])
+	assert.True(t, m.inflight[0].trackedHashes[txHash2])
+	assert.Len(t, m.inflight[0].mtx.SubmissionHistory, 2)
+	assert.Equal(t, txHash2, m.inflight[0].mtx.SubmissionHistory[0].TransactionHash)
+	assert.Equal(t, txHash1, m.inflight[0].mtx.SubmissionHistory[1].TransactionHash)
+
+	// The old TX hash is only removed once the new one completes
+	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
+		return n.NotificationType == confirmations.RemovedTransaction &&
+			n.Transaction.TransactionHash == txHash1
+	})).Return(nil)
+	pending := m.inflight[0]
+	m.policyLoopCycle(m.ctx, false)
+	assert.Equal(t, apitypes.TxStatusSucceeded, pending.mtx.Status)
+	assert.Nil(t, pending.trackedHashes)
 
 	mc.AssertExpectations(t)
 	mfc.AssertExpectations(t)
 }
 
-func TestNotifyConfirmationMgrFail(t *testing.T) {
+func TestPolicyLoopCancelSubmittedTX(t *testing.T) {
 
 	_, m, cancel := newTestManager(t)
 	defer cancel()
 
-	_ = sendSampleTX(t, m, "0xaaaaa", 12345)
+	mtx := sendSampleTX(t, m, "0xaaaaa", 12345)
 	txHash := "0x" + fftypes.NewRandB32().String()
+	cancelTxHash := "0x" + fftypes.NewRandB32().String()
 
 	mfc := m.connector.(*ffcapimocks.API)
-	mfc.On("TransactionSend", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSendResponse{
+	mfc.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.To == ""
+	})).Return(&ffcapi.TransactionSendResponse{
 		TransactionHash: txHash,
 	}, ffcapi.ErrorReason(""), nil).Once()
+	mfc.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		// The replacement is a zero value transfer to ourselves, with a higher gas price
+		return req.To == "0xaaaaa" &&
+			req.Value.Int64() == 0 &&
+			req.Nonce.Int64() == 12345 &&
+			req.GasPrice.String() == "245679012345"
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: cancelTxHash,
+	}, ffcapi.ErrorReason(""), nil).Once()
 
 	mc := m.confirmations.(*confirmationsmocks.Manager)
-	mc.On("Notify", mock.Anything).Return(fmt.Errorf("pop"))
+	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
+		return n.NotificationType == confirmations.NewTransaction &&
+			n.Transaction.TransactionHash == txHash
+	})).Return(nil)
+	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
+		// The replacement is mined
+		return n.NotificationType == confirmations.NewTransaction &&
+			n.Transaction.TransactionHash == cancelTxHash
+	})).Run(func(args mock.Arguments) {
+		n := args[0].(*confirmations.Notification)
+		n.Transaction.Receipt(context.Background(), &ffcapi.TransactionReceiptResponse{
+			BlockNumber:      fftypes.NewFFBigInt(12345),
+			TransactionIndex: fftypes.NewFFBigInt(10),
+			BlockHash:        fftypes.NewRandB32().String(),
+			Success:          true,
+		})
+	}).Return(nil)
+	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
+		return n.NotificationType == confirmations.RemovedTransaction &&
+			n.Transaction.TransactionHash == txHash
+	})).Return(nil).Once()
+	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
+		return n.NotificationType == confirmations.RemovedTransaction &&
+			n.Transaction.TransactionHash == cancelTxHash
+	})).Return(nil).Once()
 
+	// Run the policy once to do the send
+	<-m.inflightStale // from sending the TX
 	m.policyLoopCycle(m.ctx, true)
+	assert.Len(t, m.inflight, 1)
+	assert.True(t, m.inflight[0].trackedHashes[txHash])
+
+	// Request the deletion, which submits the replacement
+	req := &policyEngineAPIRequest{
+		requestType: policyEngineAPIRequestTypeDelete,
+		txID:        mtx.ID,
+		response:    make(chan policyEngineAPIResponse, 1),
+	}
+	m.policyEngineAPIRequests = append(m.policyEngineAPIRequests, req)
+	m.processPolicyAPIRequests(m.ctx)
+	res := <-req.response
+	assert.NoError(t, res.err)
+	assert.Equal(t, http.StatusAccepted, res.status)
+	assert.Equal(t, cancelTxHash, res.tx.Cancellation.TransactionHash)
+	assert.True(t, m.inflight[0].trackedHashes[cancelTxHash])
+	assert.Len(t, res.tx.SubmissionHistory, 2)
+	assert.Equal(t, cancelTxHash, res.tx.SubmissionHistory[0].TransactionHash)
+	assert.False(t, m.inflight[0].remove)
+
+	// The receipt for the replacement completes the deletion
+	m.policyLoopCycle(m.ctx, false)
+	assert.True(t, m.inflight[0].remove)
+	assert.Empty(t, m.inflight[0].trackedHashes)
+	deleted, err := m.persistence.GetTransactionByID(m.ctx, mtx.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, deleted)
 
 	mc.AssertExpectations(t)
 	mfc.AssertExpectations(t)
-
 }
 
-func TestInflightSetListFailCancel(t *testing.T) {
+func TestExecPolicyConfirmedDeleteRequested(t *testing.T) {
 
-	_, m, close := newTestManagerMockPersistence(t)
-	close()
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	mpe.On("Execute", mock.Anything, mock.Anything, mock.Anything).Return(policyengine.UpdateDelete, ffcapi.ErrorReason(""), nil).Once()
+
+	tx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	tx.DeleteRequested = fftypes.Now()
+	tx.Receipt = &ffcapi.TransactionReceiptResponse{Success: true}
+	pending := &pendingState{mtx: tx, confirmed: true}
 
 	mp := m.persistence.(*persistencemocks.Persistence)
-	mp.On("ListTransactionsPending", m.ctx, (*fftypes.UUID)(nil), m.maxInFlight, persistence.SortDirectionAscending).
-		Return(nil, fmt.Errorf("pop"))
+	mp.On("DeleteTransaction", m.ctx, tx.ID).Return(nil)
 
-	m.policyLoopCycle(m.ctx, true)
+	err := m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.True(t, pending.remove)
+	assert.Equal(t, apitypes.TxStatusPending, tx.Status)
 
 	mp.AssertExpectations(t)
+	mpe.AssertExpectations(t)
 
 }
 
-func TestPolicyLoopUpdateFail(t *testing.T) {
+func TestExecPolicyExpiredBeforeSubmit(t *testing.T) {
 
-	_, m, close := newTestManagerMockPersistence(t)
-	defer close()
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
 
-	m.inflight = []*pendingState{
-		{
-			confirmed: true,
-			mtx: &apitypes.ManagedTX{
-				ID:          fmt.Sprintf("ns1/%s", fftypes.NewUUID()),
-				Created:     fftypes.Now(),
-				SequenceID:  apitypes.NewULID(),
-				Nonce:       fftypes.NewFFBigInt(1000),
-				Status:      apitypes.TxStatusSucceeded,
-				FirstSubmit: fftypes.Now(),
-				Receipt:     &ffcapi.TransactionReceiptResponse{},
-				TransactionHeaders: ffcapi.TransactionHeaders{
-					From: "0x12345",
-				},
-			},
-		},
-	}
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+
+	tx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	expiry := fftypes.FFTime(time.Now().Add(-1 * time.Minute))
+	tx.Expiry = &expiry
+	pending := &pendingState{mtx: tx}
 
 	mp := m.persistence.(*persistencemocks.Persistence)
-	mp.On("WriteTransaction", m.ctx, mock.Anything, false).Return(fmt.Errorf("pop"))
-	mp.On("Close", mock.Anything).Return(nil).Maybe()
+	mp.On("WriteTransaction", m.ctx, tx, false).Return(nil)
+	// Failing to fill the released nonce is only logged, as the gap is found by the nonce reconciler
+	mp.On("GetTransactionByNonce", m.ctx, "0xabcd1234", fftypes.NewFFBigInt(12345)).Return(nil, fmt.Errorf("pop"))
 
-	m.policyLoopCycle(m.ctx, false)
+	err := m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.True(t, pending.remove)
+	assert.Equal(t, apitypes.TxStatusFailed, tx.Status)
+	assert.Regexp(t, "FF21076", tx.ErrorMessage)
+	assert.Empty(t, m.policyEngineAPIRequests)
 
 	mp.AssertExpectations(t)
+	mpe.AssertExpectations(t)
 
 }
 
-func TestPolicyEngineFailStaleThenUpdated(t *testing.T) {
+// mockNodeMinesInNonceOrder simulates a node that only mines the transactions of a signer once every lower nonce
+// has been submitted, starting from the next nonce given
+func mockNodeMinesInNonceOrder(m *manager, signer string, nextNonce int64) {
+	var mux sync.Mutex
+	submitted := make(map[int64]*confirmations.TransactionInfo)
+	nonces := make(map[string]int64)
 
-	_, m, cancel := newTestManager(t)
-	defer cancel()
-	m.policyLoopInterval = 1 * time.Hour
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("NextNonceForSigner", mock.Anything, &ffcapi.NextNonceForSignerRequest{
+		Signer: signer,
+	}).Return(func(ctx context.Context, req *ffcapi.NextNonceForSignerRequest) *ffcapi.NextNonceForSignerResponse {
+		mux.Lock()
+		defer mux.Unlock()
+		return &ffcapi.NextNonceForSignerResponse{Nonce: fftypes.NewFFBigInt(nextNonce)}
+	}, ffcapi.ErrorReason(""), nil).Maybe()
+	mfc.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.From == signer
+	})).Return(func(ctx context.Context, req *ffcapi.TransactionSendRequest) *ffcapi.TransactionSendResponse {
+		mux.Lock()
+		defer mux.Unlock()
+		txHash := fmt.Sprintf("0x%d", req.Nonce.Int64())
+		nonces[txHash] = req.Nonce.Int64()
+		return &ffcapi.TransactionSendResponse{TransactionHash: txHash}
+	}, ffcapi.ErrorReason(""), nil)
 
-	mpe := &policyenginemocks.PolicyEngine{}
-	m.policyEngine = mpe
-	done1 := make(chan struct{})
-	mpe.On("Execute", mock.Anything, mock.Anything, mock.Anything).
-		Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), fmt.Errorf("pop")).
-		Once().
-		Run(func(args mock.Arguments) {
-			close(done1)
-			m.markInflightUpdate()
-		})
+	mc := m.confirmations.(*confirmationsmocks.Manager)
+	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
+		return n.NotificationType == confirmations.NewTransaction
+	})).Run(func(args mock.Arguments) {
+		n := args[0].(*confirmations.Notification)
+		mux.Lock()
+		submitted[nonces[n.Transaction.TransactionHash]] = n.Transaction
+		var mined []*confirmations.TransactionInfo
+		for submitted[nextNonce] != nil {
+			mined = append(mined, submitted[nextNonce])
+			nextNonce++
+		}
+		mux.Unlock()
+		for _, info := range mined {
+			info.Receipt(context.Background(), &ffcapi.TransactionReceiptResponse{
+				BlockNumber: fftypes.NewFFBigInt(12345),
+				Success:     true,
+			})
+			info.Confirmed(context.Background(), []apitypes.BlockInfo{})
+		}
+	}).Return(nil)
+	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
+		return n.NotificationType == confirmations.RemovedTransaction
+	})).Return(nil).Maybe()
+}
 
-	done2 := make(chan struct{})
-	mpe.On("Execute", mock.Anything, mock.Anything, mock.Anything).
-		Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), fmt.Errorf("pop")).
-		Once().
-		Run(func(args mock.Arguments) {
-			close(done2)
-		})
+func mockNonceGapFillPrepare(m *manager, signer string) {
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("TransactionPrepare", mock.Anything, &ffcapi.TransactionPrepareRequest{
+		TransactionInput: ffcapi.TransactionInput{
+			TransactionHeaders: ffcapi.TransactionHeaders{
+				From:  signer,
+				To:    signer,
+				Value: fftypes.NewFFBigInt(0),
+			},
+		},
+	}).Return(&ffcapi.TransactionPrepareResponse{
+		Gas:             fftypes.NewFFBigInt(21000),
+		TransactionData: "0x",
+	}, ffcapi.ErrorReason(""), nil).Once()
+}
 
-	_ = sendSampleTX(t, m, "0xaaaaa", 12345)
+// runPolicyLoopUntilComplete runs the policy loop until the transaction has completed, or a limit is reached
+func runPolicyLoopUntilComplete(t *testing.T, m *manager, txID string) *apitypes.ManagedTX {
+	for i := 0; i < 10; i++ {
+		m.policyLoopCycle(m.ctx, true)
+		mtx, err := m.persistence.GetTransactionByID(m.ctx, txID)
+		assert.NoError(t, err)
+		if mtx.Status != apitypes.TxStatusPending {
+			return mtx
+		}
+	}
+	assert.Fail(t, "transaction did not complete", txID)
+	return nil
+}
 
-	m.Start()
+func TestPolicyLoopExpiredBeforeSubmitReleasesNonce(t *testing.T) {
 
-	<-done1
+	_, m, cancel := newTestManager(t)
+	defer cancel()
 
-	<-done2
+	expired := genTestTxn("0xaaaaa", 1000, apitypes.TxStatusPending)
+	expiry := fftypes.FFTime(time.Now().Add(-1 * time.Minute))
+	expired.Expiry = &expiry
+	err := m.persistence.WriteTransaction(m.ctx, expired, true)
+	assert.NoError(t, err)
+	later := newTestTxn(t, m, "0xaaaaa", 1001, apitypes.TxStatusPending)
 
-	mpe.AssertExpectations(t)
+	mockNodeMinesInNonceOrder(m, "0xaaaaa", 1000)
+	mockNonceGapFillPrepare(m, "0xaaaaa")
+
+	// The later transaction is mined, once the released nonce has been filled
+	rtx := runPolicyLoopUntilComplete(t, m, later.ID)
+	assert.Equal(t, apitypes.TxStatusSucceeded, rtx.Status)
+
+	rtx, err = m.persistence.GetTransactionByID(m.ctx, expired.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.TxStatusFailed, rtx.Status)
+	assert.Regexp(t, "FF21076", rtx.ErrorMessage)
+
+	fill, err := m.persistence.GetTransactionByNonce(m.ctx, "0xaaaaa", fftypes.NewFFBigInt(1000))
+	assert.NoError(t, err)
+	assert.NotEqual(t, expired.ID, fill.ID)
+	assert.Equal(t, "0x1000", fill.TransactionHash)
 
 }
 
-func TestMarkInflightStaleDoesNotBlock(t *testing.T) {
+func TestExecPolicyExpiredAfterSubmit(t *testing.T) {
 
-	_, m, cancel := newTestManager(t)
+	_, m, cancel := newTestManagerMockPersistence(t)
 	defer cancel()
 
-	m.markInflightStale()
-	m.markInflightStale()
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
 
-}
+	tx := genTestSubmittedTxn()
+	expiry := fftypes.FFTime(time.Now().Add(-1 * time.Minute))
+	tx.Expiry = &expiry
+	pending := &pendingState{mtx: tx}
 
-func TestMarkInflightUpdateDoesNotBlock(t *testing.T) {
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("WriteTransaction", m.ctx, tx, false).Return(nil)
 
-	_, m, cancel := newTestManager(t)
-	defer cancel()
+	err := m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.False(t, pending.remove)
+	assert.Equal(t, apitypes.TxStatusPending, tx.Status)
+	assert.NotNil(t, tx.ExpiryCancelRequested)
+	assert.Nil(t, tx.DeleteRequested)
+	assert.Regexp(t, "FF21077", tx.ErrorMessage)
+
+	// The policy engine is then responsible for cancelling it
+	mpe.On("Execute", mock.Anything, mock.Anything, tx).Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), nil).Once()
+	err = m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
 
-	m.markInflightUpdate()
-	m.markInflightUpdate()
+	mp.AssertExpectations(t)
+	mpe.AssertExpectations(t)
 
 }
 
-func TestExecPolicyDeleteFail(t *testing.T) {
+func TestExecPolicyExpiredCancellationMined(t *testing.T) {
 
 	_, m, cancel := newTestManagerMockPersistence(t)
 	defer cancel()
 
 	mpe := &policyenginemocks.PolicyEngine{}
 	m.policyEngine = mpe
-	mpe.On("Execute", mock.Anything, mock.Anything, mock.Anything).Return(policyengine.UpdateDelete, ffcapi.ErrorReason(""), nil).Maybe()
-
-	tx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
-
-	mp := m.persistence.(*persistencemocks.Persistence)
-	mp.On("GetTransactionByID", m.ctx, tx.ID).Return(tx, nil)
-	mp.On("DeleteTransaction", m.ctx, tx.ID).Return(fmt.Errorf("pop"))
 
-	req := &policyEngineAPIRequest{
-		requestType: policyEngineAPIRequestTypeDelete,
-		txID:        tx.ID,
-		response:    make(chan policyEngineAPIResponse, 1),
+	tx := genTestSubmittedTxn()
+	expiry := fftypes.FFTime(time.Now().Add(-1 * time.Minute))
+	tx.Expiry = &expiry
+	tx.ExpiryCancelRequested = fftypes.Now()
+	tx.Cancellation = &apitypes.ManagedTXCancellation{
+		TransactionHash:   "0x67890",
+		TransactionHashes: []string{"0x67890"},
 	}
-	m.policyEngineAPIRequests = append(m.policyEngineAPIRequests, req)
+	tx.TransactionHash = "0x67890"
+	tx.Receipt = &ffcapi.TransactionReceiptResponse{Success: true}
+	pending := &pendingState{mtx: tx, confirmed: true}
 
-	m.processPolicyAPIRequests(m.ctx)
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("WriteTransaction", m.ctx, tx, false).Return(nil)
 
-	res := <-req.response
-	assert.Regexp(t, "pop", res.err)
+	err := m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.True(t, pending.remove)
+	assert.Equal(t, apitypes.TxStatusFailed, tx.Status)
+	assert.Regexp(t, "FF21076", tx.ErrorMessage)
 
 	mp.AssertExpectations(t)
+	mpe.AssertExpectations(t)
 
 }
 
-func TestExecPolicyDeleteInflightSync(t *testing.T) {
+func TestExecPolicyExpiredOriginalMined(t *testing.T) {
 
 	_, m, cancel := newTestManagerMockPersistence(t)
 	defer cancel()
 
 	mpe := &policyenginemocks.PolicyEngine{}
 	m.policyEngine = mpe
-	mpe.On("Execute", mock.Anything, mock.Anything, mock.Anything).Return(policyengine.UpdateDelete, ffcapi.ErrorReason(""), nil).Maybe()
-
-	tx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
-	m.inflight = []*pendingState{{mtx: tx}}
-
-	mp := m.persistence.(*persistencemocks.Persistence)
-	mp.On("DeleteTransaction", m.ctx, tx.ID).Return(nil)
 
-	req := &policyEngineAPIRequest{
-		requestType: policyEngineAPIRequestTypeDelete,
-		txID:        tx.ID,
-		response:    make(chan policyEngineAPIResponse, 1),
+	tx := genTestSubmittedTxn()
+	expiry := fftypes.FFTime(time.Now().Add(-1 * time.Minute))
+	tx.Expiry = &expiry
+	tx.ExpiryCancelRequested = fftypes.Now()
+	tx.Cancellation = &apitypes.ManagedTXCancellation{
+		TransactionHash:   "0x67890",
+		TransactionHashes: []string{"0x67890"},
 	}
-	m.policyEngineAPIRequests = append(m.policyEngineAPIRequests, req)
+	tx.Receipt = &ffcapi.TransactionReceiptResponse{Success: true}
+	pending := &pendingState{mtx: tx, confirmed: true}
 
-	m.processPolicyAPIRequests(m.ctx)
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("WriteTransaction", m.ctx, tx, false).Return(nil)
 
-	res := <-req.response
-	assert.NoError(t, res.err)
-	assert.Equal(t, http.StatusOK, res.status)
-	assert.True(t, m.inflight[0].remove)
+	err := m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.True(t, pending.remove)
+	assert.Equal(t, apitypes.TxStatusSucceeded, tx.Status)
+	assert.Empty(t, tx.ErrorMessage)
 
 	mp.AssertExpectations(t)
+	mpe.AssertExpectations(t)
 
 }
 
-func TestExecPolicyDeleteNotFound(t *testing.T) {
+func TestExecPolicyNotExpired(t *testing.T) {
 
 	_, m, cancel := newTestManagerMockPersistence(t)
 	defer cancel()
 
-	mp := m.persistence.(*persistencemocks.Persistence)
-	mp.On("GetTransactionByID", m.ctx, "bad-id").Return(nil, nil)
-
-	req := &policyEngineAPIRequest{
-		requestType: policyEngineAPIRequestTypeDelete,
-		txID:        "bad-id",
-		response:    make(chan policyEngineAPIResponse, 1),
-	}
-	m.policyEngineAPIRequests = append(m.policyEngineAPIRequests, req)
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	mpe.On("Execute", mock.Anything, mock.Anything, mock.Anything).Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), nil).Once()
 
-	m.processPolicyAPIRequests(m.ctx)
+	tx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	expiry := fftypes.FFTime(time.Now().Add(1 * time.Hour))
+	tx.Expiry = &expiry
+	pending := &pendingState{mtx: tx}
 
-	res := <-req.response
-	assert.Regexp(t, "FF21067", res.err)
+	err := m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.TxStatusPending, tx.Status)
+	assert.Nil(t, tx.DeleteRequested)
 
-	mp.AssertExpectations(t)
+	mpe.AssertExpectations(t)
 
 }
 
-func TestBadPolicyAPIRequest(t *testing.T) {
+func TestExecPolicyScheduled(t *testing.T) {
 
 	_, m, cancel := newTestManagerMockPersistence(t)
 	defer cancel()
 
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+
 	tx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
-	mp := m.persistence.(*persistencemocks.Persistence)
-	mp.On("GetTransactionByID", m.ctx, tx.ID).Return(tx, nil)
+	notBefore := fftypes.FFTime(time.Now().Add(1 * time.Hour))
+	tx.NotBefore = &notBefore
+	tx.Scheduled = true
+	pending := &pendingState{mtx: tx}
 
-	req := &policyEngineAPIRequest{
-		requestType: policyEngineAPIRequestType(999),
-		txID:        tx.ID,
-		response:    make(chan policyEngineAPIResponse, 1),
-	}
-	m.policyEngineAPIRequests = append(m.policyEngineAPIRequests, req)
+	// Policy engine is not invoked before the time
+	err := m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.True(t, tx.Scheduled)
 
-	m.processPolicyAPIRequests(m.ctx)
+	// Once the time passes, the policy engine is invoked, and the state change persisted
+	notBefore = fftypes.FFTime(time.Now().Add(-1 * time.Second))
+	mpe.On("Execute", mock.Anything, mock.Anything, tx).Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), nil).Once()
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("WriteTransaction", m.ctx, tx, false).Return(nil)
 
-	res := <-req.response
-	assert.Regexp(t, "FF21069", res.err)
+	err = m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.False(t, tx.Scheduled)
 
 	mp.AssertExpectations(t)
+	mpe.AssertExpectations(t)
 
 }
 
-func TestBadPolicyAPITimeout(t *testing.T) {
+func TestUntrackTransactionFail(t *testing.T) {
 
-	_, m, cancel := newTestManagerMockPersistence(t)
+	_, m, cancel := newTestManager(t)
 	defer cancel()
 
-	ctx, cancelCtx := context.WithCancel(context.Background())
-	cancelCtx()
+	mc := m.confirmations.(*confirmationsmocks.Manager)
+	mc.On("Notify", mock.Anything).Return(fmt.Errorf("pop"))
+
+	pending := &pendingState{
+		trackedHashes: map[string]bool{
+			"0x12345": true,
+			"0x67890": true,
+		},
+	}
+	m.untrackTransaction(m.ctx, pending, "")
+	assert.Empty(t, pending.trackedHashes)
+
+	mc.AssertNumberOfCalls(t, "Notify", 2)
+
+}
+
+func TestNotifyConfirmationMgrFail(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	_ = sendSampleTX(t, m, "0xaaaaa", 12345)
+	txHash := "0x" + fftypes.NewRandB32().String()
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("TransactionSend", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: txHash,
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	mc := m.confirmations.(*confirmationsmocks.Manager)
+	mc.On("Notify", mock.Anything).Return(fmt.Errorf("pop"))
+
+	m.policyLoopCycle(m.ctx, true)
+
+	mc.AssertExpectations(t)
+	mfc.AssertExpectations(t)
+
+}
+
+func TestInflightSetListFailCancel(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	close()
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("ListTransactionsPending", m.ctx, (*fftypes.UUID)(nil), m.maxInFlight, persistence.SortDirectionAscending).
+		Return(nil, fmt.Errorf("pop"))
+
+	m.policyLoopCycle(m.ctx, true)
+
+	mp.AssertExpectations(t)
+
+}
+
+func TestInflightSetRoundRobinSigners(t *testing.T) {
+
+	_, m, close := newTestManager(t)
+	defer close()
+	m.maxInFlight = 5
+
+	var txA, txB []*apitypes.ManagedTX
+	for i := 0; i < 10; i++ {
+		txA = append(txA, newTestTxn(t, m, "0xaaaaa", int64(1000+i), apitypes.TxStatusPending))
+	}
+	for i := 0; i < 2; i++ {
+		txB = append(txB, newTestTxn(t, m, "0xbbbbb", int64(2000+i), apitypes.TxStatusPending))
+	}
+
+	ok := m.updateInflightSet(m.ctx)
+	assert.True(t, ok)
+	assert.Len(t, m.inflight, 5)
+	assert.Equal(t, txA[0].ID, m.inflight[0].mtx.ID)
+	assert.Equal(t, txB[0].ID, m.inflight[1].mtx.ID)
+	assert.Equal(t, txA[1].ID, m.inflight[2].mtx.ID)
+	assert.Equal(t, txB[1].ID, m.inflight[3].mtx.ID)
+	assert.Equal(t, txA[2].ID, m.inflight[4].mtx.ID)
+
+	// A new signer gets the next free slot, ahead of the queued transactions of the first signer
+	txC := newTestTxn(t, m, "0xccccc", 3000, apitypes.TxStatusPending)
+	txA[0].Status = apitypes.TxStatusSucceeded
+	err := m.persistence.WriteTransaction(m.ctx, txA[0], false)
+	assert.NoError(t, err)
+	m.inflight[0].remove = true
+	ok = m.updateInflightSet(m.ctx)
+	assert.True(t, ok)
+	assert.Len(t, m.inflight, 5)
+	assert.Equal(t, txC.ID, m.inflight[4].mtx.ID)
+
+}
+
+func TestInflightSetPerSignerLimits(t *testing.T) {
+
+	_, m, close := newTestManager(t)
+	defer close()
+	m.maxInFlight = 10
+	m.maxInFlightPerSigner = 2
+	m.maxInFlightSignerOverrides["0xbbbbb"] = 3
+
+	for i := 0; i < 5; i++ {
+		_ = newTestTxn(t, m, "0xaaaaa", int64(1000+i), apitypes.TxStatusPending)
+		_ = newTestTxn(t, m, "0xBBBBB", int64(2000+i), apitypes.TxStatusPending)
+	}
+
+	ok := m.updateInflightSet(m.ctx)
+	assert.True(t, ok)
+	assert.Len(t, m.inflight, 5)
+	signerCounts := make(map[string]int)
+	for _, p := range m.inflight {
+		signerCounts[p.mtx.TransactionHeaders.From]++
+	}
+	assert.Equal(t, 2, signerCounts["0xaaaaa"])
+	assert.Equal(t, 3, signerCounts["0xBBBBB"])
+
+	// Nothing more is added while the signers are at their limits
+	ok = m.updateInflightSet(m.ctx)
+	assert.True(t, ok)
+	assert.Len(t, m.inflight, 5)
+
+}
+
+func TestInflightSetSignerCursors(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	defer close()
+	m.maxInFlight = 10
+
+	a1 := genTestTxn("0xaaaaa", 5, apitypes.TxStatusPending)
+	afterNonce := func(n int64) interface{} {
+		return mock.MatchedBy(func(after *fftypes.FFBigInt) bool { return after != nil && after.Int64() == n })
+	}
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("ListTransactionsPending", m.ctx, (*fftypes.UUID)(nil), 10, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{a1}, nil).Once()
+	mp.On("ListTransactionsByNonce", m.ctx, "0xaaaaa", afterNonce(4), 10, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{a1}, nil).Once()
+	ok := m.updateInflightSet(m.ctx)
+	assert.True(t, ok)
+	assert.Len(t, m.inflight, 1)
+	assert.Equal(t, int64(5), m.signerCursors["0xaaaaa"].Int64())
+
+	// Only new pending transactions are scanned, and the signer is read from its cursor
+	mp.On("ListTransactionsPending", m.ctx, a1.SequenceID, 10, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{}, nil)
+	mp.On("ListTransactionsByNonce", m.ctx, "0xaaaaa", afterNonce(5), 9, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{}, nil).Once()
+	ok = m.updateInflightSet(m.ctx)
+	assert.True(t, ok)
+	assert.Len(t, m.inflight, 1)
+	assert.Empty(t, m.activeSigners)
+
+	// Once inactive, the signer is not read again until new transactions are found for it
+	ok = m.updateInflightSet(m.ctx)
+	assert.True(t, ok)
+
+	mp.AssertExpectations(t)
+
+}
+
+func TestInflightSetNonceOrderAfterGapFill(t *testing.T) {
+
+	_, m, close := newTestManager(t)
+	defer close()
+	m.maxInFlight = 5
+
+	// The gap fill at the lower nonce is later in the pending sequence
+	tx6 := newTestTxn(t, m, "0xaaaaa", 1006, apitypes.TxStatusPending)
+	tx5 := newTestTxn(t, m, "0xaaaaa", 1005, apitypes.TxStatusPending)
+
+	ok := m.updateInflightSet(m.ctx)
+	assert.True(t, ok)
+	assert.Len(t, m.inflight, 2)
+	assert.Equal(t, tx5.ID, m.inflight[0].mtx.ID)
+	assert.Equal(t, tx6.ID, m.inflight[1].mtx.ID)
+
+}
+
+func TestInflightSetCursorSkipsCompleted(t *testing.T) {
+
+	_, m, close := newTestManager(t)
+	defer close()
+	m.maxInFlight = 5
+
+	tx5 := newTestTxn(t, m, "0xaaaaa", 1005, apitypes.TxStatusPending)
+	tx6 := newTestTxn(t, m, "0xaaaaa", 1006, apitypes.TxStatusPending)
+
+	// Scan the pending transactions, then complete the first one before it goes in-flight
+	ok := m.scanPendingSigners(m.ctx, map[string]bool{})
+	assert.True(t, ok)
+	tx5.Status = apitypes.TxStatusFailed
+	err := m.persistence.WriteTransaction(m.ctx, tx5, false)
+	assert.NoError(t, err)
+
+	ok = m.updateInflightSet(m.ctx)
+	assert.True(t, ok)
+	assert.Len(t, m.inflight, 1)
+	assert.Equal(t, tx6.ID, m.inflight[0].mtx.ID)
+	assert.Equal(t, int64(1006), m.signerCursors["0xaaaaa"].Int64())
+
+}
+
+func TestInflightSetRescanFindsLateWrite(t *testing.T) {
+
+	_, m, close := newTestManager(t)
+	defer close()
+	m.maxInFlight = 5
+
+	// The sequence ID of the transaction of signer A is allocated first, but signer B's is written first
+	txA := genTestTxn("0xaaaaa", 1000, apitypes.TxStatusPending)
+	txB1 := newTestTxn(t, m, "0xbbbbb", 1000, apitypes.TxStatusPending)
+	newTestTxn(t, m, "0xbbbbb", 1001, apitypes.TxStatusPending)
+	ok := m.updateInflightSet(m.ctx)
+	assert.True(t, ok)
+	assert.Len(t, m.inflight, 2)
+
+	err := m.persistence.WriteTransaction(m.ctx, txA, true)
+	assert.NoError(t, err)
+
+	// An incremental scan starts after signer B's transactions, so does not find it
+	txB1.Status = apitypes.TxStatusSucceeded
+	err = m.persistence.WriteTransaction(m.ctx, txB1, false)
+	assert.NoError(t, err)
+	m.inflight[0].remove = true
+	ok = m.updateInflightSet(m.ctx)
+	assert.True(t, ok)
+	assert.Len(t, m.inflight, 1)
+
+	// It is found by the periodic full scan
+	m.pendingScanCount = pendingFullScanInterval - 1
+	ok = m.updateInflightSet(m.ctx)
+	assert.True(t, ok)
+	assert.Len(t, m.inflight, 2)
+	assert.Equal(t, txA.ID, m.inflight[1].mtx.ID)
+
+}
+
+func TestInflightSetListByNonceFailCancel(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	close()
+
+	m.activeSigners = []string{"0xaaaaa"}
+	m.signerCursors["0xaaaaa"] = fftypes.NewFFBigInt(1000)
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("ListTransactionsPending", m.ctx, (*fftypes.UUID)(nil), m.maxInFlight, persistence.SortDirectionAscending).
+		Return([]*apitypes.ManagedTX{}, nil)
+	mp.On("ListTransactionsByNonce", m.ctx, "0xaaaaa", m.signerCursors["0xaaaaa"], m.maxInFlight, persistence.SortDirectionAscending).
+		Return(nil, fmt.Errorf("pop"))
+
+	ok := m.updateInflightSet(m.ctx)
+	assert.False(t, ok)
+
+	mp.AssertExpectations(t)
+
+}
+
+func TestSelectRoundRobin(t *testing.T) {
+
+	a1, a2, a3 := genTestTxn("0xaaaaa", 1, apitypes.TxStatusPending), genTestTxn("0xaaaaa", 2, apitypes.TxStatusPending), genTestTxn("0xaaaaa", 3, apitypes.TxStatusPending)
+	b1 := genTestTxn("0xbbbbb", 1, apitypes.TxStatusPending)
+
+	assert.Equal(t, []*apitypes.ManagedTX{a1, b1, a2, a3}, selectRoundRobin([]*signerCandidates{
+		{txs: []*apitypes.ManagedTX{a1, a2, a3}},
+		{txs: []*apitypes.ManagedTX{b1}},
+	}, 10))
+	assert.Equal(t, []*apitypes.ManagedTX{a1, b1}, selectRoundRobin([]*signerCandidates{
+		{txs: []*apitypes.ManagedTX{a1, a2, a3}},
+		{txs: []*apitypes.ManagedTX{b1}},
+	}, 2))
+	assert.Equal(t, []*apitypes.ManagedTX{b1, a1}, selectRoundRobin([]*signerCandidates{
+		{inflight: 2, txs: []*apitypes.ManagedTX{a1, a2, a3}},
+		{txs: []*apitypes.ManagedTX{b1}},
+	}, 2))
+	assert.Empty(t, selectRoundRobin(nil, 10))
+
+}
+
+func TestPolicyLoopUpdateFail(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	defer close()
+
+	m.inflight = []*pendingState{
+		{
+			confirmed: true,
+			mtx: &apitypes.ManagedTX{
+				ID:          fmt.Sprintf("ns1/%s", fftypes.NewUUID()),
+				Created:     fftypes.Now(),
+				SequenceID:  apitypes.NewULID(),
+				Nonce:       fftypes.NewFFBigInt(1000),
+				Status:      apitypes.TxStatusSucceeded,
+				FirstSubmit: fftypes.Now(),
+				Receipt:     &ffcapi.TransactionReceiptResponse{},
+				TransactionHeaders: ffcapi.TransactionHeaders{
+					From: "0x12345",
+				},
+			},
+		},
+	}
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("WriteTransaction", m.ctx, mock.Anything, false).Return(fmt.Errorf("pop"))
+	mp.On("Close", mock.Anything).Return(nil).Maybe()
+
+	m.policyLoopCycle(m.ctx, false)
+
+	mp.AssertExpectations(t)
+
+}
+
+func TestPolicyEngineFailStaleThenUpdated(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.policyLoopInterval = 1 * time.Hour
+
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	done1 := make(chan struct{})
+	mpe.On("Execute", mock.Anything, mock.Anything, mock.Anything).
+		Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), fmt.Errorf("pop")).
+		Once().
+		Run(func(args mock.Arguments) {
+			close(done1)
+			m.markInflightUpdate()
+		})
+
+	done2 := make(chan struct{})
+	mpe.On("Execute", mock.Anything, mock.Anything, mock.Anything).
+		Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), fmt.Errorf("pop")).
+		Once().
+		Run(func(args mock.Arguments) {
+			close(done2)
+		})
+
+	_ = sendSampleTX(t, m, "0xaaaaa", 12345)
+
+	m.Start()
+
+	<-done1
+
+	<-done2
+
+	mpe.AssertExpectations(t)
+
+}
+
+func TestShardInflightBySigner(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	for i := 0; i < 3; i++ {
+		for _, signer := range []string{"0xaaaaa", "0xbbbbb", "0xccccc", "0xddddd"} {
+			m.inflight = append(m.inflight, &pendingState{mtx: genTestTxn(signer, int64(1000+i), apitypes.TxStatusPending)})
+		}
+	}
+
+	m.policyLoopWorkers = 0
+	shards := m.shardInflightBySigner()
+	assert.Len(t, shards, 1)
+	assert.Equal(t, m.inflight, shards[0])
+
+	m.policyLoopWorkers = 3
+	shards = m.shardInflightBySigner()
+	assert.Len(t, shards, 3)
+	shardForSigner := make(map[string]int)
+	total := 0
+	for i, shard := range shards {
+		lastNonce := make(map[string]int64)
+		for _, pending := range shard {
+			signer := pending.mtx.TransactionHeaders.From
+			if s, ok := shardForSigner[signer]; ok {
+				assert.Equal(t, s, i)
+			}
+			shardForSigner[signer] = i
+			assert.Greater(t, pending.mtx.Nonce.Int64(), lastNonce[signer])
+			lastNonce[signer] = pending.mtx.Nonce.Int64()
+			total++
+		}
+	}
+	assert.Equal(t, 12, total)
+
+}
+
+func TestPolicyLoopCycleParallelWorkers(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	signers := []string{"0xaaaaa", "0xbbbbb", "0xccccc", "0xddddd", "0xeeeee"}
+	for _, signer := range signers {
+		m.inflight = append(m.inflight, &pendingState{mtx: genTestTxn(signer, 1000, apitypes.TxStatusPending)})
+	}
+	m.policyLoopWorkers = 4
+
+	var mux sync.Mutex
+	executed := make(map[string]bool)
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	mpe.On("Execute", mock.Anything, mock.Anything, mock.Anything).Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), nil).Run(func(args mock.Arguments) {
+		mux.Lock()
+		defer mux.Unlock()
+		executed[args[2].(*apitypes.ManagedTX).TransactionHeaders.From] = true
+	})
+
+	m.policyLoopCycle(m.ctx, false)
+
+	for _, signer := range signers {
+		assert.True(t, executed[signer])
+	}
+	mpe.AssertNumberOfCalls(t, "Execute", len(signers))
+
+}
+
+func TestMarkInflightStaleDoesNotBlock(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	m.markInflightStale()
+	m.markInflightStale()
+
+}
+
+func TestMarkInflightUpdateDoesNotBlock(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	m.markInflightUpdate()
+	m.markInflightUpdate()
+
+}
+
+func TestExecPolicyDeleteFail(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	mpe.On("Execute", mock.Anything, mock.Anything, mock.Anything).Return(policyengine.UpdateDelete, ffcapi.ErrorReason(""), nil).Maybe()
+
+	tx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("GetTransactionByID", m.ctx, tx.ID).Return(tx, nil)
+	mp.On("DeleteTransaction", m.ctx, tx.ID).Return(fmt.Errorf("pop"))
+
+	req := &policyEngineAPIRequest{
+		requestType: policyEngineAPIRequestTypeDelete,
+		txID:        tx.ID,
+		response:    make(chan policyEngineAPIResponse, 1),
+	}
+	m.policyEngineAPIRequests = append(m.policyEngineAPIRequests, req)
+
+	m.processPolicyAPIRequests(m.ctx)
+
+	res := <-req.response
+	assert.Regexp(t, "pop", res.err)
+
+	mp.AssertExpectations(t)
+
+}
+
+func TestExecPolicyDeleteInflightSync(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	mpe.On("Execute", mock.Anything, mock.Anything, mock.Anything).Return(policyengine.UpdateDelete, ffcapi.ErrorReason(""), nil).Maybe()
+
+	tx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	m.inflight = []*pendingState{{mtx: tx}}
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("DeleteTransaction", m.ctx, tx.ID).Return(nil)
+
+	req := &policyEngineAPIRequest{
+		requestType: policyEngineAPIRequestTypeDelete,
+		txID:        tx.ID,
+		response:    make(chan policyEngineAPIResponse, 1),
+	}
+	m.policyEngineAPIRequests = append(m.policyEngineAPIRequests, req)
+
+	m.processPolicyAPIRequests(m.ctx)
+
+	res := <-req.response
+	assert.NoError(t, res.err)
+	assert.Equal(t, http.StatusOK, res.status)
+	assert.True(t, m.inflight[0].remove)
+
+	mp.AssertExpectations(t)
+
+}
+
+func TestExecPolicyDeleteNotFound(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("GetTransactionByID", m.ctx, "bad-id").Return(nil, nil)
+
+	req := &policyEngineAPIRequest{
+		requestType: policyEngineAPIRequestTypeDelete,
+		txID:        "bad-id",
+		response:    make(chan policyEngineAPIResponse, 1),
+	}
+	m.policyEngineAPIRequests = append(m.policyEngineAPIRequests, req)
+
+	m.processPolicyAPIRequests(m.ctx)
+
+	res := <-req.response
+	assert.Regexp(t, "FF21067", res.err)
+
+	mp.AssertExpectations(t)
+
+}
+
+func TestBadPolicyAPIRequest(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	tx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("GetTransactionByID", m.ctx, tx.ID).Return(tx, nil)
+
+	req := &policyEngineAPIRequest{
+		requestType: policyEngineAPIRequestType(999),
+		txID:        tx.ID,
+		response:    make(chan policyEngineAPIResponse, 1),
+	}
+	m.policyEngineAPIRequests = append(m.policyEngineAPIRequests, req)
+
+	m.processPolicyAPIRequests(m.ctx)
+
+	res := <-req.response
+	assert.Regexp(t, "FF21069", res.err)
+
+	mp.AssertExpectations(t)
+
+}
+
+func TestBadPolicyAPITimeout(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	cancelCtx()
 
 	res := m.policyEngineAPIRequest(ctx, &policyEngineAPIRequest{})
 	assert.Regexp(t, "FF21068", res.err)
 
 }
+
+func genTestSubmittedTxn() *apitypes.ManagedTX {
+	tx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	tx.GasPrice = fftypes.JSONAnyPtr(`{"maxFeePerGas":"1000","maxPriorityFeePerGas":100}`)
+	tx.TransactionHash = "0x12345"
+	tx.FirstSubmit = fftypes.Now()
+	return tx
+}
+
+func runSpeedUpRequest(m *manager, txID string, speedUp *apitypes.SpeedUpRequest) policyEngineAPIResponse {
+	req := &policyEngineAPIRequest{
+		requestType: policyEngineAPIRequestTypeSpeedUp,
+		txID:        txID,
+		speedUp:     speedUp,
+		response:    make(chan policyEngineAPIResponse, 1),
+	}
+	m.policyEngineAPIRequests = append(m.policyEngineAPIRequests, req)
+	m.processPolicyAPIRequests(m.ctx)
+	return <-req.response
+}
+
+func TestExecSpeedUpInflightGasPrice(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	tx := genTestSubmittedTxn()
+	m.inflight = []*pendingState{{mtx: tx, trackedHashes: map[string]bool{tx.TransactionHash: true}}}
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.String() == `{"maxFeePerGas":"2000","maxPriorityFeePerGas":200}` &&
+			req.Nonce.Int64() == 12345
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x67890",
+	}, ffcapi.ErrorReason(""), nil)
+
+	mc := &confirmationsmocks.Manager{}
+	m.confirmations = mc
+	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
+		return n.NotificationType == confirmations.NewTransaction &&
+			n.Transaction.TransactionHash == "0x67890"
+	})).Return(nil)
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("WriteTransaction", m.ctx, tx, false).Return(nil)
+
+	res := runSpeedUpRequest(m, tx.ID, &apitypes.SpeedUpRequest{
+		GasPrice: fftypes.JSONAnyPtr(`{"maxFeePerGas":"2000","maxPriorityFeePerGas":200}`),
+	})
+	assert.NoError(t, res.err)
+	assert.Equal(t, http.StatusOK, res.status)
+	assert.Equal(t, "0x67890", res.tx.TransactionHash)
+	assert.True(t, m.inflight[0].trackedHashes["0x67890"])
+	assert.True(t, m.inflight[0].trackedHashes["0x12345"])
+	assert.Len(t, res.tx.SubmissionHistory, 1)
+	assert.NotNil(t, res.tx.LastSubmit)
+
+	mfc.AssertExpectations(t)
+	mc.AssertExpectations(t)
+	mp.AssertExpectations(t)
+
+}
+
+func TestExecSpeedUpNotInFlight(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	tx := genTestSubmittedTxn()
+	tx.DeleteRequested = fftypes.Now()
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("GetTransactionByID", m.ctx, tx.ID).Return(tx, nil)
+
+	multiplier := 1.5
+	res := runSpeedUpRequest(m, tx.ID, &apitypes.SpeedUpRequest{
+		GasPriceMultiplier: &multiplier,
+	})
+	assert.Regexp(t, "FF21074", res.err)
+
+	mp.AssertExpectations(t)
+
+}
+
+func TestExecSpeedUpOutsideInflightSet(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	// The hash of the resubmission could not be tracked for a transaction outside of the in-flight set
+	tx := genTestSubmittedTxn()
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("GetTransactionByID", m.ctx, tx.ID).Return(tx, nil)
+
+	multiplier := 1.5
+	res := runSpeedUpRequest(m, tx.ID, &apitypes.SpeedUpRequest{
+		GasPriceMultiplier: &multiplier,
+	})
+	assert.Regexp(t, "FF21090", res.err)
+	assert.Equal(t, `{"maxFeePerGas":"1000","maxPriorityFeePerGas":100}`, tx.GasPrice.String())
+
+	mp.AssertExpectations(t)
+
+}
+
+func TestExecSpeedUpGasPriceNotIncreased(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	tx := genTestSubmittedTxn()
+	m.inflight = []*pendingState{{mtx: tx}}
+
+	res := runSpeedUpRequest(m, tx.ID, &apitypes.SpeedUpRequest{
+		GasPrice: fftypes.JSONAnyPtr(`{"maxFeePerGas":"2000","maxPriorityFeePerGas":100}`),
+	})
+	assert.Regexp(t, "FF21089", res.err)
+
+	tx.GasPrice = fftypes.JSONAnyPtr(`"fast"`)
+	res = runSpeedUpRequest(m, tx.ID, &apitypes.SpeedUpRequest{
+		GasPrice: fftypes.JSONAnyPtr(`2000`),
+	})
+	assert.Regexp(t, "FF21070", res.err)
+
+}
+
+func TestExecSpeedUpMissingGasPrice(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	tx := genTestSubmittedTxn()
+	m.inflight = []*pendingState{{mtx: tx}}
+
+	res := runSpeedUpRequest(m, tx.ID, &apitypes.SpeedUpRequest{})
+	assert.Regexp(t, "FF21072", res.err)
+
+}
+
+func TestExecSpeedUpBadMultiplier(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	tx := genTestSubmittedTxn()
+	m.inflight = []*pendingState{{mtx: tx}}
+
+	multiplier := 0.9
+	res := runSpeedUpRequest(m, tx.ID, &apitypes.SpeedUpRequest{
+		GasPriceMultiplier: &multiplier,
+	})
+	assert.Regexp(t, "FF21073.*0.9", res.err)
+
+}
+
+func TestExecSpeedUpGasPriceNotMultipliable(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	tx := genTestSubmittedTxn()
+	tx.GasPrice = fftypes.JSONAnyPtr(`"fast"`)
+	m.inflight = []*pendingState{{mtx: tx}}
+
+	multiplier := 2.0
+	res := runSpeedUpRequest(m, tx.ID, &apitypes.SpeedUpRequest{
+		GasPriceMultiplier: &multiplier,
+	})
+	assert.Regexp(t, "FF21070", res.err)
+
+}
+
+func TestExecSpeedUpSendFail(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	tx := genTestSubmittedTxn()
+	m.inflight = []*pendingState{{mtx: tx}}
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("TransactionSend", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReasonTransactionUnderpriced, fmt.Errorf("pop"))
+
+	multiplier := 2.0
+	res := runSpeedUpRequest(m, tx.ID, &apitypes.SpeedUpRequest{
+		GasPriceMultiplier: &multiplier,
+	})
+	assert.Regexp(t, "pop", res.err)
+	assert.Equal(t, "0x12345", tx.TransactionHash)
+	assert.Equal(t, `{"maxFeePerGas":"1000","maxPriorityFeePerGas":100}`, tx.GasPrice.String())
+
+	mfc.AssertExpectations(t)
+
+}
+
+func TestExecSpeedUpPersistFail(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	tx := genTestSubmittedTxn()
+	m.inflight = []*pendingState{{mtx: tx}}
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("TransactionSend", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x67890",
+	}, ffcapi.ErrorReason(""), nil)
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("WriteTransaction", m.ctx, tx, false).Return(fmt.Errorf("pop"))
+
+	multiplier := 2.0
+	res := runSpeedUpRequest(m, tx.ID, &apitypes.SpeedUpRequest{
+		GasPriceMultiplier: &multiplier,
+	})
+	assert.Regexp(t, "pop", res.err)
+
+	mfc.AssertExpectations(t)
+	mp.AssertExpectations(t)
+
+}
+
+func TestExecSpeedUpNotSubmittedByPolicyEngine(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	noopPolicyEngine(m)
+
+	tx := genTestSubmittedTxn()
+	tx.LastSubmit = tx.FirstSubmit
+	m.inflight = []*pendingState{{mtx: tx}}
+
+	multiplier := 2.0
+	res := runSpeedUpRequest(m, tx.ID, &apitypes.SpeedUpRequest{
+		GasPriceMultiplier: &multiplier,
+	})
+	assert.Regexp(t, "FF21088", res.err)
+	assert.Nil(t, tx.SpeedUpGasPrice)
+
+}
+
+func TestExecPolicySubStatusTransitions(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	m.policyLoopInterval = 0
+
+	tx := genTestTxn("0xabcd1234", 12345, apitypes.TxStatusPending)
+	setSubStatus(tx, apitypes.TxSubStatusQueued)
+	pending := &pendingState{mtx: tx}
+
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	mpe.On("Execute", mock.Anything, mock.Anything, tx).Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), nil).Run(func(args mock.Arguments) {
+		tx.TransactionHash = "0x12345"
+	})
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("WriteTransaction", m.ctx, tx, false).Return(nil)
+
+	// Submission is persisted, even though the policy engine did not request an update
+	err := m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.TxSubStatusSubmitted, tx.SubStatus)
+	mp.AssertNumberOfCalls(t, "WriteTransaction", 1)
+
+	// No transition, so no update
+	err = m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	mp.AssertNumberOfCalls(t, "WriteTransaction", 1)
+
+	// Receipt
+	tx.Receipt = &ffcapi.TransactionReceiptResponse{Success: true}
+	err = m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.TxSubStatusMined, tx.SubStatus)
+	mp.AssertNumberOfCalls(t, "WriteTransaction", 2)
+
+	// Confirmed
+	pending.confirmed = true
+	err = m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.TxSubStatusConfirmed, tx.SubStatus)
+	assert.Equal(t, apitypes.TxStatusSucceeded, tx.Status)
+
+	subStatuses := make([]apitypes.TxSubStatus, len(tx.SubStatusHistory))
+	for i, entry := range tx.SubStatusHistory {
+		assert.NotNil(t, entry.Time)
+		subStatuses[i] = entry.SubStatus
+	}
+	assert.Equal(t, []apitypes.TxSubStatus{
+		apitypes.TxSubStatusQueued,
+		apitypes.TxSubStatusSubmitted,
+		apitypes.TxSubStatusMined,
+		apitypes.TxSubStatusConfirmed,
+	}, subStatuses)
+
+}
+
+func TestExecPolicyConfirmationProgressThrottled(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	m.policyLoopInterval = 1 * time.Hour
+	m.progressPersistInterval = 1 * time.Hour
+
+	tx := genTestSubmittedTxn()
+	tx.Receipt = &ffcapi.TransactionReceiptResponse{Success: true}
+	setSubStatus(tx, apitypes.TxSubStatusMined)
+	pending := &pendingState{mtx: tx, lastPolicyCycle: time.Now()}
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("WriteTransaction", m.ctx, tx, false).Return(nil).Once()
+
+	// The first progress update is persisted
+	tx.ConfirmationProgress = &apitypes.ConfirmationProgress{Count: 1, Required: 20}
+	pending.progressUpdated = true
+	err := m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.False(t, pending.progressUpdated)
+
+	// The next is throttled
+	tx.ConfirmationProgress = &apitypes.ConfirmationProgress{Count: 2, Required: 20}
+	pending.progressUpdated = true
+	err = m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+
+	mp.AssertExpectations(t)
+
+}
@@ -0,0 +1,70 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+)
+
+// reorgNotificationBufferSize is the number of reorgs that can be waiting to be published on the admin topic,
+// before we start dropping them (they remain available in the history)
+const reorgNotificationBufferSize = 50
+
+// ReorgDetected is called by the confirmation managers for receipts and for each event stream, with each reorg
+// they detect under their pending items. The reorg is kept in the history, and published on the admin topic.
+func (m *manager) ReorgDetected(reorg *apitypes.Reorg) {
+	m.reorgHistory.ReorgDetected(reorg)
+	m.publishReorg(reorg)
+}
+
+func (m *manager) publishReorg(reorg *apitypes.Reorg) {
+	if m.adminTopic == "" {
+		return
+	}
+	// Notify on the websocket - this is best-effort, as only the clients listening on the topic at the time receive it.
+	// We do not block the confirmation manager on the websocket server, but we do keep the reorgs in the order
+	// they were detected, by handing them to a single dispatcher.
+	select {
+	case m.reorgNotifications <- reorg:
+	default:
+		log.L(m.ctx).Warnf("Reorg notification buffer full - not publishing reorg %s at block %d", reorg.ID, reorg.BlockNumber)
+	}
+}
+
+func (m *manager) reorgDispatcher() {
+	defer close(m.reorgDispatcherDone)
+	for {
+		select {
+		case reorg := <-m.reorgNotifications:
+			_, broadcastChannel, _ := m.wsServer.GetChannels(m.adminTopic)
+			select {
+			case broadcastChannel <- &apitypes.ReorgNotification{
+				Headers: apitypes.ReplyHeaders{
+					RequestID: reorg.ID.String(),
+					Type:      apitypes.ReorgDetected,
+				},
+				Reorg: *reorg,
+			}:
+			case <-m.ctx.Done():
+				return
+			}
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/internal/ws"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/stretchr/testify/assert"
+)
+
+type testBroadcastServer struct {
+	ws.WebSocketServer
+	broadcast chan interface{}
+}
+
+func (s *testBroadcastServer) GetChannels(topic string) (chan<- interface{}, chan<- interface{}, <-chan error) {
+	return nil, s.broadcast, nil
+}
+
+func newTestReorg() *apitypes.Reorg {
+	return &apitypes.Reorg{
+		ID:           fftypes.NewUUID(),
+		Detected:     fftypes.Now(),
+		Manager:      "receipts",
+		BlockNumber:  1002,
+		Depth:        2,
+		OldHashes:    []string{"0x1002a", "0x1003a"},
+		NewHashes:    []string{"0x1002b"},
+		Transactions: []string{"0x1dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347"},
+	}
+}
+
+func TestReorgDetectedPublishedOnAdminTopic(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	c, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(url, "http")+"/ws", nil)
+	assert.NoError(t, err)
+	defer c.Close()
+	err = c.WriteJSON(map[string]string{"type": "listen", "topic": "fftm_admin"})
+	assert.NoError(t, err)
+
+	received := make(chan *apitypes.ReorgNotification)
+	go func() {
+		var notification apitypes.ReorgNotification
+		if err := c.ReadJSON(&notification); err == nil {
+			received <- &notification
+		}
+	}()
+
+	reorg := newTestReorg()
+	m.ReorgDetected(reorg)
+	var notification *apitypes.ReorgNotification
+	for notification == nil {
+		select {
+		case notification = <-received:
+		case <-time.After(10 * time.Millisecond):
+			// The listen request might not have been processed when we published
+			m.publishReorg(reorg)
+		}
+	}
+	assert.Equal(t, apitypes.ReorgDetected, notification.Headers.Type)
+	assert.Equal(t, reorg.ID.String(), notification.Headers.RequestID)
+	assert.Equal(t, reorg.OldHashes, notification.OldHashes)
+	assert.Equal(t, reorg.NewHashes, notification.NewHashes)
+	assert.Equal(t, reorg.Transactions, notification.Transactions)
+
+	assert.Len(t, m.reorgHistory.List(), 1)
+	assert.Equal(t, uint64(1), m.reorgHistory.Metrics().Total)
+
+}
+
+func TestReorgDetectedAdminTopicDisabled(t *testing.T) {
+
+	_, m, done := newTestManager(t)
+	defer done()
+
+	m.adminTopic = ""
+	m.wsServer = nil // would panic if used
+
+	m.ReorgDetected(newTestReorg())
+	assert.Equal(t, 2, m.reorgHistory.Metrics().MaxDepth)
+
+}
+
+func TestReorgNotificationsPublishedInOrder(t *testing.T) {
+
+	_, m, done := newTestManager(t)
+	defer done()
+
+	broadcast := make(chan interface{})
+	m.wsServer = &testBroadcastServer{broadcast: broadcast}
+
+	for i := fftypes.FFuint64(0); i < 3; i++ {
+		reorg := newTestReorg()
+		reorg.BlockNumber = 1000 + i
+		m.ReorgDetected(reorg)
+	}
+
+	m.reorgDispatcherDone = make(chan struct{})
+	go m.reorgDispatcher()
+	for i := fftypes.FFuint64(0); i < 3; i++ {
+		notification := (<-broadcast).(*apitypes.ReorgNotification)
+		assert.Equal(t, 1000+i, notification.BlockNumber)
+	}
+
+	m.cancelCtx()
+	<-m.reorgDispatcherDone
+
+}
+
+func TestReorgNotificationBufferFull(t *testing.T) {
+
+	_, m, done := newTestManager(t)
+	defer done()
+
+	m.reorgNotifications = make(chan *apitypes.Reorg, 1)
+	m.ReorgDetected(newTestReorg())
+	m.ReorgDetected(newTestReorg())
+	assert.Len(t, m.reorgNotifications, 1)
+	assert.Equal(t, uint64(2), m.reorgHistory.Metrics().Total)
+
+}
+
+func TestReorgDispatcherClosedWhileBroadcasting(t *testing.T) {
+
+	_, m, done := newTestManager(t)
+	defer done()
+
+	m.wsServer = &testBroadcastServer{broadcast: make(chan interface{})}
+	m.ReorgDetected(newTestReorg())
+
+	m.reorgDispatcherDone = make(chan struct{})
+	go m.reorgDispatcher()
+	for len(m.reorgNotifications) > 0 {
+		time.Sleep(1 * time.Millisecond)
+	}
+	m.cancelCtx()
+	<-m.reorgDispatcherDone
+
+}
@@ -0,0 +1,50 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// revertReason replays a failed transaction as a query at the block it was mined in, so the connector can decode
+// the revert reason. This is only possible when we have the original input of a contract invocation.
+// An empty string is returned if the reason cannot be determined, for example because the replay succeeds
+// when the transaction did not (it might have failed for a reason such as running out of gas).
+func (m *manager) revertReason(ctx context.Context, mtx *apitypes.ManagedTX) string {
+	if mtx.TransactionInput == nil || mtx.Receipt == nil || mtx.Receipt.BlockNumber == nil {
+		return ""
+	}
+	txInput := *mtx.TransactionInput
+	_, reason, err := m.connector.QueryInvoke(ctx, &ffcapi.QueryInvokeRequest{
+		TransactionInput: txInput,
+		BlockNumber:      mtx.Receipt.BlockNumber,
+	})
+	switch {
+	case err == nil:
+		log.L(ctx).Warnf("Replay of failed transaction %s at block %s did not revert", mtx.ID, mtx.Receipt.BlockNumber)
+		return ""
+	case reason != ffcapi.ErrorReasonTransactionReverted:
+		log.L(ctx).Errorf("Failed to replay transaction %s at block %s to find the revert reason: %s", mtx.ID, mtx.Receipt.BlockNumber, err)
+		return ""
+	default:
+		return err.Error()
+	}
+}
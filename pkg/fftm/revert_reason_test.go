@@ -0,0 +1,109 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/persistencemocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func genTestFailedTxn() *apitypes.ManagedTX {
+	tx := genTestSubmittedTxn()
+	tx.TransactionInput = &ffcapi.TransactionInput{
+		TransactionHeaders: tx.TransactionHeaders,
+		Method:             fftypes.JSONAnyPtr(`{"name":"set"}`),
+		Params:             []*fftypes.JSONAny{fftypes.JSONAnyPtr(`1`)},
+	}
+	tx.Receipt = &ffcapi.TransactionReceiptResponse{
+		BlockNumber: fftypes.NewFFBigInt(100),
+		Success:     false,
+	}
+	return tx
+}
+
+func TestExecPolicyConfirmedFailureRevertReason(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	tx := genTestFailedTxn()
+	pending := &pendingState{mtx: tx, confirmed: true}
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("QueryInvoke", m.ctx, &ffcapi.QueryInvokeRequest{
+		TransactionInput: *tx.TransactionInput,
+		BlockNumber:      tx.Receipt.BlockNumber,
+	}).Return(nil, ffcapi.ErrorReasonTransactionReverted, fmt.Errorf("execution reverted: value too low"))
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("WriteTransaction", m.ctx, tx, false).Return(nil)
+
+	err := m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.True(t, pending.remove)
+	assert.Equal(t, apitypes.TxStatusFailed, tx.Status)
+	assert.Regexp(t, "FF21085.*value too low", tx.ErrorMessage)
+	assert.Equal(t, ffcapi.ErrorReasonTransactionReverted, tx.ErrorHistory[0].Mapped)
+	assert.Equal(t, tx.ErrorMessage, tx.ErrorHistory[0].Error)
+
+	mfc.AssertExpectations(t)
+	mp.AssertExpectations(t)
+
+}
+
+func TestRevertReasonReplaySucceeds(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("QueryInvoke", m.ctx, mock.Anything).Return(&ffcapi.QueryInvokeResponse{}, ffcapi.ErrorReason(""), nil)
+
+	assert.Empty(t, m.revertReason(m.ctx, genTestFailedTxn()))
+
+}
+
+func TestRevertReasonReplayFail(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("QueryInvoke", m.ctx, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+
+	assert.Empty(t, m.revertReason(m.ctx, genTestFailedTxn()))
+
+}
+
+func TestRevertReasonNoInput(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	tx := genTestFailedTxn()
+	tx.TransactionInput = nil
+	assert.Empty(t, m.revertReason(m.ctx, tx))
+
+}
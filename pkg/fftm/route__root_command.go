@@ -43,6 +43,10 @@ var postRootCommand = func(m *manager) *ffapi.Route {
 			if err == nil {
 				schemas = append(schemas, txRequest)
 			}
+			batchRequest, err := schemaGen(&apitypes.TransactionBatchRequest{})
+			if err == nil {
+				schemas = append(schemas, batchRequest)
+			}
 			deployRequest, err := schemaGen(&apitypes.ContractDeployRequest{})
 			if err == nil {
 				schemas = append(schemas, deployRequest)
@@ -70,7 +74,7 @@ var postRootCommand = func(m *manager) *ffapi.Route {
 				},
 			}, nil
 		},
-		JSONOutputCodes: []int{http.StatusAccepted},
+		JSONOutputCodes: []int{http.StatusAccepted, http.StatusOK},
 		JSONHandler: func(r *ffapi.APIRequest) (output interface{}, err error) {
 			baseReq := r.Input.(*apitypes.BaseRequest)
 			switch baseReq.Headers.Type {
@@ -79,13 +83,21 @@ var postRootCommand = func(m *manager) *ffapi.Route {
 				if err = baseReq.UnmarshalTo(&tReq); err != nil {
 					return nil, i18n.NewError(r.Req.Context(), tmmsgs.MsgInvalidRequestErr, baseReq.Headers.Type, err)
 				}
-				return m.sendManagedTransaction(r.Req.Context(), &tReq)
+				r.SuccessStatus, output, err = m.sendManagedTransaction(r.Req.Context(), &tReq)
+				return output, err
+			case apitypes.RequestTypeSendTransactionBatch:
+				var tReq apitypes.TransactionBatchRequest
+				if err = baseReq.UnmarshalTo(&tReq); err != nil {
+					return nil, i18n.NewError(r.Req.Context(), tmmsgs.MsgInvalidRequestErr, baseReq.Headers.Type, err)
+				}
+				return m.sendManagedTransactionBatch(r.Req.Context(), &tReq)
 			case apitypes.RequestTypeDeploy:
 				var tReq apitypes.ContractDeployRequest
 				if err = baseReq.UnmarshalTo(&tReq); err != nil {
 					return nil, i18n.NewError(r.Req.Context(), tmmsgs.MsgInvalidRequestErr, baseReq.Headers.Type, err)
 				}
-				return m.sendManagedContractDeployment(r.Req.Context(), &tReq)
+				r.SuccessStatus, output, err = m.sendManagedContractDeployment(r.Req.Context(), &tReq)
+				return output, err
 			case apitypes.RequestTypeQuery:
 				var tReq apitypes.QueryRequest
 				if err = baseReq.UnmarshalTo(&tReq); err != nil {
@@ -0,0 +1,71 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetNonceFindings(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	var findings []*apitypes.NonceFinding
+	res, err := resty.New().R().
+		SetResult(&findings).
+		Get(url + "/nonces/findings")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Empty(t, findings)
+
+	m.mux.Lock()
+	m.nonceFindings = map[string][]*apitypes.NonceFinding{
+		"0xbbbbb": {newNonceFinding("0xbbbbb", apitypes.NonceFindingTypeGap, 2001, 2000, "")},
+		"0xaaaaa": {
+			newNonceFinding("0xaaaaa", apitypes.NonceFindingTypeConsumed, 1000, 1002, "tx1"),
+			newNonceFinding("0xaaaaa", apitypes.NonceFindingTypeGap, 1002, 1002, ""),
+		},
+	}
+	m.mux.Unlock()
+
+	res, err = resty.New().R().
+		SetResult(&findings).
+		Get(url + "/nonces/findings")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Len(t, findings, 3)
+	assert.Equal(t, "0xaaaaa", findings[0].Signer)
+	assert.Equal(t, int64(1000), findings[0].Nonce.Int64())
+	assert.Equal(t, "0xbbbbb", findings[2].Signer)
+
+	res, err = resty.New().R().
+		SetResult(&findings).
+		Get(url + "/nonces/findings?signer=0xbbbbb")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Len(t, findings, 1)
+
+}
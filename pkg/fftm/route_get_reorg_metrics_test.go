@@ -0,0 +1,50 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetReorgMetrics(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	reorg := newTestReorg()
+	m.reorgHistory.ReorgDetected(reorg)
+
+	var metrics apitypes.ReorgMetrics
+	res, err := resty.New().R().
+		SetResult(&metrics).
+		Get(url + "/reorgs/metrics")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Equal(t, uint64(1), metrics.Total)
+	assert.Equal(t, 2, metrics.MaxDepth)
+	assert.Equal(t, map[int]uint64{2: 1}, metrics.ByDepth)
+	assert.Equal(t, map[string]uint64{"receipts": 1}, metrics.ByManager)
+
+}
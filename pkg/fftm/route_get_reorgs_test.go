@@ -0,0 +1,59 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetReorgs(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	var reorgs []*apitypes.Reorg
+	res, err := resty.New().R().
+		SetResult(&reorgs).
+		Get(url + "/reorgs")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Empty(t, reorgs)
+
+	reorg1 := newTestReorg()
+	reorg2 := newTestReorg()
+	m.reorgHistory.ReorgDetected(reorg1)
+	m.reorgHistory.ReorgDetected(reorg2)
+
+	res, err = resty.New().R().
+		SetResult(&reorgs).
+		Get(url + "/reorgs")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Len(t, reorgs, 2)
+	assert.Equal(t, reorg2.ID, reorgs[0].ID)
+	assert.Equal(t, reorg1.ID, reorgs[1].ID)
+	assert.Equal(t, reorg1.OldHashes, reorgs[1].OldHashes)
+
+}
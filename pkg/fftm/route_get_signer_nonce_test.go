@@ -0,0 +1,102 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetSignerNonce(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("NextNonceForSigner", mock.Anything, &ffcapi.NextNonceForSignerRequest{
+		Signer: "0xaaaaa",
+	}).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(10005),
+	}, ffcapi.ErrorReason(""), nil)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	_ = newTestTxn(t, m, "0xaaaaa", 10001, apitypes.TxStatusSucceeded)
+	tx2 := newTestTxn(t, m, "0xaaaaa", 10002, apitypes.TxStatusPending)
+
+	var status apitypes.SignerNonceStatus
+	res, err := resty.New().R().
+		SetResult(&status).
+		Get(url + "/signers/0xaaaaa/nonce")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Equal(t, "0xaaaaa", status.Signer)
+	assert.Equal(t, int64(10003), status.NextNonce.Int64())
+	assert.Equal(t, int64(10005), status.NodeNextNonce.Int64())
+	assert.Equal(t, int64(10002), status.HighestStoredNonce.Int64())
+	assert.Equal(t, tx2.ID, status.HighestStoredTxID)
+	assert.Equal(t, apitypes.TxStatusPending, status.HighestStoredStatus)
+
+	// Getting the status does not change the nonce we allocate next
+	ln, err := m.assignAndLockNonce(m.ctx, "", "0xaaaaa")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10003), ln.nonce)
+	ln.complete(m.ctx)
+
+}
+
+func TestGetSignerNonceKeepsFloor(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("NextNonceForSigner", mock.Anything, mock.Anything).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(10005),
+	}, ffcapi.ErrorReason(""), nil)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	_ = newTestTxn(t, m, "0xaaaaa", 10006, apitypes.TxStatusPending)
+	err = m.persistence.WriteNonceFloor(m.ctx, "0xaaaaa", fftypes.NewFFBigInt(10005))
+	assert.NoError(t, err)
+
+	// Our state store is ahead of the floor, but getting the status must not delete it
+	var status apitypes.SignerNonceStatus
+	res, err := resty.New().R().
+		SetResult(&status).
+		Get(url + "/signers/0xaaaaa/nonce")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Equal(t, int64(10007), status.NextNonce.Int64())
+
+	floor, err := m.persistence.GetNonceFloor(m.ctx, "0xaaaaa")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10005), floor.Int64())
+
+}
@@ -0,0 +1,55 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSigners(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	_ = newTestTxn(t, m, "0xaaaaa", 10001, apitypes.TxStatusSucceeded)
+	_ = newTestTxn(t, m, "0xbbbbb", 10001, apitypes.TxStatusPending)
+	_ = newTestTxn(t, m, "0xaaaaa", 10002, apitypes.TxStatusPending)
+
+	var signers []*apitypes.Signer
+	res, err := resty.New().R().
+		SetResult(&signers).
+		Get(url + "/signers?direction=asc")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Equal(t, []*apitypes.Signer{{Address: "0xaaaaa"}, {Address: "0xbbbbb"}}, signers)
+
+	res, err = resty.New().R().
+		SetResult(&signers).
+		Get(url + "/signers?limit=1&after=0xbbbbb")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Equal(t, []*apitypes.Signer{{Address: "0xaaaaa"}}, signers)
+
+}
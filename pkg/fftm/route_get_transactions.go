@@ -36,6 +36,7 @@ var getTransactions = func(m *manager) *ffapi.Route {
 			{Name: "after", Description: tmmsgs.APIParamAfter},
 			{Name: "signer", Description: tmmsgs.APIParamTXSigner},
 			{Name: "pending", Description: tmmsgs.APIParamTXPending, IsBool: true},
+			{Name: "subStatus", Description: tmmsgs.APIParamTXSubStatus},
 			{Name: "direction", Description: tmmsgs.APIParamSortDirection},
 		},
 		Description:     tmmsgs.APIEndpointGetSubscriptions,
@@ -43,7 +44,7 @@ var getTransactions = func(m *manager) *ffapi.Route {
 		JSONOutputValue: func() interface{} { return []*apitypes.ManagedTX{} },
 		JSONOutputCodes: []int{http.StatusOK},
 		JSONHandler: func(r *ffapi.APIRequest) (output interface{}, err error) {
-			return m.getTransactions(r.Req.Context(), r.QP["after"], r.QP["limit"], r.QP["signer"], strings.EqualFold(r.QP["pending"], "true"), r.QP["direction"])
+			return m.getTransactions(r.Req.Context(), r.QP["after"], r.QP["limit"], r.QP["signer"], strings.EqualFold(r.QP["pending"], "true"), r.QP["subStatus"], r.QP["direction"])
 		},
 	}
 }
@@ -0,0 +1,87 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPostSignerNonceResync(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("NextNonceForSigner", mock.Anything, &ffcapi.NextNonceForSignerRequest{
+		Signer: "0xaaaaa",
+	}).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(10005),
+	}, ffcapi.ErrorReason(""), nil)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	_ = newTestTxn(t, m, "0xaaaaa", 10001, apitypes.TxStatusSucceeded)
+
+	var status apitypes.SignerNonceStatus
+	res, err := resty.New().R().
+		SetBody(&struct{}{}).
+		SetResult(&status).
+		Post(url + "/signers/0xaaaaa/nonce/resync")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Equal(t, int64(10005), status.NextNonce.Int64())
+
+	// The realignment is persisted, so it survives a restart
+	floor, err := m.persistence.GetNonceFloor(m.ctx, "0xaaaaa")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10005), floor.Int64())
+
+	// The next allocation uses the realigned nonce, even though our state store is not stale.
+	// The floor is kept if the nonce is not spent, such as when writing the transaction fails.
+	ln, err := m.assignAndLockNonce(m.ctx, "", "0xaaaaa")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10005), ln.nonce)
+	ln.complete(m.ctx)
+	floor, err = m.persistence.GetNonceFloor(m.ctx, "0xaaaaa")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10005), floor.Int64())
+
+	// Once a transaction using the realigned nonce is written, the resync no longer applies
+	ln, err = m.assignAndLockNonce(m.ctx, "", "0xaaaaa")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10005), ln.nonce)
+	ln.spent = newTestTxn(t, m, "0xaaaaa", int64(ln.nonce), apitypes.TxStatusPending)
+	ln.complete(m.ctx)
+	floor, err = m.persistence.GetNonceFloor(m.ctx, "0xaaaaa")
+	assert.NoError(t, err)
+	assert.Nil(t, floor)
+	ln, err = m.assignAndLockNonce(m.ctx, "", "0xaaaaa")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10006), ln.nonce)
+	ln.complete(m.ctx)
+
+}
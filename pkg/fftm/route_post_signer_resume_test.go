@@ -0,0 +1,62 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostSignerResume(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	tx := newTestTxn(t, m, "0xaaaaa", 10001, apitypes.TxStatusPending)
+	m.pauseSigner(m.ctx, tx, ffcapi.ErrorReasonInsufficientFunds, fmt.Errorf("insufficient funds"))
+
+	var signers []*apitypes.Signer
+	res, err := resty.New().R().
+		SetResult(&signers).
+		Get(url + "/signers")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Len(t, signers, 1)
+	assert.Equal(t, ffcapi.ErrorReasonInsufficientFunds, signers[0].Paused.Reason)
+	assert.Equal(t, tx.ID, signers[0].Paused.TransactionID)
+
+	var signer apitypes.Signer
+	res, err = resty.New().R().
+		SetBody(&struct{}{}).
+		SetResult(&signer).
+		Post(url + "/signers/0xaaaaa/resume")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, res.StatusCode())
+	assert.Equal(t, "0xaaaaa", signer.Address)
+	assert.Nil(t, signer.Paused)
+	assert.Empty(t, m.pausedSigners)
+
+}
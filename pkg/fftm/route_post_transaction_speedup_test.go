@@ -0,0 +1,109 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/internal/confirmations"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/confirmationsmocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPostTransactionSpeedUp(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+
+	// The speed-up is performed by the policy engine
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.String() == "15000" && req.Nonce.Int64() == 10001
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x67890",
+	}, ffcapi.ErrorReason(""), nil)
+
+	// The transaction must be in the in-flight set to be sped up, which we know once its hash is tracked
+	tracked := make(chan struct{})
+	mc := m.confirmations.(*confirmationsmocks.Manager)
+	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
+		return n.NotificationType == confirmations.NewTransaction && n.Transaction.TransactionHash == "0x12345"
+	})).Run(func(args mock.Arguments) {
+		close(tracked)
+	}).Return(nil).Once()
+	mc.On("Notify", mock.Anything).Return(nil)
+
+	txIn := genTestTxn("0xaaaaa", 10001, apitypes.TxStatusPending)
+	txIn.GasPrice = fftypes.JSONAnyPtr("10000")
+	txIn.TransactionHash = "0x12345"
+	txIn.FirstSubmit = fftypes.Now()
+	err := m.persistence.WriteTransaction(m.ctx, txIn, true)
+	assert.NoError(t, err)
+
+	err = m.Start()
+	assert.NoError(t, err)
+	<-tracked
+
+	var txOut *apitypes.ManagedTX
+	res, err := resty.New().R().
+		SetBody(map[string]interface{}{
+			"gasPriceMultiplier": 1.5,
+		}).
+		SetResult(&txOut).
+		Post(fmt.Sprintf("%s/transactions/%s/speedup", url, txIn.ID))
+	assert.NoError(t, err)
+	if assert.Equal(t, 200, res.StatusCode()) {
+		assert.Equal(t, txIn.ID, txOut.ID)
+		assert.Equal(t, "15000", txOut.GasPrice.String())
+		assert.Equal(t, "0x67890", txOut.TransactionHash)
+	}
+
+	mfc.AssertExpectations(t)
+
+}
+
+func TestPostTransactionSpeedUpNotInFlight(t *testing.T) {
+
+	url, m, done := newTestManager(t)
+	defer done()
+	noopPolicyEngine(m)
+
+	err := m.Start()
+	assert.NoError(t, err)
+
+	txIn := newTestTxn(t, m, "0xaaaaa", 10001, apitypes.TxStatusPending)
+
+	var errRes fftypes.RESTError
+	res, err := resty.New().R().
+		SetBody(map[string]interface{}{
+			"gasPrice":           "20000",
+			"gasPriceMultiplier": 1.5,
+		}).
+		SetError(&errRes).
+		Post(fmt.Sprintf("%s/transactions/%s/speedup", url, txIn.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, 409, res.StatusCode())
+	assert.Regexp(t, "FF21074", errRes.Error)
+
+}
@@ -24,10 +24,16 @@ func (m *manager) routes() []*ffapi.Route {
 		deleteEventStreamListener(m),
 		deleteSubscription(m),
 		deleteTransaction(m),
+		getBlockCache(m),
 		getEventStream(m),
 		getEventStreamListener(m),
 		getEventStreamListeners(m),
 		getEventStreams(m),
+		getNonceFindings(m),
+		getReorgMetrics(m),
+		getReorgs(m),
+		getSignerNonce(m),
+		getSigners(m),
 		getSubscription(m),
 		getSubscriptions(m),
 		getTransaction(m),
@@ -41,7 +47,10 @@ func (m *manager) routes() []*ffapi.Route {
 		postEventStreamResume(m),
 		postEventStreamSuspend(m),
 		postRootCommand(m),
+		postSignerNonceResync(m),
+		postSignerResume(m),
 		postSubscriptionReset(m),
 		postSubscriptions(m),
+		postTransactionSpeedUp(m),
 	}
 }
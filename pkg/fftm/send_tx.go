@@ -18,14 +18,34 @@ package fftm
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"time"
 
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
 	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
 )
 
-func (m *manager) sendManagedTransaction(ctx context.Context, request *apitypes.TransactionRequest) (*apitypes.ManagedTX, error) {
+func (m *manager) sendManagedTransaction(ctx context.Context, request *apitypes.TransactionRequest) (int, *apitypes.ManagedTX, error) {
+
+	hashInput := *request
+	hashInput.Headers.Type = ""
+	hashInput.Headers.Simulate = nil
+	reqHash := requestHash(&hashInput)
+	if existing, err := m.checkExistingRequest(ctx, request.Headers.ID, reqHash); err != nil || existing != nil {
+		return http.StatusOK, existing, err
+	}
+
+	schedule, err := m.resolveSchedule(ctx, &request.Headers, nil)
+	if err != nil {
+		return 0, nil, err
+	}
 
 	// Prepare the transaction, which will mean we have a transaction that should be submittable.
 	// If we fail at this stage, we don't need to write any state as we are sure we haven't submitted
@@ -34,26 +54,159 @@ func (m *manager) sendManagedTransaction(ctx context.Context, request *apitypes.
 		TransactionInput: request.TransactionInput,
 	})
 	if err != nil {
-		return nil, err
+		return 0, nil, err
 	}
 
-	return m.submitPreparedTX(ctx, request.Headers.ID, &request.TransactionHeaders, prepared.Gas, prepared.TransactionData)
+	if err = m.simulateTransaction(ctx, &request.Headers, &request.TransactionInput); err != nil {
+		return 0, nil, err
+	}
+
+	txInput := request.TransactionInput
+	mtx, err := m.submitPreparedTX(ctx, request.Headers.ID, reqHash, schedule, &request.TransactionHeaders, &txInput, prepared.Gas, prepared.TransactionData)
+	return http.StatusAccepted, mtx, err
 }
 
-func (m *manager) sendManagedContractDeployment(ctx context.Context, request *apitypes.ContractDeployRequest) (*apitypes.ManagedTX, error) {
+func (m *manager) sendManagedContractDeployment(ctx context.Context, request *apitypes.ContractDeployRequest) (int, *apitypes.ManagedTX, error) {
+
+	hashInput := *request
+	hashInput.Headers.Type = ""
+	hashInput.Headers.Simulate = nil
+	reqHash := requestHash(&hashInput)
+	if existing, err := m.checkExistingRequest(ctx, request.Headers.ID, reqHash); err != nil || existing != nil {
+		return http.StatusOK, existing, err
+	}
+
+	schedule, err := m.resolveSchedule(ctx, &request.Headers, nil)
+	if err != nil {
+		return 0, nil, err
+	}
 
 	// Prepare the transaction, which will mean we have a transaction that should be submittable.
 	// If we fail at this stage, we don't need to write any state as we are sure we haven't submitted
 	// anything to the blockchain itself.
 	prepared, _, err := m.connector.DeployContractPrepare(ctx, &request.ContractDeployPrepareRequest)
 	if err != nil {
+		return 0, nil, err
+	}
+
+	mtx, err := m.submitPreparedTX(ctx, request.Headers.ID, reqHash, schedule, &request.TransactionHeaders, nil, prepared.Gas, prepared.TransactionData)
+	return http.StatusAccepted, mtx, err
+}
+
+// simulateTransaction executes the transaction as a query against the current state of the chain, if enabled in
+// config or by the request headers. This happens before nonce allocation, so a transaction that would revert is
+// rejected with the revert reason without using a nonce, or spending any gas.
+func (m *manager) simulateTransaction(ctx context.Context, headers *apitypes.RequestHeaders, txInput *ffcapi.TransactionInput) error {
+	simulate := m.simulateTransactions
+	if headers.Simulate != nil {
+		simulate = *headers.Simulate
+	}
+	if !simulate {
+		return nil
+	}
+	_, reason, err := m.connector.QueryInvoke(ctx, &ffcapi.QueryInvokeRequest{
+		TransactionInput: *txInput,
+	})
+	if err != nil {
+		if reason == ffcapi.ErrorReasonTransactionReverted {
+			return i18n.NewError(ctx, tmmsgs.MsgTransactionSimulationReverted, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// requestHash is a hash of the original request payload, stored with the transaction to detect whether a request
+// that reuses an ID is a retry of the same request. The type header is excluded, so a transaction submitted
+// individually and then retried in a batch (or vice versa) is detected as the same request. The simulate header
+// is also excluded, as it does not change the transaction that is submitted.
+func requestHash(request interface{}) string {
+	b, _ := json.Marshal(request)
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+// checkExistingRequest returns the existing transaction if the ID has already been used for an identical request,
+// or a conflict error if it was used for a different request.
+// Transactions persisted before request hashes were recorded cannot be compared, so reusing their ID is a duplicate ID
+// error as it always was. This check is not atomic with the write of a new transaction, so of two concurrent requests
+// with the same new ID both might pass it - the second is then rejected by the uniqueness check of the persistence
+// with a duplicate ID error, rather than returning the transaction of the first.
+func (m *manager) checkExistingRequest(ctx context.Context, txID, reqHash string) (*apitypes.ManagedTX, error) {
+	if txID == "" {
+		return nil, nil
+	}
+	existing, err := m.persistence.GetTransactionByID(ctx, txID)
+	if err != nil || existing == nil {
 		return nil, err
 	}
+	if existing.RequestHash == "" {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgDuplicateID, txID)
+	}
+	if existing.RequestHash != reqHash {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgDuplicateIDConflict, txID, existing.RequestHash, reqHash)
+	}
+	log.L(ctx).Infof("Request %s is a retry of an existing transaction", txID)
+	return existing, nil
+}
 
-	return m.submitPreparedTX(ctx, request.Headers.ID, &request.TransactionHeaders, prepared.Gas, prepared.TransactionData)
+// txSchedule holds the conditions from the request headers that control when a transaction can be submitted,
+// and when it is considered final
+type txSchedule struct {
+	notBefore     *fftypes.FFTime
+	expiry        *fftypes.FFTime
+	dependsOn     []string
+	confirmations *int
 }
 
-func (m *manager) submitPreparedTX(ctx context.Context, txID string, txHeaders *ffcapi.TransactionHeaders, gas *fftypes.FFBigInt, transactionData string) (*apitypes.ManagedTX, error) {
+// resolveSchedule validates the scheduling headers of a request. Dependencies must already exist, either as
+// a persisted transaction or as an earlier transaction in the same batch, so that they always have an earlier
+// sequence than the dependent transaction. This ensures they enter the in-flight set first.
+func (m *manager) resolveSchedule(ctx context.Context, headers *apitypes.RequestHeaders, batchIDs map[string]bool) (schedule *txSchedule, err error) {
+	schedule = &txSchedule{}
+	if schedule.notBefore, err = resolveRequestTime(ctx, "notBefore", headers.NotBefore); err != nil {
+		return nil, err
+	}
+	if schedule.expiry, err = resolveRequestTime(ctx, "expiry", headers.Expiry); err != nil {
+		return nil, err
+	}
+	for _, depID := range headers.DependsOn {
+		if !batchIDs[depID] {
+			dep, err := m.persistence.GetTransactionByID(ctx, depID)
+			if err != nil {
+				return nil, err
+			}
+			if dep == nil {
+				return nil, i18n.NewError(ctx, tmmsgs.MsgDependencyNotFound, depID)
+			}
+		}
+	}
+	schedule.dependsOn = headers.DependsOn
+	if headers.Confirmations != nil && *headers.Confirmations < 0 {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidConfirmations, *headers.Confirmations)
+	}
+	schedule.confirmations = headers.Confirmations
+	return schedule, nil
+}
+
+// resolveRequestTime converts an optional time from the request headers into an absolute time.
+// A duration (such as "30m") is relative to the time the request is received, otherwise an absolute time is required.
+func resolveRequestTime(ctx context.Context, field, value string) (*fftypes.FFTime, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		t := fftypes.FFTime(time.Now().Add(d))
+		return &t, nil
+	}
+	t, err := fftypes.ParseTimeString(value)
+	if err != nil {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidRequestTime, field, value)
+	}
+	return t, nil
+}
+
+func (m *manager) submitPreparedTX(ctx context.Context, txID, reqHash string, schedule *txSchedule, txHeaders *ffcapi.TransactionHeaders, txInput *ffcapi.TransactionInput, gas *fftypes.FFBigInt, transactionData string) (*apitypes.ManagedTX, error) {
 
 	// The request ID is the primary ID, and should be supplied by the user for idempotence
 	if txID == "" {
@@ -79,28 +232,157 @@ func (m *manager) submitPreparedTX(ctx context.Context, txID string, txHeaders *
 	// From this point on, we will guide this transaction through to submission.
 	// We return an "ack" at this point, and dispatch the work of getting the transaction submitted
 	// to the background worker.
+	mtx := newManagedTX(txID, reqHash, seqID, schedule, txHeaders, txInput, lockedNonce.nonce, gas, transactionData)
+
+	if err = m.persistence.WriteTransaction(m.ctx, mtx, true); err != nil {
+		return nil, err
+	}
+	log.L(m.ctx).Infof("Tracking transaction %s at nonce %s / %d", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64())
+	m.markInflightStale()
+	m.sendWSReply(mtx) // the transition into the Queued sub-status
+
+	// Ok - we've spent it. The rest of the processing will be triggered off of lockedNonce
+	// completion adding this transaction to the pool (and/or the change event that comes in from
+	// FireFly core from the update to the transaction)
+	lockedNonce.spent = mtx
+	return mtx, nil
+}
+
+func newManagedTX(txID, reqHash string, seqID *fftypes.UUID, schedule *txSchedule, txHeaders *ffcapi.TransactionHeaders, txInput *ffcapi.TransactionInput, nonce uint64, gas *fftypes.FFBigInt, transactionData string) *apitypes.ManagedTX {
 	now := fftypes.Now()
 	mtx := &apitypes.ManagedTX{
 		ID:                 txID, // on input the request ID must be the namespaced operation ID
 		Created:            now,
 		Updated:            now,
 		SequenceID:         seqID,
-		Nonce:              fftypes.NewFFBigInt(int64(lockedNonce.nonce)),
+		Nonce:              fftypes.NewFFBigInt(int64(nonce)),
 		Gas:                gas,
 		TransactionHeaders: *txHeaders,
 		TransactionData:    transactionData,
+		TransactionInput:   txInput,
 		Status:             apitypes.TxStatusPending,
+		RequestHash:        reqHash,
 	}
+	setSubStatus(mtx, apitypes.TxSubStatusQueued)
+	if schedule != nil {
+		mtx.NotBefore = schedule.notBefore
+		mtx.Expiry = schedule.expiry
+		// The nonce is allocated now, so any later transactions from the same signer cannot be mined
+		// until this one has been submitted after the not-before time.
+		mtx.Scheduled = mtx.NotBefore != nil && time.Now().Before(time.Time(*mtx.NotBefore))
+		// Dependencies are checked by the policy loop, before the policy engine is invoked
+		mtx.DependsOn = schedule.dependsOn
+		mtx.AwaitingDependency = len(mtx.DependsOn) > 0
+		// The confirmations manager uses its configured default, unless overridden for this transaction
+		mtx.RequiredConfirmations = schedule.confirmations
+	}
+	return mtx
+}
 
-	if err = m.persistence.WriteTransaction(m.ctx, mtx, true); err != nil {
+// batchItem is a transaction in a batch that has been successfully prepared, ready for nonce allocation
+type batchItem struct {
+	result   *apitypes.TransactionBatchResult
+	reqHash  string
+	schedule *txSchedule
+	headers  *ffcapi.TransactionHeaders
+	input    *ffcapi.TransactionInput
+	prepared *ffcapi.TransactionPrepareResponse
+}
+
+// sendManagedTransactionBatch prepares each transaction in the batch, then allocates a contiguous range of nonces
+// to all those that prepared successfully under a single nonce lock, and persists them in a single atomic write.
+// Failures to prepare are reported against the individual item, but a persistence failure fails the whole batch.
+func (m *manager) sendManagedTransactionBatch(ctx context.Context, request *apitypes.TransactionBatchRequest) (*apitypes.TransactionBatchResponse, error) {
+
+	if len(request.Transactions) == 0 {
+		return nil, i18n.NewError(ctx, tmmsgs.MsgTransactionBatchEmpty)
+	}
+	signer := request.Transactions[0].From
+	for _, txReq := range request.Transactions {
+		if txReq.From != signer {
+			return nil, i18n.NewError(ctx, tmmsgs.MsgTransactionBatchMixedSigners, signer, txReq.From)
+		}
+	}
+
+	res := &apitypes.TransactionBatchResponse{
+		Transactions: make([]*apitypes.TransactionBatchResult, len(request.Transactions)),
+	}
+	items := make([]*batchItem, 0, len(request.Transactions))
+	batchIDs := make(map[string]bool)
+	for i := range request.Transactions {
+		txReq := &request.Transactions[i]
+		// The request ID is the primary ID, and should be supplied by the user for idempotence
+		result := &apitypes.TransactionBatchResult{ID: txReq.Headers.ID}
+		if result.ID == "" {
+			result.ID = fftypes.NewUUID().String()
+		}
+		res.Transactions[i] = result
+		hashInput := *txReq
+		hashInput.Headers.Type = ""
+		hashInput.Headers.Simulate = nil
+		reqHash := requestHash(&hashInput)
+		existing, err := m.checkExistingRequest(ctx, txReq.Headers.ID, reqHash)
+		if existing != nil {
+			// A retry of a transaction that already exists does not consume a nonce
+			result.Transaction = existing
+			continue
+		}
+		var schedule *txSchedule
+		if err == nil {
+			schedule, err = m.resolveSchedule(ctx, &txReq.Headers, batchIDs)
+		}
+		if err == nil {
+			var prepared *ffcapi.TransactionPrepareResponse
+			prepared, _, err = m.connector.TransactionPrepare(ctx, &ffcapi.TransactionPrepareRequest{
+				TransactionInput: txReq.TransactionInput,
+			})
+			if err == nil {
+				err = m.simulateTransaction(ctx, &txReq.Headers, &txReq.TransactionInput)
+			}
+			if err == nil {
+				items = append(items, &batchItem{
+					result:   result,
+					reqHash:  reqHash,
+					schedule: schedule,
+					headers:  &txReq.TransactionHeaders,
+					input:    &txReq.TransactionInput,
+					prepared: prepared,
+				})
+				// Later transactions in the batch can depend on this one
+				batchIDs[result.ID] = true
+			}
+		}
+		if err != nil {
+			log.L(ctx).Errorf("Failed to prepare transaction %d (id=%s) in batch: %s", i, result.ID, err)
+			result.Error = err.Error()
+		}
+	}
+	if len(items) == 0 {
+		return res, nil
+	}
+
+	// Allocate the nonces for all transactions that prepared successfully, under a single nonce lock
+	lockedNonce, err := m.assignAndLockNonce(ctx, items[0].result.ID, signer)
+	if err != nil {
 		return nil, err
 	}
-	log.L(m.ctx).Infof("Tracking transaction %s at nonce %s / %d", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64())
+	defer lockedNonce.complete(ctx)
+
+	mtxs := make([]*apitypes.ManagedTX, len(items))
+	for i, item := range items {
+		// Sequencing IDs are allocated in nonce order within the nonce lock, as for individual transactions
+		mtxs[i] = newManagedTX(item.result.ID, item.reqHash, apitypes.NewULID(), item.schedule, item.headers, item.input, lockedNonce.nonce+uint64(i), item.prepared.Gas, item.prepared.TransactionData)
+	}
+	if err = m.persistence.WriteNewTransactions(m.ctx, mtxs); err != nil {
+		return nil, err
+	}
+	for i, item := range items {
+		item.result.Transaction = mtxs[i]
+		m.sendWSReply(mtxs[i]) // the transition into the Queued sub-status
+	}
+	log.L(m.ctx).Infof("Tracking %d transactions in batch at nonces %s / %d-%d", len(mtxs), signer, mtxs[0].Nonce.Int64(), mtxs[len(mtxs)-1].Nonce.Int64())
 	m.markInflightStale()
 
-	// Ok - we've spent it. The rest of the processing will be triggered off of lockedNonce
-	// completion adding this transaction to the pool (and/or the change event that comes in from
-	// FireFly core from the update to the transaction)
-	lockedNonce.spent = mtx
-	return mtx, nil
+	lockedNonce.spent = mtxs[len(mtxs)-1]
+	return res, nil
 }
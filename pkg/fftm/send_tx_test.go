@@ -17,12 +17,19 @@
 package fftm
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"testing"
+	"time"
 
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/internal/persistence"
+	"github.com/hyperledger/firefly-transaction-manager/internal/ws"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
 	"github.com/hyperledger/firefly-transaction-manager/mocks/persistencemocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/policyenginemocks"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
 	"github.com/stretchr/testify/assert"
@@ -45,7 +52,679 @@ func TestSendTXPersistFail(t *testing.T) {
 	err := json.Unmarshal([]byte(sampleSendTX), &txReq)
 	assert.NoError(t, err)
 
-	_, err = m.submitPreparedTX(m.ctx, "id1", &txReq.TransactionHeaders, fftypes.NewFFBigInt(12345), "0x123456")
+	_, err = m.submitPreparedTX(m.ctx, "id1", "", nil, &txReq.TransactionHeaders, nil, fftypes.NewFFBigInt(12345), "0x123456")
 	assert.Regexp(t, "pop", err)
 
 }
+
+func TestSendTXScheduled(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	defer close()
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("ListTransactionsByNonce", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return([]*apitypes.ManagedTX{
+			{ID: "id12345", Created: fftypes.Now(), Status: apitypes.TxStatusSucceeded, Nonce: fftypes.NewFFBigInt(1000)},
+		}, nil)
+	mp.On("WriteTransaction", m.ctx, mock.MatchedBy(func(mtx *apitypes.ManagedTX) bool {
+		return mtx.Scheduled && mtx.NotBefore != nil && mtx.Expiry != nil
+	}), true).Return(nil)
+
+	schedule, err := m.resolveSchedule(m.ctx, &apitypes.RequestHeaders{
+		NotBefore: "1h",
+		Expiry:    "2h",
+	}, nil)
+	assert.NoError(t, err)
+
+	mtx, err := m.submitPreparedTX(m.ctx, "id1", "", schedule, &ffcapi.TransactionHeaders{From: "0x12345"}, nil, fftypes.NewFFBigInt(12345), "0x123456")
+	assert.NoError(t, err)
+	assert.Equal(t, apitypes.TxStatusPending, mtx.Status)
+	assert.Equal(t, int64(1001), mtx.Nonce.Int64())
+
+	mp.AssertExpectations(t)
+
+}
+
+type testReplyServer struct {
+	ws.WebSocketServer
+	replies chan interface{}
+}
+
+func (s *testReplyServer) SendReply(message interface{}) {
+	s.replies <- message
+}
+
+func TestSendTXQueuedReply(t *testing.T) {
+
+	_, m, close := newTestManager(t)
+	defer close()
+	replies := make(chan interface{}, 3)
+	m.wsServer = &testReplyServer{replies: replies}
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("NextNonceForSigner", m.ctx, mock.Anything).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(12345),
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	mtx, err := m.submitPreparedTX(m.ctx, "id1", "", nil, &ffcapi.TransactionHeaders{From: "0xaaaaa"}, nil, fftypes.NewFFBigInt(12345), "0x123456")
+	assert.NoError(t, err)
+
+	mfc.On("TransactionPrepare", m.ctx, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{
+		Gas:             fftypes.NewFFBigInt(100000),
+		TransactionData: "0xabce1234",
+	}, ffcapi.ErrorReason(""), nil)
+	res, err := m.sendManagedTransactionBatch(m.ctx, &apitypes.TransactionBatchRequest{
+		Transactions: []apitypes.TransactionRequest{
+			{TransactionInput: ffcapi.TransactionInput{TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaaa"}}},
+			{TransactionInput: ffcapi.TransactionInput{TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaaa"}}},
+		},
+	})
+	assert.NoError(t, err)
+
+	// Listeners are told of the transition into the Queued sub-status, as for every later transition
+	for _, expectedID := range []string{mtx.ID, res.Transactions[0].ID, res.Transactions[1].ID} {
+		reply := (<-replies).(*apitypes.TransactionUpdateReply)
+		assert.Equal(t, apitypes.TransactionUpdate, reply.Headers.Type)
+		assert.Equal(t, expectedID, reply.Headers.RequestID)
+		assert.Equal(t, apitypes.TxSubStatusQueued, reply.SubStatus)
+	}
+
+}
+
+func TestSendTXBadExpiry(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	defer close()
+
+	_, _, err := m.sendManagedTransaction(m.ctx, &apitypes.TransactionRequest{
+		Headers: apitypes.RequestHeaders{
+			Expiry: "tomorrow",
+		},
+	})
+	assert.Regexp(t, "FF21075.*expiry", err)
+
+	_, _, err = m.sendManagedTransaction(m.ctx, &apitypes.TransactionRequest{
+		Headers: apitypes.RequestHeaders{
+			NotBefore: "tomorrow",
+		},
+	})
+	assert.Regexp(t, "FF21075.*notBefore", err)
+
+	_, _, err = m.sendManagedContractDeployment(m.ctx, &apitypes.ContractDeployRequest{
+		Headers: apitypes.RequestHeaders{
+			Expiry: "tomorrow",
+		},
+	})
+	assert.Regexp(t, "FF21075", err)
+
+}
+
+func TestResolveRequestTime(t *testing.T) {
+
+	expiry, err := resolveRequestTime(context.Background(), "expiry", "")
+	assert.NoError(t, err)
+	assert.Nil(t, expiry)
+
+	before := time.Now()
+	expiry, err = resolveRequestTime(context.Background(), "expiry", "30m")
+	assert.NoError(t, err)
+	assert.False(t, time.Time(*expiry).Before(before.Add(30*time.Minute)))
+
+	expiry, err = resolveRequestTime(context.Background(), "expiry", "2022-08-01T12:00:00Z")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1659355200), time.Time(*expiry).Unix())
+
+}
+
+func TestSendTXBatchOk(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("NextNonceForSigner", m.ctx, &ffcapi.NextNonceForSignerRequest{
+		Signer: "0xaaaaa",
+	}).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(12345),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mfc.On("TransactionPrepare", m.ctx, mock.MatchedBy(func(req *ffcapi.TransactionPrepareRequest) bool {
+		return req.To == "0xbad"
+	})).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+	mfc.On("TransactionPrepare", m.ctx, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{
+		Gas:             fftypes.NewFFBigInt(100000),
+		TransactionData: "0xabce1234",
+	}, ffcapi.ErrorReason(""), nil)
+
+	newReq := func(id, to string) apitypes.TransactionRequest {
+		return apitypes.TransactionRequest{
+			Headers: apitypes.RequestHeaders{ID: id},
+			TransactionInput: ffcapi.TransactionInput{
+				TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaaa", To: to},
+			},
+		}
+	}
+	res, err := m.sendManagedTransactionBatch(m.ctx, &apitypes.TransactionBatchRequest{
+		Transactions: []apitypes.TransactionRequest{
+			newReq("tx1", "0x11111"),
+			newReq("tx2", "0xbad"),
+			newReq("", "0x33333"),
+		},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, res.Transactions, 3)
+
+	assert.Equal(t, "tx1", res.Transactions[0].ID)
+	assert.Equal(t, int64(12345), res.Transactions[0].Transaction.Nonce.Int64())
+	assert.Empty(t, res.Transactions[0].Error)
+
+	assert.Equal(t, "tx2", res.Transactions[1].ID)
+	assert.Nil(t, res.Transactions[1].Transaction)
+	assert.Regexp(t, "pop", res.Transactions[1].Error)
+
+	assert.NotEmpty(t, res.Transactions[2].ID)
+	assert.Equal(t, res.Transactions[2].ID, res.Transactions[2].Transaction.ID)
+	assert.Equal(t, int64(12346), res.Transactions[2].Transaction.Nonce.Int64())
+
+	// Check they are persisted, and the next nonce follows on from the batch
+	txns, err := m.persistence.ListTransactionsPending(m.ctx, nil, 0, persistence.SortDirectionAscending)
+	assert.NoError(t, err)
+	assert.Len(t, txns, 2)
+	assert.Equal(t, "tx1", txns[0].ID)
+	nextNonce, _, err := m.calcNextNonce(m.ctx, "0xaaaaa")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(12347), nextNonce)
+
+	mfc.AssertExpectations(t)
+}
+
+func TestSendTXBatchAllPrepareFail(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	res, err := m.sendManagedTransactionBatch(m.ctx, &apitypes.TransactionBatchRequest{
+		Transactions: []apitypes.TransactionRequest{
+			{Headers: apitypes.RequestHeaders{Expiry: "tomorrow"}},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Regexp(t, "FF21075", res.Transactions[0].Error)
+
+}
+
+func TestSendTXBatchMixedSigners(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	_, err := m.sendManagedTransactionBatch(m.ctx, &apitypes.TransactionBatchRequest{
+		Transactions: []apitypes.TransactionRequest{
+			{TransactionInput: ffcapi.TransactionInput{TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaaa"}}},
+			{TransactionInput: ffcapi.TransactionInput{TransactionHeaders: ffcapi.TransactionHeaders{From: "0xbbbbb"}}},
+		},
+	})
+	assert.Regexp(t, "FF21079", err)
+
+}
+
+func TestSendTXBatchNonceFail(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("TransactionPrepare", m.ctx, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{}, ffcapi.ErrorReason(""), nil)
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("ListTransactionsByNonce", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, fmt.Errorf("pop"))
+
+	_, err := m.sendManagedTransactionBatch(m.ctx, &apitypes.TransactionBatchRequest{
+		Transactions: []apitypes.TransactionRequest{{}},
+	})
+	assert.Regexp(t, "pop", err)
+
+}
+
+func TestSendTXBatchPersistFail(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("TransactionPrepare", m.ctx, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{}, ffcapi.ErrorReason(""), nil)
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("ListTransactionsByNonce", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return([]*apitypes.ManagedTX{
+			{ID: "id12345", Created: fftypes.Now(), Status: apitypes.TxStatusSucceeded, Nonce: fftypes.NewFFBigInt(1000)},
+		}, nil)
+	mp.On("WriteNewTransactions", m.ctx, mock.Anything).Return(fmt.Errorf("pop"))
+
+	_, err := m.sendManagedTransactionBatch(m.ctx, &apitypes.TransactionBatchRequest{
+		Transactions: []apitypes.TransactionRequest{{}, {}},
+	})
+	assert.Regexp(t, "pop", err)
+
+	mp.AssertExpectations(t)
+
+}
+
+func TestSendTXDependencyNotFound(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	_, _, err := m.sendManagedTransaction(m.ctx, &apitypes.TransactionRequest{
+		Headers: apitypes.RequestHeaders{
+			DependsOn: []string{"missing"},
+		},
+	})
+	assert.Regexp(t, "FF21080", err)
+
+}
+
+func TestSendTXDependencyReadFail(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("GetTransactionByID", m.ctx, "dep1").Return(nil, fmt.Errorf("pop"))
+
+	_, _, err := m.sendManagedContractDeployment(m.ctx, &apitypes.ContractDeployRequest{
+		Headers: apitypes.RequestHeaders{
+			DependsOn: []string{"dep1"},
+		},
+	})
+	assert.Regexp(t, "pop", err)
+
+}
+
+func TestSendTXBatchDependencies(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+
+	existing := newTestTxn(t, m, "0xbbbbb", 20001, apitypes.TxStatusPending)
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("NextNonceForSigner", m.ctx, mock.Anything).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(12345),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mfc.On("TransactionPrepare", m.ctx, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{
+		Gas:             fftypes.NewFFBigInt(100000),
+		TransactionData: "0xabce1234",
+	}, ffcapi.ErrorReason(""), nil)
+
+	txInput := ffcapi.TransactionInput{TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaaa"}}
+	res, err := m.sendManagedTransactionBatch(m.ctx, &apitypes.TransactionBatchRequest{
+		Transactions: []apitypes.TransactionRequest{
+			{Headers: apitypes.RequestHeaders{ID: "tx1", DependsOn: []string{existing.ID}}, TransactionInput: txInput},
+			{Headers: apitypes.RequestHeaders{ID: "tx2", DependsOn: []string{"tx1"}}, TransactionInput: txInput},
+			{Headers: apitypes.RequestHeaders{ID: "tx3", DependsOn: []string{"tx4"}}, TransactionInput: txInput},
+			{Headers: apitypes.RequestHeaders{ID: "tx4"}, TransactionInput: txInput},
+		},
+	})
+	assert.NoError(t, err)
+	if !assert.Len(t, res.Transactions, 4) {
+		return
+	}
+	tx1 := res.Transactions[0].Transaction
+	tx2 := res.Transactions[1].Transaction
+	assert.True(t, tx1.AwaitingDependency)
+	assert.True(t, tx2.AwaitingDependency)
+	assert.Equal(t, []string{"tx1"}, tx2.DependsOn)
+	assert.Regexp(t, "FF21080", res.Transactions[2].Error)
+	assert.False(t, res.Transactions[3].Transaction.AwaitingDependency)
+
+	// Nothing is released while the parent is still pending
+	pending1 := &pendingState{mtx: tx1}
+	err = m.execPolicy(m.ctx, pending1, false)
+	assert.NoError(t, err)
+	assert.True(t, tx1.AwaitingDependency)
+
+	// Once the parent succeeds, only its direct dependent is released
+	existing.Status = apitypes.TxStatusSucceeded
+	err = m.persistence.WriteTransaction(m.ctx, existing, false)
+	assert.NoError(t, err)
+
+	err = m.execPolicy(m.ctx, pending1, false)
+	assert.NoError(t, err)
+	rtx, err := m.persistence.GetTransactionByID(m.ctx, "tx1")
+	assert.NoError(t, err)
+	assert.False(t, rtx.AwaitingDependency)
+	assert.Equal(t, apitypes.TxStatusPending, rtx.Status)
+
+	err = m.execPolicy(m.ctx, &pendingState{mtx: tx2}, false)
+	assert.NoError(t, err)
+	rtx, err = m.persistence.GetTransactionByID(m.ctx, "tx2")
+	assert.NoError(t, err)
+	assert.True(t, rtx.AwaitingDependency)
+
+	mfc.AssertExpectations(t)
+	mpe.AssertExpectations(t)
+}
+
+func TestSendTXIdempotentRetry(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("NextNonceForSigner", m.ctx, mock.Anything).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(12345),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mfc.On("TransactionPrepare", m.ctx, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{
+		Gas:             fftypes.NewFFBigInt(100000),
+		TransactionData: "0xabce1234",
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	newReq := func(to string) *apitypes.TransactionRequest {
+		return &apitypes.TransactionRequest{
+			Headers: apitypes.RequestHeaders{ID: "tx1", Type: apitypes.RequestTypeSendTransaction},
+			TransactionInput: ffcapi.TransactionInput{
+				TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaaa", To: to},
+			},
+		}
+	}
+
+	status, mtx, err := m.sendManagedTransaction(m.ctx, newReq("0x11111"))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, status)
+	assert.NotEmpty(t, mtx.RequestHash)
+
+	// Identical retry returns the existing transaction, without preparing it again
+	status, mtx2, err := m.sendManagedTransaction(m.ctx, newReq("0x11111"))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, mtx.SequenceID, mtx2.SequenceID)
+
+	// The same request as an item in a batch is also a retry
+	res, err := m.sendManagedTransactionBatch(m.ctx, &apitypes.TransactionBatchRequest{
+		Transactions: []apitypes.TransactionRequest{*newReq("0x11111")},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, mtx.SequenceID, res.Transactions[0].Transaction.SequenceID)
+	assert.Empty(t, res.Transactions[0].Error)
+
+	// A different payload is a conflict, reporting both hashes
+	_, _, err = m.sendManagedTransaction(m.ctx, newReq("0x22222"))
+	assert.Regexp(t, "FF21082.*"+mtx.RequestHash, err)
+	res, err = m.sendManagedTransactionBatch(m.ctx, &apitypes.TransactionBatchRequest{
+		Transactions: []apitypes.TransactionRequest{*newReq("0x22222")},
+	})
+	assert.NoError(t, err)
+	assert.Regexp(t, "FF21082", res.Transactions[0].Error)
+
+	mfc.AssertExpectations(t)
+}
+
+func TestDeployIdempotentRetry(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("NextNonceForSigner", m.ctx, mock.Anything).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(12345),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mfc.On("DeployContractPrepare", m.ctx, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{
+		Gas:             fftypes.NewFFBigInt(100000),
+		TransactionData: "0xabce1234",
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	req := &apitypes.ContractDeployRequest{
+		Headers: apitypes.RequestHeaders{ID: "deploy1"},
+		ContractDeployPrepareRequest: ffcapi.ContractDeployPrepareRequest{
+			TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaaa"},
+		},
+	}
+	status, _, err := m.sendManagedContractDeployment(m.ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, status)
+
+	status, _, err = m.sendManagedContractDeployment(m.ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+
+	// The simulate header is not part of the request hash
+	simulate := true
+	req.Headers.Simulate = &simulate
+	status, _, err = m.sendManagedContractDeployment(m.ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+
+	mfc.AssertExpectations(t)
+}
+
+func TestSendTXRetryBeforeRequestHash(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	// Transactions persisted before the request hash was recorded cannot be compared with the request
+	legacy := newTestTxn(t, m, "0xaaaaa", 12345, apitypes.TxStatusPending)
+	req := &apitypes.TransactionRequest{
+		Headers: apitypes.RequestHeaders{ID: legacy.ID, Type: apitypes.RequestTypeSendTransaction},
+		TransactionInput: ffcapi.TransactionInput{
+			TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaaa", To: "0x11111"},
+		},
+	}
+
+	_, _, err := m.sendManagedTransaction(m.ctx, req)
+	assert.Regexp(t, "FF21065.*"+legacy.ID, err)
+	res, err := m.sendManagedTransactionBatch(m.ctx, &apitypes.TransactionBatchRequest{
+		Transactions: []apitypes.TransactionRequest{*req},
+	})
+	assert.NoError(t, err)
+	assert.Regexp(t, "FF21065", res.Transactions[0].Error)
+	assert.Nil(t, res.Transactions[0].Transaction)
+
+	// Nothing was prepared, and the existing transaction is unchanged
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.AssertNotCalled(t, "TransactionPrepare", mock.Anything, mock.Anything)
+	rtx, err := m.persistence.GetTransactionByID(m.ctx, legacy.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, legacy.SequenceID, rtx.SequenceID)
+
+}
+
+func TestCheckExistingRequestReadFail(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("GetTransactionByID", m.ctx, "tx1").Return(nil, fmt.Errorf("pop"))
+
+	_, _, err := m.sendManagedTransaction(m.ctx, &apitypes.TransactionRequest{
+		Headers: apitypes.RequestHeaders{ID: "tx1"},
+	})
+	assert.Regexp(t, "pop", err)
+
+}
+
+func TestSendTXSimulateReverted(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.simulateTransactions = true
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("TransactionPrepare", m.ctx, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{
+		Gas:             fftypes.NewFFBigInt(100000),
+		TransactionData: "0xabce1234",
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mfc.On("QueryInvoke", m.ctx, mock.MatchedBy(func(req *ffcapi.QueryInvokeRequest) bool {
+		return req.From == "0xaaaaa" && req.To == "0x11111"
+	})).Return(nil, ffcapi.ErrorReasonTransactionReverted, fmt.Errorf("execution reverted: not allowed")).Once()
+
+	// The revert reason is returned, and no nonce is allocated (NextNonceForSigner is not called)
+	_, _, err := m.sendManagedTransaction(m.ctx, &apitypes.TransactionRequest{
+		Headers: apitypes.RequestHeaders{ID: "tx1"},
+		TransactionInput: ffcapi.TransactionInput{
+			TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaaa", To: "0x11111"},
+		},
+	})
+	assert.Regexp(t, "FF21084.*not allowed", err)
+
+	mtx, err := m.persistence.GetTransactionByID(m.ctx, "tx1")
+	assert.NoError(t, err)
+	assert.Nil(t, mtx)
+
+	mfc.AssertExpectations(t)
+}
+
+func TestSendTXSimulateHeaderOverride(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("NextNonceForSigner", m.ctx, mock.Anything).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(12345),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mfc.On("TransactionPrepare", m.ctx, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{
+		Gas:             fftypes.NewFFBigInt(100000),
+		TransactionData: "0xabce1234",
+	}, ffcapi.ErrorReason(""), nil).Twice()
+	mfc.On("QueryInvoke", m.ctx, mock.Anything).Return(&ffcapi.QueryInvokeResponse{}, ffcapi.ErrorReason(""), nil).Once()
+
+	simulate := true
+	req := &apitypes.TransactionRequest{
+		Headers: apitypes.RequestHeaders{ID: "tx1", Simulate: &simulate},
+		TransactionInput: ffcapi.TransactionInput{
+			TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaaa", To: "0x11111"},
+		},
+	}
+	status, mtx, err := m.sendManagedTransaction(m.ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, status)
+	assert.Equal(t, int64(12345), mtx.Nonce.Int64())
+	// The input is stored, so that the transaction can be replayed if it fails
+	assert.Equal(t, "0x11111", mtx.TransactionInput.To)
+
+	// The simulate header is not part of the request hash, so a retry without it is idempotent
+	req.Headers.Simulate = nil
+	status, _, err = m.sendManagedTransaction(m.ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+
+	// Simulation can be disabled per request, when enabled by default
+	m.simulateTransactions = true
+	simulate = false
+	req.Headers = apitypes.RequestHeaders{ID: "tx2", Simulate: &simulate}
+	_, _, err = m.sendManagedTransaction(m.ctx, req)
+	assert.NoError(t, err)
+
+	mfc.AssertExpectations(t)
+}
+
+func TestSendTXSimulateFail(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.simulateTransactions = true
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("TransactionPrepare", m.ctx, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{
+		Gas:             fftypes.NewFFBigInt(100000),
+		TransactionData: "0xabce1234",
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mfc.On("QueryInvoke", m.ctx, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+
+	_, _, err := m.sendManagedTransaction(m.ctx, &apitypes.TransactionRequest{
+		TransactionInput: ffcapi.TransactionInput{
+			TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaaa"},
+		},
+	})
+	assert.Regexp(t, "pop", err)
+
+	mfc.AssertExpectations(t)
+}
+
+func TestSendTXBatchSimulateReverted(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+	m.simulateTransactions = true
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("NextNonceForSigner", m.ctx, mock.Anything).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(12345),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mfc.On("TransactionPrepare", m.ctx, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{
+		Gas:             fftypes.NewFFBigInt(100000),
+		TransactionData: "0xabce1234",
+	}, ffcapi.ErrorReason(""), nil).Twice()
+	mfc.On("QueryInvoke", m.ctx, mock.MatchedBy(func(req *ffcapi.QueryInvokeRequest) bool {
+		return req.To == "0x11111"
+	})).Return(nil, ffcapi.ErrorReasonTransactionReverted, fmt.Errorf("execution reverted")).Once()
+	mfc.On("QueryInvoke", m.ctx, mock.MatchedBy(func(req *ffcapi.QueryInvokeRequest) bool {
+		return req.To == "0x22222"
+	})).Return(&ffcapi.QueryInvokeResponse{}, ffcapi.ErrorReason(""), nil).Once()
+
+	res, err := m.sendManagedTransactionBatch(m.ctx, &apitypes.TransactionBatchRequest{
+		Transactions: []apitypes.TransactionRequest{
+			{TransactionInput: ffcapi.TransactionInput{TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaaa", To: "0x11111"}}},
+			{TransactionInput: ffcapi.TransactionInput{TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaaa", To: "0x22222"}}},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Regexp(t, "FF21084", res.Transactions[0].Error)
+	assert.Nil(t, res.Transactions[0].Transaction)
+	// The reverted transaction does not consume a nonce
+	assert.Equal(t, int64(12345), res.Transactions[1].Transaction.Nonce.Int64())
+	assert.Equal(t, "0x22222", res.Transactions[1].Transaction.TransactionInput.To)
+
+	mfc.AssertExpectations(t)
+}
+
+func TestDeployConfirmationsOverride(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("NextNonceForSigner", m.ctx, mock.Anything).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(12345),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mfc.On("DeployContractPrepare", m.ctx, mock.Anything).Return(&ffcapi.TransactionPrepareResponse{
+		Gas:             fftypes.NewFFBigInt(100000),
+		TransactionData: "0xabce1234",
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	confirmations := 5
+	_, mtx, err := m.sendManagedContractDeployment(m.ctx, &apitypes.ContractDeployRequest{
+		Headers: apitypes.RequestHeaders{ID: "deploy1", Confirmations: &confirmations},
+		ContractDeployPrepareRequest: ffcapi.ContractDeployPrepareRequest{
+			TransactionHeaders: ffcapi.TransactionHeaders{From: "0xaaaaa"},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 5, *mtx.RequiredConfirmations)
+
+	rtx, err := m.persistence.GetTransactionByID(m.ctx, "deploy1")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, *rtx.RequiredConfirmations)
+
+	mfc.AssertExpectations(t)
+}
+
+func TestSendTXBadConfirmations(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	defer close()
+
+	confirmations := -1
+	_, _, err := m.sendManagedTransaction(m.ctx, &apitypes.TransactionRequest{
+		Headers: apitypes.RequestHeaders{
+			Confirmations: &confirmations,
+		},
+	})
+	assert.Regexp(t, "FF21086", err)
+
+}
@@ -0,0 +1,114 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/persistence"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+func (m *manager) getSigners(ctx context.Context, afterStr, limitStr, dirString string) (signers []*apitypes.Signer, err error) {
+	limit, err := m.parseLimit(ctx, limitStr)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := m.parseSortDirection(ctx, dirString)
+	if err != nil {
+		return nil, err
+	}
+	addresses, err := m.persistence.ListSigners(ctx, afterStr, limit, dir)
+	if err != nil {
+		return nil, err
+	}
+	signers = make([]*apitypes.Signer, len(addresses))
+	for i, address := range addresses {
+		signers[i] = &apitypes.Signer{
+			Address: address,
+			Paused:  m.getSignerPause(address),
+		}
+	}
+	return signers, nil
+}
+
+func (m *manager) getSignerNonce(ctx context.Context, signer string) (*apitypes.SignerNonceStatus, error) {
+	return m.signerNonceStatus(ctx, signer, false)
+}
+
+func (m *manager) resyncSignerNonce(ctx context.Context, signer string) (*apitypes.SignerNonceStatus, error) {
+	return m.signerNonceStatus(ctx, signer, true)
+}
+
+// signerNonceStatus takes the nonce lock for the signer, so that the status is consistent with any nonce
+// allocation in progress. Getting the status does not persist anything. When resyncing, the next nonce is
+// realigned to be ahead of both our state store and the node - so transactions submitted outside of FFTM since
+// our last transaction do not cause nonce clashes.
+func (m *manager) signerNonceStatus(ctx context.Context, signer string, resync bool) (*apitypes.SignerNonceStatus, error) {
+	lockedNonce := m.lockNonce(ctx, "", signer)
+	defer lockedNonce.complete(ctx)
+
+	nextNonce, nodeNextNonce, err := m.calcNextNonce(ctx, signer)
+	if err == nil {
+		nextNonce, _, err = m.applyNonceFloor(ctx, signer, nextNonce)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	status := &apitypes.SignerNonceStatus{
+		Signer: signer,
+	}
+	txns, err := m.persistence.ListTransactionsByNonce(ctx, signer, nil, 1, persistence.SortDirectionDescending)
+	if err != nil {
+		return nil, err
+	}
+	if len(txns) > 0 {
+		status.HighestStoredNonce = txns[0].Nonce
+		status.HighestStoredTxID = txns[0].ID
+		status.HighestStoredStatus = txns[0].Status
+	}
+	// The node might already have been queried to calculate the next nonce
+	status.NodeNextNonce = nodeNextNonce
+	if status.NodeNextNonce == nil {
+		nextNonceRes, _, err := m.connector.NextNonceForSigner(ctx, &ffcapi.NextNonceForSignerRequest{
+			Signer: signer,
+		})
+		if err != nil {
+			return nil, err
+		}
+		status.NodeNextNonce = nextNonceRes.Nonce
+	}
+
+	if resync {
+		realigned := status.NodeNextNonce.Uint64()
+		if status.HighestStoredNonce != nil && realigned <= status.HighestStoredNonce.Uint64() {
+			realigned = status.HighestStoredNonce.Uint64() + 1
+		}
+		log.L(ctx).Infof("Resync of nonce for signer %s realigned next nonce from %d to %d", signer, nextNonce, realigned)
+		if err := m.persistence.WriteNonceFloor(ctx, signer, fftypes.NewFFBigInt(int64(realigned))); err != nil {
+			return nil, err
+		}
+		nextNonce = realigned
+	}
+	status.NextNonce = fftypes.NewFFBigInt(int64(nextNonce))
+	status.Findings = m.getNonceFindings(signer)
+	return status, nil
+}
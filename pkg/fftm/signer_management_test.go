@@ -0,0 +1,111 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/internal/persistence"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/persistencemocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetSignersErrors(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	defer close()
+
+	_, err := m.getSigners(m.ctx, "", "wrong", "")
+	assert.Regexp(t, "FF21044", err)
+
+	_, err = m.getSigners(m.ctx, "", "", "wrong")
+	assert.Regexp(t, "FF21064", err)
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("ListSigners", m.ctx, "", 0, persistence.SortDirectionDescending).Return(nil, fmt.Errorf("pop"))
+	_, err = m.getSigners(m.ctx, "", "", "")
+	assert.Regexp(t, "pop", err)
+
+}
+
+func TestSignerNonceStatusErrors(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	defer close()
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("ListTransactionsByNonce", m.ctx, "0xaaaaa", (*fftypes.FFBigInt)(nil), 1, persistence.SortDirectionDescending).Return(nil, fmt.Errorf("pop")).Once()
+	_, err := m.getSignerNonce(m.ctx, "0xaaaaa")
+	assert.Regexp(t, "pop", err)
+
+	mp.On("ListTransactionsByNonce", m.ctx, "0xaaaaa", (*fftypes.FFBigInt)(nil), 1, persistence.SortDirectionDescending).Return([]*apitypes.ManagedTX{
+		genTestTxn("0xaaaaa", 10001, apitypes.TxStatusPending),
+	}, nil).Once()
+	mp.On("ListTransactionsByNonce", m.ctx, "0xaaaaa", (*fftypes.FFBigInt)(nil), 1, persistence.SortDirectionDescending).Return(nil, fmt.Errorf("pop")).Once()
+	_, err = m.getSignerNonce(m.ctx, "0xaaaaa")
+	assert.Regexp(t, "pop", err)
+
+	mp.On("ListTransactionsByNonce", m.ctx, "0xaaaaa", (*fftypes.FFBigInt)(nil), 1, persistence.SortDirectionDescending).Return([]*apitypes.ManagedTX{
+		genTestTxn("0xaaaaa", 10001, apitypes.TxStatusPending),
+	}, nil)
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("NextNonceForSigner", m.ctx, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("snap"))
+	_, err = m.resyncSignerNonce(m.ctx, "0xaaaaa")
+	assert.Regexp(t, "snap", err)
+	mp.AssertNotCalled(t, "WriteNonceFloor", mock.Anything, mock.Anything, mock.Anything)
+
+}
+
+func TestResyncSignerNonceQueriesNodeOnce(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	defer close()
+	m.nonceStateTimeout = 0 // so the state store is always stale, and the node is queried to allocate the nonce
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("ListTransactionsByNonce", m.ctx, "0xaaaaa", (*fftypes.FFBigInt)(nil), 1, persistence.SortDirectionDescending).Return([]*apitypes.ManagedTX{
+		genTestTxn("0xaaaaa", 10001, apitypes.TxStatusSucceeded),
+	}, nil)
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("NextNonceForSigner", m.ctx, mock.Anything).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(10005),
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	mp.On("WriteNonceFloor", m.ctx, "0xaaaaa", fftypes.NewFFBigInt(10005)).Return(fmt.Errorf("pop")).Once()
+	mp.On("WriteNonceFloor", m.ctx, "0xaaaaa", fftypes.NewFFBigInt(10005)).Return(nil).Once()
+
+	_, err := m.resyncSignerNonce(m.ctx, "0xaaaaa")
+	assert.Regexp(t, "pop", err)
+
+	mfc.On("NextNonceForSigner", m.ctx, mock.Anything).Return(&ffcapi.NextNonceForSignerResponse{
+		Nonce: fftypes.NewFFBigInt(10005),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	status, err := m.resyncSignerNonce(m.ctx, "0xaaaaa")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10005), status.NodeNextNonce.Int64())
+	assert.Equal(t, int64(10005), status.NextNonce.Int64())
+
+	mfc.AssertExpectations(t)
+	mp.AssertExpectations(t)
+
+}
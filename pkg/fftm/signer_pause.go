@@ -0,0 +1,93 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// checkSignerPause is called on the policy loop before the policy engine is invoked for a transaction.
+// While the signer is paused the transaction is skipped, except for a single probe each interval, so we find
+// out when the signer can submit again without every transaction of the signer failing on every cycle.
+// Deletion requests are always passed through to the policy engine.
+func (m *manager) checkSignerPause(mtx *apitypes.ManagedTX) (skip, probe bool) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	pause := m.pausedSigners[mtx.TransactionHeaders.From]
+	if pause == nil || mtx.DeleteRequested != nil {
+		return false, false
+	}
+	if time.Now().Before(time.Time(*pause.NextProbe)) {
+		return true, false
+	}
+	nextProbe := fftypes.FFTime(time.Now().Add(m.pausedSignerProbeInterval))
+	pause.NextProbe = &nextProbe
+	return false, true
+}
+
+// pauseSigner is called when the policy engine reports an error for a transaction, that means no transaction
+// from the signer can currently be submitted. If the signer is already paused, the probe interval restarts.
+func (m *manager) pauseSigner(ctx context.Context, mtx *apitypes.ManagedTX, reason ffcapi.ErrorReason, err error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	signer := mtx.TransactionHeaders.From
+	pause := m.pausedSigners[signer]
+	if pause == nil {
+		pause = &apitypes.SignerPause{
+			Paused: fftypes.Now(),
+		}
+		m.pausedSigners[signer] = pause
+		log.L(ctx).Warnf("Pausing transactions for signer %s, as transaction %s failed with reason=%s", signer, mtx.ID, reason)
+	}
+	nextProbe := fftypes.FFTime(time.Now().Add(m.pausedSignerProbeInterval))
+	pause.Reason = reason
+	pause.Error = err.Error()
+	pause.TransactionID = mtx.ID
+	pause.NextProbe = &nextProbe
+}
+
+// resumeSigner removes any pause for the signer, returning true if it was paused
+func (m *manager) resumeSigner(ctx context.Context, signer string) bool {
+	m.mux.Lock()
+	_, paused := m.pausedSigners[signer]
+	delete(m.pausedSigners, signer)
+	m.mux.Unlock()
+	if paused {
+		log.L(ctx).Infof("Resuming transactions for signer %s", signer)
+		// Transactions of the signer were skipped while it was paused, so they are all due a policy cycle
+		m.markInflightUpdate()
+	}
+	return paused
+}
+
+// getSignerPause returns a copy of the pause state of a signer, or nil if the signer is not paused
+func (m *manager) getSignerPause(signer string) *apitypes.SignerPause {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	pause := m.pausedSigners[signer]
+	if pause == nil {
+		return nil
+	}
+	pauseCopy := *pause
+	return &pauseCopy
+}
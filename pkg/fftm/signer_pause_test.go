@@ -0,0 +1,155 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/persistencemocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/policyenginemocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/policyengine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestExecPolicyPauseSignerInsufficientFunds(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	m.pausedSignerProbeInterval = 1 * time.Hour
+
+	tx1 := genTestTxn("0xaaaaa", 10001, apitypes.TxStatusPending)
+	tx2 := genTestTxn("0xaaaaa", 10002, apitypes.TxStatusPending)
+	tx3 := genTestTxn("0xbbbbb", 10001, apitypes.TxStatusPending)
+
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	mpe.On("Execute", mock.Anything, mock.Anything, tx1).Return(policyengine.UpdateNo, ffcapi.ErrorReasonInsufficientFunds, fmt.Errorf("insufficient funds")).Once()
+	mpe.On("Execute", mock.Anything, mock.Anything, tx3).Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), nil).Once()
+
+	// The first failure pauses the signer
+	err := m.execPolicy(m.ctx, &pendingState{mtx: tx1}, false)
+	assert.NoError(t, err)
+	assert.Len(t, tx1.ErrorHistory, 1)
+	pause := m.getSignerPause("0xaaaaa")
+	assert.Equal(t, ffcapi.ErrorReasonInsufficientFunds, pause.Reason)
+	assert.Equal(t, tx1.ID, pause.TransactionID)
+	assert.Equal(t, "insufficient funds", pause.Error)
+
+	// Other transactions for the signer are skipped, without adding any errors
+	err = m.execPolicy(m.ctx, &pendingState{mtx: tx2}, false)
+	assert.NoError(t, err)
+	assert.Empty(t, tx2.ErrorHistory)
+
+	// Other signers are unaffected
+	err = m.execPolicy(m.ctx, &pendingState{mtx: tx3}, false)
+	assert.NoError(t, err)
+	assert.Nil(t, m.getSignerPause("0xbbbbb"))
+
+	mpe.AssertExpectations(t)
+
+}
+
+func TestExecPolicyPausedSignerProbe(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	m.pausedSignerProbeInterval = 1 * time.Hour
+
+	tx1 := genTestTxn("0xaaaaa", 10001, apitypes.TxStatusPending)
+	tx2 := genTestTxn("0xaaaaa", 10002, apitypes.TxStatusPending)
+	m.pauseSigner(m.ctx, tx1, ffcapi.ErrorReasonInsufficientFunds, fmt.Errorf("insufficient funds"))
+	pastProbe := fftypes.FFTime(time.Now().Add(-1 * time.Second))
+	m.pausedSigners["0xaaaaa"].NextProbe = &pastProbe
+
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	mpe.On("Execute", mock.Anything, mock.Anything, tx1).Return(policyengine.UpdateNo, ffcapi.ErrorReasonInsufficientFunds, fmt.Errorf("still insufficient funds")).Once()
+	mpe.On("Execute", mock.Anything, mock.Anything, tx2).Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), nil).Once()
+	mpe.On("Execute", mock.Anything, mock.Anything, tx2).Return(policyengine.UpdateYes, ffcapi.ErrorReason(""), nil).Run(func(args mock.Arguments) {
+		tx2.LastSubmit = fftypes.Now()
+	}).Once()
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("WriteTransaction", m.ctx, tx2, false).Return(nil)
+
+	// A failed probe leaves the signer paused, until the next probe
+	pending1 := &pendingState{mtx: tx1, lastPolicyCycle: time.Now()}
+	err := m.execPolicy(m.ctx, pending1, false)
+	assert.NoError(t, err)
+	pause := m.getSignerPause("0xaaaaa")
+	assert.Equal(t, "still insufficient funds", pause.Error)
+	assert.True(t, time.Time(*pause.NextProbe).After(time.Now()))
+
+	err = m.execPolicy(m.ctx, &pendingState{mtx: tx2}, false)
+	assert.NoError(t, err)
+
+	// A probe that does not submit anything leaves the signer paused
+	m.pausedSigners["0xaaaaa"].NextProbe = &pastProbe
+	err = m.execPolicy(m.ctx, &pendingState{mtx: tx2}, false)
+	assert.NoError(t, err)
+	assert.NotNil(t, m.getSignerPause("0xaaaaa"))
+
+	// A successful submission on the probe resumes the signer
+	m.pausedSigners["0xaaaaa"].NextProbe = &pastProbe
+	err = m.execPolicy(m.ctx, &pendingState{mtx: tx2}, false)
+	assert.NoError(t, err)
+	assert.Nil(t, m.getSignerPause("0xaaaaa"))
+
+	mpe.AssertExpectations(t)
+
+}
+
+func TestExecPolicyPausedSignerDelete(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	m.pausedSignerProbeInterval = 1 * time.Hour
+
+	tx := genTestTxn("0xaaaaa", 10001, apitypes.TxStatusPending)
+	m.pauseSigner(m.ctx, tx, ffcapi.ErrorReasonInsufficientFunds, fmt.Errorf("insufficient funds"))
+
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	mpe.On("Execute", mock.Anything, mock.Anything, tx).Return(policyengine.UpdateDelete, ffcapi.ErrorReason(""), nil).Once()
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("DeleteTransaction", m.ctx, tx.ID).Return(nil)
+
+	// Deletion requests are still passed to the policy engine while the signer is paused
+	err := m.execPolicy(m.ctx, &pendingState{mtx: tx}, true)
+	assert.NoError(t, err)
+	assert.NotNil(t, m.getSignerPause("0xaaaaa"))
+
+	mpe.AssertExpectations(t)
+	mp.AssertExpectations(t)
+
+}
+
+func TestResumeSignerNotPaused(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	assert.False(t, m.resumeSigner(m.ctx, "0xaaaaa"))
+
+}
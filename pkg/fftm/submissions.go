@@ -0,0 +1,106 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// submissionRecorderAPI wraps the connector for a single transaction, so that every submission is recorded
+// in the history of the transaction - regardless of which policy engine (or API request) performs the submission
+type submissionRecorderAPI struct {
+	ffcapi.API
+	mtx *apitypes.ManagedTX
+}
+
+func (m *manager) submissionRecorder(mtx *apitypes.ManagedTX) ffcapi.API {
+	return &submissionRecorderAPI{
+		API: m.connector,
+		mtx: mtx,
+	}
+}
+
+func (sr *submissionRecorderAPI) TransactionSend(ctx context.Context, req *ffcapi.TransactionSendRequest) (*ffcapi.TransactionSendResponse, ffcapi.ErrorReason, error) {
+	res, reason, err := sr.API.TransactionSend(ctx, req)
+	addSubmission(sr.mtx, req, res, reason, err)
+	return res, reason, err
+}
+
+// addSubmission records a submission attempt at the front of the history (most recent first).
+// Consecutive identical attempts, such as periodic resubmission of the same transaction, are combined into a single entry.
+func addSubmission(mtx *apitypes.ManagedTX, req *ffcapi.TransactionSendRequest, res *ffcapi.TransactionSendResponse, reason ffcapi.ErrorReason, err error) {
+	now := fftypes.Now()
+	submission := &apitypes.ManagedTXSubmission{
+		Time:        now,
+		LastAttempt: now,
+		Attempts:    1,
+		GasPrice:    req.GasPrice,
+		Reason:      reason,
+	}
+	if err != nil {
+		submission.Error = err.Error()
+	} else if res != nil {
+		submission.TransactionHash = res.TransactionHash
+	}
+	if len(mtx.SubmissionHistory) > 0 {
+		latest := mtx.SubmissionHistory[0]
+		if latest.TransactionHash == submission.TransactionHash &&
+			latest.Reason == submission.Reason &&
+			latest.GasPrice.String() == submission.GasPrice.String() {
+			latest.Attempts++
+			latest.LastAttempt = now
+			latest.Error = submission.Error
+			return
+		}
+	}
+	mtx.SubmissionHistory = append([]*apitypes.ManagedTXSubmission{submission}, mtx.SubmissionHistory...)
+}
+
+// nonceTooLowSince is true if the node rejected a submission of the transaction because the nonce was too low, at or after
+// the given time. Policy engines treat this as success on resubmission when the transaction already has a hash.
+func nonceTooLowSince(mtx *apitypes.ManagedTX, since time.Time) bool {
+	if len(mtx.SubmissionHistory) == 0 {
+		return false
+	}
+	latest := mtx.SubmissionHistory[0]
+	return latest.Reason == ffcapi.ErrorReasonNonceTooLow && latest.LastAttempt != nil && !latest.LastAttempt.Time().Before(since)
+}
+
+// submittedHashes returns every distinct hash that has been submitted for a transaction
+func submittedHashes(mtx *apitypes.ManagedTX) []string {
+	hashes := make([]string, 0, len(mtx.SubmissionHistory)+2)
+	unique := make(map[string]bool)
+	add := func(txHash string) {
+		if txHash != "" && !unique[txHash] {
+			unique[txHash] = true
+			hashes = append(hashes, txHash)
+		}
+	}
+	add(mtx.TransactionHash)
+	for _, submission := range mtx.SubmissionHistory {
+		add(submission.TransactionHash)
+	}
+	if mtx.Cancellation != nil {
+		add(mtx.Cancellation.TransactionHash)
+	}
+	return hashes
+}
@@ -0,0 +1,154 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/internal/confirmations"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/confirmationsmocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSubmissionRecorderSendOk(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	mtx := genTestSubmittedTxn()
+	req := &ffcapi.TransactionSendRequest{GasPrice: fftypes.JSONAnyPtr(`"1000"`)}
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("TransactionSend", mock.Anything, req).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x67890",
+	}, ffcapi.ErrorReason(""), nil).Twice()
+
+	res, _, err := m.submissionRecorder(mtx).TransactionSend(m.ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, "0x67890", res.TransactionHash)
+	assert.Len(t, mtx.SubmissionHistory, 1)
+	assert.Equal(t, "0x67890", mtx.SubmissionHistory[0].TransactionHash)
+	assert.Equal(t, `"1000"`, mtx.SubmissionHistory[0].GasPrice.String())
+	assert.Equal(t, 1, mtx.SubmissionHistory[0].Attempts)
+
+	// Identical resubmission is combined into the same entry
+	_, _, err = m.submissionRecorder(mtx).TransactionSend(m.ctx, req)
+	assert.NoError(t, err)
+	assert.Len(t, mtx.SubmissionHistory, 1)
+	assert.Equal(t, 2, mtx.SubmissionHistory[0].Attempts)
+
+	mfc.AssertExpectations(t)
+}
+
+func TestSubmissionRecorderSendFail(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	mtx := genTestSubmittedTxn()
+	mtx.SubmissionHistory = []*apitypes.ManagedTXSubmission{
+		{TransactionHash: "0x12345", GasPrice: fftypes.JSONAnyPtr(`"1000"`), Attempts: 1},
+	}
+	req := &ffcapi.TransactionSendRequest{GasPrice: fftypes.JSONAnyPtr(`"1100"`)}
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("TransactionSend", mock.Anything, req).Return(nil, ffcapi.ErrorReasonTransactionUnderpriced, fmt.Errorf("pop"))
+
+	_, reason, err := m.submissionRecorder(mtx).TransactionSend(m.ctx, req)
+	assert.Regexp(t, "pop", err)
+	assert.Equal(t, ffcapi.ErrorReasonTransactionUnderpriced, reason)
+	assert.Len(t, mtx.SubmissionHistory, 2)
+	assert.Empty(t, mtx.SubmissionHistory[0].TransactionHash)
+	assert.Equal(t, "pop", mtx.SubmissionHistory[0].Error)
+	assert.Equal(t, ffcapi.ErrorReasonTransactionUnderpriced, mtx.SubmissionHistory[0].Reason)
+	assert.Equal(t, "0x12345", mtx.SubmissionHistory[1].TransactionHash)
+
+	mfc.AssertExpectations(t)
+}
+
+func TestSubmittedHashes(t *testing.T) {
+
+	mtx := genTestSubmittedTxn()
+	mtx.SubmissionHistory = []*apitypes.ManagedTXSubmission{
+		{TransactionHash: "0x67890"},
+		{Error: "pop"},
+		{TransactionHash: "0x12345"},
+	}
+	mtx.Cancellation = &apitypes.ManagedTXCancellation{
+		TransactionHash: "0xabcde",
+	}
+
+	assert.Equal(t, []string{"0x12345", "0x67890", "0xabcde"}, submittedHashes(mtx))
+}
+
+func TestTrackEarlierSubmissionMined(t *testing.T) {
+
+	_, m, cancel := newTestManager(t)
+	defer cancel()
+
+	mtx := genTestSubmittedTxn()
+	mtx.TransactionHash = "0x67890"
+	mtx.SubmissionHistory = []*apitypes.ManagedTXSubmission{
+		{TransactionHash: "0x67890"},
+		{TransactionHash: "0x12345"},
+	}
+	pending := &pendingState{mtx: mtx}
+
+	mc := m.confirmations.(*confirmationsmocks.Manager)
+	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
+		return n.NotificationType == confirmations.NewTransaction &&
+			n.Transaction.TransactionHash == "0x67890"
+	})).Return(nil)
+	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
+		return n.NotificationType == confirmations.NewTransaction &&
+			n.Transaction.TransactionHash == "0x12345"
+	})).Run(func(args mock.Arguments) {
+		// The earlier submission is the one that gets mined
+		n := args[0].(*confirmations.Notification)
+		n.Transaction.Receipt(context.Background(), &ffcapi.TransactionReceiptResponse{
+			BlockNumber: fftypes.NewFFBigInt(12345),
+			Success:     true,
+		})
+		n.Transaction.Confirmed(context.Background(), []apitypes.BlockInfo{})
+	}).Return(nil)
+	mc.On("Notify", mock.MatchedBy(func(n *confirmations.Notification) bool {
+		return n.NotificationType == confirmations.RemovedTransaction &&
+			n.Transaction.TransactionHash == "0x67890"
+	})).Return(nil).Once()
+
+	m.trackSubmissions(m.ctx, pending)
+	assert.Len(t, pending.trackedHashes, 2)
+	assert.Equal(t, "0x12345", pending.minedHash)
+	assert.Equal(t, "0x67890", mtx.TransactionHash) // only updated on the policy loop
+	assert.True(t, pending.confirmed)
+
+	// The mined hash is copied across, and only the hash that was not mined is removed on completion
+	err := m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "0x12345", mtx.TransactionHash)
+	assert.Equal(t, apitypes.TxStatusSucceeded, mtx.Status)
+	assert.Nil(t, pending.trackedHashes)
+
+	mc.AssertExpectations(t)
+}
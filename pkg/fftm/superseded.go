@@ -0,0 +1,134 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"context"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+)
+
+// confirmedNonce is the result of querying the confirmed nonce of a signer, kept for the rest of the policy loop cycle
+type confirmedNonce struct {
+	nonce *fftypes.FFBigInt
+	err   error
+}
+
+func (m *manager) resetConfirmedNonces() {
+	m.confirmedNonceMux.Lock()
+	defer m.confirmedNonceMux.Unlock()
+	m.confirmedNonces = make(map[string]*confirmedNonce)
+}
+
+// getConfirmedNonce queries the next nonce of a signer as of the latest block, at most once per policy loop cycle.
+// Returns nil if the connector does not support the query.
+func (m *manager) getConfirmedNonce(ctx context.Context, signer string) (*fftypes.FFBigInt, error) {
+	cna, ok := m.connector.(ffcapi.ConfirmedNonceAPI)
+	if !ok {
+		return nil, nil
+	}
+	m.confirmedNonceMux.Lock()
+	cn := m.confirmedNonces[signer]
+	m.confirmedNonceMux.Unlock()
+	if cn == nil {
+		res, _, err := cna.ConfirmedNonceForSigner(ctx, &ffcapi.ConfirmedNonceForSignerRequest{
+			Signer: signer,
+		})
+		cn = &confirmedNonce{err: err}
+		if err == nil {
+			cn.nonce = res.Nonce
+		}
+		m.confirmedNonceMux.Lock()
+		m.confirmedNonces[signer] = cn
+		m.confirmedNonceMux.Unlock()
+	}
+	return cn.nonce, cn.err
+}
+
+// supersededCheckDue is true for an in-flight transaction without a receipt, when the node has rejected a submission because
+// the nonce is too low, or the transaction has gone the check interval since it was first submitted. Once the nonce has been
+// found to be used by another transaction, we check on every policy cycle until the grace period has passed.
+func (m *manager) supersededCheckDue(pending *pendingState, nonceTooLow bool) bool {
+	mtx := pending.mtx
+	m.mux.Lock()
+	mined := mtx.Receipt != nil
+	m.mux.Unlock()
+	if mined {
+		return false
+	}
+	return nonceTooLow ||
+		!pending.supersededSince.IsZero() ||
+		(mtx.FirstSubmit != nil && time.Since(*mtx.FirstSubmit.Time()) > m.supersededCheckInterval)
+}
+
+// checkSuperseded determines whether the nonce of an in-flight transaction has been used by another system signing with the same key,
+// in which case the transaction can never be mined. The nonce is used if it is below the confirmed nonce of the signer, and none of our
+// submitted hashes has a receipt. As the receipt for our own transaction might not yet be available from the node, this must hold for
+// the grace period before we return true.
+func (m *manager) checkSuperseded(ctx context.Context, pending *pendingState, nonceTooLow bool) (bool, error) {
+	mtx := pending.mtx
+	signerNonce, err := m.getConfirmedNonce(ctx, mtx.TransactionHeaders.From)
+	if err != nil {
+		return false, err
+	}
+	nonceUsed := nonceTooLow
+	switch {
+	case signerNonce != nil:
+		nonceUsed = signerNonce.Uint64() > mtx.Nonce.Uint64()
+	case !nonceUsed:
+		// Without the confirmed nonce from the connector, only a nonce too low error from the node tells us anything
+		return false, nil
+	}
+	if nonceUsed {
+		// If the nonce was used by one of our own submissions, the confirmations manager will find the receipt
+		hasReceipt, err := m.hasSubmissionReceipt(ctx, mtx)
+		if err != nil {
+			return false, err
+		}
+		nonceUsed = !hasReceipt
+	}
+	if !nonceUsed {
+		pending.supersededSince = time.Time{}
+		return false, nil
+	}
+	if pending.supersededSince.IsZero() {
+		log.L(ctx).Warnf("Nonce %s / %s of transaction %s used by another transaction - waiting %s for a receipt", mtx.TransactionHeaders.From, mtx.Nonce, mtx.ID, m.supersededGracePeriod)
+		pending.supersededSince = time.Now()
+	}
+	return time.Since(pending.supersededSince) >= m.supersededGracePeriod, nil
+}
+
+func (m *manager) hasSubmissionReceipt(ctx context.Context, mtx *apitypes.ManagedTX) (bool, error) {
+	for _, txHash := range submittedHashes(mtx) {
+		receipt, reason, err := m.connector.TransactionReceipt(ctx, &ffcapi.TransactionReceiptRequest{
+			TransactionHash: txHash,
+		})
+		switch {
+		case err != nil && reason == ffcapi.ErrorReasonNotFound:
+			continue
+		case err != nil:
+			return false, err
+		case receipt != nil:
+			return true, nil
+		}
+	}
+	return false, nil
+}
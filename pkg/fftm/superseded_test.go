@@ -0,0 +1,351 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftm
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/persistencemocks"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/policyenginemocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/policyengine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type testConfirmedNonceConnector struct {
+	*ffcapimocks.API
+	*ffcapimocks.ConfirmedNonceAPI
+}
+
+// mockConfirmedNonce switches the connector to one that supports ConfirmedNonceForSigner
+func mockConfirmedNonce(m *manager) (*ffcapimocks.API, *ffcapimocks.ConfirmedNonceAPI) {
+	mfc := m.connector.(*ffcapimocks.API)
+	mcn := &ffcapimocks.ConfirmedNonceAPI{}
+	m.connector = &testConfirmedNonceConnector{API: mfc, ConfirmedNonceAPI: mcn}
+	return mfc, mcn
+}
+
+func mockNonceTooLow(m *manager, tx *apitypes.ManagedTX) *policyenginemocks.PolicyEngine {
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	mpe.On("Execute", mock.Anything, mock.Anything, tx).Return(policyengine.UpdateNo, ffcapi.ErrorReasonNonceTooLow, fmt.Errorf("nonce too low")).Once()
+	return mpe
+}
+
+func mockEngineNoop(m *manager, tx *apitypes.ManagedTX) *policyenginemocks.PolicyEngine {
+	mpe := &policyenginemocks.PolicyEngine{}
+	m.policyEngine = mpe
+	mpe.On("Execute", mock.Anything, mock.Anything, tx).Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), nil)
+	return mpe
+}
+
+func genTestStaleSubmittedTxn() *apitypes.ManagedTX {
+	tx := genTestSubmittedTxn()
+	firstSubmit := fftypes.FFTime(time.Now().Add(-1 * time.Hour))
+	tx.FirstSubmit = &firstSubmit
+	tx.LastSubmit = &firstSubmit
+	return tx
+}
+
+func TestExecPolicySuperseded(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	m.supersededGracePeriod = 0
+
+	tx := genTestSubmittedTxn()
+	tx.SubmissionHistory = []*apitypes.ManagedTXSubmission{
+		{TransactionHash: "0x12345"},
+		{TransactionHash: "0x23456"},
+	}
+	pending := &pendingState{mtx: tx}
+	mpe := mockNonceTooLow(m, tx)
+
+	mfc, mcn := mockConfirmedNonce(m)
+	mcn.On("ConfirmedNonceForSigner", m.ctx, &ffcapi.ConfirmedNonceForSignerRequest{Signer: "0xabcd1234"}).
+		Return(&ffcapi.ConfirmedNonceForSignerResponse{Nonce: fftypes.NewFFBigInt(12346)}, ffcapi.ErrorReason(""), nil).Once()
+	mfc.On("TransactionReceipt", m.ctx, mock.Anything).
+		Return(nil, ffcapi.ErrorReasonNotFound, fmt.Errorf("not found")).Twice()
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("WriteTransaction", m.ctx, tx, false).Return(nil)
+
+	err := m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.True(t, pending.remove)
+	assert.Equal(t, apitypes.TxStatusSuperseded, tx.Status)
+	assert.Regexp(t, "FF21083.*2 transaction hashes", tx.ErrorMessage)
+	assert.Equal(t, ffcapi.ErrorReasonNonceTooLow, tx.ErrorHistory[0].Mapped)
+
+	mfc.AssertExpectations(t)
+	mcn.AssertExpectations(t)
+	mp.AssertExpectations(t)
+	mpe.AssertExpectations(t)
+
+}
+
+func TestExecPolicySupersededAfterGracePeriod(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	tx := genTestSubmittedTxn()
+	pending := &pendingState{mtx: tx}
+	mpe := mockNonceTooLow(m, tx)
+	mpe.On("Execute", mock.Anything, mock.Anything, tx).Return(policyengine.UpdateNo, ffcapi.ErrorReason(""), nil).Once()
+
+	mfc, mcn := mockConfirmedNonce(m)
+	mcn.On("ConfirmedNonceForSigner", m.ctx, mock.Anything).
+		Return(&ffcapi.ConfirmedNonceForSignerResponse{Nonce: fftypes.NewFFBigInt(12346)}, ffcapi.ErrorReason(""), nil).Twice()
+	mfc.On("TransactionReceipt", m.ctx, &ffcapi.TransactionReceiptRequest{TransactionHash: "0x12345"}).
+		Return(nil, ffcapi.ErrorReasonNotFound, fmt.Errorf("not found")).Twice()
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("WriteTransaction", m.ctx, tx, false).Return(nil)
+
+	// The receipt for our own transaction might not be available yet, so we wait
+	err := m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.False(t, pending.remove)
+	assert.Equal(t, apitypes.TxStatusPending, tx.Status)
+	assert.False(t, pending.supersededSince.IsZero())
+
+	// We keep checking on each cycle, until the grace period has passed
+	m.resetConfirmedNonces()
+	pending.lastPolicyCycle = time.Time{}
+	pending.supersededSince = time.Now().Add(-2 * m.supersededGracePeriod)
+	err = m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.True(t, pending.remove)
+	assert.Equal(t, apitypes.TxStatusSuperseded, tx.Status)
+	assert.Regexp(t, "FF21083.*1 transaction hashes", tx.ErrorMessage)
+
+	mfc.AssertExpectations(t)
+	mcn.AssertExpectations(t)
+	mpe.AssertExpectations(t)
+
+}
+
+func TestExecPolicySupersededEngineSuccess(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+	m.supersededGracePeriod = 0
+
+	// The simple policy engine treats nonce too low on resubmission as success, as it has a hash.
+	// The connector cannot give us the confirmed nonce, so we rely on the recorded submission.
+	tx := genTestStaleSubmittedTxn()
+	pending := &pendingState{mtx: tx}
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.Nonce.Int64() == 12345
+	})).Return(nil, ffcapi.ErrorReasonNonceTooLow, fmt.Errorf("nonce too low")).Once()
+	mfc.On("TransactionReceipt", m.ctx, &ffcapi.TransactionReceiptRequest{TransactionHash: "0x12345"}).
+		Return(nil, ffcapi.ErrorReasonNotFound, fmt.Errorf("not found")).Once()
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("WriteTransaction", m.ctx, tx, false).Return(nil)
+
+	err := m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.True(t, pending.remove)
+	assert.Equal(t, apitypes.TxStatusSuperseded, tx.Status)
+	assert.Regexp(t, "FF21083.*1 transaction hashes", tx.ErrorMessage)
+
+	mfc.AssertExpectations(t)
+	mp.AssertExpectations(t)
+
+}
+
+func TestExecPolicyNotCheckedWhileRecentlySubmitted(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	// Our own transaction in the node's transaction pool is not checked until the interval has passed
+	tx := genTestSubmittedTxn()
+	pending := &pendingState{mtx: tx}
+	mpe := mockEngineNoop(m, tx)
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("WriteTransaction", m.ctx, tx, false).Return(nil)
+	_, mcn := mockConfirmedNonce(m)
+
+	err := m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.False(t, pending.remove)
+	assert.Equal(t, apitypes.TxStatusPending, tx.Status)
+
+	mcn.AssertNotCalled(t, "ConfirmedNonceForSigner", mock.Anything, mock.Anything)
+	mpe.AssertExpectations(t)
+
+}
+
+func TestExecPolicyNotCheckedWithoutConfirmedNonce(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	// Without the confirmed nonce or a nonce too low error, we have nothing to go on - and we must not use the
+	// next nonce of the node, as that includes our own transaction in its transaction pool
+	tx := genTestStaleSubmittedTxn()
+	pending := &pendingState{mtx: tx}
+	pending.supersededSince = time.Now()
+	mpe := mockEngineNoop(m, tx)
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("WriteTransaction", m.ctx, tx, false).Return(nil)
+
+	err := m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.False(t, pending.remove)
+	assert.Equal(t, apitypes.TxStatusPending, tx.Status)
+	assert.False(t, pending.supersededSince.IsZero())
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.AssertNotCalled(t, "NextNonceForSigner", mock.Anything, mock.Anything)
+	mpe.AssertExpectations(t)
+
+}
+
+func TestExecPolicyStaleNodeBehind(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	tx := genTestStaleSubmittedTxn()
+	pending := &pendingState{mtx: tx}
+	mpe := mockEngineNoop(m, tx)
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("WriteTransaction", m.ctx, tx, false).Return(nil)
+
+	// The confirmed nonce has not moved past ours, so there is no need to look for receipts
+	_, mcn := mockConfirmedNonce(m)
+	mcn.On("ConfirmedNonceForSigner", m.ctx, mock.Anything).
+		Return(&ffcapi.ConfirmedNonceForSignerResponse{Nonce: fftypes.NewFFBigInt(12345)}, ffcapi.ErrorReason(""), nil).Once()
+
+	err := m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.False(t, pending.remove)
+	assert.Equal(t, apitypes.TxStatusPending, tx.Status)
+
+	mcn.AssertExpectations(t)
+	mpe.AssertExpectations(t)
+
+}
+
+func TestExecPolicyNonceTooLowOwnReceipt(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	tx := genTestSubmittedTxn()
+	pending := &pendingState{mtx: tx}
+	pending.supersededSince = time.Now()
+	mpe := mockNonceTooLow(m, tx)
+
+	mfc, mcn := mockConfirmedNonce(m)
+	mcn.On("ConfirmedNonceForSigner", m.ctx, mock.Anything).
+		Return(&ffcapi.ConfirmedNonceForSignerResponse{Nonce: fftypes.NewFFBigInt(12346)}, ffcapi.ErrorReason(""), nil)
+	mfc.On("TransactionReceipt", m.ctx, &ffcapi.TransactionReceiptRequest{TransactionHash: "0x12345"}).
+		Return(&ffcapi.TransactionReceiptResponse{}, ffcapi.ErrorReason(""), nil)
+
+	err := m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.False(t, pending.remove)
+	assert.Equal(t, apitypes.TxStatusPending, tx.Status)
+	assert.True(t, pending.supersededSince.IsZero())
+
+	mfc.AssertExpectations(t)
+	mcn.AssertExpectations(t)
+	mpe.AssertExpectations(t)
+
+}
+
+func TestExecPolicyNonceTooLowCheckFail(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	tx := genTestSubmittedTxn()
+	pending := &pendingState{mtx: tx}
+	mpe := mockNonceTooLow(m, tx)
+
+	_, mcn := mockConfirmedNonce(m)
+	mcn.On("ConfirmedNonceForSigner", m.ctx, mock.Anything).
+		Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+
+	err := m.execPolicy(m.ctx, pending, false)
+	assert.NoError(t, err)
+	assert.False(t, pending.remove)
+	assert.Equal(t, apitypes.TxStatusPending, tx.Status)
+
+	mcn.AssertExpectations(t)
+	mpe.AssertExpectations(t)
+
+}
+
+func TestConfirmedNonceQueriedOncePerCycle(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	_, mcn := mockConfirmedNonce(m)
+	mcn.On("ConfirmedNonceForSigner", m.ctx, &ffcapi.ConfirmedNonceForSignerRequest{Signer: "0xaaaaa"}).
+		Return(&ffcapi.ConfirmedNonceForSignerResponse{Nonce: fftypes.NewFFBigInt(1000)}, ffcapi.ErrorReason(""), nil).Once()
+	mcn.On("ConfirmedNonceForSigner", m.ctx, &ffcapi.ConfirmedNonceForSignerRequest{Signer: "0xbbbbb"}).
+		Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop")).Once()
+
+	for i := 0; i < 2; i++ {
+		nonce, err := m.getConfirmedNonce(m.ctx, "0xaaaaa")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1000), nonce.Int64())
+		_, err = m.getConfirmedNonce(m.ctx, "0xbbbbb")
+		assert.Regexp(t, "pop", err)
+	}
+	mcn.AssertExpectations(t)
+
+	// Queried again on the next cycle
+	mcn.On("ConfirmedNonceForSigner", m.ctx, &ffcapi.ConfirmedNonceForSignerRequest{Signer: "0xaaaaa"}).
+		Return(&ffcapi.ConfirmedNonceForSignerResponse{Nonce: fftypes.NewFFBigInt(1001)}, ffcapi.ErrorReason(""), nil).Once()
+	m.resetConfirmedNonces()
+	nonce, err := m.getConfirmedNonce(m.ctx, "0xaaaaa")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1001), nonce.Int64())
+
+}
+
+func TestCheckSupersededReceiptFail(t *testing.T) {
+
+	_, m, cancel := newTestManagerMockPersistence(t)
+	defer cancel()
+
+	tx := genTestSubmittedTxn()
+
+	mfc := m.connector.(*ffcapimocks.API)
+	mfc.On("TransactionReceipt", m.ctx, mock.Anything).
+		Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+
+	_, err := m.checkSuperseded(m.ctx, &pendingState{mtx: tx}, true)
+	assert.Regexp(t, "pop", err)
+
+}
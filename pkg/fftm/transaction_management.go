@@ -38,19 +38,25 @@ func (m *manager) getTransactionByID(ctx context.Context, txID string) (transact
 	return tx, nil
 }
 
-func (m *manager) getTransactions(ctx context.Context, afterStr, limitStr, signer string, pending bool, dirString string) (transactions []*apitypes.ManagedTX, err error) {
-	limit, err := m.parseLimit(ctx, limitStr)
-	if err != nil {
-		return nil, err
-	}
-	var dir persistence.SortDirection
+func (m *manager) parseSortDirection(ctx context.Context, dirString string) (persistence.SortDirection, error) {
 	switch strings.ToLower(dirString) {
 	case "", "desc", "descending":
-		dir = persistence.SortDirectionDescending // descending is default
+		return persistence.SortDirectionDescending, nil // descending is default
 	case "asc", "ascending":
-		dir = persistence.SortDirectionAscending
+		return persistence.SortDirectionAscending, nil
 	default:
-		return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidSortDirection, dirString)
+		return -1, i18n.NewError(ctx, tmmsgs.MsgInvalidSortDirection, dirString)
+	}
+}
+
+func (m *manager) getTransactions(ctx context.Context, afterStr, limitStr, signer string, pending bool, subStatus, dirString string) (transactions []*apitypes.ManagedTX, err error) {
+	limit, err := m.parseLimit(ctx, limitStr)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := m.parseSortDirection(ctx, dirString)
+	if err != nil {
+		return nil, err
 	}
 	var afterTx *apitypes.ManagedTX
 	if afterStr != "" {
@@ -63,27 +69,70 @@ func (m *manager) getTransactions(ctx context.Context, afterStr, limitStr, signe
 			return nil, i18n.NewError(ctx, tmmsgs.MsgPaginationErrTxNotFound, afterStr)
 		}
 	}
+	var list func(afterTx *apitypes.ManagedTX, pageLimit int) ([]*apitypes.ManagedTX, error)
 	switch {
 	case signer != "" && pending:
 		return nil, i18n.NewError(ctx, tmmsgs.MsgTXConflictSignerPending)
 	case signer != "":
-		var afterNonce *fftypes.FFBigInt
-		if afterTx != nil {
-			afterNonce = afterTx.Nonce
+		list = func(afterTx *apitypes.ManagedTX, pageLimit int) ([]*apitypes.ManagedTX, error) {
+			var afterNonce *fftypes.FFBigInt
+			if afterTx != nil {
+				afterNonce = afterTx.Nonce
+			}
+			return m.persistence.ListTransactionsByNonce(ctx, signer, afterNonce, pageLimit, dir)
 		}
-		return m.persistence.ListTransactionsByNonce(ctx, signer, afterNonce, limit, dir)
 	case pending:
-		var afterSequence *fftypes.UUID
-		if afterTx != nil {
-			afterSequence = afterTx.SequenceID
+		list = func(afterTx *apitypes.ManagedTX, pageLimit int) ([]*apitypes.ManagedTX, error) {
+			var afterSequence *fftypes.UUID
+			if afterTx != nil {
+				afterSequence = afterTx.SequenceID
+			}
+			return m.persistence.ListTransactionsPending(ctx, afterSequence, pageLimit, dir)
 		}
-		return m.persistence.ListTransactionsPending(ctx, afterSequence, limit, dir)
 	default:
-		return m.persistence.ListTransactionsByCreateTime(ctx, afterTx, limit, dir)
+		list = func(afterTx *apitypes.ManagedTX, pageLimit int) ([]*apitypes.ManagedTX, error) {
+			return m.persistence.ListTransactionsByCreateTime(ctx, afterTx, pageLimit, dir)
+		}
+	}
+	if subStatus == "" {
+		return list(afterTx, limit)
 	}
+	return filterTransactionsBySubStatus(list, afterTx, limit, m.subStatusScanLimit, apitypes.TxSubStatus(subStatus))
 
 }
 
+// filterTransactionsBySubStatus pages through the transactions using the chosen index, until it has found the
+// requested number of transactions with the sub-status. There is no index for sub-status, as it changes frequently
+// through the lifecycle of every transaction. So the scan stops after scanLimit transactions, to bound the cost of
+// a query for a sub-status that few transactions have - matches beyond that are only found by a query using 'after'.
+func filterTransactionsBySubStatus(list func(afterTx *apitypes.ManagedTX, pageLimit int) ([]*apitypes.ManagedTX, error), afterTx *apitypes.ManagedTX, limit, scanLimit int, subStatus apitypes.TxSubStatus) ([]*apitypes.ManagedTX, error) {
+	transactions := make([]*apitypes.ManagedTX, 0)
+	for scanned := 0; scanned < scanLimit; {
+		pageLimit := scanLimit - scanned
+		if limit > 0 && limit < pageLimit {
+			pageLimit = limit
+		}
+		page, err := list(afterTx, pageLimit)
+		if err != nil {
+			return nil, err
+		}
+		scanned += len(page)
+		for _, tx := range page {
+			if strings.EqualFold(string(tx.SubStatus), string(subStatus)) {
+				transactions = append(transactions, tx)
+				if len(transactions) == limit {
+					return transactions, nil
+				}
+			}
+		}
+		if len(page) < pageLimit {
+			break
+		}
+		afterTx = page[len(page)-1]
+	}
+	return transactions, nil
+}
+
 func (m *manager) requestTransactionDeletion(ctx context.Context, txID string) (status int, transaction *apitypes.ManagedTX, err error) {
 	res := m.policyEngineAPIRequest(ctx, &policyEngineAPIRequest{
 		requestType: policyEngineAPIRequestTypeDelete,
@@ -91,3 +140,12 @@ func (m *manager) requestTransactionDeletion(ctx context.Context, txID string) (
 	})
 	return res.status, res.tx, res.err
 }
+
+func (m *manager) requestTransactionSpeedUp(ctx context.Context, txID string, speedUp *apitypes.SpeedUpRequest) (transaction *apitypes.ManagedTX, err error) {
+	res := m.policyEngineAPIRequest(ctx, &policyEngineAPIRequest{
+		requestType: policyEngineAPIRequestTypeSpeedUp,
+		txID:        txID,
+		speedUp:     speedUp,
+	})
+	return res.tx, res.err
+}
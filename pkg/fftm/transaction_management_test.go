@@ -20,7 +20,9 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/hyperledger/firefly-transaction-manager/internal/persistence"
 	"github.com/hyperledger/firefly-transaction-manager/mocks/persistencemocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -55,21 +57,94 @@ func TestGetTransactionsErrors(t *testing.T) {
 	mp.On("GetTransactionByID", m.ctx, mock.Anything).Return(nil, nil).Once()
 	mp.On("Close", mock.Anything).Return(nil).Maybe()
 
-	_, err := m.getTransactions(m.ctx, "", "bad limit", "", false, "")
+	_, err := m.getTransactions(m.ctx, "", "bad limit", "", false, "", "")
 	assert.Regexp(t, "FF21044", err)
 
-	_, err = m.getTransactions(m.ctx, "", "", "", false, "wrong")
+	_, err = m.getTransactions(m.ctx, "", "", "", false, "", "wrong")
 	assert.Regexp(t, "FF21064", err)
 
-	_, err = m.getTransactions(m.ctx, "", "", "cannot be specified with pending", true, "")
+	_, err = m.getTransactions(m.ctx, "", "", "cannot be specified with pending", true, "", "")
 	assert.Regexp(t, "FF21063", err)
 
-	_, err = m.getTransactions(m.ctx, "after-causes-failure", "", "", false, "")
+	_, err = m.getTransactions(m.ctx, "after-causes-failure", "", "", false, "", "")
 	assert.Regexp(t, "pop", err)
 
-	_, err = m.getTransactions(m.ctx, "after-not-found", "", "", false, "")
+	_, err = m.getTransactions(m.ctx, "after-not-found", "", "", false, "", "")
 	assert.Regexp(t, "FF21062", err)
 
 	mp.AssertExpectations(t)
 
 }
+
+func TestGetTransactionsSubStatus(t *testing.T) {
+
+	_, m, close := newTestManager(t)
+	defer close()
+
+	newSubStatusTxn := func(nonce int64, subStatus apitypes.TxSubStatus) *apitypes.ManagedTX {
+		tx := genTestTxn("0xaaaaa", nonce, apitypes.TxStatusPending)
+		setSubStatus(tx, subStatus)
+		err := m.persistence.WriteTransaction(m.ctx, tx, true)
+		assert.NoError(t, err)
+		return tx
+	}
+	t1 := newSubStatusTxn(10001, apitypes.TxSubStatusMined)
+	_ = newSubStatusTxn(10002, apitypes.TxSubStatusSubmitted)
+	t3 := newSubStatusTxn(10003, apitypes.TxSubStatusQueued)
+	t4 := newSubStatusTxn(10004, apitypes.TxSubStatusMined)
+
+	// Pages through the index until enough matches are found
+	transactions, err := m.getTransactions(m.ctx, "", "1", "0xaaaaa", false, "mined", "")
+	assert.NoError(t, err)
+	assert.Len(t, transactions, 1)
+	assert.Equal(t, t4.ID, transactions[0].ID)
+	assert.Equal(t, apitypes.TxSubStatusMined, transactions[0].SubStatusHistory[0].SubStatus)
+
+	transactions, err = m.getTransactions(m.ctx, t4.ID, "1", "0xaaaaa", false, "Mined", "")
+	assert.NoError(t, err)
+	assert.Len(t, transactions, 1)
+	assert.Equal(t, t1.ID, transactions[0].ID)
+
+	transactions, err = m.getTransactions(m.ctx, t1.ID, "1", "0xaaaaa", false, "Mined", "")
+	assert.NoError(t, err)
+	assert.Empty(t, transactions)
+
+	// No limit
+	transactions, err = m.getTransactions(m.ctx, "", "", "", true, "Mined", "asc")
+	assert.NoError(t, err)
+	assert.Len(t, transactions, 2)
+	assert.Equal(t, t1.ID, transactions[0].ID)
+
+	transactions, err = m.getTransactions(m.ctx, "", "", "", false, "Confirmed", "")
+	assert.NoError(t, err)
+	assert.Empty(t, transactions)
+
+	// The scan is bounded, so later matches are only found by continuing after the scanned transactions
+	m.subStatusScanLimit = 2
+	transactions, err = m.getTransactions(m.ctx, "", "", "0xaaaaa", false, "Mined", "")
+	assert.NoError(t, err)
+	assert.Len(t, transactions, 1)
+	assert.Equal(t, t4.ID, transactions[0].ID)
+
+	transactions, err = m.getTransactions(m.ctx, t3.ID, "5", "0xaaaaa", false, "Mined", "")
+	assert.NoError(t, err)
+	assert.Len(t, transactions, 1)
+	assert.Equal(t, t1.ID, transactions[0].ID)
+
+}
+
+func TestGetTransactionsSubStatusListFail(t *testing.T) {
+
+	_, m, close := newTestManagerMockPersistence(t)
+	defer close()
+
+	mp := m.persistence.(*persistencemocks.Persistence)
+	mp.On("ListTransactionsByCreateTime", m.ctx, (*apitypes.ManagedTX)(nil), 10, persistence.SortDirectionDescending).Return(nil, fmt.Errorf("pop"))
+	mp.On("Close", mock.Anything).Return(nil).Maybe()
+
+	_, err := m.getTransactions(m.ctx, "", "10", "", false, "Mined", "")
+	assert.Regexp(t, "pop", err)
+
+	mp.AssertExpectations(t)
+
+}
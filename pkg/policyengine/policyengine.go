@@ -32,6 +32,13 @@ const (
 	UpdateDelete                   // Instructs that the transaction should be removed completely from persistence - generally only returned when TX status is TxStatusDeleteRequested
 )
 
+// PolicyEngine is invoked by the policy loop for each in-flight transaction. The policy loop can be configured
+// with multiple workers, in which case Execute is called in parallel for transactions from different signers.
+// Transactions from the same signer are always executed by the same worker, in nonce order.
+//
+// When a speed-up is requested via the API, Execute is called straight away with SpeedUpGasPrice set on the
+// submitted transaction. The policy engine should resubmit at that gas price, and update its own state so that
+// the resubmission is taken into account in later executions.
 type PolicyEngine interface {
 	Execute(ctx context.Context, cAPI ffcapi.API, mtx *apitypes.ManagedTX) (updateType UpdateType, reason ffcapi.ErrorReason, err error)
 }
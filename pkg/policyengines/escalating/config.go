@@ -0,0 +1,56 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package escalating
+
+import (
+	"github.com/hyperledger/firefly-common/pkg/config"
+)
+
+const (
+	FixedGasPrice          = "fixedGasPrice"      // the gas price used for the first submission when not using a gas oracle - treated as raw JSON, so can be numeric 123, or string "123", or object {"maxPriorityFeePerGas":123})
+	ResubmitInterval       = "resubmitInterval"   // if mining has not occurred after this interval, the gas price is escalated and the TX resubmitted
+	IncreasePercentage     = "increasePercentage" // percentage increase applied to the gas price on each escalation
+	IncreaseStep           = "increaseStep"       // fixed amount added to the gas price on each escalation (after the percentage increase)
+	MaxGasPrice            = "maxGasPrice"        // ceiling for the escalation - applied to each numeric field of an object gas price
+	GasOracleConfig        = "gasOracle"
+	GasOracleMode          = "mode"
+	GasOracleQueryInterval = "queryInterval"
+)
+
+const (
+	GasOracleModeDisabled  = "disabled"
+	GasOracleModeConnector = "connector"
+)
+
+const (
+	defaultResubmitInterval       = "5m"
+	defaultIncreasePercentage     = 10
+	defaultGasOracleQueryInterval = "5m"
+	defaultGasOracleMode          = GasOracleModeConnector
+)
+
+func (f *PolicyEngineFactory) InitConfig(conf config.Section) {
+	conf.AddKnownKey(FixedGasPrice)
+	conf.AddKnownKey(ResubmitInterval, defaultResubmitInterval)
+	conf.AddKnownKey(IncreasePercentage, defaultIncreasePercentage)
+	conf.AddKnownKey(IncreaseStep)
+	conf.AddKnownKey(MaxGasPrice)
+
+	gasOracleConfig := conf.SubSection(GasOracleConfig)
+	gasOracleConfig.AddKnownKey(GasOracleMode, defaultGasOracleMode)
+	gasOracleConfig.AddKnownKey(GasOracleQueryInterval, defaultGasOracleQueryInterval)
+}
@@ -0,0 +1,288 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package escalating
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-common/pkg/i18n"
+	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/gasprice"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/policyengine"
+)
+
+type PolicyEngineFactory struct{}
+
+func (f *PolicyEngineFactory) Name() string {
+	return "escalating"
+}
+
+func (f *PolicyEngineFactory) NewPolicyEngine(ctx context.Context, conf config.Section) (pe policyengine.PolicyEngine, err error) {
+	gasOracleConfig := conf.SubSection(GasOracleConfig)
+	p := &escalatingPolicyEngine{
+		resubmitInterval: conf.GetDuration(ResubmitInterval),
+		fixedGasPrice:    fftypes.JSONAnyPtr(conf.GetString(FixedGasPrice)),
+
+		gasOracleCache: gasprice.NewOracleCache(gasOracleConfig.GetDuration(GasOracleQueryInterval)),
+		gasOracleMode:  gasOracleConfig.GetString(GasOracleMode),
+	}
+	switch p.gasOracleMode {
+	case GasOracleModeConnector:
+		// No initialization required
+	default:
+		if p.fixedGasPrice.IsNil() {
+			return nil, i18n.NewError(ctx, tmmsgs.MsgNoGasConfigSetForPolicyEngine)
+		}
+	}
+
+	percentage, err := gasprice.ParsePercentage(ctx, IncreasePercentage, conf.GetFloat64(IncreasePercentage))
+	if err != nil {
+		return nil, err
+	}
+	step, err := gasprice.ParseOptionalNumber(ctx, IncreaseStep, conf.GetString(IncreaseStep))
+	if err != nil {
+		return nil, err
+	}
+	maxGasPrice, err := gasprice.ParseOptionalNumber(ctx, MaxGasPrice, conf.GetString(MaxGasPrice))
+	if err != nil {
+		return nil, err
+	}
+	if percentage.Sign() == 0 && (step == nil || step.Sign() == 0) {
+		// We must make progress on each escalation
+		return nil, i18n.NewError(ctx, tmmsgs.MsgInvalidPolicyEngineConfig, conf.GetString(IncreasePercentage), IncreasePercentage)
+	}
+	p.escalator = gasprice.NewEscalator(percentage, step, maxGasPrice)
+	// Cancellations escalate on the same schedule as the transactions they replace
+	p.canceller = gasprice.NewCanceller(p.escalator, p.resubmitInterval, true)
+	return p, nil
+}
+
+type escalatingPolicyEngine struct {
+	fixedGasPrice    *fftypes.JSONAny
+	resubmitInterval time.Duration
+	escalator        *gasprice.Escalator
+	canceller        *gasprice.Canceller
+
+	gasOracleMode  string
+	gasOracleCache *gasprice.OracleCache // the engine can be invoked in parallel for different signers
+}
+
+type escalationReason string
+
+const (
+	escalationReasonResubmitInterval escalationReason = "resubmit_interval"
+	escalationReasonUnderpriced      escalationReason = escalationReason(ffcapi.ErrorReasonTransactionUnderpriced)
+	escalationReasonSpeedUp          escalationReason = "speed_up"
+)
+
+// maxUnderpricedResubmits limits the number of times a transaction is escalated and resubmitted in a single
+// execution of the policy engine, when the node keeps rejecting it as underpriced
+const maxUnderpricedResubmits = 3
+
+// gasEscalation is recorded in the policy info of the transaction, each time the gas price is raised
+type gasEscalation struct {
+	Time        *fftypes.FFTime  `json:"time"`
+	Reason      escalationReason `json:"reason"`
+	OldGasPrice *fftypes.JSONAny `json:"oldGasPrice"`
+	NewGasPrice *fftypes.JSONAny `json:"newGasPrice"`
+}
+
+type escalatingPolicyInfo struct {
+	LastResubmit     *fftypes.FFTime  `json:"lastResubmit,omitempty"`
+	ResubmitRequired bool             `json:"resubmitRequired,omitempty"`
+	Escalations      []*gasEscalation `json:"escalations,omitempty"`
+}
+
+func (p *escalatingPolicyEngine) withPolicyInfo(ctx context.Context, mtx *apitypes.ManagedTX, fn func(info *escalatingPolicyInfo) (update policyengine.UpdateType, reason ffcapi.ErrorReason, err error)) (update policyengine.UpdateType, reason ffcapi.ErrorReason, err error) {
+	var info escalatingPolicyInfo
+	infoBytes := []byte(mtx.PolicyInfo.String())
+	if len(infoBytes) > 0 {
+		err := json.Unmarshal(infoBytes, &info)
+		if err != nil {
+			log.L(ctx).Warnf("Failed to parse existing info `%s`: %s", infoBytes, err)
+		}
+	}
+	update, reason, err = fn(&info)
+	if update != policyengine.UpdateNo {
+		infoBytes, _ = json.Marshal(&info)
+		mtx.PolicyInfo = fftypes.JSONAnyPtrBytes(infoBytes)
+	}
+	return update, reason, err
+}
+
+// escalate raises the gas price on the transaction, recording the escalation in the policy info.
+// Returns false if the gas price is already at the configured ceiling.
+func (p *escalatingPolicyEngine) escalate(ctx context.Context, mtx *apitypes.ManagedTX, info *escalatingPolicyInfo, reason escalationReason) (bool, error) {
+	newGasPrice, escalated, err := p.escalator.Escalate(ctx, mtx.GasPrice)
+	if err != nil {
+		return false, err
+	}
+	if !escalated {
+		log.L(ctx).Warnf("Transaction %s at nonce %s / %d cannot be escalated beyond gas price %s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), mtx.GasPrice)
+		return false, nil
+	}
+	log.L(ctx).Infof("Escalating gas price for transaction %s at nonce %s / %d from %s to %s (reason=%s)", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), mtx.GasPrice, newGasPrice, reason)
+	info.Escalations = append(info.Escalations, &gasEscalation{
+		Time:        fftypes.Now(),
+		Reason:      reason,
+		OldGasPrice: mtx.GasPrice,
+		NewGasPrice: newGasPrice,
+	})
+	mtx.GasPrice = newGasPrice
+	return true, nil
+}
+
+func (p *escalatingPolicyEngine) submitTX(ctx context.Context, cAPI ffcapi.API, mtx *apitypes.ManagedTX, info *escalatingPolicyInfo) (reason ffcapi.ErrorReason, err error) {
+	for attempt := 0; ; attempt++ {
+		sendTX := &ffcapi.TransactionSendRequest{
+			TransactionHeaders: mtx.TransactionHeaders,
+			GasPrice:           mtx.GasPrice,
+			TransactionData:    mtx.TransactionData,
+		}
+		sendTX.TransactionHeaders.Nonce = (*fftypes.FFBigInt)(mtx.Nonce.Int())
+		sendTX.TransactionHeaders.Gas = (*fftypes.FFBigInt)(mtx.Gas.Int())
+		log.L(ctx).Debugf("Sending transaction %s at nonce %s / %d gasPrice=%s (lastSubmit=%s)", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), mtx.GasPrice, mtx.LastSubmit)
+		res, reason, err := cAPI.TransactionSend(ctx, sendTX)
+		if err != nil {
+			switch reason {
+			case ffcapi.ErrorKnownTransaction, ffcapi.ErrorReasonNonceTooLow:
+				// If we already have a transaction hash, this is fine - we just return as if we submitted it
+				if mtx.TransactionHash != "" {
+					log.L(ctx).Debugf("Transaction %s at nonce %s / %d known with hash: %s (%s)", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), mtx.TransactionHash, err)
+					return "", nil
+				}
+			case ffcapi.ErrorReasonTransactionUnderpriced:
+				// Bump the gas price and resubmit straight away. If we run out of attempts, we flag that the
+				// next execution should resubmit with the bumped price without waiting for the resubmit interval
+				escalated, escalateErr := p.escalate(ctx, mtx, info, escalationReasonUnderpriced)
+				if escalateErr != nil {
+					log.L(ctx).Warnf("Failed to escalate underpriced transaction %s: %s", mtx.ID, escalateErr)
+				}
+				if escalated && attempt < maxUnderpricedResubmits {
+					continue
+				}
+				info.ResubmitRequired = escalated
+			}
+			return reason, err
+		}
+		mtx.TransactionHash = res.TransactionHash
+		mtx.LastSubmit = fftypes.Now()
+		info.ResubmitRequired = false
+		log.L(ctx).Infof("Transaction %s at nonce %s / %d submitted. Hash: %s", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), mtx.TransactionHash)
+		return "", nil
+	}
+}
+
+func (p *escalatingPolicyEngine) Execute(ctx context.Context, cAPI ffcapi.API, mtx *apitypes.ManagedTX) (update policyengine.UpdateType, reason ffcapi.ErrorReason, err error) {
+
+	// Once submitted, deletion (or cancellation after expiry) requires the transaction to be replaced at the same nonce
+	if mtx.DeleteRequested != nil || mtx.ExpiryCancelRequested != nil {
+		return p.canceller.CancelTX(ctx, cAPI, mtx)
+	}
+
+	return p.withPolicyInfo(ctx, mtx, func(info *escalatingPolicyInfo) (update policyengine.UpdateType, reason ffcapi.ErrorReason, err error) {
+		switch {
+		case mtx.FirstSubmit == nil:
+			// We only query the gas price for the first submission. If we have a gas price already, it has
+			// been escalated due to the first submission being rejected as underpriced.
+			if mtx.GasPrice == nil {
+				mtx.GasPrice, err = p.getGasPrice(ctx, cAPI)
+				if err != nil {
+					return policyengine.UpdateNo, "", err
+				}
+			}
+			if reason, err := p.submitTX(ctx, cAPI, mtx, info); err != nil {
+				return policyengine.UpdateYes, reason, err
+			}
+			mtx.FirstSubmit = mtx.LastSubmit
+			return policyengine.UpdateYes, "", nil
+
+		case mtx.Receipt == nil && mtx.SpeedUpGasPrice != nil:
+			return p.speedUp(ctx, cAPI, mtx, info)
+
+		case mtx.Receipt == nil:
+			lastResubmit := info.LastResubmit
+			if lastResubmit == nil || mtx.FirstSubmit.Time().After(*lastResubmit.Time()) {
+				lastResubmit = mtx.FirstSubmit
+			}
+			now := fftypes.Now()
+			if !info.ResubmitRequired {
+				if now.Time().Sub(*lastResubmit.Time()) <= p.resubmitInterval {
+					return policyengine.UpdateNo, "", nil
+				}
+				secsSinceSubmit := float64(now.Time().Sub(*mtx.FirstSubmit.Time())) / float64(time.Second)
+				log.L(ctx).Infof("Transaction %s at nonce %s / %d has not been mined after %.2fs", mtx.ID, mtx.TransactionHeaders.From, mtx.Nonce.Int64(), secsSinceSubmit)
+				// If we're at the ceiling, we still resubmit at the same price - as it might no longer be in the TX pool
+				if _, err := p.escalate(ctx, mtx, info, escalationReasonResubmitInterval); err != nil {
+					return policyengine.UpdateNo, "", err
+				}
+			}
+			info.LastResubmit = now
+			if reason, err := p.submitTX(ctx, cAPI, mtx, info); err != nil {
+				if reason != ffcapi.ErrorKnownTransaction {
+					return policyengine.UpdateYes, reason, err
+				}
+			}
+			return policyengine.UpdateYes, "", nil
+		}
+		// No action in the case we have a receipt
+		return policyengine.UpdateNo, "", nil
+	})
+}
+
+// speedUp resubmits the transaction straight away at the gas price requested via the API. This is recorded as an
+// escalation like any other, so the next escalation is due a full resubmit interval later, from the new gas price.
+func (p *escalatingPolicyEngine) speedUp(ctx context.Context, cAPI ffcapi.API, mtx *apitypes.ManagedTX, info *escalatingPolicyInfo) (update policyengine.UpdateType, reason ffcapi.ErrorReason, err error) {
+	oldGasPrice := mtx.GasPrice
+	info.Escalations = append(info.Escalations, &gasEscalation{
+		Time:        fftypes.Now(),
+		Reason:      escalationReasonSpeedUp,
+		OldGasPrice: oldGasPrice,
+		NewGasPrice: mtx.SpeedUpGasPrice,
+	})
+	mtx.GasPrice = mtx.SpeedUpGasPrice
+	if reason, err := p.submitTX(ctx, cAPI, mtx, info); err != nil {
+		// The policy info is not updated, so we carry on from where we were before the speed-up
+		mtx.GasPrice = oldGasPrice
+		return policyengine.UpdateNo, reason, err
+	}
+	info.LastResubmit = mtx.LastSubmit
+	return policyengine.UpdateYes, "", nil
+}
+
+func (p *escalatingPolicyEngine) getGasPrice(ctx context.Context, cAPI ffcapi.API) (gasPrice *fftypes.JSONAny, err error) {
+	switch p.gasOracleMode {
+	case GasOracleModeConnector:
+		return p.gasOracleCache.Get(ctx, func(ctx context.Context) (*fftypes.JSONAny, error) {
+			res, _, err := cAPI.GasPriceEstimate(ctx, &ffcapi.GasPriceEstimateRequest{})
+			if err != nil {
+				return nil, err
+			}
+			return res.GasPrice, nil
+		})
+	default:
+		// Disabled - just a fixed value
+		return p.fixedGasPrice, nil
+	}
+}
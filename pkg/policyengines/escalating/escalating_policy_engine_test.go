@@ -0,0 +1,790 @@
+// Copyright © 2022 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package escalating
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-common/pkg/config"
+	"github.com/hyperledger/firefly-common/pkg/fftypes"
+	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/mocks/ffcapimocks"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/policyengine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestPolicyEngineFactory(t *testing.T) (*PolicyEngineFactory, config.Section) {
+	tmconfig.Reset()
+	conf := config.RootSection("unittest.escalating")
+	f := &PolicyEngineFactory{}
+	f.InitConfig(conf)
+	assert.Equal(t, "escalating", f.Name())
+	return f, conf
+}
+
+func newTestFixedGasPricePolicyEngine(t *testing.T, setup ...func(conf config.Section)) policyengine.PolicyEngine {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.SubSection(GasOracleConfig).Set(GasOracleMode, GasOracleModeDisabled)
+	conf.Set(FixedGasPrice, `12345`)
+	for _, fn := range setup {
+		fn(conf)
+	}
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+	return p
+}
+
+func testPolicyInfo(t *testing.T, mtx *apitypes.ManagedTX) *escalatingPolicyInfo {
+	var info escalatingPolicyInfo
+	err := json.Unmarshal([]byte(mtx.PolicyInfo.String()), &info)
+	assert.NoError(t, err)
+	return &info
+}
+
+func TestMissingGasConfig(t *testing.T) {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.SubSection(GasOracleConfig).Set(GasOracleMode, GasOracleModeDisabled)
+	_, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.Regexp(t, "FF21020", err)
+}
+
+func TestBadIncreasePercentage(t *testing.T) {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.Set(IncreasePercentage, -1)
+	_, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.Regexp(t, "FF21071.*increasePercentage", err)
+}
+
+func TestBadIncreaseStep(t *testing.T) {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.Set(IncreaseStep, "lots")
+	_, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.Regexp(t, "FF21071.*increaseStep", err)
+}
+
+func TestBadMaxGasPrice(t *testing.T) {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.Set(MaxGasPrice, "-100")
+	_, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.Regexp(t, "FF21071.*maxGasPrice", err)
+}
+
+func TestNoIncreaseConfigured(t *testing.T) {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.Set(IncreasePercentage, 0)
+	_, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.Regexp(t, "FF21071.*increasePercentage", err)
+}
+
+func TestStepOnlyIncreaseOK(t *testing.T) {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.Set(IncreasePercentage, 0)
+	conf.Set(IncreaseStep, "1000000000")
+	_, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+}
+
+func TestFixedGasPriceFirstSubmitOK(t *testing.T) {
+	p := newTestFixedGasPricePolicyEngine(t)
+
+	mtx := &apitypes.ManagedTX{
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From: "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712",
+		},
+		TransactionData: "SOME_RAW_TX_BYTES",
+	}
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.String() == "12345" &&
+			req.From == "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712" &&
+			req.TransactionData == "SOME_RAW_TX_BYTES"
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x12345",
+	}, ffcapi.ErrorReason(""), nil)
+
+	updated, reason, err := p.Execute(context.Background(), mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.NotNil(t, mtx.FirstSubmit)
+	assert.NotNil(t, mtx.LastSubmit)
+	assert.Equal(t, "0x12345", mtx.TransactionHash)
+	assert.Empty(t, testPolicyInfo(t, mtx).Escalations)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestConnectorGasOracleFirstSubmitOK(t *testing.T) {
+	f, conf := newTestPolicyEngineFactory(t)
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+
+	mtx := &apitypes.ManagedTX{
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From: "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712",
+		},
+		TransactionData: "SOME_RAW_TX_BYTES",
+	}
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(&ffcapi.GasPriceEstimateResponse{
+		GasPrice: fftypes.JSONAnyPtr(`"12345"`),
+	}, ffcapi.ErrorReason(""), nil).Once()
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x12345",
+	}, ffcapi.ErrorReason(""), nil)
+
+	ctx := context.Background()
+	updated, reason, err := p.Execute(ctx, mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Equal(t, `"12345"`, mtx.GasPrice.String())
+
+	// Check cache
+	gasPrice, err := p.(*escalatingPolicyEngine).getGasPrice(ctx, mockFFCAPI)
+	assert.NoError(t, err)
+	assert.Equal(t, `"12345"`, gasPrice.String())
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestConnectorGasOracleFail(t *testing.T) {
+	f, conf := newTestPolicyEngineFactory(t)
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+
+	mtx := &apitypes.ManagedTX{
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From: "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712",
+		},
+		TransactionData: "SOME_RAW_TX_BYTES",
+	}
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("GasPriceEstimate", mock.Anything, mock.Anything).Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+
+	updated, reason, err := p.Execute(context.Background(), mockFFCAPI, mtx)
+	assert.Regexp(t, "pop", err)
+	assert.Empty(t, reason)
+	assert.Equal(t, policyengine.UpdateNo, updated)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestFirstSubmitUnderpricedEscalatesImmediately(t *testing.T) {
+	p := newTestFixedGasPricePolicyEngine(t)
+
+	mtx := &apitypes.ManagedTX{
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From: "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712",
+		},
+		TransactionData: "SOME_RAW_TX_BYTES",
+	}
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.String() == "12345"
+	})).Return(nil, ffcapi.ErrorReasonTransactionUnderpriced, fmt.Errorf("underpriced")).Once()
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.String() == "13580"
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x12345",
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	updated, reason, err := p.Execute(context.Background(), mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.NotNil(t, mtx.FirstSubmit)
+	assert.Equal(t, "0x12345", mtx.TransactionHash)
+	assert.Equal(t, "13580", mtx.GasPrice.String())
+	info := testPolicyInfo(t, mtx)
+	assert.False(t, info.ResubmitRequired)
+	assert.Len(t, info.Escalations, 1)
+	assert.Equal(t, escalationReasonUnderpriced, info.Escalations[0].Reason)
+	assert.Equal(t, "12345", info.Escalations[0].OldGasPrice.String())
+	assert.Equal(t, "13580", info.Escalations[0].NewGasPrice.String())
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestFirstSubmitUnderpricedResubmitLimit(t *testing.T) {
+	p := newTestFixedGasPricePolicyEngine(t)
+
+	mtx := &apitypes.ManagedTX{
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From: "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712",
+		},
+		TransactionData: "SOME_RAW_TX_BYTES",
+	}
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).
+		Return(nil, ffcapi.ErrorReasonTransactionUnderpriced, fmt.Errorf("underpriced")).Times(maxUnderpricedResubmits + 1)
+
+	ctx := context.Background()
+	updated, reason, err := p.Execute(ctx, mockFFCAPI, mtx)
+	assert.Regexp(t, "underpriced", err)
+	assert.Equal(t, ffcapi.ErrorReasonTransactionUnderpriced, reason)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Nil(t, mtx.FirstSubmit)
+	info := testPolicyInfo(t, mtx)
+	assert.True(t, info.ResubmitRequired)
+	assert.Len(t, info.Escalations, maxUnderpricedResubmits+1)
+	escalatedGasPrice := mtx.GasPrice.String()
+
+	// The escalated gas price is used on the next execution
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.String() == escalatedGasPrice
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x12345",
+	}, ffcapi.ErrorReason(""), nil).Once()
+	updated, reason, err = p.Execute(ctx, mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.NotNil(t, mtx.FirstSubmit)
+	assert.False(t, testPolicyInfo(t, mtx).ResubmitRequired)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestResubmitUnderpricedAtCeiling(t *testing.T) {
+	p := newTestFixedGasPricePolicyEngine(t, func(conf config.Section) {
+		conf.Set(MaxGasPrice, "12345")
+	})
+
+	submitTime := fftypes.FFTime(time.Now().Add(-100 * time.Hour))
+	mtx := &apitypes.ManagedTX{
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From: "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712",
+		},
+		TransactionData: "SOME_RAW_TX_BYTES",
+		GasPrice:        fftypes.JSONAnyPtr("12345"),
+		FirstSubmit:     &submitTime,
+	}
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).
+		Return(nil, ffcapi.ErrorReasonTransactionUnderpriced, fmt.Errorf("underpriced")).Once()
+
+	updated, reason, err := p.Execute(context.Background(), mockFFCAPI, mtx)
+	assert.Regexp(t, "underpriced", err)
+	assert.Equal(t, ffcapi.ErrorReasonTransactionUnderpriced, reason)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Equal(t, "12345", mtx.GasPrice.String())
+	info := testPolicyInfo(t, mtx)
+	assert.False(t, info.ResubmitRequired)
+	assert.Empty(t, info.Escalations)
+	assert.NotNil(t, info.LastResubmit)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestResubmitEscalatesAfterInterval(t *testing.T) {
+	p := newTestFixedGasPricePolicyEngine(t, func(conf config.Section) {
+		conf.Set(MaxGasPrice, "105")
+	})
+
+	submitTime := fftypes.FFTime(time.Now().Add(-100 * time.Hour))
+	mtx := &apitypes.ManagedTX{
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From: "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712",
+		},
+		TransactionData: "SOME_RAW_TX_BYTES",
+		TransactionHash: "0x12345",
+		GasPrice:        fftypes.JSONAnyPtr(`{"maxFeePerGas":100,"maxPriorityFeePerGas":"10"}`),
+		FirstSubmit:     &submitTime,
+		LastSubmit:      &submitTime,
+	}
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.String() == `{"maxFeePerGas":105,"maxPriorityFeePerGas":"11"}`
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x67890",
+	}, ffcapi.ErrorReason(""), nil)
+
+	updated, reason, err := p.Execute(context.Background(), mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Equal(t, "0x67890", mtx.TransactionHash)
+	info := testPolicyInfo(t, mtx)
+	assert.NotNil(t, info.LastResubmit)
+	assert.Len(t, info.Escalations, 1)
+	assert.Equal(t, escalationReasonResubmitInterval, info.Escalations[0].Reason)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestResubmitAtCeilingKnownTransaction(t *testing.T) {
+	p := newTestFixedGasPricePolicyEngine(t, func(conf config.Section) {
+		conf.Set(MaxGasPrice, "100")
+	})
+
+	submitTime := fftypes.FFTime(time.Now().Add(-100 * time.Hour))
+	mtx := &apitypes.ManagedTX{
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From: "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712",
+		},
+		TransactionData: "SOME_RAW_TX_BYTES",
+		TransactionHash: "0x12345",
+		GasPrice:        fftypes.JSONAnyPtr(`200`),
+		FirstSubmit:     &submitTime,
+		PolicyInfo:      fftypes.JSONAnyPtr("!not json!"),
+	}
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).
+		Return(nil, ffcapi.ErrorKnownTransaction, fmt.Errorf("Known transaction"))
+
+	updated, reason, err := p.Execute(context.Background(), mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Equal(t, "200", mtx.GasPrice.String())
+	info := testPolicyInfo(t, mtx)
+	assert.NotNil(t, info.LastResubmit)
+	assert.Empty(t, info.Escalations)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestResubmitRequiredSkipsInterval(t *testing.T) {
+	p := newTestFixedGasPricePolicyEngine(t, func(conf config.Section) {
+		conf.Set(ResubmitInterval, "100s")
+	})
+
+	mtx := &apitypes.ManagedTX{
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From: "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712",
+		},
+		TransactionData: "SOME_RAW_TX_BYTES",
+		GasPrice:        fftypes.JSONAnyPtr(`13580`),
+		FirstSubmit:     fftypes.Now(),
+		PolicyInfo:      fftypes.JSONAnyPtr(fmt.Sprintf(`{"resubmitRequired":true,"lastResubmit":"%s"}`, fftypes.Now())),
+	}
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.String() == "13580"
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x12345",
+	}, ffcapi.ErrorReason(""), nil)
+
+	updated, reason, err := p.Execute(context.Background(), mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.False(t, testPolicyInfo(t, mtx).ResubmitRequired)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestResubmitNotDue(t *testing.T) {
+	p := newTestFixedGasPricePolicyEngine(t, func(conf config.Section) {
+		conf.Set(ResubmitInterval, "100s")
+	})
+
+	submitTime := fftypes.FFTime(time.Now().Add(-100 * time.Hour))
+	mtx := &apitypes.ManagedTX{
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From: "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712",
+		},
+		TransactionData: "SOME_RAW_TX_BYTES",
+		GasPrice:        fftypes.JSONAnyPtr(`12345`),
+		FirstSubmit:     &submitTime,
+		PolicyInfo:      fftypes.JSONAnyPtr(fmt.Sprintf(`{"lastResubmit":"%s"}`, fftypes.Now())),
+	}
+
+	mockFFCAPI := &ffcapimocks.API{}
+
+	updated, reason, err := p.Execute(context.Background(), mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, policyengine.UpdateNo, updated)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestResubmitBadGasPrice(t *testing.T) {
+	p := newTestFixedGasPricePolicyEngine(t)
+
+	submitTime := fftypes.FFTime(time.Now().Add(-100 * time.Hour))
+	mtx := &apitypes.ManagedTX{
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From: "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712",
+		},
+		TransactionData: "SOME_RAW_TX_BYTES",
+		GasPrice:        fftypes.JSONAnyPtr(`true`),
+		FirstSubmit:     &submitTime,
+	}
+
+	mockFFCAPI := &ffcapimocks.API{}
+
+	updated, _, err := p.Execute(context.Background(), mockFFCAPI, mtx)
+	assert.Regexp(t, "FF21070", err)
+	assert.Equal(t, policyengine.UpdateNo, updated)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestResubmitFail(t *testing.T) {
+	p := newTestFixedGasPricePolicyEngine(t)
+
+	submitTime := fftypes.FFTime(time.Now().Add(-100 * time.Hour))
+	mtx := &apitypes.ManagedTX{
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From: "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712",
+		},
+		TransactionData: "SOME_RAW_TX_BYTES",
+		GasPrice:        fftypes.JSONAnyPtr(`12345`),
+		FirstSubmit:     &submitTime,
+	}
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).
+		Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+
+	updated, reason, err := p.Execute(context.Background(), mockFFCAPI, mtx)
+	assert.Regexp(t, "pop", err)
+	assert.Empty(t, reason)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Len(t, testPolicyInfo(t, mtx).Escalations, 1)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestSpeedUpResetsEscalation(t *testing.T) {
+	p := newTestFixedGasPricePolicyEngine(t, func(conf config.Section) {
+		conf.Set(ResubmitInterval, "100s")
+	})
+
+	submitTime := fftypes.FFTime(time.Now().Add(-100 * time.Hour))
+	mtx := &apitypes.ManagedTX{
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From: "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712",
+		},
+		TransactionData: "SOME_RAW_TX_BYTES",
+		TransactionHash: "0x12345",
+		GasPrice:        fftypes.JSONAnyPtr(`12345`),
+		SpeedUpGasPrice: fftypes.JSONAnyPtr(`20000`),
+		FirstSubmit:     &submitTime,
+		LastSubmit:      &submitTime,
+	}
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.String() == "20000"
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x67890",
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	updated, reason, err := p.Execute(context.Background(), mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Equal(t, "0x67890", mtx.TransactionHash)
+	assert.Equal(t, "20000", mtx.GasPrice.String())
+	info := testPolicyInfo(t, mtx)
+	assert.Equal(t, mtx.LastSubmit, info.LastResubmit)
+	assert.Len(t, info.Escalations, 1)
+	assert.Equal(t, escalationReasonSpeedUp, info.Escalations[0].Reason)
+	assert.Equal(t, "12345", info.Escalations[0].OldGasPrice.String())
+
+	// The next escalation is not due until a full interval after the speed-up
+	mtx.SpeedUpGasPrice = nil
+	updated, _, err = p.Execute(context.Background(), mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Equal(t, policyengine.UpdateNo, updated)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestSpeedUpFail(t *testing.T) {
+	p := newTestFixedGasPricePolicyEngine(t)
+
+	submitTime := fftypes.FFTime(time.Now().Add(-100 * time.Hour))
+	mtx := &apitypes.ManagedTX{
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From: "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712",
+		},
+		TransactionData: "SOME_RAW_TX_BYTES",
+		TransactionHash: "0x12345",
+		GasPrice:        fftypes.JSONAnyPtr(`12345`),
+		SpeedUpGasPrice: fftypes.JSONAnyPtr(`20000`),
+		FirstSubmit:     &submitTime,
+		PolicyInfo:      fftypes.JSONAnyPtr(`{"escalations":[]}`),
+	}
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).
+		Return(nil, ffcapi.ErrorReason(""), fmt.Errorf("pop"))
+
+	updated, _, err := p.Execute(context.Background(), mockFFCAPI, mtx)
+	assert.Regexp(t, "pop", err)
+	assert.Equal(t, policyengine.UpdateNo, updated)
+	assert.Equal(t, "12345", mtx.GasPrice.String())
+	assert.Equal(t, `{"escalations":[]}`, mtx.PolicyInfo.String())
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestNoOpWithReceipt(t *testing.T) {
+	p := newTestFixedGasPricePolicyEngine(t)
+
+	mtx := &apitypes.ManagedTX{
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From: "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712",
+		},
+		TransactionData: "SOME_RAW_TX_BYTES",
+		FirstSubmit:     fftypes.Now(),
+		Receipt: &ffcapi.TransactionReceiptResponse{
+			BlockHash: "0x39e2664effa5ad0651c35f1fe3b4c4b90492b1955fee731c2e9fb4d6518de114",
+		},
+	}
+
+	mockFFCAPI := &ffcapimocks.API{}
+
+	updated, reason, err := p.Execute(context.Background(), mockFFCAPI, mtx)
+	assert.Empty(t, reason)
+	assert.NoError(t, err)
+	assert.Equal(t, policyengine.UpdateNo, updated)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestAllowsDeleteRequest(t *testing.T) {
+	p := newTestFixedGasPricePolicyEngine(t)
+
+	mtx := &apitypes.ManagedTX{
+		DeleteRequested: fftypes.Now(),
+	}
+
+	mockFFCAPI := &ffcapimocks.API{}
+
+	updated, reason, err := p.Execute(context.Background(), mockFFCAPI, mtx)
+	assert.Empty(t, reason)
+	assert.NoError(t, err)
+	assert.Equal(t, policyengine.UpdateDelete, updated)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func newTestSubmittedTX() *apitypes.ManagedTX {
+	return &apitypes.ManagedTX{
+		ID: "ns1:" + fftypes.NewUUID().String(),
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From: "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712",
+			To:   "0x05c9b54f6a5ae7fea4f39e3b0ab6a5ad4b8e2d29",
+		},
+		Nonce:           fftypes.NewFFBigInt(42),
+		Gas:             fftypes.NewFFBigInt(100000),
+		GasPrice:        fftypes.JSONAnyPtr(`12345`),
+		TransactionHash: "0x12345",
+		TransactionData: "SOME_RAW_TX_BYTES",
+		Status:          apitypes.TxStatusPending,
+		FirstSubmit:     fftypes.Now(),
+		LastSubmit:      fftypes.Now(),
+		DeleteRequested: fftypes.Now(),
+	}
+}
+
+func TestCancelSubmittedTXOk(t *testing.T) {
+	p := newTestFixedGasPricePolicyEngine(t)
+
+	mtx := newTestSubmittedTX()
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.String() == "13580" &&
+			req.From == "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712" &&
+			req.To == "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712" &&
+			req.Nonce.Int64() == 42 &&
+			req.Value.Int64() == 0 &&
+			req.TransactionData == ""
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x67890",
+	}, ffcapi.ErrorReason(""), nil)
+
+	ctx := context.Background()
+	updated, reason, err := p.Execute(ctx, mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Equal(t, "0x12345", mtx.TransactionHash)
+	assert.Equal(t, "12345", mtx.GasPrice.String())
+	assert.Equal(t, "0x67890", mtx.Cancellation.TransactionHash)
+	assert.NotNil(t, mtx.Cancellation.FirstSubmit)
+
+	// Not due to resubmit on the next execution
+	updated, reason, err = p.Execute(ctx, mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, policyengine.UpdateNo, updated)
+
+	// Once a receipt is available, the delete completes
+	mtx.Receipt = &ffcapi.TransactionReceiptResponse{
+		BlockHash: "0x39e2664effa5ad0651c35f1fe3b4c4b90492b1955fee731c2e9fb4d6518de114",
+	}
+	updated, reason, err = p.Execute(ctx, mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, policyengine.UpdateDelete, updated)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestCancelExpiredTXOk(t *testing.T) {
+	p := newTestFixedGasPricePolicyEngine(t)
+
+	mtx := newTestSubmittedTX()
+	mtx.DeleteRequested = nil
+	mtx.ExpiryCancelRequested = fftypes.Now()
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.String() == "13580" && req.Nonce.Int64() == 42 && req.Value.Int64() == 0
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x67890",
+	}, ffcapi.ErrorReason(""), nil)
+
+	ctx := context.Background()
+	updated, reason, err := p.Execute(ctx, mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Equal(t, "0x67890", mtx.Cancellation.TransactionHash)
+
+	// Once a receipt is available, the transaction is left for the policy loop to complete
+	mtx.Receipt = &ffcapi.TransactionReceiptResponse{
+		BlockHash: "0x39e2664effa5ad0651c35f1fe3b4c4b90492b1955fee731c2e9fb4d6518de114",
+	}
+	updated, reason, err = p.Execute(ctx, mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, policyengine.UpdateNo, updated)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestCancelSubmittedTXResubmitEscalates(t *testing.T) {
+	p := newTestFixedGasPricePolicyEngine(t, func(conf config.Section) {
+		conf.Set(ResubmitInterval, "100s")
+	})
+
+	lastSubmit := fftypes.FFTime(time.Now().Add(-101 * time.Second))
+	mtx := newTestSubmittedTX()
+	mtx.Cancellation = &apitypes.ManagedTXCancellation{
+		TransactionHash: "0x67890",
+		GasPrice:        fftypes.JSONAnyPtr(`13580`),
+		FirstSubmit:     &lastSubmit,
+		LastSubmit:      &lastSubmit,
+	}
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.String() == "14938"
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0xabcde",
+	}, ffcapi.ErrorReason(""), nil)
+
+	updated, reason, err := p.Execute(context.Background(), mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Equal(t, "0xabcde", mtx.Cancellation.TransactionHash)
+	assert.Equal(t, &lastSubmit, mtx.Cancellation.FirstSubmit)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestCancelSubmittedTXResubmitBadGasPrice(t *testing.T) {
+	p := newTestFixedGasPricePolicyEngine(t)
+
+	lastSubmit := fftypes.FFTime(time.Now().Add(-100 * time.Hour))
+	mtx := newTestSubmittedTX()
+	mtx.Cancellation = &apitypes.ManagedTXCancellation{
+		GasPrice:   fftypes.JSONAnyPtr(`true`),
+		LastSubmit: &lastSubmit,
+	}
+
+	mockFFCAPI := &ffcapimocks.API{}
+
+	updated, _, err := p.Execute(context.Background(), mockFFCAPI, mtx)
+	assert.Regexp(t, "FF21070", err)
+	assert.Equal(t, policyengine.UpdateNo, updated)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestCancelSubmittedTXUnderpricedThenNonceTooLow(t *testing.T) {
+	p := newTestFixedGasPricePolicyEngine(t)
+
+	mtx := newTestSubmittedTX()
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.String() == "13580"
+	})).Return(nil, ffcapi.ErrorReasonTransactionUnderpriced, fmt.Errorf("underpriced")).Once()
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.String() == "14938"
+	})).Return(nil, ffcapi.ErrorReasonNonceTooLow, fmt.Errorf("nonce too low")).Once()
+
+	ctx := context.Background()
+	updated, reason, err := p.Execute(ctx, mockFFCAPI, mtx)
+	assert.Regexp(t, "underpriced", err)
+	assert.Equal(t, ffcapi.ErrorReasonTransactionUnderpriced, reason)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Nil(t, mtx.Cancellation.LastSubmit)
+
+	// Retried straight away at the escalated price, and the nonce has been consumed
+	updated, reason, err = p.Execute(ctx, mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.NotNil(t, mtx.Cancellation.LastSubmit)
+	assert.Empty(t, mtx.Cancellation.TransactionHash)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestCancelSubmittedTXBadGasPrice(t *testing.T) {
+	p := newTestFixedGasPricePolicyEngine(t)
+
+	mtx := newTestSubmittedTX()
+	mtx.GasPrice = fftypes.JSONAnyPtr(`"not a number"`)
+
+	mockFFCAPI := &ffcapimocks.API{}
+
+	updated, _, err := p.Execute(context.Background(), mockFFCAPI, mtx)
+	assert.Regexp(t, "FF21070", err)
+	assert.Equal(t, policyengine.UpdateNo, updated)
+	assert.Nil(t, mtx.Cancellation)
+
+	mockFFCAPI.AssertExpectations(t)
+}
@@ -21,6 +21,7 @@ import (
 	"testing"
 
 	"github.com/hyperledger/firefly-transaction-manager/internal/tmconfig"
+	"github.com/hyperledger/firefly-transaction-manager/pkg/policyengines/escalating"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/policyengines/simple"
 	"github.com/stretchr/testify/assert"
 )
@@ -40,3 +41,16 @@ func TestRegistry(t *testing.T) {
 	assert.Regexp(t, "FF21019", err)
 
 }
+
+func TestRegistryEscalating(t *testing.T) {
+
+	tmconfig.Reset()
+	name := RegisterEngine(&escalating.PolicyEngineFactory{})
+	assert.Equal(t, "escalating", name)
+
+	tmconfig.PolicyEngineBaseConfig.SubSection("escalating").Set(escalating.FixedGasPrice, "12345")
+	p, err := NewPolicyEngine(context.Background(), tmconfig.PolicyEngineBaseConfig, "escalating")
+	assert.NotNil(t, p)
+	assert.NoError(t, err)
+
+}
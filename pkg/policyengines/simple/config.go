@@ -24,8 +24,9 @@ import (
 )
 
 const (
-	FixedGasPrice          = "fixedGasPrice"    // when not using a gas station - will be treated as a raw JSON string, so can be numeric 123, or string "123", or object {"maxPriorityFeePerGas":123})
-	ResubmitInterval       = "resubmitInterval" // warnings will be written to the log at this interval if mining has not occurred, and the TX will be resubmitted
+	FixedGasPrice          = "fixedGasPrice"          // when not using a gas station - will be treated as a raw JSON string, so can be numeric 123, or string "123", or object {"maxPriorityFeePerGas":123})
+	ResubmitInterval       = "resubmitInterval"       // warnings will be written to the log at this interval if mining has not occurred, and the TX will be resubmitted
+	CancelGasPriceIncrease = "cancelGasPriceIncrease" // percentage increase over the submitted gas price, used for the replacement TX that cancels a deleted transaction
 	GasOracleConfig        = "gasOracle"
 	GasOracleMode          = "mode"
 	GasOracleMethod        = "method"
@@ -41,6 +42,7 @@ const (
 
 const (
 	defaultResubmitInterval       = "5m"
+	defaultCancelGasPriceIncrease = 10
 	defaultGasOracleQueryInterval = "5m"
 	defaultGasOracleMethod        = http.MethodGet
 	defaultGasOracleMode          = GasOracleModeConnector
@@ -49,6 +51,7 @@ const (
 func (f *PolicyEngineFactory) InitConfig(conf config.Section) {
 	conf.AddKnownKey(FixedGasPrice)
 	conf.AddKnownKey(ResubmitInterval, defaultResubmitInterval)
+	conf.AddKnownKey(CancelGasPriceIncrease, defaultCancelGasPriceIncrease)
 
 	gasOracleConfig := conf.SubSection(GasOracleConfig)
 	ffresty.InitConfig(gasOracleConfig)
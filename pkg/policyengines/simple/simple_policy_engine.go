@@ -29,6 +29,7 @@ import (
 	"github.com/hyperledger/firefly-common/pkg/fftypes"
 	"github.com/hyperledger/firefly-common/pkg/i18n"
 	"github.com/hyperledger/firefly-common/pkg/log"
+	"github.com/hyperledger/firefly-transaction-manager/internal/gasprice"
 	"github.com/hyperledger/firefly-transaction-manager/internal/tmmsgs"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/apitypes"
 	"github.com/hyperledger/firefly-transaction-manager/pkg/ffcapi"
@@ -51,9 +52,9 @@ func (f *PolicyEngineFactory) NewPolicyEngine(ctx context.Context, conf config.S
 		resubmitInterval: conf.GetDuration(ResubmitInterval),
 		fixedGasPrice:    fftypes.JSONAnyPtr(conf.GetString(FixedGasPrice)),
 
-		gasOracleMethod:        gasOracleConfig.GetString(GasOracleMethod),
-		gasOracleQueryInterval: gasOracleConfig.GetDuration(GasOracleQueryInterval),
-		gasOracleMode:          gasOracleConfig.GetString(GasOracleMode),
+		gasOracleMethod: gasOracleConfig.GetString(GasOracleMethod),
+		gasOracleCache:  gasprice.NewOracleCache(gasOracleConfig.GetDuration(GasOracleQueryInterval)),
+		gasOracleMode:   gasOracleConfig.GetString(GasOracleMode),
 	}
 	switch p.gasOracleMode {
 	case GasOracleModeConnector:
@@ -73,20 +74,25 @@ func (f *PolicyEngineFactory) NewPolicyEngine(ctx context.Context, conf config.S
 			return nil, i18n.NewError(ctx, tmmsgs.MsgNoGasConfigSetForPolicyEngine)
 		}
 	}
+	cancelGasPriceIncrease, err := gasprice.ParsePercentage(ctx, CancelGasPriceIncrease, conf.GetFloat64(CancelGasPriceIncrease))
+	if err != nil {
+		return nil, err
+	}
+	// The simple policy engine does not escalate, other than the one increase required to replace the transaction
+	p.canceller = gasprice.NewCanceller(gasprice.NewEscalator(cancelGasPriceIncrease, nil, nil), p.resubmitInterval, false)
 	return p, nil
 }
 
 type simplePolicyEngine struct {
 	fixedGasPrice    *fftypes.JSONAny
 	resubmitInterval time.Duration
+	canceller        *gasprice.Canceller
 
-	gasOracleMode          string
-	gasOracleClient        *resty.Client
-	gasOracleMethod        string
-	gasOracleTemplate      *template.Template
-	gasOracleQueryInterval time.Duration
-	gasOracleQueryValue    *fftypes.JSONAny
-	gasOracleLastQueryTime *fftypes.FFTime
+	gasOracleMode     string
+	gasOracleClient   *resty.Client
+	gasOracleMethod   string
+	gasOracleTemplate *template.Template
+	gasOracleCache    *gasprice.OracleCache // the engine can be invoked in parallel for different signers
 }
 
 type simplePolicyInfo struct {
@@ -148,9 +154,9 @@ func (p *simplePolicyEngine) submitTX(ctx context.Context, cAPI ffcapi.API, mtx
 
 func (p *simplePolicyEngine) Execute(ctx context.Context, cAPI ffcapi.API, mtx *apitypes.ManagedTX) (update policyengine.UpdateType, reason ffcapi.ErrorReason, err error) {
 
-	// Simply policy engine allows deletion of the transaction without additional checks ( ensuring the TX has not been submitted / gap filling the nonce etc. )
-	if mtx.DeleteRequested != nil {
-		return policyengine.UpdateDelete, "", nil
+	// Once submitted, deletion (or cancellation after expiry) requires the transaction to be replaced at the same nonce
+	if mtx.DeleteRequested != nil || mtx.ExpiryCancelRequested != nil {
+		return p.canceller.CancelTX(ctx, cAPI, mtx)
 	}
 
 	// Simple policy engine only submits once.
@@ -173,6 +179,17 @@ func (p *simplePolicyEngine) Execute(ctx context.Context, cAPI ffcapi.API, mtx *
 		// action such as increasing the gas cost slowly over time. This simple example shows how the policy engine
 		// can use the FireFly core operation as a store for its historical state/decisions (in this case the last time we warned).
 		return p.withPolicyInfo(ctx, mtx, func(info *simplePolicyInfo) (update policyengine.UpdateType, reason ffcapi.ErrorReason, err error) {
+			if mtx.SpeedUpGasPrice != nil {
+				// A speed-up is resubmitted straight away, and restarts the interval before we warn and resubmit again
+				oldGasPrice := mtx.GasPrice
+				mtx.GasPrice = mtx.SpeedUpGasPrice
+				if reason, err := p.submitTX(ctx, cAPI, mtx); err != nil {
+					mtx.GasPrice = oldGasPrice
+					return policyengine.UpdateNo, reason, err
+				}
+				info.LastWarnTime = mtx.LastSubmit
+				return policyengine.UpdateYes, "", nil
+			}
 			lastWarnTime := info.LastWarnTime
 			if lastWarnTime == nil {
 				lastWarnTime = mtx.FirstSubmit
@@ -200,29 +217,19 @@ func (p *simplePolicyEngine) Execute(ctx context.Context, cAPI ffcapi.API, mtx *
 
 // getGasPrice either uses a fixed gas price, or invokes a gas station API
 func (p *simplePolicyEngine) getGasPrice(ctx context.Context, cAPI ffcapi.API) (gasPrice *fftypes.JSONAny, err error) {
-	if p.gasOracleQueryValue != nil && p.gasOracleLastQueryTime != nil &&
-		time.Since(*p.gasOracleLastQueryTime.Time()) < p.gasOracleQueryInterval {
-		return p.gasOracleQueryValue, nil
-	}
 	switch p.gasOracleMode {
 	case GasOracleModeRESTAPI:
 		// Make a REST call against an endpoint, and extract a value/structure to pass to the connector
-		gasPrice, err := p.getGasPriceAPI(ctx)
-		if err != nil {
-			return nil, err
-		}
-		p.gasOracleQueryValue = gasPrice
-		p.gasOracleLastQueryTime = fftypes.Now()
-		return p.gasOracleQueryValue, nil
+		return p.gasOracleCache.Get(ctx, p.getGasPriceAPI)
 	case GasOracleModeConnector:
 		// Call the connector
-		res, _, err := cAPI.GasPriceEstimate(ctx, &ffcapi.GasPriceEstimateRequest{})
-		if err != nil {
-			return nil, err
-		}
-		p.gasOracleQueryValue = res.GasPrice
-		p.gasOracleLastQueryTime = fftypes.Now()
-		return p.gasOracleQueryValue, nil
+		return p.gasOracleCache.Get(ctx, func(ctx context.Context) (*fftypes.JSONAny, error) {
+			res, _, err := cAPI.GasPriceEstimate(ctx, &ffcapi.GasPriceEstimateRequest{})
+			if err != nil {
+				return nil, err
+			}
+			return res.GasPrice, nil
+		})
 	default:
 		// Disabled - just a fixed value
 		return p.fixedGasPrice, nil
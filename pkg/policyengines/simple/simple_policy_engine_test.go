@@ -492,6 +492,82 @@ func TestWarnStaleNoWarning(t *testing.T) {
 	mockFFCAPI.AssertExpectations(t)
 }
 
+func TestSpeedUpRestartsWarningInterval(t *testing.T) {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	conf.Set(ResubmitInterval, "100s")
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+
+	submitTime := fftypes.FFTime(time.Now().Add(-100 * time.Hour))
+	mtx := &apitypes.ManagedTX{
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From: "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712",
+		},
+		TransactionData: "SOME_RAW_TX_BYTES",
+		TransactionHash: "0x12345",
+		GasPrice:        fftypes.JSONAnyPtr(`12345`),
+		SpeedUpGasPrice: fftypes.JSONAnyPtr(`20000`),
+		FirstSubmit:     &submitTime,
+	}
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.String() == "20000"
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x67890",
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	ctx := context.Background()
+	updated, reason, err := p.Execute(ctx, mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Equal(t, "0x67890", mtx.TransactionHash)
+	assert.Equal(t, "20000", mtx.GasPrice.String())
+
+	// No resubmission until a full interval after the speed-up
+	mtx.SpeedUpGasPrice = nil
+	updated, _, err = p.Execute(ctx, mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Equal(t, policyengine.UpdateNo, updated)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestSpeedUpFail(t *testing.T) {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+
+	submitTime := fftypes.FFTime(time.Now().Add(-100 * time.Hour))
+	mtx := &apitypes.ManagedTX{
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From: "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712",
+		},
+		TransactionData: "SOME_RAW_TX_BYTES",
+		TransactionHash: "0x12345",
+		GasPrice:        fftypes.JSONAnyPtr(`12345`),
+		SpeedUpGasPrice: fftypes.JSONAnyPtr(`20000`),
+		FirstSubmit:     &submitTime,
+	}
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).
+		Return(nil, ffcapi.ErrorReasonTransactionUnderpriced, fmt.Errorf("pop"))
+
+	ctx := context.Background()
+	updated, reason, err := p.Execute(ctx, mockFFCAPI, mtx)
+	assert.Regexp(t, "pop", err)
+	assert.Equal(t, ffcapi.ErrorReasonTransactionUnderpriced, reason)
+	assert.Equal(t, policyengine.UpdateNo, updated)
+	assert.Equal(t, "12345", mtx.GasPrice.String())
+	assert.Nil(t, mtx.PolicyInfo)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
 func TestNoOpWithReceipt(t *testing.T) {
 	f, conf := newTestPolicyEngineFactory(t)
 	conf.Set(FixedGasPrice, `12345`)
@@ -543,3 +619,225 @@ func TestAllowsDeleteRequest(t *testing.T) {
 
 	mockFFCAPI.AssertExpectations(t)
 }
+
+func TestBadCancelGasPriceIncrease(t *testing.T) {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	conf.Set(CancelGasPriceIncrease, -10)
+	_, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.Regexp(t, "FF21071.*cancelGasPriceIncrease", err)
+}
+
+func newTestSubmittedTX() *apitypes.ManagedTX {
+	return &apitypes.ManagedTX{
+		ID: "ns1:" + fftypes.NewUUID().String(),
+		TransactionHeaders: ffcapi.TransactionHeaders{
+			From: "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712",
+			To:   "0x05c9b54f6a5ae7fea4f39e3b0ab6a5ad4b8e2d29",
+		},
+		Nonce:           fftypes.NewFFBigInt(42),
+		Gas:             fftypes.NewFFBigInt(100000),
+		GasPrice:        fftypes.JSONAnyPtr(`12345`),
+		TransactionHash: "0x12345",
+		TransactionData: "SOME_RAW_TX_BYTES",
+		Status:          apitypes.TxStatusPending,
+		FirstSubmit:     fftypes.Now(),
+		LastSubmit:      fftypes.Now(),
+		DeleteRequested: fftypes.Now(),
+	}
+}
+
+func TestCancelSubmittedTXOk(t *testing.T) {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+
+	mtx := newTestSubmittedTX()
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.String() == "13580" &&
+			req.From == "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712" &&
+			req.To == "0x6b7cfa4cf9709d3b3f5f7c22de123d2e16aee712" &&
+			req.Nonce.Int64() == 42 &&
+			req.Gas.Int64() == 100000 &&
+			req.Value.Int64() == 0 &&
+			req.TransactionData == ""
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x67890",
+	}, ffcapi.ErrorReason(""), nil)
+
+	ctx := context.Background()
+	updated, reason, err := p.Execute(ctx, mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Equal(t, "0x12345", mtx.TransactionHash)
+	assert.Equal(t, "0x67890", mtx.Cancellation.TransactionHash)
+	assert.Equal(t, "13580", mtx.Cancellation.GasPrice.String())
+	assert.NotNil(t, mtx.Cancellation.FirstSubmit)
+	assert.Equal(t, mtx.Cancellation.FirstSubmit, mtx.Cancellation.LastSubmit)
+
+	// Not due to resubmit on the next execution
+	updated, reason, err = p.Execute(ctx, mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, policyengine.UpdateNo, updated)
+
+	// Once a receipt is available, the delete completes
+	mtx.Receipt = &ffcapi.TransactionReceiptResponse{
+		BlockHash: "0x39e2664effa5ad0651c35f1fe3b4c4b90492b1955fee731c2e9fb4d6518de114",
+	}
+	updated, reason, err = p.Execute(ctx, mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, policyengine.UpdateDelete, updated)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestCancelExpiredTXOk(t *testing.T) {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+
+	mtx := newTestSubmittedTX()
+	mtx.DeleteRequested = nil
+	mtx.ExpiryCancelRequested = fftypes.Now()
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.String() == "13580" && req.Nonce.Int64() == 42 && req.Value.Int64() == 0
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x67890",
+	}, ffcapi.ErrorReason(""), nil)
+
+	ctx := context.Background()
+	updated, reason, err := p.Execute(ctx, mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Equal(t, "0x67890", mtx.Cancellation.TransactionHash)
+
+	// Once a receipt is available, the transaction is left for the policy loop to complete
+	mtx.TransactionHash = "0x67890"
+	mtx.Receipt = &ffcapi.TransactionReceiptResponse{
+		BlockHash: "0x39e2664effa5ad0651c35f1fe3b4c4b90492b1955fee731c2e9fb4d6518de114",
+	}
+	updated, reason, err = p.Execute(ctx, mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, policyengine.UpdateNo, updated)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestCancelSubmittedTXResubmit(t *testing.T) {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	conf.Set(ResubmitInterval, "100s")
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+
+	lastSubmit := fftypes.FFTime(time.Now().Add(-101 * time.Second))
+	mtx := newTestSubmittedTX()
+	mtx.Cancellation = &apitypes.ManagedTXCancellation{
+		TransactionHash: "0x67890",
+		GasPrice:        fftypes.JSONAnyPtr(`13580`),
+		FirstSubmit:     &lastSubmit,
+		LastSubmit:      &lastSubmit,
+	}
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.String() == "13580"
+	})).Return(nil, ffcapi.ErrorKnownTransaction, fmt.Errorf("known transaction"))
+
+	updated, reason, err := p.Execute(context.Background(), mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Equal(t, "0x67890", mtx.Cancellation.TransactionHash)
+	assert.Equal(t, &lastSubmit, mtx.Cancellation.FirstSubmit)
+	assert.True(t, mtx.Cancellation.LastSubmit.Time().After(*lastSubmit.Time()))
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestCancelSubmittedTXUnderpriced(t *testing.T) {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+
+	mtx := newTestSubmittedTX()
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.String() == "13580"
+	})).Return(nil, ffcapi.ErrorReasonTransactionUnderpriced, fmt.Errorf("underpriced")).Once()
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.MatchedBy(func(req *ffcapi.TransactionSendRequest) bool {
+		return req.GasPrice.String() == "14938"
+	})).Return(&ffcapi.TransactionSendResponse{
+		TransactionHash: "0x67890",
+	}, ffcapi.ErrorReason(""), nil).Once()
+
+	ctx := context.Background()
+	updated, reason, err := p.Execute(ctx, mockFFCAPI, mtx)
+	assert.Regexp(t, "underpriced", err)
+	assert.Equal(t, ffcapi.ErrorReasonTransactionUnderpriced, reason)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Nil(t, mtx.Cancellation.LastSubmit)
+
+	// Retried straight away, at the higher price
+	updated, reason, err = p.Execute(ctx, mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Equal(t, "0x67890", mtx.Cancellation.TransactionHash)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestCancelSubmittedTXNonceTooLow(t *testing.T) {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+
+	mtx := newTestSubmittedTX()
+
+	mockFFCAPI := &ffcapimocks.API{}
+	mockFFCAPI.On("TransactionSend", mock.Anything, mock.Anything).
+		Return(nil, ffcapi.ErrorReasonNonceTooLow, fmt.Errorf("nonce too low"))
+
+	updated, reason, err := p.Execute(context.Background(), mockFFCAPI, mtx)
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.Equal(t, policyengine.UpdateYes, updated)
+	assert.Empty(t, mtx.Cancellation.TransactionHash)
+	assert.NotNil(t, mtx.Cancellation.LastSubmit)
+
+	mockFFCAPI.AssertExpectations(t)
+}
+
+func TestCancelSubmittedTXBadGasPrice(t *testing.T) {
+	f, conf := newTestPolicyEngineFactory(t)
+	conf.Set(FixedGasPrice, `12345`)
+	p, err := f.NewPolicyEngine(context.Background(), conf)
+	assert.NoError(t, err)
+
+	mtx := newTestSubmittedTX()
+	mtx.GasPrice = fftypes.JSONAnyPtr(`"not a number"`)
+
+	mockFFCAPI := &ffcapimocks.API{}
+
+	updated, _, err := p.Execute(context.Background(), mockFFCAPI, mtx)
+	assert.Regexp(t, "FF21070", err)
+	assert.Equal(t, policyengine.UpdateNo, updated)
+	assert.Nil(t, mtx.Cancellation)
+
+	mockFFCAPI.AssertExpectations(t)
+}